@@ -0,0 +1,301 @@
+// Package activity reads the GPX, TCX and FIT files sport watches record and
+// summarizes the track they contain
+package activity
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	GPX Format = "gpx"
+	TCX Format = "tcx"
+	FIT Format = "fit"
+)
+
+type Sport string
+
+const (
+	Running  Sport = "running"
+	Walking  Sport = "walking"
+	Hiking   Sport = "hiking"
+	Cycling  Sport = "cycling"
+	Swimming Sport = "swimming"
+	Other    Sport = "other"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown activity file format, expected gpx, tcx or fit")
+	ErrUnknownSport  = errors.New("unknown sport, expected running, walking, hiking, cycling, swimming or other")
+	ErrInvalidFile   = errors.New("invalid activity file")
+	ErrNoTrack       = errors.New("activity file has no timed track points")
+)
+
+// One sample of a recorded track. Files leave out what the device didn't measure.
+type TrackPoint struct {
+	Time            time.Time `json:"time"`
+	Latitude        *float64  `json:"latitude"`
+	Longitude       *float64  `json:"longitude"`
+	ElevationMeters *float64  `json:"elevation_meters"`
+	HeartRate       *int      `json:"heart_rate"`
+	Cadence         *int      `json:"cadence"`         // Per minute, of one leg for running as devices record it
+	DistanceMeters  *float64  `json:"distance_meters"` // Cumulative from the start
+}
+
+// A parsed file, Sport is empty when the file doesn't say
+type Activity struct {
+	Name   string
+	Sport  Sport
+	Points []TrackPoint
+}
+
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "."))); format {
+	case GPX, TCX, FIT:
+		return format, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+}
+
+func ParseSport(name string) (Sport, error) {
+	switch sport := Sport(strings.ToLower(strings.TrimSpace(name))); sport {
+	case Running, Walking, Hiking, Cycling, Swimming, Other:
+		return sport, nil
+	case "run":
+		return Running, nil
+	case "walk":
+		return Walking, nil
+	case "hike":
+		return Hiking, nil
+	case "biking", "ride", "bike":
+		return Cycling, nil
+	case "swim":
+		return Swimming, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownSport, name)
+}
+
+// Parse reads an activity file in format, detecting it from the content when format is empty.
+// Points come back in time order; points without a time are dropped.
+func Parse(r io.Reader, format Format) (*Activity, error) {
+	buffered := bufio.NewReader(r)
+	if format == "" {
+		var err error
+		format, err = detectFormat(buffered)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var activity *Activity
+	var err error
+	switch format {
+	case GPX:
+		activity, err = parseGPX(buffered)
+	case TCX:
+		activity, err = parseTCX(buffered)
+	case FIT:
+		activity, err = parseFIT(buffered)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	timed := activity.Points[:0]
+	for _, point := range activity.Points {
+		if !point.Time.IsZero() {
+			timed = append(timed, point)
+		}
+	}
+	if len(timed) == 0 {
+		return nil, ErrNoTrack
+	}
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].Time.Before(timed[j].Time) })
+	activity.Points = timed
+	return activity, nil
+}
+
+// FIT files carry ".FIT" at byte 8, the XML formats are told apart by their root element
+func detectFormat(r *bufio.Reader) (Format, error) {
+	head, _ := r.Peek(512)
+	switch {
+	case len(head) >= 12 && string(head[8:12]) == ".FIT":
+		return FIT, nil
+	case bytes.Contains(head, []byte("<gpx")):
+		return GPX, nil
+	case bytes.Contains(head, []byte("<TrainingCenterDatabase")):
+		return TCX, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// Totals of a track
+type Summary struct {
+	StartedAt           time.Time `json:"started_at"`
+	DurationSeconds     int       `json:"duration_seconds"` // Elapsed, pauses included
+	DistanceMeters      float64   `json:"distance_meters"`
+	ElevationGainMeters float64   `json:"elevation_gain_meters"`
+	AvgHeartRate        *int      `json:"avg_heart_rate"`
+	MaxHeartRate        *int      `json:"max_heart_rate"`
+	AvgCadence          *int      `json:"avg_cadence"`
+	Calories            int       `json:"calories"`
+}
+
+// Climbs smaller than this are taken as GPS and barometer noise
+const elevationNoiseMeters = 2.0
+
+// Summarize totals points, which must be in time order. Points without a recorded
+// distance get the distance along the track from their positions, so every point
+// carries a cumulative distance afterwards. Calories are estimated for sport and
+// the athlete's body weight.
+func Summarize(points []TrackPoint, sport Sport, bodyWeightKg float64) Summary {
+	fillDistances(points)
+
+	summary := Summary{}
+	if len(points) == 0 {
+		return summary
+	}
+	first, last := points[0], points[len(points)-1]
+	summary.StartedAt = first.Time
+	summary.DurationSeconds = int(last.Time.Sub(first.Time).Round(time.Second) / time.Second)
+	for _, point := range points {
+		if point.DistanceMeters != nil {
+			summary.DistanceMeters = math.Max(summary.DistanceMeters, *point.DistanceMeters)
+		}
+	}
+	summary.DistanceMeters = math.Round(summary.DistanceMeters*10) / 10
+
+	// Gain is counted once the track has climbed past the noise from its last low point
+	var reference *float64
+	for _, point := range points {
+		if point.ElevationMeters == nil {
+			continue
+		}
+		elevation := *point.ElevationMeters
+		switch {
+		case reference == nil || elevation < *reference:
+			reference = &elevation
+		case elevation-*reference >= elevationNoiseMeters:
+			summary.ElevationGainMeters += elevation - *reference
+			reference = &elevation
+		}
+	}
+	summary.ElevationGainMeters = math.Round(summary.ElevationGainMeters*10) / 10
+
+	summary.AvgHeartRate, summary.MaxHeartRate = averageAndMax(points, func(p TrackPoint) *int { return p.HeartRate })
+	summary.AvgCadence, _ = averageAndMax(points, func(p TrackPoint) *int { return p.Cadence })
+
+	summary.Calories = EstimateCalories(sport, bodyWeightKg, summary.DistanceMeters, summary.ElevationGainMeters,
+		summary.DurationSeconds)
+	return summary
+}
+
+// Mean and max of the positive samples of a point field
+func averageAndMax(points []TrackPoint, field func(TrackPoint) *int) (*int, *int) {
+	sum, count, highest := 0, 0, 0
+	for _, point := range points {
+		if value := field(point); value != nil && *value > 0 {
+			sum += *value
+			count++
+			highest = max(highest, *value)
+		}
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	average := int(math.Round(float64(sum) / float64(count)))
+	return &average, &highest
+}
+
+// Fills in cumulative distances from positions where the device didn't record them
+func fillDistances(points []TrackPoint) {
+	total := 0.0
+	var previous *TrackPoint
+	for i := range points {
+		point := &points[i]
+		if point.DistanceMeters != nil {
+			total = *point.DistanceMeters
+		} else {
+			if previous != nil && hasPosition(*previous) && hasPosition(*point) {
+				total += haversine(*previous.Latitude, *previous.Longitude, *point.Latitude, *point.Longitude)
+			}
+			distance := math.Round(total*10) / 10
+			point.DistanceMeters = &distance
+		}
+		if hasPosition(*point) {
+			previous = point
+		}
+	}
+}
+
+func hasPosition(point TrackPoint) bool {
+	return point.Latitude != nil && point.Longitude != nil
+}
+
+const earthRadiusMeters = 6371008.8
+
+// Great circle distance in meters between two positions in degrees
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat, dLon := toRadians(lat2-lat1), toRadians(lon2-lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
+
+// EstimateCalories estimates the kilocalories burned as MET x body weight x hours. Running
+// and walking METs follow the ACSM equations for the average speed and grade, cycling
+// uses the speed bands of the Compendium of Physical Activities, and other sports a
+// moderate fixed MET.
+func EstimateCalories(sport Sport, bodyWeightKg, distanceMeters, elevationGainMeters float64, durationSeconds int) int {
+	if durationSeconds <= 0 || bodyWeightKg <= 0 {
+		return 0
+	}
+	minutes := float64(durationSeconds) / 60
+	metersPerMinute := distanceMeters / minutes
+	grade := 0.0
+	if distanceMeters > 0 {
+		grade = elevationGainMeters / distanceMeters
+	}
+
+	var met float64
+	switch sport {
+	case Running:
+		met = (0.2*metersPerMinute + 0.9*metersPerMinute*grade + 3.5) / 3.5
+	case Walking, Hiking:
+		met = (0.1*metersPerMinute + 1.8*metersPerMinute*grade + 3.5) / 3.5
+	case Cycling:
+		kmh := metersPerMinute * 60 / 1000
+		switch {
+		case kmh < 16:
+			met = 4
+		case kmh < 19:
+			met = 6
+		case kmh < 22:
+			met = 8
+		case kmh < 25:
+			met = 10
+		case kmh < 30:
+			met = 12
+		default:
+			met = 16
+		}
+	case Swimming:
+		met = 7
+	default:
+		met = 6
+	}
+
+	return int(math.Round(met * bodyWeightKg * minutes / 60))
+}
@@ -0,0 +1,119 @@
+package activity
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gpxRun = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="Watch" xmlns="http://www.topografix.com/GPX/1/1"
+  xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+ <trk>
+  <name>Lunch Run</name>
+  <type>running</type>
+  <trkseg>
+   <trkpt lat="51.5000" lon="-0.1000"><ele>10</ele><time>2024-05-01T12:00:00Z</time>
+    <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>120</gpxtpx:hr><gpxtpx:cad>80</gpxtpx:cad></gpxtpx:TrackPointExtension></extensions>
+   </trkpt>
+   <trkpt lat="51.5045" lon="-0.1000"><ele>11</ele><time>2024-05-01T12:02:30Z</time>
+    <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>150</gpxtpx:hr><gpxtpx:cad>86</gpxtpx:cad></gpxtpx:TrackPointExtension></extensions>
+   </trkpt>
+   <trkpt lat="51.5090" lon="-0.1000"><ele>16</ele><time>2024-05-01T12:05:00Z</time>
+    <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>165</gpxtpx:hr><gpxtpx:cad>88</gpxtpx:cad></gpxtpx:TrackPointExtension></extensions>
+   </trkpt>
+   <trkpt lat="51.5100" lon="-0.1000"><ele>14</ele></trkpt>
+  </trkseg>
+ </trk>
+</gpx>`
+
+func TestParseGPX(t *testing.T) {
+	activity, err := Parse(strings.NewReader(gpxRun), "")
+	require.NoError(t, err)
+	assert.Equal(t, "Lunch Run", activity.Name)
+	assert.Equal(t, Running, activity.Sport)
+	require.Len(t, activity.Points, 3, "points without a time are dropped")
+	assert.Equal(t, 150, *activity.Points[1].HeartRate)
+
+	summary := Summarize(activity.Points, activity.Sport, 70)
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), summary.StartedAt)
+	assert.Equal(t, 300, summary.DurationSeconds)
+	assert.InDelta(t, 1000.7, summary.DistanceMeters, 1, "0.009 degrees of latitude")
+	assert.Equal(t, 6.0, summary.ElevationGainMeters, "the first meter is noise")
+	assert.Equal(t, 145, *summary.AvgHeartRate)
+	assert.Equal(t, 165, *summary.MaxHeartRate)
+	assert.Equal(t, 85, *summary.AvgCadence)
+	assert.InDelta(t, 500.3, *activity.Points[1].DistanceMeters, 1, "cumulative distances are filled in")
+	assert.Greater(t, summary.Calories, 0)
+}
+
+func TestParseTCX(t *testing.T) {
+	tcx := `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+  xmlns:ns3="http://www.garmin.com/xmlschemas/ActivityExtension/v2">
+ <Activities>
+  <Activity Sport="Biking">
+   <Id>2024-05-02T07:00:00Z</Id>
+   <Lap StartTime="2024-05-02T07:00:00Z">
+    <Track>
+     <Trackpoint><Time>2024-05-02T07:00:00Z</Time><AltitudeMeters>100</AltitudeMeters><DistanceMeters>0</DistanceMeters><HeartRateBpm><Value>110</Value></HeartRateBpm><Cadence>85</Cadence></Trackpoint>
+     <Trackpoint><Time>2024-05-02T07:30:00Z</Time><AltitudeMeters>140</AltitudeMeters><DistanceMeters>12500</DistanceMeters><HeartRateBpm><Value>140</Value></HeartRateBpm><Cadence>90</Cadence></Trackpoint>
+    </Track>
+   </Lap>
+   <Lap StartTime="2024-05-02T07:30:00Z">
+    <Track>
+     <Trackpoint><Time>2024-05-02T08:00:00Z</Time><AltitudeMeters>120</AltitudeMeters><DistanceMeters>25000</DistanceMeters><HeartRateBpm><Value>150</Value></HeartRateBpm><Extensions><ns3:TPX><ns3:RunCadence>0</ns3:RunCadence></ns3:TPX></Extensions></Trackpoint>
+    </Track>
+   </Lap>
+  </Activity>
+ </Activities>
+</TrainingCenterDatabase>`
+
+	activity, err := Parse(strings.NewReader(tcx), TCX)
+	require.NoError(t, err)
+	assert.Equal(t, Cycling, activity.Sport)
+	require.Len(t, activity.Points, 3)
+	assert.Nil(t, activity.Points[0].Latitude, "indoor rides have no positions")
+
+	summary := Summarize(activity.Points, activity.Sport, 80)
+	assert.Equal(t, 3600, summary.DurationSeconds)
+	assert.Equal(t, 25000.0, summary.DistanceMeters, "recorded distances are kept")
+	assert.Equal(t, 40.0, summary.ElevationGainMeters)
+	assert.Equal(t, 88, *summary.AvgCadence, "zero cadence is a pause, not a sample")
+	assert.Equal(t, 12*80, summary.Calories, "riding 25 km/h is a MET of 12")
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(strings.NewReader("lat,lon\n"), "")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = Parse(strings.NewReader("<gpx><trk><trkseg><trkpt lat=\"1\" lon=\"1\"></trkpt></trkseg></trk></gpx>"), GPX)
+	assert.ErrorIs(t, err, ErrNoTrack)
+
+	_, err = Parse(strings.NewReader("<gpx><trk>"), GPX)
+	assert.ErrorIs(t, err, ErrInvalidFile)
+
+	_, err = ParseFormat("kml")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	format, err := ParseFormat(".FIT")
+	require.NoError(t, err)
+	assert.Equal(t, FIT, format)
+
+	_, err = ParseSport("curling")
+	assert.ErrorIs(t, err, ErrUnknownSport)
+}
+
+func TestEstimateCalories(t *testing.T) {
+	// 10 km in an hour is a running MET of about 10.5
+	assert.Equal(t, 737, EstimateCalories(Running, 70, 10000, 0, 3600))
+	// Climbing costs more
+	assert.Greater(t, EstimateCalories(Running, 70, 10000, 200, 3600), 737)
+	// 5 km/h walking is a MET of about 3.4
+	assert.Equal(t, 237, EstimateCalories(Walking, 70, 5000, 0, 3600))
+	assert.Equal(t, 0, EstimateCalories(Running, 70, 10000, 0, 0))
+	assert.Equal(t, 420, EstimateCalories(Other, 70, 0, 0, 3600))
+}
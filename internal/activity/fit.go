@@ -0,0 +1,301 @@
+package activity
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// FIT is Garmin's binary activity format: a header, then records that each either define
+// the layout of a local message type or hold the data of one. Only the record messages,
+// one per track point, and the sport are read; everything else is skipped by its size.
+
+const (
+	fitMesgSport   = 12
+	fitMesgSession = 18
+	fitMesgRecord  = 20
+
+	fitFieldTimestamp = 253
+)
+
+// Seconds between the Unix epoch and the FIT epoch, 1989-12-31T00:00:00Z
+const fitEpoch = 631065600
+
+// Sport enum of the sport and session messages
+var fitSports = map[uint64]Sport{0: Other, 1: Running, 2: Cycling, 5: Swimming, 11: Walking, 17: Hiking}
+
+type fitField struct {
+	number   byte
+	size     int
+	baseType byte
+}
+
+type fitDefinition struct {
+	bigEndian      bool
+	globalMesg     uint16
+	fields         []fitField
+	developerBytes int // Developer fields aren't read, only skipped
+}
+
+func parseFIT(r io.Reader) (*Activity, error) {
+	header := make([]byte, 12)
+	_, err := io.ReadFull(r, header)
+	if err != nil || string(header[8:12]) != ".FIT" || header[0] < 12 {
+		return nil, fmt.Errorf("%w: missing FIT header", ErrInvalidFile)
+	}
+	// 14 byte headers end with a CRC
+	_, err = io.CopyN(io.Discard, r, int64(header[0])-12)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	dataSize := int64(binary.LittleEndian.Uint32(header[4:8]))
+	data := &fitReader{r: io.LimitReader(r, dataSize)}
+	definitions := map[byte]*fitDefinition{}
+	activity := &Activity{}
+	var lastTimestamp uint32
+
+	for data.read < dataSize {
+		recordHeader := data.byte()
+		if data.err != nil {
+			break
+		}
+
+		var localType byte
+		var compressedOffset *byte
+		switch {
+		case recordHeader&0x80 != 0: // Compressed timestamp header, always a data message
+			localType = (recordHeader >> 5) & 0x03
+			offset := recordHeader & 0x1F
+			compressedOffset = &offset
+		case recordHeader&0x40 != 0: // Definition message
+			definitions[recordHeader&0x0F] = data.definition(recordHeader&0x20 != 0)
+			continue
+		default:
+			localType = recordHeader & 0x0F
+		}
+
+		definition, ok := definitions[localType]
+		if !ok {
+			return nil, fmt.Errorf("%w: data message without a definition", ErrInvalidFile)
+		}
+
+		values := map[byte]fitValue{}
+		for _, field := range definition.fields {
+			values[field.number] = fitValue{raw: data.bytes(field.size), baseType: field.baseType,
+				bigEndian: definition.bigEndian}
+		}
+		data.bytes(definition.developerBytes)
+		if data.err != nil {
+			break
+		}
+
+		// A compressed header carries the low 5 bits of the timestamp
+		if compressedOffset != nil {
+			lastTimestamp += uint32((*compressedOffset - byte(lastTimestamp&0x1F)) & 0x1F)
+		} else if timestamp, ok := values[fitFieldTimestamp].uint(); ok {
+			lastTimestamp = uint32(timestamp)
+		}
+
+		switch definition.globalMesg {
+		case fitMesgRecord:
+			activity.Points = append(activity.Points, fitRecord(values, lastTimestamp))
+		case fitMesgSport, fitMesgSession:
+			field := byte(0) // sport is field 0 of the sport message and 5 of the session
+			if definition.globalMesg == fitMesgSession {
+				field = 5
+			}
+			if sport, ok := values[field].uint(); ok && activity.Sport == "" {
+				activity.Sport = fitSports[sport]
+			}
+		}
+	}
+	if data.err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, data.err)
+	}
+
+	return activity, nil
+}
+
+// Reads a record message into a track point, converting the FIT scales and offsets
+func fitRecord(values map[byte]fitValue, timestamp uint32) TrackPoint {
+	point := TrackPoint{}
+	if timestamp != 0 {
+		point.Time = time.Unix(int64(timestamp)+fitEpoch, 0).UTC()
+	}
+
+	latitude, okLat := values[0].int()
+	longitude, okLon := values[1].int()
+	if okLat && okLon {
+		lat, lon := semicirclesToDegrees(latitude), semicirclesToDegrees(longitude)
+		point.Latitude, point.Longitude = &lat, &lon
+	}
+
+	// enhanced_altitude (78) supersedes altitude (2), both in 1/5 m above -500 m
+	for _, field := range []byte{2, 78} {
+		if altitude, ok := values[field].uint(); ok {
+			elevation := float64(altitude)/5 - 500
+			point.ElevationMeters = &elevation
+		}
+	}
+	if heartRate, ok := values[3].uint(); ok {
+		bpm := int(heartRate)
+		point.HeartRate = &bpm
+	}
+	if cadence, ok := values[4].uint(); ok {
+		perMinute := int(cadence)
+		point.Cadence = &perMinute
+	}
+	if distance, ok := values[5].uint(); ok {
+		meters := float64(distance) / 100
+		point.DistanceMeters = &meters
+	}
+
+	return point
+}
+
+func semicirclesToDegrees(semicircles int64) float64 {
+	return float64(semicircles) * 180 / math.Pow(2, 31)
+}
+
+// Reads the data section, keeping the first error and how far it got
+type fitReader struct {
+	r    io.Reader
+	read int64
+	err  error
+}
+
+func (f *fitReader) bytes(n int) []byte {
+	buf := make([]byte, n)
+	if f.err != nil || n == 0 {
+		return buf
+	}
+	read, err := io.ReadFull(f.r, buf)
+	f.read += int64(read)
+	if err != nil {
+		f.err = fmt.Errorf("truncated data: %w", err)
+	}
+	return buf
+}
+
+func (f *fitReader) byte() byte {
+	return f.bytes(1)[0]
+}
+
+func (f *fitReader) definition(developerFields bool) *fitDefinition {
+	fixed := f.bytes(5) // reserved, architecture, global message number and field count
+	definition := &fitDefinition{bigEndian: fixed[1] == 1}
+	if definition.bigEndian {
+		definition.globalMesg = binary.BigEndian.Uint16(fixed[2:4])
+	} else {
+		definition.globalMesg = binary.LittleEndian.Uint16(fixed[2:4])
+	}
+
+	for range int(fixed[4]) {
+		field := f.bytes(3)
+		definition.fields = append(definition.fields, fitField{number: field[0], size: int(field[1]), baseType: field[2]})
+	}
+
+	if developerFields {
+		count := int(f.byte())
+		for range count {
+			definition.developerBytes += int(f.bytes(3)[1])
+		}
+	}
+
+	return definition
+}
+
+// A field as read, decoded on demand by its base type
+type fitValue struct {
+	raw       []byte
+	baseType  byte
+	bigEndian bool
+}
+
+// Base type numbers without the endian flag, see the FIT SDK profile
+const (
+	fitEnum    = 0x00
+	fitSint8   = 0x01
+	fitUint8   = 0x02
+	fitSint16  = 0x03
+	fitUint16  = 0x04
+	fitSint32  = 0x05
+	fitUint32  = 0x06
+	fitUint8z  = 0x0A
+	fitUint16z = 0x0B
+	fitUint32z = 0x0C
+)
+
+// Reads an unsigned field, ok is false for missing and invalid values
+func (v fitValue) uint() (uint64, bool) {
+	if len(v.raw) == 0 {
+		return 0, false
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if v.bigEndian {
+		order = binary.BigEndian
+	}
+
+	var value, invalid uint64
+	switch v.baseType & 0x1F {
+	case fitEnum, fitUint8:
+		value, invalid = uint64(v.raw[0]), 0xFF
+	case fitUint8z:
+		value, invalid = uint64(v.raw[0]), 0
+	case fitUint16, fitUint16z:
+		if len(v.raw) < 2 {
+			return 0, false
+		}
+		value, invalid = uint64(order.Uint16(v.raw)), 0xFFFF
+		if v.baseType&0x1F == fitUint16z {
+			invalid = 0
+		}
+	case fitUint32, fitUint32z:
+		if len(v.raw) < 4 {
+			return 0, false
+		}
+		value, invalid = uint64(order.Uint32(v.raw)), 0xFFFFFFFF
+		if v.baseType&0x1F == fitUint32z {
+			invalid = 0
+		}
+	default:
+		return 0, false
+	}
+
+	return value, value != invalid
+}
+
+// Reads a signed field, ok is false for missing and invalid values
+func (v fitValue) int() (int64, bool) {
+	if len(v.raw) == 0 {
+		return 0, false
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if v.bigEndian {
+		order = binary.BigEndian
+	}
+
+	switch v.baseType & 0x1F {
+	case fitSint8:
+		value := int8(v.raw[0])
+		return int64(value), value != math.MaxInt8
+	case fitSint16:
+		if len(v.raw) < 2 {
+			return 0, false
+		}
+		value := int16(order.Uint16(v.raw))
+		return int64(value), value != math.MaxInt16
+	case fitSint32:
+		if len(v.raw) < 4 {
+			return 0, false
+		}
+		value := int32(order.Uint32(v.raw))
+		return int64(value), value != math.MaxInt32
+	}
+	return 0, false
+}
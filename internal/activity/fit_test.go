@@ -0,0 +1,111 @@
+package activity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Writes a FIT file with a sport message and a record message per sample, the second
+// and later records using compressed timestamp headers
+func encodeFIT(t *testing.T, start time.Time, sport byte, samples [][4]int32) []byte {
+	t.Helper()
+	var data bytes.Buffer
+	le := binary.LittleEndian
+
+	// Sport: local type 1, field 0 enum
+	data.Write([]byte{0x41, 0, 0})
+	binary.Write(&data, le, uint16(fitMesgSport))
+	data.Write([]byte{1, 0, 1, fitEnum})
+	data.Write([]byte{0x01, sport})
+
+	// Record: local type 0 with timestamp, lat, long, altitude, heart rate and distance,
+	// plus a developer field to skip
+	data.Write([]byte{0x60, 0, 0})
+	binary.Write(&data, le, uint16(fitMesgRecord))
+	data.Write([]byte{6,
+		fitFieldTimestamp, 4, 0x86,
+		0, 4, 0x85,
+		1, 4, 0x85,
+		2, 2, 0x84,
+		3, 1, fitUint8,
+		5, 4, 0x86,
+	})
+	data.Write([]byte{1, 0, 2, 0})
+
+	// A record without a timestamp field for the compressed headers: lat, long, altitude, hr, distance
+	data.Write([]byte{0x42, 0, 0})
+	binary.Write(&data, le, uint16(fitMesgRecord))
+	data.Write([]byte{5, 0, 4, 0x85, 1, 4, 0x85, 2, 2, 0x84, 3, 1, fitUint8, 5, 4, 0x86})
+
+	timestamp := uint32(start.Unix() - fitEpoch)
+	for i, sample := range samples {
+		seconds, lat, hr, distance := sample[0], sample[1], sample[2], sample[3]
+		current := timestamp + uint32(seconds)
+		if i == 0 {
+			data.WriteByte(0x00)
+			binary.Write(&data, le, current)
+		} else {
+			data.WriteByte(0x80 | 2<<5 | byte(current&0x1F))
+		}
+		binary.Write(&data, le, lat)
+		binary.Write(&data, le, int32(-1193046)) // -0.1 degrees
+		binary.Write(&data, le, uint16((100+500)*5))
+		data.WriteByte(byte(hr))
+		binary.Write(&data, le, uint32(distance))
+		if i == 0 {
+			data.Write([]byte{0xAA, 0xBB}) // developer field
+		}
+	}
+
+	var file bytes.Buffer
+	file.Write([]byte{14, 0x20})
+	binary.Write(&file, le, uint16(2132))
+	binary.Write(&file, le, uint32(data.Len()))
+	file.WriteString(".FIT")
+	file.Write([]byte{0, 0}) // header CRC, not checked
+	file.Write(data.Bytes())
+	file.Write([]byte{0, 0}) // file CRC
+	return file.Bytes()
+}
+
+func TestParseFIT(t *testing.T) {
+	start := time.Date(2024, 5, 3, 6, 30, 0, 0, time.UTC)
+	file := encodeFIT(t, start, 1, [][4]int32{
+		{0, 613566757, 0xFF, 0}, // heart rate invalid
+		{10, 613566800, 130, 3500},
+		{25, 613566900, 150, 8000},
+	})
+
+	activity, err := Parse(bytes.NewReader(file), "")
+	require.NoError(t, err)
+	assert.Equal(t, Running, activity.Sport)
+	require.Len(t, activity.Points, 3)
+
+	first, last := activity.Points[0], activity.Points[2]
+	assert.Equal(t, start, first.Time)
+	assert.Equal(t, start.Add(25*time.Second), last.Time, "compressed timestamps count from the last full one")
+	assert.InDelta(t, 51.4285, *first.Latitude, 0.0001)
+	assert.InDelta(t, -0.1, *first.Longitude, 0.0001)
+	assert.Nil(t, first.HeartRate, "invalid values are left out")
+	assert.Equal(t, 100.0, *first.ElevationMeters)
+	assert.Equal(t, 80.0, *last.DistanceMeters)
+
+	summary := Summarize(activity.Points, activity.Sport, 70)
+	assert.Equal(t, 25, summary.DurationSeconds)
+	assert.Equal(t, 80.0, summary.DistanceMeters)
+	assert.Equal(t, 140, *summary.AvgHeartRate)
+}
+
+func TestParseFITErrors(t *testing.T) {
+	_, err := Parse(bytes.NewReader([]byte("not a fit file at all")), FIT)
+	assert.ErrorIs(t, err, ErrInvalidFile)
+
+	file := encodeFIT(t, time.Now(), 1, [][4]int32{{0, 0, 120, 0}})
+	_, err = Parse(bytes.NewReader(file[:len(file)-6]), FIT)
+	assert.ErrorIs(t, err, ErrInvalidFile, "truncated data")
+}
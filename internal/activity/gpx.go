@@ -0,0 +1,67 @@
+package activity
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The parts of GPX 1.1 a track needs. Heart rate and cadence come from Garmin's
+// TrackPointExtension, which most devices and apps write.
+type gpxFile struct {
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []struct {
+				Latitude   float64    `xml:"lat,attr"`
+				Longitude  float64    `xml:"lon,attr"`
+				Elevation  *float64   `xml:"ele"`
+				Time       *time.Time `xml:"time"`
+				Extensions struct {
+					TrackPoint struct {
+						HeartRate *int `xml:"hr"`
+						Cadence   *int `xml:"cad"`
+					} `xml:"TrackPointExtension"`
+				} `xml:"extensions"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+func parseGPX(r io.Reader) (*Activity, error) {
+	var file gpxFile
+	err := xml.NewDecoder(r).Decode(&file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	activity := &Activity{}
+	for _, track := range file.Tracks {
+		if activity.Name == "" {
+			activity.Name = track.Name
+		}
+		if activity.Sport == "" {
+			activity.Sport, _ = ParseSport(track.Type)
+		}
+		for _, segment := range track.Segments {
+			for _, trkpt := range segment.Points {
+				latitude, longitude := trkpt.Latitude, trkpt.Longitude
+				point := TrackPoint{
+					Latitude:        &latitude,
+					Longitude:       &longitude,
+					ElevationMeters: trkpt.Elevation,
+					HeartRate:       trkpt.Extensions.TrackPoint.HeartRate,
+					Cadence:         trkpt.Extensions.TrackPoint.Cadence,
+				}
+				if trkpt.Time != nil {
+					point.Time = trkpt.Time.UTC()
+				}
+				activity.Points = append(activity.Points, point)
+			}
+		}
+	}
+
+	return activity, nil
+}
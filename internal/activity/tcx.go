@@ -0,0 +1,79 @@
+package activity
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The parts of Garmin's Training Center XML a track needs. Runs record their cadence
+// in the RunCadence extension, rides in the Cadence element.
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Notes string `xml:"Notes"`
+		Laps  []struct {
+			Points []struct {
+				Time     *time.Time `xml:"Time"`
+				Position *struct {
+					Latitude  float64 `xml:"LatitudeDegrees"`
+					Longitude float64 `xml:"LongitudeDegrees"`
+				} `xml:"Position"`
+				Altitude  *float64 `xml:"AltitudeMeters"`
+				Distance  *float64 `xml:"DistanceMeters"`
+				HeartRate *struct {
+					Value int `xml:"Value"`
+				} `xml:"HeartRateBpm"`
+				Cadence    *int `xml:"Cadence"`
+				Extensions struct {
+					TPX struct {
+						RunCadence *int `xml:"RunCadence"`
+					} `xml:"TPX"`
+				} `xml:"Extensions"`
+			} `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+func parseTCX(r io.Reader) (*Activity, error) {
+	var file tcxFile
+	err := xml.NewDecoder(r).Decode(&file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	activity := &Activity{}
+	for _, tcxActivity := range file.Activities {
+		if activity.Name == "" {
+			activity.Name = tcxActivity.Notes
+		}
+		if activity.Sport == "" {
+			activity.Sport, _ = ParseSport(tcxActivity.Sport)
+		}
+		for _, lap := range tcxActivity.Laps {
+			for _, trackpoint := range lap.Points {
+				point := TrackPoint{
+					ElevationMeters: trackpoint.Altitude,
+					DistanceMeters:  trackpoint.Distance,
+					Cadence:         trackpoint.Cadence,
+				}
+				if trackpoint.Time != nil {
+					point.Time = trackpoint.Time.UTC()
+				}
+				if trackpoint.Position != nil {
+					point.Latitude, point.Longitude = &trackpoint.Position.Latitude, &trackpoint.Position.Longitude
+				}
+				if trackpoint.HeartRate != nil {
+					point.HeartRate = &trackpoint.HeartRate.Value
+				}
+				if trackpoint.Extensions.TPX.RunCadence != nil {
+					point.Cadence = trackpoint.Extensions.TPX.RunCadence
+				}
+				activity.Points = append(activity.Points, point)
+			}
+		}
+	}
+
+	return activity, nil
+}
@@ -0,0 +1,69 @@
+// Package account lets users take their data with them and close their account:
+// exports are built in the background into a ZIP archive downloaded through a signed
+// link, and deletions wait out a grace period before anything is removed.
+package account
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusReady   = "ready"
+	StatusFailed  = "failed"
+)
+
+const (
+	DeleteCascade   = "cascade"   // the user and everything they own is removed
+	DeleteAnonymize = "anonymize" // personal data is scrubbed, training history is kept without an owner's identity
+)
+
+const (
+	// How long a deletion can be cancelled before it's carried out
+	DeletionGracePeriod = 30 * 24 * time.Hour
+	// How long a finished archive is kept on disk
+	ExportRetention = 7 * 24 * time.Hour
+	// How long a signed download link works, a new one is issued on every status check
+	DownloadLinkTTL = time.Hour
+)
+
+var (
+	ErrUnknownDeletionMode = errors.New("unknown deletion mode, expected cascade or anonymize")
+	ErrInvalidSignature    = errors.New("invalid or expired download link")
+)
+
+// A requested data export. FilePath is only known to the server.
+type Export struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Status      string     `json:"status"`
+	SizeBytes   *int64     `json:"size_bytes,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"` // Only while the archive is ready
+	FilePath    string     `json:"-"`
+}
+
+// A scheduled account deletion
+type Deletion struct {
+	UserID       int       `json:"user_id"`
+	Mode         string    `json:"mode"`
+	RequestedAt  time.Time `json:"requested_at"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
+
+// Defaults to cascade
+func ParseDeletionMode(mode string) (string, error) {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "":
+		return DeleteCascade, nil
+	case DeleteCascade, DeleteAnonymize:
+		return mode, nil
+	}
+	return "", ErrUnknownDeletionMode
+}
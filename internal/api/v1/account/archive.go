@@ -0,0 +1,140 @@
+package account
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/exercises"
+	"github.com/Josesx506/gofems/internal/api/v1/records"
+	"github.com/Josesx506/gofems/internal/api/v1/schedule"
+	"github.com/Josesx506/gofems/internal/api/v1/templates"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+)
+
+// Everything a user owns, as it goes into their export. Weights are in kilograms.
+type Archive struct {
+	ExportedAt  time.Time
+	User        *users.User
+	Preferences *users.Preferences
+	Workouts    []workouts.Workout // Trashed workouts included, with their deleted_at
+	Templates   []templates.Template
+	Exercises   []exercises.Exercise // Custom exercises only
+	Records     []records.Record     // Every record event, not just the standing bests
+	Sessions    []schedule.Session   // Planned sessions with their completions
+}
+
+var recordCSVColumns = []string{
+	"id", "exercise_name", "record_type", "value", "weight", "previous_value", "workout_id", "achieved_at",
+}
+
+// WriteArchive writes archive as a ZIP of one JSON file per kind of data, plus CSV
+// copies of the entries and records for spreadsheets. entries.csv has the columns of
+// the workout CSV export, so it can be imported again.
+func WriteArchive(w io.Writer, archive *Archive) error {
+	zw := zip.NewWriter(w)
+
+	profile := map[string]any{
+		"exported_at": archive.ExportedAt,
+		"user":        archive.User,
+		"preferences": archive.Preferences,
+	}
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", profile},
+		{"workouts.json", archive.Workouts},
+		{"templates.json", archive.Templates},
+		{"exercises.json", archive.Exercises},
+		{"records.json", archive.Records},
+		{"sessions.json", archive.Sessions},
+	}
+	for _, file := range files {
+		err := writeJSONFile(zw, file.name, file.data, archive.ExportedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := writeCSVFile(zw, "entries.csv", archive.ExportedAt, workouts.CSVColumns, func(cw *csv.Writer) error {
+		for i := range archive.Workouts {
+			workout := &archive.Workouts[i]
+			for j := range workout.Entries {
+				err := cw.Write(workouts.EncodeCSVRow(workout, &workout.Entries[j]))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = writeCSVFile(zw, "records.csv", archive.ExportedAt, recordCSVColumns, func(cw *csv.Writer) error {
+		for _, record := range archive.Records {
+			err := cw.Write([]string{
+				strconv.Itoa(record.ID),
+				record.ExerciseName,
+				record.RecordType,
+				strconv.FormatFloat(record.Value, 'f', -1, 64),
+				formatOptionalFloat(record.Weight),
+				formatOptionalFloat(record.PreviousValue),
+				strconv.Itoa(record.WorkoutID),
+				record.AchievedAt.UTC().Format(time.RFC3339),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeJSONFile(zw *zip.Writer, name string, data any, modified time.Time) error {
+	file, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(data)
+}
+
+func writeCSVFile(zw *zip.Writer, name string, modified time.Time, header []string, rows func(*csv.Writer) error) error {
+	file, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(file)
+	err = cw.Write(header)
+	if err != nil {
+		return err
+	}
+	err = rows(cw)
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatOptionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
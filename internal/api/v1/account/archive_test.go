@@ -0,0 +1,128 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/records"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/units"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteArchive(t *testing.T) {
+	exportedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	reps, weight := 5, 100.0
+	archive := &Archive{
+		ExportedAt:  exportedAt,
+		User:        &users.User{ID: 7, Username: "lifter", Email: "lifter@example.com"},
+		Preferences: &users.Preferences{UserID: 7, WeightUnit: units.Kilograms},
+		Workouts: []workouts.Workout{{
+			ID: 3, UserID: 7, Title: "Leg day", CreatedAt: exportedAt.Add(-24 * time.Hour),
+			Entries: []workouts.WorkoutEntry{
+				{ExerciseName: "Squat", Sets: 3, Reps: &reps, Weight: &weight, WeightUnit: units.Kilograms},
+				{ExerciseName: "Lunge", Sets: 2, Reps: &reps},
+			},
+		}},
+		Records: []records.Record{
+			{ID: 1, ExerciseName: "Squat", RecordType: records.MaxWeight, Value: 100, WorkoutID: 3, AchievedAt: exportedAt},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteArchive(&buf, archive))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		require.NoError(t, err)
+		var content bytes.Buffer
+		_, err = content.ReadFrom(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[file.Name] = content.Bytes()
+	}
+	for _, name := range []string{"profile.json", "workouts.json", "templates.json", "exercises.json",
+		"records.json", "sessions.json", "entries.csv", "records.csv"} {
+		assert.Contains(t, files, name)
+	}
+
+	var profile struct {
+		User        users.User        `json:"user"`
+		Preferences users.Preferences `json:"preferences"`
+	}
+	require.NoError(t, json.Unmarshal(files["profile.json"], &profile))
+	assert.Equal(t, "lifter@example.com", profile.User.Email)
+	assert.Equal(t, units.Kilograms, profile.Preferences.WeightUnit)
+
+	var exported []workouts.Workout
+	require.NoError(t, json.Unmarshal(files["workouts.json"], &exported))
+	require.Len(t, exported, 1)
+	assert.Len(t, exported[0].Entries, 2)
+
+	entries, err := csv.NewReader(bytes.NewReader(files["entries.csv"])).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, entries, 3, "header and one row per entry")
+	assert.Equal(t, workouts.CSVColumns, entries[0])
+	assert.Equal(t, "Squat", entries[1][6])
+	assert.Equal(t, "100", entries[1][10])
+
+	// Archives can be imported again
+	parsed, err := workouts.ParseCSV(bytes.NewReader(files["entries.csv"]), nil)
+	require.NoError(t, err)
+	assert.Empty(t, parsed.Errors)
+	assert.Len(t, parsed.Workouts, 1)
+
+	recordRows, err := csv.NewReader(bytes.NewReader(files["records.csv"])).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, recordRows, 2)
+	assert.Equal(t, []string{"1", "Squat", "max_weight", "100", "", "", "3", "2026-10-01T12:00:00Z"}, recordRows[1])
+}
+
+func TestDownloadSignature(t *testing.T) {
+	key := []byte("secret")
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(DownloadLinkTTL)
+	signature := signDownload(key, 5, expires.Unix())
+	unix := strconv.FormatInt(expires.Unix(), 10)
+
+	assert.Equal(t, "/v1/exports/5/download?expires="+unix+"&signature="+signature, DownloadURL(key, 5, expires))
+
+	tests := []struct {
+		name      string
+		exportID  int64
+		expires   string
+		signature string
+		now       time.Time
+		wantErr   bool
+	}{
+		{name: "valid link", exportID: 5, expires: unix, signature: signature, now: now},
+		{name: "expired link", exportID: 5, expires: unix, signature: signature, now: expires.Add(time.Second), wantErr: true},
+		{name: "other export", exportID: 6, expires: unix, signature: signature, now: now, wantErr: true},
+		{name: "extended expiry", exportID: 5, expires: strconv.FormatInt(expires.Unix()+3600, 10), signature: signature, now: now, wantErr: true},
+		{name: "other key", exportID: 5, expires: unix, signature: signDownload([]byte("other"), 5, expires.Unix()), now: now, wantErr: true},
+		{name: "malformed signature", exportID: 5, expires: unix, signature: "zz", now: now, wantErr: true},
+		{name: "missing expiry", exportID: 5, expires: "", signature: signature, now: now, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyDownload(key, tt.exportID, tt.expires, tt.signature, tt.now)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSignature)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
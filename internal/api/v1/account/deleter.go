@@ -0,0 +1,61 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// AccountDeleter carries out account deletions once their grace period is over
+type AccountDeleter struct {
+	store  AccountStore
+	logger *log.Logger
+}
+
+func NewAccountDeleter(store AccountStore, logger *log.Logger) *AccountDeleter {
+	return &AccountDeleter{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// Deletes every due account. One failing doesn't hold up the others, the failures
+// are returned together once they've all been tried.
+func (ad *AccountDeleter) DeleteOnce() error {
+	due, err := ad.store.ListDueDeletions(time.Now())
+	if err != nil {
+		return err
+	}
+
+	var failed []error
+	for _, deletion := range due {
+		err := ad.delete(deletion)
+		if err != nil {
+			failed = append(failed, fmt.Errorf("deleting account %d: %w", deletion.UserID, err))
+			continue
+		}
+		ad.logger.Printf("Deleted account %d (%s)", deletion.UserID, deletion.Mode)
+	}
+	return errors.Join(failed...)
+}
+
+// Export archives go first, their rows are removed with the account
+func (ad *AccountDeleter) delete(deletion Deletion) error {
+	userID := int64(deletion.UserID)
+	exports, err := ad.store.ListExports(userID)
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		err := removeArchive(export.FilePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if deletion.Mode == DeleteAnonymize {
+		return ad.store.AnonymizeAccount(userID)
+	}
+	return ad.store.DeleteAccount(userID)
+}
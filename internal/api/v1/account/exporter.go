@@ -0,0 +1,223 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/exercises"
+	"github.com/Josesx506/gofems/internal/api/v1/records"
+	"github.com/Josesx506/gofems/internal/api/v1/schedule"
+	"github.com/Josesx506/gofems/internal/api/v1/templates"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/jobs"
+)
+
+// Exports running for longer than this belong to a worker that died and are started over.
+// It's the timeout of the build job too, whose lease runs out just after.
+const staleExportAfter = time.Hour
+
+// Exporter builds the archives of queued exports and removes them from disk once
+// they expire. Both run as jobs.
+type Exporter struct {
+	store         AccountStore
+	userStore     users.UserStore
+	workoutStore  workouts.WorkoutStore
+	templateStore templates.TemplateStore
+	exerciseStore exercises.ExerciseStore
+	recordStore   records.RecordStore
+	scheduleStore schedule.ScheduleStore
+	logger        *log.Logger
+	dir           string
+}
+
+func NewExporter(db *sql.DB, logger *log.Logger, dir string) *Exporter {
+	return &Exporter{
+		store:         NewPostgresAccountStore(db),
+		userStore:     users.NewPostgresUserStore(db),
+		workoutStore:  workouts.NewPostgresWorkoutStore(db),
+		templateStore: templates.NewPostgresTemplateStore(db),
+		exerciseStore: exercises.NewPostgresExerciseStore(db),
+		recordStore:   records.NewPostgresRecordStore(db),
+		scheduleStore: schedule.NewPostgresScheduleStore(db),
+		logger:        logger,
+		dir:           dir,
+	}
+}
+
+// Export builds the archive of a queued export. An export already built, or being
+// built by a live worker, is left alone, so requesting it twice is harmless. When ctx
+// is cancelled by a shutdown the export is handed back to the queue for the retry.
+func (e *Exporter) Export(ctx context.Context, id int64) error {
+	export, err := e.store.ClaimExport(id, staleExportAfter)
+	if err != nil {
+		return err
+	}
+	if export == nil {
+		return nil
+	}
+
+	err = e.build(ctx, export)
+	if errors.Is(err, context.Canceled) {
+		return errors.Join(err, e.store.ReleaseExport(id))
+	}
+	if err != nil {
+		failErr := e.store.FailExport(id, "failed to build the archive, please request a new export")
+		if failErr != nil {
+			return errors.Join(err, failErr)
+		}
+		// The user is told to request a new export rather than have this one retried
+		return jobs.Permanent(err)
+	}
+	return nil
+}
+
+// Writes the archive next to its final path and renames it into place, so a crash
+// never leaves a truncated archive behind a ready export
+func (e *Exporter) build(ctx context.Context, export *Export) error {
+	archive, err := e.collect(ctx, int64(export.UserID))
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(e.dir, 0o700)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(e.dir, fmt.Sprintf("export-%d-*.zip.tmp", export.ID))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // no-op once renamed
+
+	err = WriteArchive(file, archive)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path := filepath.Join(e.dir, fmt.Sprintf("export-%d.zip", export.ID))
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+	expiresAt := time.Now().Add(ExportRetention)
+	export.FilePath, export.SizeBytes, export.ExpiresAt = path, &size, &expiresAt
+	err = e.store.CompleteExport(export)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	e.logger.Printf("Built export %d for user %d (%d bytes)", export.ID, export.UserID, size)
+	return nil
+}
+
+// Reads everything the user owns, checking ctx between the steps
+func (e *Exporter) collect(ctx context.Context, userID int64) (*Archive, error) {
+	user, err := e.userStore.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %d no longer exists", userID)
+	}
+
+	archive := &Archive{ExportedAt: time.Now().UTC(), User: user, Exercises: []exercises.Exercise{}}
+	steps := []func() error{
+		func() (err error) {
+			archive.Preferences, err = e.userStore.GetPreferences(userID)
+			return err
+		},
+		func() (err error) {
+			archive.Workouts, err = e.workoutStore.ListWorkoutsByUser(userID)
+			return err
+		},
+		func() (err error) {
+			archive.Templates, err = e.templateStore.ListTemplates(userID)
+			return err
+		},
+		func() (err error) {
+			archive.Records, err = e.recordStore.ListRecordHistory(userID, 0)
+			return err
+		},
+		func() (err error) {
+			archive.Sessions, err = e.scheduleStore.ListSessions(userID)
+			return err
+		},
+		func() error {
+			catalog, err := e.exerciseStore.ListExercises(userID, "")
+			if err != nil {
+				return err
+			}
+			for _, exercise := range catalog {
+				if !exercise.BuiltIn {
+					archive.Exercises = append(archive.Exercises, exercise)
+				}
+			}
+			return nil
+		},
+	}
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+
+	return archive, nil
+}
+
+// Deletes the archives of expired exports along with their rows
+func (e *Exporter) RemoveExpired() error {
+	expired, err := e.store.ListExpiredExports(time.Now())
+	if err != nil {
+		return err
+	}
+
+	var failed []error
+	for _, export := range expired {
+		err := removeArchive(export.FilePath)
+		if err == nil {
+			err = e.store.DeleteExport(int64(export.ID))
+		}
+		if err != nil {
+			failed = append(failed, fmt.Errorf("removing export %d: %w", export.ID, err))
+		}
+	}
+	return errors.Join(failed...)
+}
+
+func removeArchive(path string) error {
+	if path == "" {
+		return nil
+	}
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package account
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/stretchr/testify/assert"
+)
+
+// Claims every export and remembers what happened to it
+type fakeExportStore struct {
+	AccountStore
+	released []int64
+	failed   []int64
+}
+
+func (fs *fakeExportStore) ClaimExport(id int64, staleAfter time.Duration) (*Export, error) {
+	return &Export{ID: int(id), UserID: 7, Status: StatusRunning}, nil
+}
+
+func (fs *fakeExportStore) ReleaseExport(id int64) error {
+	fs.released = append(fs.released, id)
+	return nil
+}
+
+func (fs *fakeExportStore) FailExport(id int64, reason string) error {
+	fs.failed = append(fs.failed, id)
+	return nil
+}
+
+type fakeUserStore struct {
+	users.UserStore
+}
+
+func (fs fakeUserStore) GetUser(userID int64) (*users.User, error) {
+	return &users.User{ID: int(userID)}, nil
+}
+
+func TestExportCancelled(t *testing.T) {
+	store := &fakeExportStore{}
+	exporter := &Exporter{store: store, userStore: fakeUserStore{}, logger: log.New(io.Discard, "", 0),
+		dir: t.TempDir()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := exporter.Export(ctx, 3)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []int64{3}, store.released, "a shutdown hands the export back for the retry")
+	assert.Empty(t, store.failed)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	err = exporter.Export(ctx, 4)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []int64{4}, store.failed, "an export that timed out is failed rather than retried")
+	assert.Equal(t, []int64{3}, store.released)
+}
@@ -0,0 +1,220 @@
+package account
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/jobs"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type AccountHandler struct {
+	store       AccountStore
+	buildExport jobs.Kind[BuildExportArgs]
+	logger      *log.Logger
+	signingKey  []byte
+}
+
+func NewAccountHandler(store AccountStore, buildExport jobs.Kind[BuildExportArgs], logger *log.Logger,
+	signingKey []byte) *AccountHandler {
+	return &AccountHandler{
+		store:       store,
+		buildExport: buildExport,
+		logger:      logger,
+		signingKey:  signingKey,
+	}
+}
+
+type deleteAccountRequest struct {
+	Mode string `json:"mode"`
+}
+
+// Queues an export of the caller's data, the archive is built in the background
+func (ah *AccountHandler) HandleRequestExport(w http.ResponseWriter, r *http.Request) {
+	user := users.CurrentUser(r)
+
+	// Only a new export gets a job, asking again while one is open returns it as is
+	export, err := ah.store.CreateExport(int64(user.ID), func(tx *sql.Tx, export *Export) error {
+		_, err := ah.buildExport.EnqueueTx(tx, BuildExportArgs{ExportID: int64(export.ID)}, time.Time{})
+		return err
+	})
+	if err != nil {
+		ah.logger.Printf("Error createExport: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to request export"}) // 500
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/v1/users/me/exports/%d", export.ID))
+	utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{"export": export})
+}
+
+func (ah *AccountHandler) HandleListExports(w http.ResponseWriter, r *http.Request) {
+	user := users.CurrentUser(r)
+
+	exports, err := ah.store.ListExports(int64(user.ID))
+	if err != nil {
+		ah.logger.Printf("Error listExports: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	for i := range exports {
+		ah.signExport(&exports[i])
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exports": exports})
+}
+
+// Returns the status of an export and, once it's ready, a fresh signed download link
+func (ah *AccountHandler) HandleGetExport(w http.ResponseWriter, r *http.Request) {
+	exportID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ah.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid export id"}) // 400
+		return
+	}
+
+	export, err := ah.store.GetExport(exportID)
+	if err != nil {
+		ah.logger.Printf("Error getExport: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	// Other users' exports are indistinguishable from missing ones
+	if export == nil || export.UserID != users.CurrentUser(r).ID {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "export not found"}) // 404
+		return
+	}
+
+	ah.signExport(export)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"export": export})
+}
+
+func (ah *AccountHandler) signExport(export *Export) {
+	if export.Status == StatusReady {
+		expires := time.Now().Add(DownloadLinkTTL)
+		if export.ExpiresAt != nil && export.ExpiresAt.Before(expires) {
+			expires = *export.ExpiresAt
+		}
+		export.DownloadURL = DownloadURL(ah.signingKey, int64(export.ID), expires)
+	}
+}
+
+// Serves an export's archive to whoever holds a valid signed link
+func (ah *AccountHandler) HandleDownloadExport(w http.ResponseWriter, r *http.Request) {
+	exportID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ah.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid export id"}) // 400
+		return
+	}
+
+	query := r.URL.Query()
+	err = VerifyDownload(ah.signingKey, exportID, query.Get("expires"), query.Get("signature"), time.Now())
+	if err != nil {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": err.Error()}) // 403
+		return
+	}
+
+	export, err := ah.store.GetExport(exportID)
+	if err != nil {
+		ah.logger.Printf("Error getExport: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if export == nil || export.Status != StatusReady {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "export not found"}) // 404
+		return
+	}
+
+	file, err := os.Open(export.FilePath)
+	if os.IsNotExist(err) {
+		utils.WriteJSON(w, http.StatusGone, utils.Envelope{"error": "export has expired, please request a new one"}) // 410
+		return
+	}
+	if err != nil {
+		ah.logger.Printf("Error opening export %d: %v", export.ID, err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+	defer file.Close()
+
+	filename := fmt.Sprintf("gofems-export-%s.zip", export.CreatedAt.UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, filename, *export.CompletedAt, file)
+}
+
+// Schedules the caller's account for deletion after the grace period. The body is
+// optional, {"mode": "anonymize"} keeps the training history without the identity.
+func (ah *AccountHandler) HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	var req deleteAccountRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		ah.logger.Printf("Error decodingDeleteAccount: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	mode, err := ParseDeletionMode(req.Mode)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	deletion, err := ah.store.ScheduleDeletion(int64(users.CurrentUser(r).ID), mode, time.Now().Add(DeletionGracePeriod))
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "user not found"}) // 404
+		return
+	}
+	if err != nil {
+		ah.logger.Printf("Error scheduleDeletion: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to schedule deletion"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{"deletion": deletion})
+}
+
+func (ah *AccountHandler) HandleGetDeletion(w http.ResponseWriter, r *http.Request) {
+	deletion, err := ah.store.GetDeletion(int64(users.CurrentUser(r).ID))
+	if err != nil {
+		ah.logger.Printf("Error getDeletion: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if deletion == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "no deletion scheduled"}) // 404
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"deletion": deletion})
+}
+
+// Cancels a scheduled deletion while it's still in its grace period
+func (ah *AccountHandler) HandleCancelDeletion(w http.ResponseWriter, r *http.Request) {
+	err := ah.store.CancelDeletion(int64(users.CurrentUser(r).ID))
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "no deletion scheduled"}) // 404
+		return
+	}
+
+	if err != nil {
+		ah.logger.Printf("Error cancelDeletion: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to cancel deletion"}) // 500
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,49 @@
+package account
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/Josesx506/gofems/internal/jobs"
+)
+
+const (
+	// Builds the archive of a requested export
+	BuildExportJob = "account.build_export"
+	// Hourly, removes the archives of expired exports
+	RemoveExpiredExportsJob = "account.remove_expired_exports"
+	// Hourly, carries out the deletions whose grace period is over
+	DeleteDueAccountsJob = "account.delete_due_accounts"
+)
+
+// Archives are built on their own queue so a burst of exports doesn't hold up other jobs
+const ExportQueue = "exports"
+
+type BuildExportArgs struct {
+	ExportID int64 `json:"export_id"`
+}
+
+// RegisterJobs adds the export and deletion jobs and their schedules
+func RegisterJobs(app *app.Application) {
+	store := NewPostgresAccountStore(app.DB)
+	exporter := NewExporter(app.DB, app.Logger, filepath.Join(app.DataDir, "exports"))
+	deleter := NewAccountDeleter(store, app.Logger)
+
+	jobs.Register(app.Jobs, BuildExportJob, jobs.Options{Queue: ExportQueue, MaxAttempts: 3, Timeout: staleExportAfter},
+		func(ctx context.Context, args BuildExportArgs) error {
+			return exporter.Export(ctx, args.ExportID)
+		})
+
+	removeExpired := jobs.Register(app.Jobs, RemoveExpiredExportsJob, jobs.Options{Queue: jobs.MaintenanceQueue},
+		func(ctx context.Context, _ struct{}) error {
+			return exporter.RemoveExpired()
+		})
+	removeExpired.Schedule("remove-expired-exports", "@hourly", struct{}{})
+
+	deleteDue := jobs.Register(app.Jobs, DeleteDueAccountsJob, jobs.Options{Queue: jobs.MaintenanceQueue},
+		func(ctx context.Context, _ struct{}) error {
+			return deleter.DeleteOnce()
+		})
+	deleteDue.Schedule("delete-due-accounts", "@hourly", struct{}{})
+}
@@ -0,0 +1,46 @@
+package account
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/Josesx506/gofems/internal/jobs"
+	"github.com/go-chi/chi/v5"
+)
+
+// Routes of the calling user, mounted at /users/me
+func AccountRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and store
+	r := chi.NewRouter()
+	store := NewPostgresAccountStore(app.DB)
+	handler := NewAccountHandler(store, jobs.KindOf[BuildExportArgs](app.Jobs, BuildExportJob), app.Logger,
+		app.SigningKey)
+
+	r.Use(users.RequireUser(users.NewPostgresUserStore(app.DB)))
+
+	// Define subroutes
+	r.Delete("/", handler.HandleDeleteAccount)
+	r.Post("/export", handler.HandleRequestExport)
+	r.Get("/exports", handler.HandleListExports)
+	r.Get("/exports/{id}", handler.HandleGetExport)
+	r.Get("/deletion", handler.HandleGetDeletion)
+	r.Delete("/deletion", handler.HandleCancelDeletion)
+
+	return r
+}
+
+// Signed export downloads, mounted at /exports. They are authorized by the link
+// rather than the caller, so they can be opened straight from a browser.
+func DownloadRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and store
+	r := chi.NewRouter()
+	store := NewPostgresAccountStore(app.DB)
+	handler := NewAccountHandler(store, jobs.KindOf[BuildExportArgs](app.Jobs, BuildExportJob), app.Logger,
+		app.SigningKey)
+
+	// Define subroutes
+	r.Get("/{id}/download", handler.HandleDownloadExport)
+
+	return r
+}
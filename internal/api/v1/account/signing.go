@@ -0,0 +1,44 @@
+package account
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Download links carry their expiry and an HMAC of it with the export id, so they
+// work without identifying the user and can't be altered to reach another export.
+
+func signDownload(key []byte, exportID int64, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "export:%d:%d", exportID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DownloadURL returns the path of a signed link to an export's archive valid until expires
+func DownloadURL(key []byte, exportID int64, expires time.Time) string {
+	return fmt.Sprintf("/v1/exports/%d/download?expires=%d&signature=%s", exportID, expires.Unix(),
+		signDownload(key, exportID, expires.Unix()))
+}
+
+// VerifyDownload checks the expires and signature query values of a download link
+func VerifyDownload(key []byte, exportID int64, expires, signature string, now time.Time) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > expiresAt {
+		return ErrInvalidSignature
+	}
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	expected, _ := hex.DecodeString(signDownload(key, exportID, expiresAt))
+	if !hmac.Equal(given, expected) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
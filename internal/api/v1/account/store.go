@@ -0,0 +1,345 @@
+package account
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DB connector struct
+type PostgresAccountStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAccountStore(db *sql.DB) *PostgresAccountStore {
+	return &PostgresAccountStore{db: db}
+}
+
+type AccountStore interface {
+	CreateExport(userID int64, enqueue func(tx *sql.Tx, export *Export) error) (*Export, error)
+	GetExport(id int64) (*Export, error)
+	ListExports(userID int64) ([]Export, error)
+	ClaimExport(id int64, staleAfter time.Duration) (*Export, error)
+	CompleteExport(export *Export) error
+	ReleaseExport(id int64) error
+	FailExport(id int64, reason string) error
+	ListExpiredExports(now time.Time) ([]Export, error)
+	DeleteExport(id int64) error
+	ScheduleDeletion(userID int64, mode string, at time.Time) (*Deletion, error)
+	GetDeletion(userID int64) (*Deletion, error)
+	CancelDeletion(userID int64) error
+	ListDueDeletions(now time.Time) ([]Deletion, error)
+	DeleteAccount(userID int64) error
+	AnonymizeAccount(userID int64) error
+}
+
+const exportColumns = `id, user_id, status, size_bytes, COALESCE(error, ''), created_at, completed_at, expires_at,
+	COALESCE(file_path, '')`
+
+func scanExport(row interface{ Scan(...any) error }) (*Export, error) {
+	export := &Export{}
+	err := row.Scan(&export.ID, &export.UserID, &export.Status, &export.SizeBytes, &export.Error, &export.CreatedAt,
+		&export.CompletedAt, &export.ExpiresAt, &export.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// Queues an export, or returns the one already queued or running for the user. A new
+// export is handed to enqueue in the same transaction, so it exists exactly when the
+// job that builds it does.
+func (pgStore *PostgresAccountStore) CreateExport(userID int64, enqueue func(tx *sql.Tx, export *Export) error) (*Export, error) {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // rollback transaction if not committed
+
+	// Requests of the same user wait for each other, so only one creates the export
+	_, err = tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR NO KEY UPDATE`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	SELECT ` + exportColumns + `
+	FROM account_exports
+	WHERE user_id = $1 AND status IN ('pending', 'running')
+	ORDER BY id
+	LIMIT 1
+	`
+	export, err := scanExport(tx.QueryRow(query, userID))
+	if err == nil {
+		return export, tx.Commit()
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	query = `INSERT INTO account_exports (user_id) VALUES ($1) RETURNING ` + exportColumns
+	export, err = scanExport(tx.QueryRow(query, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = enqueue(tx, export)
+	if err != nil {
+		return nil, err
+	}
+
+	return export, tx.Commit()
+}
+
+// Returns nil when the export doesn't exist
+func (pgStore *PostgresAccountStore) GetExport(id int64) (*Export, error) {
+	query := `SELECT ` + exportColumns + ` FROM account_exports WHERE id = $1`
+	export, err := scanExport(pgStore.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil // No export found
+	}
+	return export, err
+}
+
+// Lists the exports of a user, newest first
+func (pgStore *PostgresAccountStore) ListExports(userID int64) ([]Export, error) {
+	query := `SELECT ` + exportColumns + ` FROM account_exports WHERE user_id = $1 ORDER BY id DESC`
+	return pgStore.queryExports(query, userID)
+}
+
+// Marks a pending export as running and returns it, nil when there is nothing to do.
+// Exports left running for longer than staleAfter are taken to have been abandoned by a crashed
+// worker and are claimed again.
+func (pgStore *PostgresAccountStore) ClaimExport(id int64, staleAfter time.Duration) (*Export, error) {
+	query := `
+	UPDATE account_exports
+	SET status = 'running', started_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND (status = 'pending' OR (status = 'running' AND started_at < $2))
+	RETURNING ` + exportColumns
+	export, err := scanExport(pgStore.db.QueryRow(query, id, time.Now().Add(-staleAfter)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return export, err
+}
+
+// Stores the archive of a running export and marks it ready
+func (pgStore *PostgresAccountStore) CompleteExport(export *Export) error {
+	query := `
+	UPDATE account_exports
+	SET status = 'ready', file_path = $2, size_bytes = $3, error = NULL, completed_at = CURRENT_TIMESTAMP,
+		expires_at = $4
+	WHERE id = $1
+	RETURNING status, completed_at
+	`
+	return pgStore.db.QueryRow(query, export.ID, export.FilePath, export.SizeBytes, export.ExpiresAt).
+		Scan(&export.Status, &export.CompletedAt)
+}
+
+// Puts a running export back in the queue, for a worker that stopped before building it
+func (pgStore *PostgresAccountStore) ReleaseExport(id int64) error {
+	query := `
+	UPDATE account_exports
+	SET status = 'pending', started_at = NULL
+	WHERE id = $1 AND status = 'running'
+	`
+	_, err := pgStore.db.Exec(query, id)
+	return err
+}
+
+func (pgStore *PostgresAccountStore) FailExport(id int64, reason string) error {
+	query := `
+	UPDATE account_exports
+	SET status = 'failed', error = $2, completed_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	`
+	_, err := pgStore.db.Exec(query, id, reason)
+	return err
+}
+
+// Lists ready exports whose archive has outlived its retention
+func (pgStore *PostgresAccountStore) ListExpiredExports(now time.Time) ([]Export, error) {
+	query := `SELECT ` + exportColumns + ` FROM account_exports WHERE status = 'ready' AND expires_at <= $1`
+	return pgStore.queryExports(query, now)
+}
+
+func (pgStore *PostgresAccountStore) DeleteExport(id int64) error {
+	_, err := pgStore.db.Exec(`DELETE FROM account_exports WHERE id = $1`, id)
+	return err
+}
+
+func (pgStore *PostgresAccountStore) queryExports(query string, args ...any) ([]Export, error) {
+	rows, err := pgStore.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []Export{}
+	for rows.Next() {
+		export, err := scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, *export)
+	}
+
+	return exports, rows.Err()
+}
+
+// Schedules the deletion of an account for at. Asking again only changes the mode,
+// the grace period keeps running from the first request. Returns sql.ErrNoRows for
+// unknown or already anonymized users.
+func (pgStore *PostgresAccountStore) ScheduleDeletion(userID int64, mode string, at time.Time) (*Deletion, error) {
+	deletion := &Deletion{}
+	query := `
+	UPDATE users
+	SET deletion_mode = $2,
+		deletion_requested_at = COALESCE(deletion_requested_at, CURRENT_TIMESTAMP),
+		deletion_scheduled_for = COALESCE(deletion_scheduled_for, $3)
+	WHERE id = $1 AND anonymized_at IS NULL
+	RETURNING id, deletion_mode, deletion_requested_at, deletion_scheduled_for
+	`
+	err := pgStore.db.QueryRow(query, userID, mode, at).Scan(&deletion.UserID, &deletion.Mode,
+		&deletion.RequestedAt, &deletion.ScheduledFor)
+	if err != nil {
+		return nil, err
+	}
+	return deletion, nil
+}
+
+// Returns nil when no deletion is scheduled
+func (pgStore *PostgresAccountStore) GetDeletion(userID int64) (*Deletion, error) {
+	deletion := &Deletion{}
+	query := `
+	SELECT id, deletion_mode, deletion_requested_at, deletion_scheduled_for
+	FROM users
+	WHERE id = $1 AND deletion_scheduled_for IS NOT NULL
+	`
+	err := pgStore.db.QueryRow(query, userID).Scan(&deletion.UserID, &deletion.Mode, &deletion.RequestedAt,
+		&deletion.ScheduledFor)
+	if err == sql.ErrNoRows {
+		return nil, nil // No deletion scheduled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return deletion, nil
+}
+
+// Returns sql.ErrNoRows when no deletion is scheduled
+func (pgStore *PostgresAccountStore) CancelDeletion(userID int64) error {
+	query := `
+	UPDATE users
+	SET deletion_mode = NULL, deletion_requested_at = NULL, deletion_scheduled_for = NULL
+	WHERE id = $1 AND deletion_scheduled_for IS NOT NULL
+	`
+	result, err := pgStore.db.Exec(query, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Lists the deletions whose grace period is over
+func (pgStore *PostgresAccountStore) ListDueDeletions(now time.Time) ([]Deletion, error) {
+	query := `
+	SELECT id, deletion_mode, deletion_requested_at, deletion_scheduled_for
+	FROM users
+	WHERE deletion_scheduled_for <= $1
+	ORDER BY deletion_scheduled_for, id
+	`
+	rows, err := pgStore.db.Query(query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deletions := []Deletion{}
+	for rows.Next() {
+		var deletion Deletion
+		err := rows.Scan(&deletion.UserID, &deletion.Mode, &deletion.RequestedAt, &deletion.ScheduledFor)
+		if err != nil {
+			return nil, err
+		}
+		deletions = append(deletions, deletion)
+	}
+
+	return deletions, rows.Err()
+}
+
+// Removes the user, the foreign keys cascade to every row they own. Program days
+// restrict deleting their template, so the programs the user coached and the days of
+// other programs built on their templates go first.
+func (pgStore *PostgresAccountStore) DeleteAccount(userID int64) error {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // rollback transaction if not committed
+
+	statements := []string{
+		`DELETE FROM programs WHERE user_id = $1`,
+		`DELETE FROM program_days
+		WHERE template_id IN (SELECT id FROM workout_templates WHERE user_id = $1)`,
+		`DELETE FROM users WHERE id = $1`,
+	}
+	for _, statement := range statements {
+		_, err = tx.Exec(statement, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Scrubs everything that identifies the user and keeps their training history as
+// anonymous numbers: credentials and profile are replaced, free text is cleared and
+// revisions, GPS tracks, exports, calendar feeds, events, webhooks and live sessions
+// are dropped.
+func (pgStore *PostgresAccountStore) AnonymizeAccount(userID int64) error {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // rollback transaction if not committed
+
+	statements := []string{
+		`UPDATE users
+		SET username = 'deleted-' || id, email = 'deleted-' || id || '@deleted.invalid',
+			password_hash = 'deleted-' || id, bio = NULL, updated_at = CURRENT_TIMESTAMP,
+			anonymized_at = CURRENT_TIMESTAMP,
+			deletion_mode = NULL, deletion_requested_at = NULL, deletion_scheduled_for = NULL
+		WHERE id = $1`,
+		`UPDATE workouts SET description = '', slug = NULL WHERE user_id = $1`,
+		`UPDATE workout_entries SET notes = ''
+		WHERE workout_id IN (SELECT id FROM workouts WHERE user_id = $1)`,
+		`DELETE FROM workout_revisions WHERE workout_id IN (SELECT id FROM workouts WHERE user_id = $1)`,
+		`DELETE FROM workout_track_points WHERE workout_id IN (SELECT id FROM workouts WHERE user_id = $1)`,
+		`UPDATE workout_templates SET description = '' WHERE user_id = $1`,
+		`UPDATE planned_sessions SET notes = '' WHERE user_id = $1`,
+		`UPDATE template_entries SET notes = ''
+		WHERE template_id IN (SELECT id FROM workout_templates WHERE user_id = $1)`,
+		`DELETE FROM account_exports WHERE user_id = $1`,
+		`DELETE FROM calendar_tokens WHERE user_id = $1`,
+		`DELETE FROM outbox_events WHERE user_id = $1`,
+		`DELETE FROM webhook_subscriptions WHERE user_id = $1`,
+		`DELETE FROM live_sessions WHERE user_id = $1`,
+	}
+	for _, statement := range statements {
+		_, err = tx.Exec(statement, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
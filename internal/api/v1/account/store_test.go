@@ -0,0 +1,167 @@
+package account
+
+import (
+	"database/sql"
+	"io"
+	"log"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/store"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportQueue(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresAccountStore(db)
+
+	var userID int64
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('lifter', 'lifter@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	// A failing enqueue takes the export with it
+	_, err := pgStore.CreateExport(userID, func(tx *sql.Tx, export *Export) error { return sql.ErrConnDone })
+	assert.Equal(t, sql.ErrConnDone, err)
+	exports, err := pgStore.ListExports(userID)
+	require.NoError(t, err)
+	assert.Empty(t, exports)
+
+	queued := []int{}
+	enqueue := func(tx *sql.Tx, export *Export) error {
+		queued = append(queued, export.ID)
+		return nil
+	}
+	export, err := pgStore.CreateExport(userID, enqueue)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, export.Status)
+
+	again, err := pgStore.CreateExport(userID, enqueue)
+	require.NoError(t, err)
+	assert.Equal(t, export.ID, again.ID, "an open export is reused")
+	assert.Equal(t, []int{export.ID}, queued, "and isn't queued twice")
+
+	claimed, err := pgStore.ClaimExport(int64(export.ID), time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, export.ID, claimed.ID)
+	assert.Equal(t, StatusRunning, claimed.Status)
+
+	none, err := pgStore.ClaimExport(int64(export.ID), time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, none, "running exports aren't claimed twice")
+
+	stale, err := pgStore.ClaimExport(int64(export.ID), 0)
+	require.NoError(t, err)
+	require.NotNil(t, stale, "exports of a dead worker are claimed again")
+
+	require.NoError(t, pgStore.ReleaseExport(int64(export.ID)))
+	released, err := pgStore.ClaimExport(int64(export.ID), time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, released, "released exports are claimed by the next worker")
+
+	size, expiresAt := int64(42), time.Now().Add(-time.Minute)
+	claimed.FilePath, claimed.SizeBytes, claimed.ExpiresAt = "/tmp/export.zip", &size, &expiresAt
+	require.NoError(t, pgStore.CompleteExport(claimed))
+	assert.Equal(t, StatusReady, claimed.Status)
+
+	ready, err := pgStore.GetExport(int64(export.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/export.zip", ready.FilePath)
+
+	expired, err := pgStore.ListExpiredExports(time.Now())
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+
+	next, err := pgStore.CreateExport(userID, enqueue)
+	require.NoError(t, err)
+	assert.NotEqual(t, export.ID, next.ID, "a new export once the last one finished")
+}
+
+func TestAccountDeletion(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresAccountStore(db)
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+
+	newUser := func(name string) int64 {
+		var userID int64
+		require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash, bio)
+			VALUES ($1, $1 || '@example.com', $1 || '-hash', 'about me') RETURNING id`, name).Scan(&userID))
+
+		seconds := 60
+		_, err := workoutStore.CreateWorkout(&workouts.Workout{UserID: int(userID), Title: name + " workout",
+			Description: "felt tired", Entries: []workouts.WorkoutEntry{
+				{ExerciseName: "Plank", Sets: 1, DurationSeconds: &seconds, Notes: "at home"},
+			}})
+		require.NoError(t, err)
+		return userID
+	}
+	anonymized, cascaded := newUser("anon"), newUser("gone")
+
+	// A program built on the user's template doesn't hold up the deletion
+	var templateID, programID int64
+	require.NoError(t, db.QueryRow(`INSERT INTO workout_templates (user_id, name) VALUES ($1, 'Push')
+		RETURNING id`, cascaded).Scan(&templateID))
+	require.NoError(t, db.QueryRow(`INSERT INTO programs (user_id, name) VALUES ($1, 'Strength')
+		RETURNING id`, cascaded).Scan(&programID))
+	_, err := db.Exec(`INSERT INTO program_days (program_id, week_number, day_number, template_id)
+		VALUES ($1, 1, 1, $2)`, programID, templateID)
+	require.NoError(t, err)
+
+	// Scheduling and cancelling
+	deletion, err := pgStore.ScheduleDeletion(anonymized, DeleteCascade, time.Now().Add(DeletionGracePeriod))
+	require.NoError(t, err)
+	again, err := pgStore.ScheduleDeletion(anonymized, DeleteAnonymize, time.Now().Add(2*DeletionGracePeriod))
+	require.NoError(t, err)
+	assert.Equal(t, DeleteAnonymize, again.Mode)
+	assert.WithinDuration(t, deletion.ScheduledFor, again.ScheduledFor, time.Millisecond, "the grace period isn't reset")
+
+	require.NoError(t, pgStore.CancelDeletion(anonymized))
+	assert.Equal(t, sql.ErrNoRows, pgStore.CancelDeletion(anonymized))
+	missing, err := pgStore.GetDeletion(anonymized)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	// Only deletions past their grace period are due
+	_, err = pgStore.ScheduleDeletion(anonymized, DeleteAnonymize, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	_, err = pgStore.ScheduleDeletion(cascaded, DeleteCascade, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	kept := newUser("kept")
+	_, err = pgStore.ScheduleDeletion(kept, DeleteCascade, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	due, err := pgStore.ListDueDeletions(time.Now())
+	require.NoError(t, err)
+	assert.Len(t, due, 2)
+
+	require.NoError(t, NewAccountDeleter(pgStore, log.New(io.Discard, "", 0)).DeleteOnce())
+
+	var username, description, notes string
+	var bio sql.NullString
+	require.NoError(t, db.QueryRow(`SELECT username, bio FROM users WHERE id = $1`, anonymized).Scan(&username, &bio))
+	assert.Equal(t, "deleted-"+strconv.FormatInt(anonymized, 10), username)
+	assert.False(t, bio.Valid)
+	require.NoError(t, db.QueryRow(`SELECT w.description, e.notes FROM workouts w
+		JOIN workout_entries e ON e.workout_id = w.id WHERE w.user_id = $1`, anonymized).Scan(&description, &notes))
+	assert.Empty(t, description)
+	assert.Empty(t, notes)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM workouts WHERE user_id = $1`, cascaded).Scan(&count))
+	assert.Zero(t, count, "cascaded accounts take their workouts with them")
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM programs WHERE id = $1`, programID).Scan(&count))
+	assert.Zero(t, count, "and the programs they coached")
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM users WHERE id = $1`, kept).Scan(&count))
+	assert.Equal(t, 1, count, "accounts in their grace period are kept")
+
+	_, err = pgStore.ScheduleDeletion(anonymized, DeleteCascade, time.Now())
+	assert.Equal(t, sql.ErrNoRows, err, "anonymized accounts are gone")
+}
@@ -0,0 +1,174 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Josesx506/gofems/internal/jobs"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+const (
+	defaultJobLimit = 50
+	maxJobLimit     = 200
+)
+
+type AdminHandler struct {
+	jobStore jobs.JobStore
+	queue    *jobs.Queue
+	logger   *log.Logger
+}
+
+func NewAdminHandler(jobStore jobs.JobStore, queue *jobs.Queue, logger *log.Logger) *AdminHandler {
+	return &AdminHandler{
+		jobStore: jobStore,
+		queue:    queue,
+		logger:   logger,
+	}
+}
+
+// The counts of a queue along with what the instance that answered runs of it
+type queueStatus struct {
+	jobs.QueueStats
+	Workers int      `json:"workers"`
+	Kinds   []string `json:"kinds"`
+}
+
+// Lists jobs newest first, narrowed by ?queue=, ?kind= and ?status=. Older pages
+// are read with ?before_id= set to the last id of the previous one.
+func (ah *AdminHandler) HandleListJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := jobs.JobFilter{
+		Queue:  query.Get("queue"),
+		Kind:   query.Get("kind"),
+		Status: query.Get("status"),
+		Limit:  defaultJobLimit,
+	}
+
+	switch filter.Status {
+	case "", jobs.StatusPending, jobs.StatusRunning, jobs.StatusSucceeded, jobs.StatusDead:
+	default:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid status"}) // 400
+		return
+	}
+
+	if param := query.Get("before_id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil || id <= 0 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid before_id"}) // 400
+			return
+		}
+		filter.BeforeID = id
+	}
+
+	if param := query.Get("limit"); param != "" {
+		l, err := strconv.Atoi(param)
+		if err != nil || l <= 0 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid limit"}) // 400
+			return
+		}
+		filter.Limit = min(l, maxJobLimit)
+	}
+
+	list, err := ah.jobStore.ListJobs(filter)
+	if err != nil {
+		ah.logger.Printf("Error listJobs: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"jobs": list})
+}
+
+// Job counts per queue, the workers and kinds of each, and the cron schedules
+func (ah *AdminHandler) HandleGetJobStatus(w http.ResponseWriter, r *http.Request) {
+	stats, err := ah.jobStore.QueueStats()
+	if err != nil {
+		ah.logger.Printf("Error queueStats: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	schedules, err := ah.jobStore.ListSchedules()
+	if err != nil {
+		ah.logger.Printf("Error listSchedules: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	// Queues without jobs yet are listed too, and queues only other instances run
+	byName := map[string]*queueStatus{}
+	for _, s := range stats {
+		byName[s.Queue] = &queueStatus{QueueStats: s, Kinds: []string{}}
+	}
+	for _, info := range ah.queue.Queues() {
+		status, ok := byName[info.Name]
+		if !ok {
+			status = &queueStatus{QueueStats: jobs.QueueStats{Queue: info.Name}}
+			byName[info.Name] = status
+		}
+		status.Workers = info.Workers
+		status.Kinds = info.Kinds
+	}
+
+	queues := make([]queueStatus, 0, len(byName))
+	for _, status := range byName {
+		queues = append(queues, *status)
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].Queue < queues[j].Queue })
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"queues": queues, "schedules": schedules})
+}
+
+func (ah *AdminHandler) HandleGetJobByID(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid job id"}) // 400
+		return
+	}
+
+	job, err := ah.jobStore.GetJob(id)
+	if err != nil {
+		ah.logger.Printf("Error getJob: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+	if job == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "job not found"}) // 404
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"job": job})
+}
+
+// Requeues a dead job with a fresh set of attempts
+func (ah *AdminHandler) HandleRetryJob(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid job id"}) // 400
+		return
+	}
+
+	err = ah.jobStore.RetryJob(id)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "dead job not found"}) // 404
+		return
+	}
+	if err != nil {
+		ah.logger.Printf("Error retryJob: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	job, err := ah.jobStore.GetJob(id)
+	if err != nil {
+		ah.logger.Printf("Error getJob: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{"job": job})
+}
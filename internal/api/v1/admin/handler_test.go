@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/jobs"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeJobs struct {
+	jobs.JobStore
+	filter jobs.JobFilter
+}
+
+func (fj *fakeJobs) ListJobs(filter jobs.JobFilter) ([]jobs.Job, error) {
+	fj.filter = filter
+	return []jobs.Job{}, nil
+}
+
+func (fj *fakeJobs) QueueStats() ([]jobs.QueueStats, error) {
+	return []jobs.QueueStats{{Queue: "default", Due: 3, Dead: 1}}, nil
+}
+
+func (fj *fakeJobs) ListSchedules() ([]jobs.Schedule, error) {
+	return []jobs.Schedule{}, nil
+}
+
+func newTestRouter(token string) (http.Handler, *fakeJobs) {
+	logger := log.New(io.Discard, "", 0)
+	queue := jobs.NewQueue(nil, logger, time.Second)
+	jobs.Register(queue, "account.build_export", jobs.Options{Queue: "exports"},
+		func(ctx context.Context, _ struct{}) error { return nil })
+	queue.SetWorkers("exports", 2)
+
+	store := &fakeJobs{}
+	handler := NewAdminHandler(store, queue, logger)
+	r := chi.NewRouter()
+	r.Use(RequireAdmin(token))
+	r.Get("/jobs", handler.HandleListJobs)
+	r.Get("/jobs/status", handler.HandleGetJobStatus)
+	return r, store
+}
+
+func get(router http.Handler, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequireAdmin(t *testing.T) {
+	closed, _ := newTestRouter("")
+	assert.Equal(t, http.StatusForbidden, get(closed, "/jobs", "").Code, "closed without a token")
+	assert.Equal(t, http.StatusForbidden, get(closed, "/jobs", "anything").Code)
+
+	router, _ := newTestRouter("s3cret")
+	assert.Equal(t, http.StatusUnauthorized, get(router, "/jobs", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(router, "/jobs", "guess").Code)
+	assert.Equal(t, http.StatusOK, get(router, "/jobs", "s3cret").Code)
+}
+
+func TestListJobs(t *testing.T) {
+	router, store := newTestRouter("s3cret")
+
+	rec := get(router, "/jobs?queue=exports&status=dead&before_id=40&limit=1000", "s3cret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, jobs.JobFilter{Queue: "exports", Status: jobs.StatusDead, BeforeID: 40, Limit: maxJobLimit},
+		store.filter)
+
+	assert.Equal(t, http.StatusBadRequest, get(router, "/jobs?status=stuck", "s3cret").Code)
+	assert.Equal(t, http.StatusBadRequest, get(router, "/jobs?limit=0", "s3cret").Code)
+	assert.Equal(t, http.StatusBadRequest, get(router, "/jobs?before_id=x", "s3cret").Code)
+}
+
+func TestJobStatus(t *testing.T) {
+	router, _ := newTestRouter("s3cret")
+
+	rec := get(router, "/jobs/status", "s3cret")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Queues []queueStatus `json:"queues"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	require.Len(t, body.Queues, 3)
+
+	assert.Equal(t, "default", body.Queues[0].Queue)
+	assert.Equal(t, 3, body.Queues[0].Due)
+	assert.Zero(t, body.Queues[0].Workers, "only other instances run it")
+
+	assert.Equal(t, "exports", body.Queues[1].Queue)
+	assert.Equal(t, 2, body.Queues[1].Workers)
+	assert.Equal(t, []string{"account.build_export"}, body.Queues[1].Kinds)
+	assert.Zero(t, body.Queues[1].Due, "listed before it has jobs")
+
+	assert.Equal(t, jobs.MaintenanceQueue, body.Queues[2].Queue)
+}
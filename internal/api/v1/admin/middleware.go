@@ -0,0 +1,30 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+// RequireAdmin lets through requests bearing the operator's ADMIN_TOKEN. The admin
+// routes are closed altogether when no token is configured.
+func RequireAdmin(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "admin endpoints are disabled"}) // 403
+				return
+			}
+
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "missing or invalid admin token"}) // 401
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
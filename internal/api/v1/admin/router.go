@@ -0,0 +1,26 @@
+package admin
+
+import (
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/Josesx506/gofems/internal/jobs"
+	"github.com/go-chi/chi/v5"
+)
+
+// Operator routes, mounted at /admin
+func AdminRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and store
+	r := chi.NewRouter()
+	store := jobs.NewPostgresJobStore(app.DB)
+	handler := NewAdminHandler(store, app.Jobs, app.Logger)
+
+	r.Use(RequireAdmin(app.AdminToken))
+
+	// Define subroutes
+	r.Get("/jobs", handler.HandleListJobs)
+	r.Get("/jobs/status", handler.HandleGetJobStatus)
+	r.Get("/jobs/{id}", handler.HandleGetJobByID)
+	r.Post("/jobs/{id}/retry", handler.HandleRetryJob)
+
+	return r
+}
@@ -0,0 +1,110 @@
+package analytics
+
+import (
+	"errors"
+	"time"
+)
+
+// Bucket widths, periods are UTC and weeks start on Monday
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+const dateLayout = "2006-01-02"
+
+// Day buckets are capped so a single request can't generate an unbounded series
+const maxDayBuckets = 366
+
+var ErrInvalidRange = errors.New("from must be before to")
+
+// The slice of training history a report covers
+type Query struct {
+	UserID int64     // 0 selects workouts logged without a user
+	Bucket string    // day, week or month
+	From   time.Time // inclusive
+	To     time.Time // exclusive
+}
+
+func (q Query) Validate() error {
+	switch q.Bucket {
+	case BucketDay, BucketWeek, BucketMonth:
+	default:
+		return errors.New("bucket must be day, week or month")
+	}
+
+	if !q.From.Before(q.To) {
+		return ErrInvalidRange
+	}
+
+	if q.Bucket == BucketDay && q.To.Sub(q.From) > maxDayBuckets*24*time.Hour {
+		return errors.New("day buckets are limited to a one year range")
+	}
+
+	return nil
+}
+
+// Session totals of one period. Volume is sets x reps x weight over weighted entries.
+type Period struct {
+	PeriodStart      time.Time `json:"period_start"`
+	Sessions         int       `json:"sessions"`
+	DurationMinutes  int       `json:"duration_minutes"`
+	CaloriesBurned   int       `json:"calories_burned"`
+	Sets             int       `json:"sets"`
+	Volume           float64   `json:"volume"`
+	CumulativeVolume float64   `json:"cumulative_volume"` // running total over the range
+}
+
+// Working sets that hit a muscle group as a primary mover in one period
+type MuscleGroupSets struct {
+	PeriodStart time.Time `json:"period_start"`
+	MuscleGroup string    `json:"muscle_group"`
+	Sets        int       `json:"sets"`
+}
+
+// Drill-down of one exercise in one period
+type ExercisePeriod struct {
+	PeriodStart      time.Time `json:"period_start"`
+	Sessions         int       `json:"sessions"`
+	Sets             int       `json:"sets"`
+	Reps             int       `json:"reps"`
+	Volume           float64   `json:"volume"`
+	CumulativeVolume float64   `json:"cumulative_volume"`
+	MaxWeight        *float64  `json:"max_weight"`    // nil for periods without weighted sets
+	Estimated1RM     *float64  `json:"estimated_1rm"` // best Epley estimate of the period
+}
+
+// Distance covered in one period, in the unit the report was requested in
+type DistancePeriod struct {
+	PeriodStart     time.Time `json:"period_start"`
+	Sessions        int       `json:"sessions"` // workouts with at least one distance logged
+	Distance        float64   `json:"distance"`
+	DurationSeconds int       `json:"duration_seconds"` // time spent on the entries that logged distance
+}
+
+// Standard race distances best efforts are reported for
+var EffortDistances = []struct {
+	Name   string
+	Meters float64
+}{
+	{"1k", 1000},
+	{"5k", 5000},
+	{"10k", 10000},
+	{"half_marathon", 21097.5},
+	{"marathon", 42195},
+}
+
+// Fastest time over a standard distance for one exercise. Entries at least as long as
+// the distance count, with their time scaled to the distance at the entry's average pace.
+type BestEffort struct {
+	ExerciseID       *int      `json:"exercise_id"`
+	ExerciseName     string    `json:"exercise_name"`
+	Effort           string    `json:"effort"`
+	DistanceMeters   float64   `json:"distance_meters"`
+	Seconds          float64   `json:"seconds"`
+	PaceSecondsPerKm float64   `json:"pace_seconds_per_km"`
+	WorkoutID        int       `json:"workout_id"`
+	EntryID          int       `json:"entry_id"`
+	AchievedAt       time.Time `json:"achieved_at"`
+}
@@ -0,0 +1,216 @@
+package analytics
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/units"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type AnalyticsHandler struct {
+	store     AnalyticsStore
+	userStore users.UserStore
+	logger    *log.Logger
+}
+
+func NewAnalyticsHandler(store AnalyticsStore, userStore users.UserStore, logger *log.Logger) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		store:     store,
+		userStore: userStore,
+		logger:    logger,
+	}
+}
+
+// Session totals per ?bucket= for ?user_id= between ?from= and ?to=.
+// ?rollup=true reads the materialized daily rollups, faster but refreshed periodically.
+func (ah *AnalyticsHandler) HandleSummary(w http.ResponseWriter, r *http.Request) {
+	q, ok := ah.readQuery(w, r)
+	if !ok {
+		return
+	}
+
+	fromRollups := r.URL.Query().Get("rollup") == "true"
+
+	unit, ok := users.ReadWeightUnit(w, r, ah.userStore, q.UserID, ah.logger)
+	if !ok {
+		return
+	}
+
+	series, err := ah.store.Summary(q, fromRollups)
+	if err != nil {
+		ah.logger.Printf("Error summary: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	for i := range series {
+		series[i].Volume = units.FromKilograms(series[i].Volume, unit)
+		series[i].CumulativeVolume = units.FromKilograms(series[i].CumulativeVolume, unit)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"bucket": q.Bucket, "weight_unit": unit, "series": series})
+}
+
+func (ah *AnalyticsHandler) HandleMuscleGroups(w http.ResponseWriter, r *http.Request) {
+	q, ok := ah.readQuery(w, r)
+	if !ok {
+		return
+	}
+
+	groups, err := ah.store.MuscleGroups(q)
+	if err != nil {
+		ah.logger.Printf("Error muscleGroups: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"bucket": q.Bucket, "muscle_groups": groups})
+}
+
+func (ah *AnalyticsHandler) HandleExerciseProgress(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.ReadInt64Param(r, "exerciseID")
+	if err != nil {
+		ah.logger.Printf("Error reading exerciseID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	q, ok := ah.readQuery(w, r)
+	if !ok {
+		return
+	}
+
+	unit, ok := users.ReadWeightUnit(w, r, ah.userStore, q.UserID, ah.logger)
+	if !ok {
+		return
+	}
+
+	series, err := ah.store.ExerciseProgress(q, exerciseID)
+	if err != nil {
+		ah.logger.Printf("Error exerciseProgress: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	for i := range series {
+		series[i].Volume = units.FromKilograms(series[i].Volume, unit)
+		series[i].CumulativeVolume = units.FromKilograms(series[i].CumulativeVolume, unit)
+		units.ConvertWeight(series[i].MaxWeight, unit)
+		units.ConvertWeight(series[i].Estimated1RM, unit)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"bucket":      q.Bucket,
+		"exercise_id": exerciseID,
+		"weight_unit": unit,
+		"series":      series,
+	})
+}
+
+// Distance per period, in ?unit= (km by default), e.g. weekly mileage with ?bucket=week&unit=mi
+func (ah *AnalyticsHandler) HandleDistance(w http.ResponseWriter, r *http.Request) {
+	unit, err := units.ParseDistanceUnit(r.URL.Query().Get("unit"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	q, ok := ah.readQuery(w, r)
+	if !ok {
+		return
+	}
+
+	series, err := ah.store.Distance(q)
+	if err != nil {
+		ah.logger.Printf("Error distance: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	for i := range series {
+		series[i].Distance = math.Round(units.FromMeters(series[i].Distance, unit)*100) / 100
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"bucket": q.Bucket, "unit": unit, "series": series})
+}
+
+// Fastest 1k, 5k, 10k, half marathon and marathon of ?user_id= per exercise
+func (ah *AnalyticsHandler) HandleBestEfforts(w http.ResponseWriter, r *http.Request) {
+	var userID int64
+	if param := r.URL.Query().Get("user_id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user id"}) // 400
+			return
+		}
+		userID = id
+	}
+
+	efforts, err := ah.store.BestEfforts(userID)
+	if err != nil {
+		ah.logger.Printf("Error bestEfforts: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"best_efforts": efforts})
+}
+
+// Reads ?user_id=, ?bucket= (week by default) and the inclusive ?from= and ?to= dates.
+// Without dates the range ends today and covers 30 days, 12 weeks or 12 months.
+func (ah *AnalyticsHandler) readQuery(w http.ResponseWriter, r *http.Request) (Query, bool) {
+	params := r.URL.Query()
+	q := Query{Bucket: params.Get("bucket")}
+	if q.Bucket == "" {
+		q.Bucket = BucketWeek
+	}
+
+	if param := params.Get("user_id"); param != "" {
+		userID, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user id"}) // 400
+			return q, false
+		}
+		q.UserID = userID
+	}
+
+	q.To = time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if param := params.Get("to"); param != "" {
+		to, err := time.Parse(dateLayout, param)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "to must be a YYYY-MM-DD date"}) // 400
+			return q, false
+		}
+		q.To = to.AddDate(0, 0, 1)
+	}
+
+	switch q.Bucket {
+	case BucketDay:
+		q.From = q.To.AddDate(0, 0, -30)
+	case BucketMonth:
+		q.From = q.To.AddDate(0, -12, 0)
+	default:
+		q.From = q.To.AddDate(0, 0, -12*7)
+	}
+	if param := params.Get("from"); param != "" {
+		from, err := time.Parse(dateLayout, param)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "from must be a YYYY-MM-DD date"}) // 400
+			return q, false
+		}
+		q.From = from
+	}
+
+	err := q.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return q, false
+	}
+
+	return q, true
+}
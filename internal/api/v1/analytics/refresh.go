@@ -0,0 +1,26 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/Josesx506/gofems/internal/jobs"
+)
+
+// Recomputes the materialized daily rollups read by ?rollup=true summaries
+const RefreshRollupsJob = "analytics.refresh_rollups"
+
+// RegisterJobs schedules the rollup refresh every interval, never when it's 0
+func RegisterJobs(app *app.Application, every time.Duration) {
+	store := NewPostgresAnalyticsStore(app.DB)
+
+	refresh := jobs.Register(app.Jobs, RefreshRollupsJob, jobs.Options{Queue: jobs.MaintenanceQueue},
+		func(ctx context.Context, _ struct{}) error {
+			return store.RefreshRollups()
+		})
+	if every > 0 {
+		refresh.Schedule("refresh-rollups", fmt.Sprintf("@every %s", every), struct{}{})
+	}
+}
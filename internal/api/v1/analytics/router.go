@@ -0,0 +1,24 @@
+package analytics
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/go-chi/chi/v5"
+)
+
+func AnalyticsRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and store
+	r := chi.NewRouter()
+	store := NewPostgresAnalyticsStore(app.DB)
+	handler := NewAnalyticsHandler(store, users.NewPostgresUserStore(app.DB), app.Logger)
+
+	// Define subroutes
+	r.Get("/summary", handler.HandleSummary)
+	r.Get("/muscle-groups", handler.HandleMuscleGroups)
+	r.Get("/exercises/{exerciseID}", handler.HandleExerciseProgress)
+	r.Get("/distance", handler.HandleDistance)
+	r.Get("/best-efforts", handler.HandleBestEfforts)
+
+	return r
+}
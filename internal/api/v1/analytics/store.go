@@ -0,0 +1,297 @@
+package analytics
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DB connector struct, reports are read-only aggregates over workouts and their entries
+type PostgresAnalyticsStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAnalyticsStore(db *sql.DB) *PostgresAnalyticsStore {
+	return &PostgresAnalyticsStore{db: db}
+}
+
+type AnalyticsStore interface {
+	Summary(q Query, fromRollups bool) ([]Period, error)
+	MuscleGroups(q Query) ([]MuscleGroupSets, error)
+	ExerciseProgress(q Query, exerciseID int64) ([]ExercisePeriod, error)
+	Distance(q Query) ([]DistancePeriod, error)
+	BestEfforts(userID int64) ([]BestEffort, error)
+	RefreshRollups() error
+}
+
+// Every query takes $1 bucket, $2 user, $3 from and $4 to. Periods are UTC timestamps so
+// the series lines up with the UTC days of workout_daily_rollups.
+const periods = `
+	periods AS (
+		SELECT generate_series(
+			date_trunc($1, $3::timestamptz AT TIME ZONE 'UTC'),
+			($4::timestamptz AT TIME ZONE 'UTC') - interval '1 microsecond',
+			('1 ' || $1)::interval
+		) AS period_start
+	)`
+
+// Live workouts of the user within the range
+const liveWorkouts = `w.user_id IS NOT DISTINCT FROM $2 AND w.deleted_at IS NULL AND w.created_at >= $3 AND w.created_at < $4`
+
+// Per-session rows from the live tables
+const liveSessions = `
+	sessions AS (
+		SELECT date_trunc($1, w.created_at AT TIME ZONE 'UTC') AS period_start, 1 AS sessions,
+			w.duration_minutes, COALESCE(w.calories_burned, 0) AS calories_burned,
+			COALESCE(e.sets, 0) AS sets, COALESCE(e.volume, 0) AS volume
+		FROM workouts w
+		CROSS JOIN LATERAL (
+			SELECT SUM(we.sets) AS sets, SUM(entry_volume(we.id, we.sets, we.reps, we.weight)) AS volume
+			FROM workout_entries we
+			WHERE we.workout_id = w.id
+		) e
+		WHERE ` + liveWorkouts + `
+	)`
+
+// Per-day rows from the materialized rollup, which only knows whole days
+const rollupSessions = `
+	sessions AS (
+		SELECT date_trunc($1, r.day::timestamp) AS period_start, r.sessions,
+			r.duration_minutes, r.calories_burned, r.sets, r.volume
+		FROM workout_daily_rollups r
+		WHERE r.user_id = COALESCE($2::bigint, 0)
+			AND r.day >= ($3::timestamptz AT TIME ZONE 'UTC')::date
+			AND r.day < ($4::timestamptz AT TIME ZONE 'UTC')::date
+	)`
+
+// Session totals per period, including empty periods so charts don't skip them
+func (pgStore *PostgresAnalyticsStore) Summary(q Query, fromRollups bool) ([]Period, error) {
+	sessions := liveSessions
+	if fromRollups {
+		sessions = rollupSessions
+	}
+
+	query := `
+	WITH ` + periods + `,` + sessions + `,
+	totals AS (
+		SELECT p.period_start,
+			COALESCE(SUM(s.sessions), 0) AS sessions,
+			COALESCE(SUM(s.duration_minutes), 0) AS duration_minutes,
+			COALESCE(SUM(s.calories_burned), 0) AS calories_burned,
+			COALESCE(SUM(s.sets), 0) AS sets,
+			COALESCE(SUM(s.volume), 0) AS volume
+		FROM periods p
+		LEFT JOIN sessions s ON s.period_start = p.period_start
+		GROUP BY p.period_start
+	)
+	SELECT period_start, sessions, duration_minutes, calories_burned, sets, volume,
+		SUM(volume) OVER (ORDER BY period_start)
+	FROM totals
+	ORDER BY period_start
+	`
+	rows, err := pgStore.db.Query(query, q.Bucket, nullableUser(q.UserID), q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := []Period{}
+	for rows.Next() {
+		var period Period
+		err := rows.Scan(&period.PeriodStart, &period.Sessions, &period.DurationMinutes, &period.CaloriesBurned,
+			&period.Sets, &period.Volume, &period.CumulativeVolume)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, period)
+	}
+
+	return series, rows.Err()
+}
+
+// Sets per primary muscle group and period. Only entries linked to the exercise
+// catalog know their muscles, so unlinked entries aren't counted.
+func (pgStore *PostgresAnalyticsStore) MuscleGroups(q Query) ([]MuscleGroupSets, error) {
+	query := `
+	SELECT date_trunc($1, w.created_at AT TIME ZONE 'UTC') AS period_start, muscle, SUM(we.sets)
+	FROM workouts w
+	JOIN workout_entries we ON we.workout_id = w.id
+	JOIN exercises e ON e.id = we.exercise_id
+	CROSS JOIN UNNEST(e.primary_muscles) AS muscle
+	WHERE ` + liveWorkouts + `
+	GROUP BY 1, 2
+	ORDER BY 1, 2
+	`
+	rows, err := pgStore.db.Query(query, q.Bucket, nullableUser(q.UserID), q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []MuscleGroupSets{}
+	for rows.Next() {
+		var group MuscleGroupSets
+		err := rows.Scan(&group.PeriodStart, &group.MuscleGroup, &group.Sets)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+// Per-period totals and bests of one catalog exercise
+func (pgStore *PostgresAnalyticsStore) ExerciseProgress(q Query, exerciseID int64) ([]ExercisePeriod, error) {
+	query := `
+	WITH ` + periods + `,
+	sets AS (
+		SELECT date_trunc($1, w.created_at AT TIME ZONE 'UTC') AS period_start, w.id AS workout_id,
+			we.sets, we.reps, we.weight
+		FROM workouts w
+		JOIN workout_entries we ON we.workout_id = w.id
+		WHERE ` + liveWorkouts + ` AND we.exercise_id = $5
+	),
+	totals AS (
+		SELECT p.period_start,
+			COUNT(DISTINCT s.workout_id) AS sessions,
+			COALESCE(SUM(s.sets), 0) AS sets,
+			COALESCE(SUM(s.sets * s.reps), 0) AS reps,
+			COALESCE(SUM(s.sets * s.reps * s.weight), 0) AS volume,
+			MAX(s.weight) AS max_weight,
+			ROUND(MAX(CASE WHEN s.reps = 1 THEN s.weight ELSE s.weight * (1 + s.reps / 30.0) END), 2) AS estimated_1rm
+		FROM periods p
+		LEFT JOIN sets s ON s.period_start = p.period_start
+		GROUP BY p.period_start
+	)
+	SELECT period_start, sessions, sets, reps, volume, SUM(volume) OVER (ORDER BY period_start),
+		max_weight, estimated_1rm
+	FROM totals
+	ORDER BY period_start
+	`
+	rows, err := pgStore.db.Query(query, q.Bucket, nullableUser(q.UserID), q.From, q.To, exerciseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := []ExercisePeriod{}
+	for rows.Next() {
+		var period ExercisePeriod
+		var maxWeight, estimate sql.NullFloat64
+		err := rows.Scan(&period.PeriodStart, &period.Sessions, &period.Sets, &period.Reps, &period.Volume,
+			&period.CumulativeVolume, &maxWeight, &estimate)
+		if err != nil {
+			return nil, err
+		}
+		if maxWeight.Valid {
+			period.MaxWeight = &maxWeight.Float64
+		}
+		if estimate.Valid {
+			period.Estimated1RM = &estimate.Float64
+		}
+		series = append(series, period)
+	}
+
+	return series, rows.Err()
+}
+
+// Distance and time per period over entries with cardio metrics, in meters.
+// Distances are per set, so intervals count every repeat.
+func (pgStore *PostgresAnalyticsStore) Distance(q Query) ([]DistancePeriod, error) {
+	query := `
+	WITH ` + periods + `,
+	efforts AS (
+		SELECT date_trunc($1, w.created_at AT TIME ZONE 'UTC') AS period_start, w.id AS workout_id,
+			we.sets * ec.distance_meters AS distance_meters, we.sets * COALESCE(we.duration_seconds, 0) AS duration_seconds
+		FROM workouts w
+		JOIN workout_entries we ON we.workout_id = w.id
+		JOIN entry_cardio ec ON ec.entry_id = we.id
+		WHERE ` + liveWorkouts + ` AND ec.distance_meters > 0
+	)
+	SELECT p.period_start, COUNT(DISTINCT e.workout_id), COALESCE(SUM(e.distance_meters), 0),
+		COALESCE(SUM(e.duration_seconds), 0)
+	FROM periods p
+	LEFT JOIN efforts e ON e.period_start = p.period_start
+	GROUP BY p.period_start
+	ORDER BY p.period_start
+	`
+	rows, err := pgStore.db.Query(query, q.Bucket, nullableUser(q.UserID), q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := []DistancePeriod{}
+	for rows.Next() {
+		var period DistancePeriod
+		err := rows.Scan(&period.PeriodStart, &period.Sessions, &period.Distance, &period.DurationSeconds)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, period)
+	}
+
+	return series, rows.Err()
+}
+
+// The fastest effort of userID over every standard distance, per exercise
+func (pgStore *PostgresAnalyticsStore) BestEfforts(userID int64) ([]BestEffort, error) {
+	values := ""
+	args := []any{nullableUser(userID)}
+	for i, effort := range EffortDistances {
+		if i > 0 {
+			values += ", "
+		}
+		values += fmt.Sprintf("($%d::text, $%d::numeric)", len(args)+1, len(args)+2)
+		args = append(args, effort.Name, effort.Meters)
+	}
+
+	query := `
+	WITH efforts AS (
+		SELECT w.id AS workout_id, we.id AS entry_id, we.exercise_id, we.exercise_name, w.created_at,
+			COALESCE(we.exercise_id::text, LOWER(TRIM(we.exercise_name))) AS exercise_key,
+			ec.distance_meters, we.duration_seconds
+		FROM workouts w
+		JOIN workout_entries we ON we.workout_id = w.id
+		JOIN entry_cardio ec ON ec.entry_id = we.id
+		WHERE w.user_id IS NOT DISTINCT FROM $1 AND w.deleted_at IS NULL
+			AND ec.distance_meters > 0 AND we.duration_seconds > 0
+	)
+	SELECT DISTINCT ON (e.exercise_key, t.meters)
+		e.exercise_id, e.exercise_name, t.name, t.meters,
+		ROUND(e.duration_seconds * t.meters / e.distance_meters, 1) AS seconds,
+		ROUND(e.duration_seconds * 1000 / e.distance_meters, 1),
+		e.workout_id, e.entry_id, e.created_at
+	FROM (VALUES ` + values + `) AS t (name, meters)
+	JOIN efforts e ON e.distance_meters >= t.meters
+	ORDER BY e.exercise_key, t.meters, seconds ASC, e.created_at ASC
+	`
+	rows, err := pgStore.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	efforts := []BestEffort{}
+	for rows.Next() {
+		var effort BestEffort
+		err := rows.Scan(&effort.ExerciseID, &effort.ExerciseName, &effort.Effort, &effort.DistanceMeters,
+			&effort.Seconds, &effort.PaceSecondsPerKm, &effort.WorkoutID, &effort.EntryID, &effort.AchievedAt)
+		if err != nil {
+			return nil, err
+		}
+		efforts = append(efforts, effort)
+	}
+
+	return efforts, rows.Err()
+}
+
+// Recomputes workout_daily_rollups without blocking readers
+func (pgStore *PostgresAnalyticsStore) RefreshRollups() error {
+	_, err := pgStore.db.Exec(`REFRESH MATERIALIZED VIEW CONCURRENTLY workout_daily_rollups`)
+	return err
+}
+
+func nullableUser(userID int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: userID, Valid: userID != 0}
+}
@@ -0,0 +1,197 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/store"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalytics(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+	pgStore := NewPostgresAnalyticsStore(db)
+
+	// Two sessions in the week of Monday 2025-03-03 and one in the week after
+	logged := []struct {
+		title string
+		at    string
+	}{
+		{"Monday Squats", "2025-03-03T18:00:00Z"},
+		{"Thursday Squats", "2025-03-06T18:00:00Z"},
+		{"Next Monday Squats", "2025-03-10T18:00:00Z"},
+	}
+	for _, l := range logged {
+		workout, err := workoutStore.CreateWorkout(&workouts.Workout{
+			Title:           l.title,
+			DurationMinutes: 60,
+			CaloriesBurned:  400,
+			Entries: []workouts.WorkoutEntry{
+				{ExerciseName: "Back Squat", Sets: 5, Reps: intPtr(5), Weight: floatPtr(100), OrderIndex: 1},
+				{ExerciseName: "Plank", Sets: 3, DurationSeconds: intPtr(60), OrderIndex: 2},
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = db.Exec(`UPDATE workouts SET created_at = $1 WHERE id = $2`, l.at, workout.ID)
+		require.NoError(t, err)
+	}
+
+	q := Query{
+		Bucket: BucketWeek,
+		From:   time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2025, 3, 24, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, q.Validate())
+
+	require.NoError(t, pgStore.RefreshRollups())
+	for _, fromRollups := range []bool{false, true} {
+		series, err := pgStore.Summary(q, fromRollups)
+		require.NoError(t, err)
+		require.Len(t, series, 3, "empty weeks are included")
+
+		assert.Equal(t, 2, series[0].Sessions)
+		assert.Equal(t, 120, series[0].DurationMinutes)
+		assert.Equal(t, 800, series[0].CaloriesBurned)
+		assert.Equal(t, 16, series[0].Sets)
+		assert.Equal(t, 5000.0, series[0].Volume, "timed entries don't add volume")
+		assert.Equal(t, 1, series[1].Sessions)
+		assert.Equal(t, 0, series[2].Sessions)
+		assert.Equal(t, 7500.0, series[2].CumulativeVolume)
+	}
+
+	groups, err := pgStore.MuscleGroups(q)
+	require.NoError(t, err)
+	require.NotEmpty(t, groups)
+	assert.Contains(t, groups, MuscleGroupSets{PeriodStart: q.From, MuscleGroup: "quadriceps", Sets: 10})
+
+	var squatID int64
+	require.NoError(t, db.QueryRow(`SELECT id FROM exercises WHERE name = 'Back Squat'`).Scan(&squatID))
+	progress, err := pgStore.ExerciseProgress(q, squatID)
+	require.NoError(t, err)
+	require.Len(t, progress, 3)
+	assert.Equal(t, 2, progress[0].Sessions)
+	assert.Equal(t, 50, progress[0].Reps)
+	require.NotNil(t, progress[0].Estimated1RM)
+	assert.InDelta(t, 116.67, *progress[0].Estimated1RM, 0.01)
+	assert.Nil(t, progress[2].MaxWeight)
+}
+
+func TestSetVolume(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+	pgStore := NewPostgresAnalyticsStore(db)
+
+	missed := false
+	workout, err := workoutStore.CreateWorkout(&workouts.Workout{
+		Title:           "Pyramid",
+		DurationMinutes: 45,
+		Entries: []workouts.WorkoutEntry{{ExerciseName: "Back Squat", OrderIndex: 1, SetsDetail: []workouts.EntrySet{
+			{Reps: intPtr(10), Weight: floatPtr(60), SetType: workouts.SetWarmup},
+			{Reps: intPtr(5), Weight: floatPtr(100)},
+			{Reps: intPtr(8), Weight: floatPtr(80)},
+			{Reps: intPtr(5), Weight: floatPtr(100), Completed: &missed},
+		}}},
+	})
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE workouts SET created_at = '2025-04-02T18:00:00Z' WHERE id = $1`, workout.ID)
+	require.NoError(t, err)
+
+	q := Query{
+		Bucket: BucketWeek,
+		From:   time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2025, 4, 7, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, pgStore.RefreshRollups())
+	for _, fromRollups := range []bool{false, true} {
+		series, err := pgStore.Summary(q, fromRollups)
+		require.NoError(t, err)
+		require.Len(t, series, 1)
+		assert.Equal(t, 1140.0, series[0].Volume, "the counted sets, not two sets at the top weight")
+	}
+}
+
+func TestCardioAnalytics(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+	pgStore := NewPostgresAnalyticsStore(db)
+
+	runs := []struct {
+		title    string
+		at       string
+		distance float64
+		seconds  int
+	}{
+		{"Tempo Run", "2025-03-04T07:00:00Z", 5, 1500},
+		{"Long Run", "2025-03-08T07:00:00Z", 12, 3960},
+		{"Easy Run", "2025-03-11T07:00:00Z", 10, 3300},
+	}
+	for _, run := range runs {
+		workout, err := workoutStore.CreateWorkout(&workouts.Workout{
+			Title:           run.title,
+			DurationMinutes: run.seconds / 60,
+			Entries: []workouts.WorkoutEntry{{
+				ExerciseName:    "Running",
+				Sets:            1,
+				DurationSeconds: intPtr(run.seconds),
+				OrderIndex:      1,
+				Cardio:          &workouts.CardioMetrics{Distance: floatPtr(run.distance), DistanceUnit: "km"},
+			}},
+		})
+		require.NoError(t, err)
+
+		_, err = db.Exec(`UPDATE workouts SET created_at = $1 WHERE id = $2`, run.at, workout.ID)
+		require.NoError(t, err)
+	}
+
+	q := Query{
+		Bucket: BucketWeek,
+		From:   time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC),
+	}
+	series, err := pgStore.Distance(q)
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+	assert.Equal(t, 2, series[0].Sessions)
+	assert.Equal(t, 17000.0, series[0].Distance)
+	assert.Equal(t, 5460, series[0].DurationSeconds)
+
+	efforts, err := pgStore.BestEfforts(0)
+	require.NoError(t, err)
+	best := map[string]BestEffort{}
+	for _, effort := range efforts {
+		best[effort.Effort] = effort
+	}
+	require.Contains(t, best, "5k")
+	assert.Equal(t, 1500.0, best["5k"].Seconds, "the tempo run is the fastest 5k")
+	require.Contains(t, best, "10k")
+	assert.Equal(t, 3300.0, best["10k"].Seconds, "the long run averaged 330s/km")
+	assert.NotContains(t, best, "half_marathon")
+}
+
+func TestQueryValidate(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, Query{Bucket: BucketMonth, From: from, To: from.AddDate(2, 0, 0)}.Validate())
+	assert.ErrorIs(t, Query{Bucket: BucketWeek, From: from, To: from}.Validate(), ErrInvalidRange)
+	assert.Error(t, Query{Bucket: "year", From: from, To: from.AddDate(1, 0, 0)}.Validate())
+	assert.Error(t, Query{Bucket: BucketDay, From: from, To: from.AddDate(2, 0, 0)}.Validate())
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
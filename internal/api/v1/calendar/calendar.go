@@ -0,0 +1,96 @@
+// Package calendar publishes a user's logged workouts and planned sessions as an
+// iCalendar feed that calendar apps subscribe to through a secret link.
+package calendar
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/schedule"
+)
+
+const (
+	// Feeds without ?from= and ?to= cover this far back and ahead of today
+	defaultPast   = 90 * 24 * time.Hour
+	defaultFuture = 90 * 24 * time.Hour
+	// Longest range a single feed request can cover
+	MaxRange = 366 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRange = errors.New("from must be before to")
+	ErrRangeTooLong = errors.New("calendar feeds are limited to a 366 day range")
+)
+
+// A feed token as handed to the user, only returned when it's issued
+type Token struct {
+	Token     string    `json:"token"`
+	FeedURL   string    `json:"feed_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewToken returns a random feed token and the hash it's stored under
+func NewToken() (string, []byte) {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	token := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+	return token, HashToken(token)
+}
+
+// Tokens are only stored hashed, a leaked table doesn't expose anyone's feed
+func HashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+// Inclusive from, exclusive to
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+func (r Range) Validate() error {
+	if !r.From.Before(r.To) {
+		return ErrInvalidRange
+	}
+	if r.To.Sub(r.From) > MaxRange {
+		return ErrRangeTooLong
+	}
+	return nil
+}
+
+// PlannedEvents turns occurrences of planned sessions into tentative events. Done
+// occurrences are left out, the workout logged for them is in the feed instead.
+func PlannedEvents(occurrences []schedule.Occurrence, now time.Time) []Event {
+	events := []Event{}
+	for _, occurrence := range occurrences {
+		if occurrence.Status == schedule.OccurrenceDone {
+			continue
+		}
+		events = append(events, Event{
+			UID:         fmt.Sprintf("session-%d-%d@gofems", occurrence.SessionID, occurrence.StartsAt.Unix()),
+			Start:       occurrence.StartsAt,
+			End:         occurrence.EndsAt,
+			Summary:     occurrence.Title,
+			Description: occurrence.Notes,
+			Status:      StatusTentative,
+			Updated:     now,
+		})
+	}
+	return events
+}
+
+// Combines event lists in start order
+func mergeEvents(lists ...[]Event) []Event {
+	events := []Event{}
+	for _, list := range lists {
+		events = append(events, list...)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events
+}
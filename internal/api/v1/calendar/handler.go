@@ -0,0 +1,131 @@
+package calendar
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/schedule"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type CalendarHandler struct {
+	store         CalendarStore
+	scheduleStore schedule.ScheduleStore
+	logger        *log.Logger
+}
+
+// Feeds show planned sessions next to logged workouts, so the handler needs both stores
+func NewCalendarHandler(store CalendarStore, scheduleStore schedule.ScheduleStore, logger *log.Logger) *CalendarHandler {
+	return &CalendarHandler{
+		store:         store,
+		scheduleStore: scheduleStore,
+		logger:        logger,
+	}
+}
+
+// Issues the caller a new feed token, the previous feed link stops working
+func (ch *CalendarHandler) HandleRotateToken(w http.ResponseWriter, r *http.Request) {
+	token, hash := NewToken()
+	createdAt, err := ch.store.RotateToken(int64(users.CurrentUser(r).ID), hash)
+	if err != nil {
+		ch.logger.Printf("Error rotateToken: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to issue calendar token"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"calendar": Token{
+		Token:     token,
+		FeedURL:   fmt.Sprintf("/v1/calendar/%s.ics", token),
+		CreatedAt: createdAt,
+	}})
+}
+
+func (ch *CalendarHandler) HandleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	err := ch.store.DeleteToken(int64(users.CurrentUser(r).ID))
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "no calendar token issued"}) // 404
+		return
+	}
+
+	if err != nil {
+		ch.logger.Printf("Error deleteToken: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to revoke calendar token"}) // 500
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Serves the feed of the token's owner, logged workouts and the planned sessions still
+// open. Calendar apps can't send credentials, the token in the path is the credential.
+// Reads the inclusive ?from= and ?to= dates, 90 days either side of today by default.
+func (ch *CalendarHandler) HandleFeed(w http.ResponseWriter, r *http.Request) {
+	userID, err := ch.store.GetUserIDByToken(HashToken(chi.URLParam(r, "token")))
+	if err != nil {
+		ch.logger.Printf("Error getUserIDByToken: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if userID == 0 {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "calendar not found"}) // 404
+		return
+	}
+
+	feedRange, err := readRange(r, time.Now())
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	events, err := ch.store.ListWorkoutEvents(userID, feedRange)
+	if err != nil {
+		ch.logger.Printf("Error listWorkoutEvents: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	occurrences, err := ch.scheduleStore.ListOccurrences(userID, feedRange.From, feedRange.To)
+	if err != nil {
+		ch.logger.Printf("Error listOccurrences: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+	events = mergeEvents(events, PlannedEvents(occurrences, time.Now()))
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="workouts.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=900")
+	err = WriteCalendar(w, "Workouts", events)
+	if err != nil {
+		ch.logger.Printf("Error writing calendar: %v", err)
+	}
+}
+
+func readRange(r *http.Request, now time.Time) (Range, error) {
+	params := r.URL.Query()
+	today := now.UTC().Truncate(24 * time.Hour)
+	feedRange := Range{From: today.Add(-defaultPast), To: today.Add(defaultFuture)}
+
+	if param := params.Get("from"); param != "" {
+		from, err := time.Parse(time.DateOnly, param)
+		if err != nil {
+			return feedRange, fmt.Errorf("from must be a YYYY-MM-DD date")
+		}
+		feedRange.From = from
+	}
+	if param := params.Get("to"); param != "" {
+		to, err := time.Parse(time.DateOnly, param)
+		if err != nil {
+			return feedRange, fmt.Errorf("to must be a YYYY-MM-DD date")
+		}
+		feedRange.To = to.AddDate(0, 0, 1)
+	}
+
+	return feedRange, feedRange.Validate()
+}
@@ -0,0 +1,92 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// RFC 5545 event statuses
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusTentative = "TENTATIVE"
+	StatusCancelled = "CANCELLED"
+)
+
+// One VEVENT. UID must stay the same for the same workout across requests so
+// calendar apps update events rather than duplicating them.
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Status      string
+	Updated     time.Time // DTSTAMP, when the event last changed
+}
+
+const icsTimeLayout = "20060102T150405Z"
+
+// Lines longer than this many octets are folded onto continuation lines
+const maxLineOctets = 75
+
+// WriteCalendar writes events as an iCalendar (RFC 5545) document named name
+func WriteCalendar(w io.Writer, name string, events []Event) error {
+	bw := bufio.NewWriter(w)
+	line := func(property, value string) {
+		writeFolded(bw, property+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//gofems//Workouts//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", escapeText(name))
+	line("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	line("X-PUBLISHED-TTL", "PT1H")
+
+	for _, event := range events {
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("DTSTAMP", event.Updated.UTC().Format(icsTimeLayout))
+		line("DTSTART", event.Start.UTC().Format(icsTimeLayout))
+		line("DTEND", event.End.UTC().Format(icsTimeLayout))
+		line("SUMMARY", escapeText(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", escapeText(event.Description))
+		}
+		if event.Status != "" {
+			line("STATUS", event.Status)
+		}
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// Escapes a TEXT value, line breaks become the literal \n
+func escapeText(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", `\n`).Replace(value)
+}
+
+// Writes a content line ended by CRLF, folding it every 75 octets without splitting a
+// UTF-8 character. Continuation lines start with a space, which counts towards them.
+func writeFolded(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		fmt.Fprintf(w, "%s\r\n ", line[:cut])
+		line = line[cut:]
+		limit = maxLineOctets - 1
+	}
+	fmt.Fprintf(w, "%s\r\n", line)
+}
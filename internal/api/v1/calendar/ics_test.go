@@ -0,0 +1,109 @@
+package calendar
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/schedule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCalendar(t *testing.T) {
+	start := time.Date(2026, 10, 1, 7, 30, 0, 0, time.FixedZone("WAT", 3600))
+	events := []Event{{
+		UID:         "workout-1@gofems",
+		Start:       start,
+		End:         start.Add(45 * time.Minute),
+		Summary:     "Legs, glutes; core",
+		Description: "Felt strong\nsquats \\ lunges",
+		Status:      StatusConfirmed,
+		Updated:     start,
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCalendar(&buf, "Workouts", events))
+	ics := buf.String()
+
+	assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	assert.Contains(t, ics, "\r\nDTSTART:20261001T063000Z\r\n", "times are written in UTC")
+	assert.Contains(t, ics, "\r\nDTEND:20261001T071500Z\r\n")
+	assert.Contains(t, ics, "\r\nSUMMARY:Legs\\, glutes\\; core\r\n")
+	assert.Contains(t, ics, "\r\nDESCRIPTION:Felt strong\\nsquats \\\\ lunges\r\n")
+	assert.Contains(t, ics, "\r\nSTATUS:CONFIRMED\r\n")
+	assert.NotContains(t, strings.ReplaceAll(ics, "\r\n", ""), "\n", "every line ends in CRLF")
+}
+
+func TestLineFolding(t *testing.T) {
+	var buf bytes.Buffer
+	summary := strings.Repeat("é", 100) // 2 octets each
+	require.NoError(t, WriteCalendar(&buf, "Workouts", []Event{{UID: "x", Summary: summary}}))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	var unfolded strings.Builder
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(line), maxLineOctets)
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+			continue
+		}
+		unfolded.WriteString("\n" + line)
+	}
+	assert.Contains(t, unfolded.String(), "\nSUMMARY:"+summary+"\n", "folds never split a character")
+}
+
+func TestReadRange(t *testing.T) {
+	now := time.Date(2026, 10, 15, 18, 0, 0, 0, time.UTC)
+	today := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    Range
+		wantErr error
+	}{
+		{name: "default", query: "", want: Range{From: today.Add(-defaultPast), To: today.Add(defaultFuture)}},
+		{name: "inclusive to", query: "?from=2026-01-01&to=2026-01-31",
+			want: Range{From: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)}},
+		{name: "reversed", query: "?from=2026-02-01&to=2026-01-01", wantErr: ErrInvalidRange},
+		{name: "too long", query: "?from=2024-01-01&to=2026-01-01", wantErr: ErrRangeTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRange(httptest.NewRequest("GET", "/feed.ics"+tt.query, nil), now)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := readRange(httptest.NewRequest("GET", "/feed.ics?from=yesterday", nil), now)
+	assert.Error(t, err)
+}
+
+func TestPlannedEvents(t *testing.T) {
+	start := time.Date(2026, 10, 5, 7, 0, 0, 0, time.UTC)
+	workoutID := 3
+	occurrences := []schedule.Occurrence{
+		{SessionID: 1, Title: "Strength", StartsAt: start, EndsAt: start.Add(time.Hour),
+			Status: schedule.OccurrenceDone, WorkoutID: &workoutID},
+		{SessionID: 1, Title: "Strength", StartsAt: start.AddDate(0, 0, 7), EndsAt: start.AddDate(0, 0, 7).Add(time.Hour),
+			Status: schedule.OccurrencePlanned},
+	}
+
+	events := PlannedEvents(occurrences, start)
+	require.Len(t, events, 1, "done occurrences show as their workout")
+	assert.Equal(t, "session-1-1791788400@gofems", events[0].UID, "stable across requests")
+	assert.Equal(t, StatusTentative, events[0].Status)
+
+	merged := mergeEvents([]Event{{UID: "late", Start: start.AddDate(0, 0, 8)}}, events)
+	assert.Equal(t, []string{events[0].UID, "late"}, []string{merged[0].UID, merged[1].UID})
+}
@@ -0,0 +1,29 @@
+package calendar
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/schedule"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/go-chi/chi/v5"
+)
+
+func CalendarRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and stores
+	r := chi.NewRouter()
+	store := NewPostgresCalendarStore(app.DB)
+	scheduleStore := schedule.NewPostgresScheduleStore(app.DB)
+	handler := NewCalendarHandler(store, scheduleStore, app.Logger)
+
+	// Define subroutes
+	r.Get("/{token}.ics", handler.HandleFeed)
+
+	// Managing the feed link needs the caller
+	r.Group(func(r chi.Router) {
+		r.Use(users.RequireUser(users.NewPostgresUserStore(app.DB)))
+		r.Post("/token", handler.HandleRotateToken)
+		r.Delete("/token", handler.HandleRevokeToken)
+	})
+
+	return r
+}
@@ -0,0 +1,103 @@
+package calendar
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DB connector struct
+type PostgresCalendarStore struct {
+	db *sql.DB
+}
+
+func NewPostgresCalendarStore(db *sql.DB) *PostgresCalendarStore {
+	return &PostgresCalendarStore{db: db}
+}
+
+type CalendarStore interface {
+	RotateToken(userID int64, hash []byte) (time.Time, error)
+	DeleteToken(userID int64) error
+	GetUserIDByToken(hash []byte) (int64, error)
+	ListWorkoutEvents(userID int64, r Range) ([]Event, error)
+}
+
+// Workouts logged without a duration show up as an hour long
+const defaultEventMinutes = 60
+
+// Stores the hash of a user's new feed token, replacing the previous token so old
+// links stop working. Returns when the token was issued.
+func (pgStore *PostgresCalendarStore) RotateToken(userID int64, hash []byte) (time.Time, error) {
+	var createdAt time.Time
+	query := `
+	INSERT INTO calendar_tokens (user_id, token_hash)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = CURRENT_TIMESTAMP
+	RETURNING created_at
+	`
+	err := pgStore.db.QueryRow(query, userID, hash).Scan(&createdAt)
+	return createdAt, err
+}
+
+// Returns sql.ErrNoRows when the user has no token
+func (pgStore *PostgresCalendarStore) DeleteToken(userID int64) error {
+	result, err := pgStore.db.Exec(`DELETE FROM calendar_tokens WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Returns 0 for unknown tokens
+func (pgStore *PostgresCalendarStore) GetUserIDByToken(hash []byte) (int64, error) {
+	var userID int64
+	err := pgStore.db.QueryRow(`SELECT user_id FROM calendar_tokens WHERE token_hash = $1`, hash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return userID, err
+}
+
+// Lists the live workouts of a user that started within r. A workout starts at its
+// created_at and is last updated by its latest revision.
+func (pgStore *PostgresCalendarStore) ListWorkoutEvents(userID int64, r Range) ([]Event, error) {
+	query := `
+	SELECT w.id, w.title, COALESCE(w.description, ''), w.duration_minutes, w.created_at,
+		COALESCE((SELECT MAX(wr.created_at) FROM workout_revisions wr WHERE wr.workout_id = w.id), w.created_at)
+	FROM workouts w
+	WHERE w.user_id = $1 AND w.deleted_at IS NULL AND w.created_at >= $2 AND w.created_at < $3
+	ORDER BY w.created_at, w.id
+	`
+	rows, err := pgStore.db.Query(query, userID, r.From, r.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var id, minutes int
+		var event Event
+		err := rows.Scan(&id, &event.Summary, &event.Description, &minutes, &event.Start, &event.Updated)
+		if err != nil {
+			return nil, err
+		}
+		if minutes <= 0 {
+			minutes = defaultEventMinutes
+		}
+		event.UID = fmt.Sprintf("workout-%d@gofems", id)
+		event.End = event.Start.Add(time.Duration(minutes) * time.Minute)
+		event.Status = StatusConfirmed
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,72 @@
+package calendar
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/store"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarFeed(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresCalendarStore(db)
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+
+	var userID int64
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('lifter', 'lifter@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	token, hash := NewToken()
+	_, err := pgStore.RotateToken(userID, hash)
+	require.NoError(t, err)
+
+	owner, err := pgStore.GetUserIDByToken(HashToken(token))
+	require.NoError(t, err)
+	assert.Equal(t, userID, owner)
+
+	rotated, rotatedHash := NewToken()
+	_, err = pgStore.RotateToken(userID, rotatedHash)
+	require.NoError(t, err)
+	owner, err = pgStore.GetUserIDByToken(HashToken(token))
+	require.NoError(t, err)
+	assert.Zero(t, owner, "rotating invalidates the old token")
+	owner, err = pgStore.GetUserIDByToken(HashToken(rotated))
+	require.NoError(t, err)
+	assert.Equal(t, userID, owner)
+
+	reps := 5
+	workout, err := workoutStore.CreateWorkout(&workouts.Workout{UserID: int(userID), Title: "Squats",
+		Description: "heavy", DurationMinutes: 40,
+		Entries: []workouts.WorkoutEntry{{ExerciseName: "Squat", Sets: 5, Reps: &reps}}})
+	require.NoError(t, err)
+	_, err = workoutStore.CreateWorkout(&workouts.Workout{UserID: int(userID), Title: "No duration",
+		Entries: []workouts.WorkoutEntry{{ExerciseName: "Squat", Sets: 1, Reps: &reps}}})
+	require.NoError(t, err)
+
+	now := time.Now()
+	events, err := pgStore.ListWorkoutEvents(userID, Range{From: now.Add(-time.Hour), To: now.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "Squats", events[0].Summary)
+	assert.Equal(t, 40*time.Minute, events[0].End.Sub(events[0].Start))
+	assert.Equal(t, defaultEventMinutes*time.Minute, events[1].End.Sub(events[1].Start))
+
+	require.NoError(t, workoutStore.DeleteWorkout(int64(workout.ID)))
+	events, err = pgStore.ListWorkoutEvents(userID, Range{From: now.Add(-time.Hour), To: now.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Len(t, events, 1, "trashed workouts leave the feed")
+
+	events, err = pgStore.ListWorkoutEvents(userID, Range{From: now.Add(time.Hour), To: now.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	require.NoError(t, pgStore.DeleteToken(userID))
+	assert.Equal(t, sql.ErrNoRows, pgStore.DeleteToken(userID))
+}
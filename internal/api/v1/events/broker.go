@@ -0,0 +1,167 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+)
+
+// The Postgres channel outbox_events inserts notify, with the owner's id as payload
+const OutboxChannel = "outbox_events"
+
+// Wait before listening again after the connection failed, doubled up to maxReconnectWait
+const (
+	reconnectWait    = time.Second
+	maxReconnectWait = 30 * time.Second
+)
+
+// Notifier wakes the streams of a user when their events may have changed
+type Notifier interface {
+	Subscribe(userID int64) (<-chan struct{}, func())
+}
+
+// Broker listens on a Postgres channel whose payloads are user ids, so a change committed
+// by any instance wakes the streams of its user. It holds a connection only while
+// streams are open.
+type Broker struct {
+	db      *sql.DB
+	channel string
+	logger  *log.Logger
+
+	mu        sync.Mutex
+	listeners map[int64]map[chan struct{}]struct{}
+	stop      context.CancelFunc // nil while nobody is subscribed
+}
+
+func NewBroker(db *sql.DB, channel string, logger *log.Logger) *Broker {
+	return &Broker{
+		db:        db,
+		channel:   channel,
+		logger:    logger,
+		listeners: map[int64]map[chan struct{}]struct{}{},
+	}
+}
+
+// Subscribe returns a channel signalled whenever the user's events may have changed
+// and the func that unsubscribes it. Signals coalesce, so readers should load
+// everything after the last event they saw on each one.
+func (b *Broker) Subscribe(userID int64) (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.listeners[userID] == nil {
+		b.listeners[userID] = map[chan struct{}]struct{}{}
+	}
+	b.listeners[userID][wake] = struct{}{}
+
+	if b.stop == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		b.stop = cancel
+		go b.listen(ctx)
+	}
+
+	return wake, func() { b.unsubscribe(userID, wake) }
+}
+
+func (b *Broker) unsubscribe(userID int64, wake chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.listeners[userID], wake)
+	if len(b.listeners[userID]) == 0 {
+		delete(b.listeners, userID)
+	}
+
+	if len(b.listeners) == 0 && b.stop != nil {
+		b.stop()
+		b.stop = nil
+	}
+}
+
+// Wakes the streams of userID, or of everyone when userID is 0
+func (b *Broker) notify(userID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id, listeners := range b.listeners {
+		if userID != 0 && id != userID {
+			continue
+		}
+		for wake := range listeners {
+			select {
+			case wake <- struct{}{}:
+			default: // a wake-up is already pending
+			}
+		}
+	}
+}
+
+// Listens until ctx is cancelled, reconnecting with a growing delay after failures
+func (b *Broker) listen(ctx context.Context) {
+	wait := reconnectWait
+	for ctx.Err() == nil {
+		connected, err := b.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		b.logger.Printf("Error listenOnce: %v", err)
+
+		if connected {
+			wait = reconnectWait
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxReconnectWait)
+	}
+}
+
+// Holds a connection listening on the channel until it fails or ctx is cancelled
+func (b *Broker) listenOnce(ctx context.Context) (bool, error) {
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	connected := false
+	err = conn.Raw(func(driverConn any) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+		_, err := pgConn.Exec(ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize())
+		if err != nil {
+			return err
+		}
+		connected = true
+
+		// Events committed while nobody listened are found by the streams' next read
+		b.notify(0)
+
+		for {
+			notification, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				// The connection still listens, it's closed rather than returned to the pool
+				return fmt.Errorf("%w: %w", driver.ErrBadConn, err)
+			}
+
+			userID, err := strconv.ParseInt(notification.Payload, 10, 64)
+			if err != nil {
+				continue
+			}
+			b.notify(userID)
+		}
+	})
+
+	return connected, err
+}
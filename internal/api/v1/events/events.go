@@ -0,0 +1,19 @@
+// Package events streams the changes to a user's workouts to their devices as
+// server-sent events, whichever instance made the change.
+package events
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// Comments sent while idle, well within the minute proxies and the server's IdleTimeout allow
+	heartbeatInterval = 25 * time.Second
+	// How long clients wait before reconnecting, sent as the stream's retry field
+	retryMillis = 3000
+	// Events loaded per query when catching up
+	pageSize = 100
+)
+
+var ErrInvalidLastEventID = errors.New("Last-Event-ID must be the id of an event")
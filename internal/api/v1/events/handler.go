@@ -0,0 +1,142 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type EventHandler struct {
+	store     EventStore
+	notifier  Notifier
+	logger    *log.Logger
+	heartbeat time.Duration
+}
+
+func NewEventHandler(store EventStore, notifier Notifier, logger *log.Logger) *EventHandler {
+	return &EventHandler{
+		store:     store,
+		notifier:  notifier,
+		logger:    logger,
+		heartbeat: heartbeatInterval,
+	}
+}
+
+// Streams the caller's workout events as server-sent events. Reconnecting clients send
+// the id of the last event they got as Last-Event-ID, or ?last_event_id=, and resume
+// right after it; new streams start with the next event.
+func (eh *EventHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
+	userID := int64(users.CurrentUser(r).ID)
+
+	lastID, resumed, err := readLastEventID(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	// Subscribing before the first read means nothing committed in between is missed
+	wake, unsubscribe := eh.notifier.Subscribe(userID)
+	defer unsubscribe()
+
+	if !resumed {
+		lastID, err = eh.store.LatestEventID(userID)
+		if err != nil {
+			eh.logger.Printf("Error latestEventID: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+			return
+		}
+	}
+
+	// The stream outlives the server's timeouts. Every write gets its own deadline
+	// instead, so a peer that went away without closing is let go within two heartbeats.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	writeDeadline := func() { rc.SetWriteDeadline(time.Now().Add(2 * eh.heartbeat)) }
+	writeDeadline()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // stops nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+
+	heartbeat := time.NewTicker(eh.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		// Every wake-up reads from the last event sent, so coalesced or missed
+		// notifications only delay events until the next one or the next heartbeat
+		writeDeadline()
+		lastID, err = eh.writeEvents(w, userID, lastID)
+		if err != nil {
+			eh.logger.Printf("Error writeEvents: %v", err)
+			return
+		}
+		if rc.Flush() != nil {
+			return // the client is gone
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-wake:
+		case <-heartbeat.C:
+			writeDeadline()
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Writes the user's events after lastID and returns the id of the last one written
+func (eh *EventHandler) writeEvents(w io.Writer, userID, lastID int64) (int64, error) {
+	for {
+		events, err := eh.store.ListEvents(userID, lastID, pageSize)
+		if err != nil {
+			return lastID, err
+		}
+
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return lastID, err
+			}
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			if err != nil {
+				return lastID, err
+			}
+			lastID = event.ID
+		}
+
+		if len(events) < pageSize {
+			return lastID, nil
+		}
+	}
+}
+
+// Reads the Last-Event-ID header browsers send when reconnecting, or ?last_event_id=
+// for clients that can't set it. The bool is false when neither is given.
+func readLastEventID(r *http.Request) (int64, bool, error) {
+	param := r.Header.Get("Last-Event-ID")
+	if param == "" {
+		param = r.URL.Query().Get("last_event_id")
+	}
+	if param == "" {
+		return 0, false, nil
+	}
+
+	id, err := strconv.ParseInt(param, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, ErrInvalidLastEventID
+	}
+	return id, true, nil
+}
@@ -0,0 +1,130 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUsers struct {
+	users.UserStore
+}
+
+func (fakeUsers) GetUser(userID int64) (*users.User, error) {
+	return &users.User{ID: int(userID)}, nil
+}
+
+// Keeps events in memory and wakes streams when one is added
+type fakeEvents struct {
+	mu     sync.Mutex
+	events []outbox.Event
+	wake   chan struct{}
+}
+
+func (fe *fakeEvents) ListEvents(userID, afterID int64, limit int) ([]outbox.Event, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	events := []outbox.Event{}
+	for _, event := range fe.events {
+		if int64(event.UserID) == userID && event.ID > afterID && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (fe *fakeEvents) LatestEventID(userID int64) (int64, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	return fe.events[len(fe.events)-1].ID, nil
+}
+
+func (fe *fakeEvents) Subscribe(userID int64) (<-chan struct{}, func()) {
+	return fe.wake, func() {}
+}
+
+func (fe *fakeEvents) add(event outbox.Event) {
+	fe.mu.Lock()
+	fe.events = append(fe.events, event)
+	fe.mu.Unlock()
+	fe.wake <- struct{}{}
+}
+
+func TestStream(t *testing.T) {
+	events := &fakeEvents{wake: make(chan struct{}, 1)}
+	for id := int64(1); id <= 3; id++ {
+		events.events = append(events.events, outbox.Event{ID: id, Type: outbox.WorkoutUpdated, UserID: 7,
+			WorkoutID: 1, Data: json.RawMessage(`{}`)})
+	}
+	events.events = append(events.events, outbox.Event{ID: 4, Type: outbox.WorkoutCreated, UserID: 8,
+		Data: json.RawMessage(`{}`)})
+
+	handler := NewEventHandler(events, events, log.New(io.Discard, "", 0))
+	handler.heartbeat = 50 * time.Millisecond
+	server := httptest.NewServer(users.RequireUser(fakeUsers{})(http.HandlerFunc(handler.HandleStream)))
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-User-ID", "7")
+	req.Header.Set("Last-Event-ID", "1")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(res.Body)
+	readMessage := func() string {
+		var message strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				return message.String()
+			}
+			message.WriteString(line)
+		}
+	}
+
+	assert.Equal(t, "retry: 3000\n", readMessage())
+	assert.True(t, strings.HasPrefix(readMessage(), "id: 2\nevent: workout.updated\ndata: {\"id\":2,"),
+		"resumes after Last-Event-ID")
+	assert.True(t, strings.HasPrefix(readMessage(), "id: 3\n"))
+
+	events.add(outbox.Event{ID: 5, Type: outbox.WorkoutDeleted, UserID: 7, Data: json.RawMessage(`{"id":1}`)})
+	assert.True(t, strings.HasPrefix(readMessage(), "id: 5\nevent: workout.deleted\n"), "other users' events are skipped")
+
+	assert.Equal(t, ": heartbeat\n", readMessage())
+}
+
+func TestReadLastEventID(t *testing.T) {
+	req := httptest.NewRequest("GET", "/stream?last_event_id=12", nil)
+	id, resumed, err := readLastEventID(req)
+	require.NoError(t, err)
+	assert.True(t, resumed)
+	assert.Equal(t, int64(12), id)
+
+	req.Header.Set("Last-Event-ID", "15")
+	id, _, _ = readLastEventID(req)
+	assert.Equal(t, int64(15), id, "the header wins")
+
+	_, resumed, err = readLastEventID(httptest.NewRequest("GET", "/stream", nil))
+	require.NoError(t, err)
+	assert.False(t, resumed)
+
+	_, _, err = readLastEventID(httptest.NewRequest("GET", "/stream?last_event_id=abc", nil))
+	assert.ErrorIs(t, err, ErrInvalidLastEventID)
+}
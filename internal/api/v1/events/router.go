@@ -0,0 +1,22 @@
+package events
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/go-chi/chi/v5"
+)
+
+func EventRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and store
+	r := chi.NewRouter()
+	store := NewPostgresEventStore(app.DB)
+	handler := NewEventHandler(store, NewBroker(app.DB, OutboxChannel, app.Logger), app.Logger)
+
+	r.Use(users.RequireUser(users.NewPostgresUserStore(app.DB)))
+
+	// Define subroutes
+	r.Get("/stream", handler.HandleStream)
+
+	return r
+}
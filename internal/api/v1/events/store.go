@@ -0,0 +1,91 @@
+package events
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/outbox"
+)
+
+// How long streams wait on a transaction still running before reading past it
+const MaxCommitLag = 30 * time.Second
+
+// DB connector struct
+type PostgresEventStore struct {
+	db     *sql.DB
+	maxLag time.Duration
+}
+
+func NewPostgresEventStore(db *sql.DB) *PostgresEventStore {
+	return &PostgresEventStore{db: db, maxLag: MaxCommitLag}
+}
+
+type EventStore interface {
+	ListEvents(userID, afterID int64, limit int) ([]outbox.Event, error)
+	LatestEventID(userID int64) (int64, error)
+}
+
+// Lists up to limit of the user's events after the event afterID, in the order their
+// transactions committed in. Only events of transactions older than every one still
+// running are listed: one of those may yet commit an event that sorts earlier, and
+// the stream would already have passed it. Streams see such events on their next read.
+//
+// Any transaction holding an xid holds back the streams of every user, so the wait is
+// bounded: events older than maxLag are listed even while an older transaction runs.
+// An event that transaction commits afterwards sorts before the stream's position and
+// is skipped by it, the webhooks relay still delivers it. Transactions writing events
+// are short, so only one stuck past maxLag costs streams its events.
+func (pgStore *PostgresEventStore) ListEvents(userID, afterID int64, limit int) ([]outbox.Event, error) {
+	query := `
+	WITH after AS (
+		SELECT xid, id FROM outbox_events WHERE id = $2
+	)
+	SELECT e.id, e.event_type, e.user_id, e.workout_id, e.payload, e.created_at
+	FROM outbox_events e
+	WHERE e.user_id = $1
+		AND (e.xid < pg_snapshot_xmin(pg_current_snapshot())
+			OR e.created_at < CURRENT_TIMESTAMP - make_interval(secs => $4))
+		AND CASE WHEN EXISTS (SELECT 1 FROM after)
+			THEN (e.xid, e.id) > (SELECT xid, id FROM after)
+			ELSE e.id > $2 END
+	ORDER BY e.xid, e.id
+	LIMIT $3
+	`
+	rows, err := pgStore.db.Query(query, userID, afterID, limit, pgStore.maxLag.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []outbox.Event{}
+	for rows.Next() {
+		var event outbox.Event
+		var payload []byte
+		err := rows.Scan(&event.ID, &event.Type, &event.UserID, &event.WorkoutID, &payload, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		event.Data = payload
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// The last event ListEvents would list, 0 when the user has no events yet
+func (pgStore *PostgresEventStore) LatestEventID(userID int64) (int64, error) {
+	query := `
+	SELECT COALESCE((
+		SELECT id
+		FROM outbox_events
+		WHERE user_id = $1
+			AND (xid < pg_snapshot_xmin(pg_current_snapshot())
+				OR created_at < CURRENT_TIMESTAMP - make_interval(secs => $2))
+		ORDER BY xid DESC, id DESC
+		LIMIT 1
+	), 0)
+	`
+	var id int64
+	err := pgStore.db.QueryRow(query, userID, pgStore.maxLag.Seconds()).Scan(&id)
+	return id, err
+}
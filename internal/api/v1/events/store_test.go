@@ -0,0 +1,119 @@
+package events
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/outbox"
+	"github.com/Josesx506/gofems/internal/store"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrokerNotifications(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresEventStore(db)
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+
+	var userID int64
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('lifter', 'lifter@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	latest, err := pgStore.LatestEventID(userID)
+	require.NoError(t, err)
+	assert.Zero(t, latest)
+
+	broker := NewBroker(db, OutboxChannel, log.New(io.Discard, "", 0))
+	wake, unsubscribe := broker.Subscribe(userID)
+	defer unsubscribe()
+
+	waitForWake := func() {
+		select {
+		case <-wake:
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream wasn't woken")
+		}
+	}
+	waitForWake() // listening has started
+
+	workout, err := workoutStore.CreateWorkout(&workouts.Workout{UserID: int(userID), Title: "Leg day"})
+	require.NoError(t, err)
+	waitForWake()
+
+	// Events wait for older transactions, which other test packages may hold open briefly
+	var events []outbox.Event
+	require.Eventually(t, func() bool {
+		events, err = pgStore.ListEvents(userID, latest, 10)
+		return err == nil && len(events) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, outbox.WorkoutCreated, events[0].Type)
+	assert.Equal(t, workout.ID, events[0].WorkoutID)
+
+	latest, err = pgStore.LatestEventID(userID)
+	require.NoError(t, err)
+	assert.Equal(t, events[len(events)-1].ID, latest)
+
+	// An event committed after a later one was read still follows it
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, outbox.Write(tx, outbox.WorkoutUpdated, int(userID), workout.ID, map[string]int{"id": workout.ID}))
+	laterWorkout, err := workoutStore.CreateWorkout(&workouts.Workout{UserID: int(userID), Title: "Arm day"})
+	require.NoError(t, err)
+
+	held, err := pgStore.ListEvents(userID, latest, 10)
+	require.NoError(t, err)
+	assert.Empty(t, held, "events wait for the open transaction")
+
+	require.NoError(t, tx.Commit())
+	require.Eventually(t, func() bool {
+		events, err = pgStore.ListEvents(userID, latest, 10)
+		return err == nil && len(events) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, outbox.WorkoutUpdated, events[0].Type, "in commit order of its transaction")
+	assert.Equal(t, laterWorkout.ID, events[1].WorkoutID)
+}
+
+func TestListEventsPastOpenTransaction(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := &PostgresEventStore{db: db, maxLag: time.Second}
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+
+	var userID int64
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('lifter', 'lifter@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	// A transaction holding an xid, left open like a stuck session would
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.Exec(`SELECT pg_current_xact_id()`)
+	require.NoError(t, err)
+
+	workout, err := workoutStore.CreateWorkout(&workouts.Workout{UserID: int(userID), Title: "Leg day"})
+	require.NoError(t, err)
+
+	held, err := pgStore.ListEvents(userID, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, held, "events wait for the open transaction")
+
+	// Past the max lag the stream reads on while the transaction is still open
+	var events []outbox.Event
+	require.Eventually(t, func() bool {
+		events, err = pgStore.ListEvents(userID, 0, 10)
+		return err == nil && len(events) > 0
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.Equal(t, outbox.WorkoutCreated, events[0].Type)
+	assert.Equal(t, workout.ID, events[0].WorkoutID)
+
+	latest, err := pgStore.LatestEventID(userID)
+	require.NoError(t, err)
+	assert.Equal(t, events[0].ID, latest)
+}
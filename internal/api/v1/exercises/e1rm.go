@@ -0,0 +1,47 @@
+package exercises
+
+import (
+	"time"
+
+	"github.com/Josesx506/gofems/internal/onerm"
+)
+
+// A logged set with both a weight and a rep count, the raw material for one rep max estimates
+type LoggedSet struct {
+	WorkoutID   int       `json:"workout_id"`
+	EntryID     int       `json:"entry_id"`
+	PerformedAt time.Time `json:"performed_at"`
+	Weight      float64   `json:"weight"`
+	Reps        int       `json:"reps"`
+}
+
+// The best estimated one rep max of a workout and the set it came from
+type OneRepMaxPoint struct {
+	LoggedSet
+	Estimate float64 `json:"estimated_1rm"`
+}
+
+// OneRepMaxHistory keeps the best estimate of each workout, in the order sets were logged.
+// Sets the formula can't estimate, such as ones past onerm.MaxReps, are skipped.
+func OneRepMaxHistory(sets []LoggedSet, formula onerm.Formula) []OneRepMaxPoint {
+	history := []OneRepMaxPoint{}
+	index := map[int]int{}
+	for _, set := range sets {
+		estimate, err := onerm.Estimate(formula, set.Weight, set.Reps)
+		if err != nil {
+			continue
+		}
+
+		point := OneRepMaxPoint{LoggedSet: set, Estimate: estimate}
+		i, ok := index[set.WorkoutID]
+		if !ok {
+			index[set.WorkoutID] = len(history)
+			history = append(history, point)
+			continue
+		}
+		if estimate > history[i].Estimate {
+			history[i] = point
+		}
+	}
+	return history
+}
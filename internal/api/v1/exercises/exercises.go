@@ -0,0 +1,61 @@
+package exercises
+
+import (
+	"errors"
+	"strings"
+)
+
+// A catalog exercise. Built-in exercises have no owner, custom ones belong to a user.
+type Exercise struct {
+	ID               int      `json:"id"`
+	UserID           int      `json:"user_id"`
+	Name             string   `json:"name"`
+	Aliases          []string `json:"aliases"`
+	Category         string   `json:"category"`
+	PrimaryMuscles   []string `json:"primary_muscles"`
+	SecondaryMuscles []string `json:"secondary_muscles"`
+	Equipment        string   `json:"equipment"`
+	Unilateral       bool     `json:"unilateral"`
+	MeasurementType  string   `json:"measurement_type"` // reps or duration
+	BuiltIn          bool     `json:"built_in"`
+}
+
+const (
+	MeasurementReps     = "reps"
+	MeasurementDuration = "duration"
+)
+
+// Checks a custom exercise and fills in defaults before it reaches the db
+func (e *Exercise) Validate() error {
+	e.Name = strings.TrimSpace(e.Name)
+	if e.Name == "" {
+		return errors.New("exercise name is required")
+	}
+
+	if e.Category == "" {
+		return errors.New("exercise category is required")
+	}
+
+	if e.MeasurementType == "" {
+		e.MeasurementType = MeasurementReps
+	}
+	if e.MeasurementType != MeasurementReps && e.MeasurementType != MeasurementDuration {
+		return errors.New("measurement_type must be reps or duration")
+	}
+
+	if e.Equipment == "" {
+		e.Equipment = "none"
+	}
+
+	if e.Aliases == nil {
+		e.Aliases = []string{}
+	}
+	if e.PrimaryMuscles == nil {
+		e.PrimaryMuscles = []string{}
+	}
+	if e.SecondaryMuscles == nil {
+		e.SecondaryMuscles = []string{}
+	}
+
+	return nil
+}
@@ -0,0 +1,349 @@
+package exercises
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/onerm"
+	"github.com/Josesx506/gofems/internal/units"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type ExerciseHandler struct {
+	store     ExerciseStore
+	userStore users.UserStore
+	logger    *log.Logger
+}
+
+func NewExerciseHandler(store ExerciseStore, userStore users.UserStore, logger *log.Logger) *ExerciseHandler {
+	return &ExerciseHandler{
+		store:     store,
+		userStore: userStore,
+		logger:    logger,
+	}
+}
+
+// Lists the built-in library and the custom exercises of ?user_id=, filtered by ?q=
+func (eh *ExerciseHandler) HandleListExercises(w http.ResponseWriter, r *http.Request) {
+	var userID int64
+	if param := r.URL.Query().Get("user_id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user id"}) // 400
+			return
+		}
+		userID = id
+	}
+
+	exercises, err := eh.store.ListExercises(userID, r.URL.Query().Get("q"))
+	if err != nil {
+		eh.logger.Printf("Error listExercises: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercises": exercises})
+}
+
+func (eh *ExerciseHandler) HandleGetExerciseByID(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		eh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	exercise, err := eh.store.GetExerciseByID(exerciseID)
+	if err != nil {
+		eh.logger.Printf("Error getExerciseByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if exercise == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "exercise not found"}) // 404
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+func (eh *ExerciseHandler) HandleCreateExercise(w http.ResponseWriter, r *http.Request) {
+	var exercise Exercise
+
+	err := json.NewDecoder(r.Body).Decode(&exercise)
+	if err != nil {
+		eh.logger.Printf("Error decodingCreateExercise: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	if exercise.UserID == 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "user_id is required for custom exercises"}) // 400
+		return
+	}
+
+	err = exercise.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	createdExercise, err := eh.store.CreateExercise(&exercise)
+	if eh.writeConflict(w, err) {
+		return
+	}
+
+	if err != nil {
+		eh.logger.Printf("Error createExercise: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create exercise"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"exercise": createdExercise})
+}
+
+func (eh *ExerciseHandler) HandleUpdateExerciseByID(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		eh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	var exercise Exercise
+
+	err = json.NewDecoder(r.Body).Decode(&exercise)
+	if err != nil {
+		eh.logger.Printf("Error decodingUpdateExercise: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	err = exercise.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	exercise.ID = int(exerciseID)
+
+	err = eh.store.UpdateExercise(&exercise)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "custom exercise not found"}) // 404
+		return
+	}
+
+	if eh.writeConflict(w, err) {
+		return
+	}
+
+	if err != nil {
+		eh.logger.Printf("Error updateExercise: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update exercise"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+func (eh *ExerciseHandler) HandleDeleteExerciseByID(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		eh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	err = eh.store.DeleteExercise(exerciseID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "custom exercise not found"}) // 404
+		return
+	}
+
+	if err != nil {
+		eh.logger.Printf("Error deleteExercise: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete exercise"}) // 500
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (eh *ExerciseHandler) writeConflict(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, ErrDuplicateExercise):
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+	case errors.Is(err, ErrUnknownUser):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+	default:
+		return false
+	}
+	return true
+}
+
+// Autocomplete for ?q= over names and aliases of the built-in library and ?user_id='s custom exercises
+func (eh *ExerciseHandler) HandleSuggestExercises(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "q is required"}) // 400
+		return
+	}
+
+	var userID int64
+	if param := r.URL.Query().Get("user_id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user id"}) // 400
+			return
+		}
+		userID = id
+	}
+
+	limit := 10
+	if param := r.URL.Query().Get("limit"); param != "" {
+		l, err := strconv.Atoi(param)
+		if err != nil || l <= 0 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid limit"}) // 400
+			return
+		}
+		limit = l
+	}
+
+	candidates, err := eh.store.ListExercises(userID, "")
+	if err != nil {
+		eh.logger.Printf("Error listExercises: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"suggestions": Suggest(query, candidates, limit)})
+}
+
+// Best estimated one rep max per workout for ?user_id=, computed with ?formula= (Epley by default)
+func (eh *ExerciseHandler) HandleGetOneRepMaxHistory(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		eh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	formula, err := onerm.ParseFormula(r.URL.Query().Get("formula"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	var userID int64
+	if param := r.URL.Query().Get("user_id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user id"}) // 400
+			return
+		}
+		userID = id
+	}
+
+	exercise, err := eh.store.GetExerciseByID(exerciseID)
+	if err != nil {
+		eh.logger.Printf("Error getExerciseByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if exercise == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "exercise not found"}) // 404
+		return
+	}
+
+	unit, ok := users.ReadWeightUnit(w, r, eh.userStore, userID, eh.logger)
+	if !ok {
+		return
+	}
+
+	sets, err := eh.store.ListLoggedSets(exerciseID, userID)
+	if err != nil {
+		eh.logger.Printf("Error listLoggedSets: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	history := OneRepMaxHistory(sets, formula)
+	for i := range history {
+		history[i].Weight = units.FromKilograms(history[i].Weight, unit)
+		history[i].Estimate = units.FromKilograms(history[i].Estimate, unit)
+	}
+
+	var best *OneRepMaxPoint
+	for i := range history {
+		if best == nil || history[i].Estimate > best.Estimate {
+			best = &history[i]
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"exercise":    exercise,
+		"formula":     formula,
+		"weight_unit": unit,
+		"best":        best,
+		"history":     history,
+	})
+}
+
+// Standalone calculator: estimates the one rep max from ?weight= and ?reps= with every formula,
+// or takes a known ?one_rep_max=, and lays out a 50-100% loading table with the ?formula= estimate
+func (eh *ExerciseHandler) HandleCalculateOneRepMax(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	formula, err := onerm.ParseFormula(query.Get("formula"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	var oneRepMax float64
+	estimates := map[onerm.Formula]float64{}
+
+	if param := query.Get("one_rep_max"); param != "" {
+		oneRepMax, err = strconv.ParseFloat(param, 64)
+		if err != nil || oneRepMax <= 0 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "one_rep_max must be a positive number"}) // 400
+			return
+		}
+	} else {
+		weight, err := strconv.ParseFloat(query.Get("weight"), 64)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "weight and reps, or one_rep_max, are required"}) // 400
+			return
+		}
+		reps, err := strconv.Atoi(query.Get("reps"))
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "weight and reps, or one_rep_max, are required"}) // 400
+			return
+		}
+
+		for _, f := range onerm.Formulas {
+			estimate, err := onerm.Estimate(f, weight, reps)
+			if err != nil {
+				utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+				return
+			}
+			estimates[f] = math.Round(estimate*100) / 100
+		}
+		oneRepMax = estimates[formula]
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"formula":       formula,
+		"estimated_1rm": oneRepMax,
+		"estimates":     estimates,
+		"percentages":   onerm.PercentageTable(formula, oneRepMax),
+	})
+}
@@ -0,0 +1,27 @@
+package exercises
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/go-chi/chi/v5"
+)
+
+func ExerciseRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and store
+	r := chi.NewRouter()
+	store := NewPostgresExerciseStore(app.DB)
+	handler := NewExerciseHandler(store, users.NewPostgresUserStore(app.DB), app.Logger)
+
+	// Define subroutes
+	r.Get("/", handler.HandleListExercises)
+	r.Post("/", handler.HandleCreateExercise)
+	r.Get("/suggest", handler.HandleSuggestExercises)
+	r.Get("/e1rm", handler.HandleCalculateOneRepMax)
+	r.Get("/{id}", handler.HandleGetExerciseByID)
+	r.Put("/{id}", handler.HandleUpdateExerciseByID)
+	r.Delete("/{id}", handler.HandleDeleteExerciseByID)
+	r.Get("/{id}/e1rm", handler.HandleGetOneRepMaxHistory)
+
+	return r
+}
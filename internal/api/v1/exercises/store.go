@@ -0,0 +1,220 @@
+package exercises
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
+)
+
+var (
+	ErrDuplicateExercise = errors.New("an exercise with this name already exists")
+	ErrUnknownUser       = errors.New("exercise user does not exist")
+)
+
+// DB connector struct
+type PostgresExerciseStore struct {
+	db *sql.DB
+}
+
+func NewPostgresExerciseStore(db *sql.DB) *PostgresExerciseStore {
+	return &PostgresExerciseStore{db: db}
+}
+
+type ExerciseStore interface {
+	CreateExercise(*Exercise) (*Exercise, error)
+	GetExerciseByID(id int64) (*Exercise, error)
+	ListExercises(userID int64, query string) ([]Exercise, error)
+	UpdateExercise(*Exercise) error
+	DeleteExercise(id int64) error
+	ListLoggedSets(exerciseID, userID int64) ([]LoggedSet, error)
+}
+
+const exerciseColumns = `id, user_id, name, aliases, category, primary_muscles, secondary_muscles,
+	equipment, unilateral, measurement_type`
+
+// Custom exercises are always owned by a user, the built-in library is seeded by migrations
+func (pgStore *PostgresExerciseStore) CreateExercise(exercise *Exercise) (*Exercise, error) {
+	query := `
+	INSERT INTO exercises (user_id, name, aliases, category, primary_muscles, secondary_muscles,
+		equipment, unilateral, measurement_type)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING id
+	`
+	err := pgStore.db.QueryRow(query, exercise.UserID, exercise.Name, exercise.Aliases, exercise.Category,
+		exercise.PrimaryMuscles, exercise.SecondaryMuscles, exercise.Equipment, exercise.Unilateral,
+		exercise.MeasurementType).Scan(&exercise.ID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return exercise, nil
+}
+
+func (pgStore *PostgresExerciseStore) GetExerciseByID(id int64) (*Exercise, error) {
+	query := `SELECT ` + exerciseColumns + ` FROM exercises WHERE id = $1`
+
+	exercise, err := scanExercise(pgStore.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil // No exercise found
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return exercise, nil
+}
+
+// Lists the built-in library plus the custom exercises of userID, optionally
+// filtered by a case-insensitive substring of the name or an alias.
+func (pgStore *PostgresExerciseStore) ListExercises(userID int64, search string) ([]Exercise, error) {
+	query := `
+	SELECT ` + exerciseColumns + `
+	FROM exercises
+	WHERE (user_id IS NULL OR user_id = $1) AND (
+		$2 = '' OR
+		name ILIKE '%' || $2 || '%' OR
+		EXISTS (SELECT 1 FROM UNNEST(aliases) alias WHERE alias ILIKE '%' || $2 || '%')
+	)
+	ORDER BY name ASC, id ASC
+	`
+	rows, err := pgStore.db.Query(query, userID, search)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []Exercise{}
+	for rows.Next() {
+		exercise, err := scanExercise(rows)
+		if err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, *exercise)
+	}
+
+	return exercises, rows.Err()
+}
+
+// Only custom exercises can be changed, built-in ones report sql.ErrNoRows
+func (pgStore *PostgresExerciseStore) UpdateExercise(exercise *Exercise) error {
+	query := `
+	UPDATE exercises
+	SET name = $1, aliases = $2, category = $3, primary_muscles = $4, secondary_muscles = $5,
+		equipment = $6, unilateral = $7, measurement_type = $8, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $9 AND user_id IS NOT NULL
+	RETURNING user_id
+	`
+	err := pgStore.db.QueryRow(query, exercise.Name, exercise.Aliases, exercise.Category, exercise.PrimaryMuscles,
+		exercise.SecondaryMuscles, exercise.Equipment, exercise.Unilateral, exercise.MeasurementType,
+		exercise.ID).Scan(&exercise.UserID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+// Entries linked to a deleted custom exercise keep their exercise_name
+func (pgStore *PostgresExerciseStore) DeleteExercise(id int64) error {
+	result, err := pgStore.db.Exec(`DELETE FROM exercises WHERE id = $1 AND user_id IS NOT NULL`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Lists the weighted entries userID logged against the exercise in live workouts, oldest first.
+// A userID of 0 selects workouts logged without a user.
+func (pgStore *PostgresExerciseStore) ListLoggedSets(exerciseID, userID int64) ([]LoggedSet, error) {
+	query := `
+	SELECT w.id, we.id, w.created_at, we.weight, we.reps
+	FROM workout_entries we
+	JOIN workouts w ON w.id = we.workout_id
+	WHERE we.exercise_id = $1 AND w.user_id IS NOT DISTINCT FROM $2 AND w.deleted_at IS NULL
+		AND we.weight > 0 AND we.reps > 0
+	ORDER BY w.created_at ASC, w.id ASC, we.order_index ASC
+	`
+	rows, err := pgStore.db.Query(query, exerciseID, sql.NullInt64{Int64: userID, Valid: userID != 0})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := []LoggedSet{}
+	for rows.Next() {
+		var set LoggedSet
+		err := rows.Scan(&set.WorkoutID, &set.EntryID, &set.PerformedAt, &set.Weight, &set.Reps)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+
+	return sets, rows.Err()
+}
+
+// Shared scanner for *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExercise(row rowScanner) (*Exercise, error) {
+	exercise := &Exercise{}
+	var userID sql.NullInt64
+	var aliases, primaryMuscles, secondaryMuscles pgtype.TextArray
+
+	err := row.Scan(&exercise.ID, &userID, &exercise.Name, &aliases, &exercise.Category, &primaryMuscles,
+		&secondaryMuscles, &exercise.Equipment, &exercise.Unilateral, &exercise.MeasurementType)
+	if err != nil {
+		return nil, err
+	}
+
+	exercise.UserID = int(userID.Int64)
+	exercise.BuiltIn = !userID.Valid
+
+	for _, array := range []struct {
+		src *pgtype.TextArray
+		dst *[]string
+	}{
+		{&aliases, &exercise.Aliases},
+		{&primaryMuscles, &exercise.PrimaryMuscles},
+		{&secondaryMuscles, &exercise.SecondaryMuscles},
+	} {
+		*array.dst = []string{}
+		err := array.src.AssignTo(array.dst)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return exercise, nil
+}
+
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch {
+	case pgErr.Code == "23505" && pgErr.ConstraintName == "exercises_user_name_key": // unique_violation
+		return ErrDuplicateExercise
+	case pgErr.Code == "23503" && pgErr.ConstraintName == "exercises_user_id_fkey": // foreign_key_violation
+		return ErrUnknownUser
+	}
+
+	return err
+}
@@ -0,0 +1,100 @@
+package exercises
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/onerm"
+	"github.com/Josesx506/gofems/internal/store"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExerciseCatalog(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresExerciseStore(db)
+
+	var userID int
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('coach', 'coach@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	// Aliases are searchable and built-in exercises can't be edited
+	matches, err := pgStore.ListExercises(0, "squats")
+	require.NoError(t, err)
+	require.NotEmpty(t, matches)
+	assert.Equal(t, "Back Squat", matches[0].Name)
+	assert.True(t, matches[0].BuiltIn)
+	assert.Contains(t, matches[0].PrimaryMuscles, "quadriceps")
+
+	backSquat := matches[0]
+	assert.ErrorIs(t, pgStore.UpdateExercise(&backSquat), sql.ErrNoRows)
+	assert.ErrorIs(t, pgStore.DeleteExercise(int64(backSquat.ID)), sql.ErrNoRows)
+
+	custom := &Exercise{UserID: userID, Name: "Zercher Squat", Aliases: []string{"Zerchers"}, Category: "strength"}
+	require.NoError(t, custom.Validate())
+	_, err = pgStore.CreateExercise(custom)
+	require.NoError(t, err)
+
+	duplicate := &Exercise{UserID: userID, Name: "zercher squat", Category: "strength"}
+	require.NoError(t, duplicate.Validate())
+	_, err = pgStore.CreateExercise(duplicate)
+	assert.ErrorIs(t, err, ErrDuplicateExercise)
+
+	// Custom exercises are only listed for their owner
+	mine, err := pgStore.ListExercises(int64(userID), "zercher")
+	require.NoError(t, err)
+	assert.Len(t, mine, 1)
+	others, err := pgStore.ListExercises(0, "zercher")
+	require.NoError(t, err)
+	assert.Empty(t, others)
+}
+
+func TestSuggest(t *testing.T) {
+	catalog := []Exercise{
+		{ID: 1, Name: "Back Squat", Aliases: []string{"Squat", "Squats"}, BuiltIn: true},
+		{ID: 2, Name: "Bench Press", Aliases: []string{"Flat Bench"}, BuiltIn: true},
+		{ID: 3, Name: "Leg Press", BuiltIn: true},
+		{ID: 4, Name: "Bench Press", UserID: 9}, // custom variant with the same name
+	}
+
+	suggestions := Suggest("ben", catalog, 2)
+	require.Len(t, suggestions, 2)
+	assert.Equal(t, 4, suggestions[0].Exercise.ID, "custom exercise wins the tie")
+	assert.Equal(t, 2, suggestions[1].Exercise.ID)
+
+	match := BestMatch("squats", catalog, DefaultMatchThreshold)
+	require.NotNil(t, match)
+	assert.Equal(t, 1, match.Exercise.ID)
+	assert.Equal(t, "Squats", match.MatchedName)
+
+	match = BestMatch("Benchpress", catalog, DefaultMatchThreshold)
+	require.NotNil(t, match)
+	assert.Equal(t, "Bench Press", match.Exercise.Name)
+
+	// Prefixes autocomplete but don't auto-link
+	assert.Nil(t, BestMatch("ben", catalog, DefaultMatchThreshold))
+	assert.Nil(t, BestMatch("Turkish Get Up", catalog, DefaultMatchThreshold))
+}
+
+func TestOneRepMaxHistory(t *testing.T) {
+	day := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	sets := []LoggedSet{
+		{WorkoutID: 1, EntryID: 1, PerformedAt: day, Weight: 100, Reps: 5},
+		{WorkoutID: 1, EntryID: 2, PerformedAt: day, Weight: 115, Reps: 1},
+		{WorkoutID: 2, EntryID: 3, PerformedAt: day.AddDate(0, 0, 3), Weight: 60, Reps: 40}, // past onerm.MaxReps
+		{WorkoutID: 3, EntryID: 4, PerformedAt: day.AddDate(0, 0, 7), Weight: 105, Reps: 5},
+	}
+
+	history := OneRepMaxHistory(sets, onerm.Epley)
+	require.Len(t, history, 2)
+	assert.Equal(t, 1, history[0].EntryID, "100x5 estimates higher than a 115 single")
+	assert.InDelta(t, 116.67, history[0].Estimate, 0.01)
+	assert.Equal(t, 3, history[1].WorkoutID)
+
+	history = OneRepMaxHistory(sets, onerm.Brzycki)
+	assert.Equal(t, 2, history[0].EntryID, "Brzycki puts 100x5 at 112.5")
+}
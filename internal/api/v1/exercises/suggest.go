@@ -0,0 +1,64 @@
+package exercises
+
+import (
+	"sort"
+
+	"github.com/Josesx506/gofems/internal/fuzzy"
+)
+
+// Entries are only auto-linked when the best match scores at least this much
+const DefaultMatchThreshold = 0.8
+
+// A ranked catalog exercise for a typed name. MatchedName is the name or
+// alias that scored best.
+type Suggestion struct {
+	Exercise    Exercise `json:"exercise"`
+	MatchedName string   `json:"matched_name"`
+	Score       float64  `json:"score"`
+}
+
+// Suggest ranks candidates for autocomplete, favouring names that start with the query
+func Suggest(query string, candidates []Exercise, limit int) []Suggestion {
+	suggestions := rank(query, candidates, fuzzy.PrefixSimilarity)
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// BestMatch returns the closest candidate to a full exercise name, or nil when
+// nothing scores at least threshold. Prefixes get no boost here so that a short
+// name isn't linked to a longer, different exercise.
+func BestMatch(name string, candidates []Exercise, threshold float64) *Suggestion {
+	suggestions := rank(name, candidates, fuzzy.Similarity)
+	if len(suggestions) == 0 || suggestions[0].Score < threshold {
+		return nil
+	}
+	return &suggestions[0]
+}
+
+func rank(query string, candidates []Exercise, score func(query, candidate string) float64) []Suggestion {
+	suggestions := []Suggestion{}
+	for _, exercise := range candidates {
+		best := Suggestion{Exercise: exercise}
+		for _, name := range append([]string{exercise.Name}, exercise.Aliases...) {
+			if s := score(query, name); s > best.Score {
+				best.Score = s
+				best.MatchedName = name
+			}
+		}
+		if best.Score > 0 {
+			suggestions = append(suggestions, best)
+		}
+	}
+
+	// Custom exercises win ties so users get their own variant first
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return !suggestions[i].Exercise.BuiltIn && suggestions[j].Exercise.BuiltIn
+	})
+
+	return suggestions
+}
@@ -0,0 +1,331 @@
+package live
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/events"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/units"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type LiveHandler struct {
+	store     LiveStore
+	userStore users.UserStore
+	notifier  events.Notifier
+	logger    *log.Logger
+
+	tickInterval time.Duration
+	pingInterval time.Duration
+}
+
+// The notifier wakes the sockets of a user whenever one of their sessions changes
+func NewLiveHandler(store LiveStore, userStore users.UserStore, notifier events.Notifier,
+	logger *log.Logger) *LiveHandler {
+	return &LiveHandler{
+		store:        store,
+		userStore:    userStore,
+		notifier:     notifier,
+		logger:       logger,
+		tickInterval: time.Second,
+		pingInterval: pingInterval,
+	}
+}
+
+// What was actually done, each field given replaces the planned one
+type completeSetRequest struct {
+	SetType         *string  `json:"set_type"`
+	Reps            *int     `json:"reps"`
+	DurationSeconds *int     `json:"duration_seconds"`
+	Weight          *float64 `json:"weight"`
+	WeightUnit      *string  `json:"weight_unit"`
+	RPE             *float64 `json:"rpe"`
+}
+
+type startRestRequest struct {
+	Seconds int `json:"seconds"`
+}
+
+func (lh *LiveHandler) HandleStartSession(w http.ResponseWriter, r *http.Request) {
+	var session Session
+	err := json.NewDecoder(r.Body).Decode(&session)
+	if err != nil {
+		lh.logger.Printf("Error decodingStartSession: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+	session.UserID = users.CurrentUser(r).ID
+
+	err = session.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	startedSession, err := lh.store.StartSession(&session)
+	if errors.Is(err, ErrSessionActive) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+		return
+	}
+	if err != nil {
+		lh.logger.Printf("Error startSession: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to start session"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"session": startedSession})
+}
+
+// The session in progress, for a device that lost track of it
+func (lh *LiveHandler) HandleGetActiveSession(w http.ResponseWriter, r *http.Request) {
+	session, err := lh.store.GetActiveSession(int64(users.CurrentUser(r).ID))
+	if err != nil {
+		lh.logger.Printf("Error getActiveSession: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if session == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "no session in progress"}) // 404
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"session": session})
+}
+
+func (lh *LiveHandler) HandleGetSessionByID(w http.ResponseWriter, r *http.Request) {
+	session, ok := lh.readSession(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"session": session})
+}
+
+// Plans a set, it's completed once done
+func (lh *LiveHandler) HandleAddSet(w http.ResponseWriter, r *http.Request) {
+	session, ok := lh.readSession(w, r)
+	if !ok {
+		return
+	}
+
+	var set Set
+	err := json.NewDecoder(r.Body).Decode(&set)
+	if err != nil {
+		lh.logger.Printf("Error decodingAddSet: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	err = set.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	err = lh.store.AddSet(int64(session.ID), &set)
+	lh.writeSession(w, session.ID, err, http.StatusCreated)
+}
+
+// Completes a set, with the body correcting what was planned when it went differently
+func (lh *LiveHandler) HandleCompleteSet(w http.ResponseWriter, r *http.Request) {
+	session, ok := lh.readSession(w, r)
+	if !ok {
+		return
+	}
+
+	setID, err := utils.ReadInt64Param(r, "setID")
+	if err != nil {
+		lh.logger.Printf("Error reading setID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid set id"}) // 400
+		return
+	}
+
+	var set *Set
+	for i := range session.Sets {
+		if int64(session.Sets[i].ID) == setID {
+			set = &session.Sets[i]
+		}
+	}
+	if set == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": ErrUnknownSet.Error()}) // 404
+		return
+	}
+
+	// The body is optional, a set done as planned is completed without one
+	var req completeSetRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		lh.logger.Printf("Error decodingCompleteSet: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+	if req.SetType != nil {
+		set.SetType = *req.SetType
+	}
+	if req.Reps != nil {
+		set.Reps, set.DurationSeconds = req.Reps, nil
+	}
+	if req.DurationSeconds != nil {
+		set.DurationSeconds, set.Reps = req.DurationSeconds, nil
+	}
+	if req.Weight != nil {
+		set.Weight = req.Weight
+	}
+	if req.WeightUnit != nil {
+		set.WeightUnit = units.WeightUnit(*req.WeightUnit)
+	}
+	if req.RPE != nil {
+		set.RPE = req.RPE
+	}
+
+	err = set.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	err = lh.store.CompleteSet(int64(session.ID), set)
+	lh.writeSession(w, session.ID, err, http.StatusOK)
+}
+
+func (lh *LiveHandler) HandleStartRest(w http.ResponseWriter, r *http.Request) {
+	session, ok := lh.readSession(w, r)
+	if !ok {
+		return
+	}
+
+	var req startRestRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		lh.logger.Printf("Error decodingStartRest: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	err = ValidateRest(req.Seconds)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	err = lh.store.StartRest(int64(session.ID), req.Seconds)
+	lh.writeSession(w, session.ID, err, http.StatusOK)
+}
+
+// Stops the rest timer, the rest taken is recorded on the last completed set
+func (lh *LiveHandler) HandleStopRest(w http.ResponseWriter, r *http.Request) {
+	session, ok := lh.readSession(w, r)
+	if !ok {
+		return
+	}
+
+	err := lh.store.StopRest(int64(session.ID))
+	lh.writeSession(w, session.ID, err, http.StatusOK)
+}
+
+// Saves the session as a workout with an entry per exercise
+func (lh *LiveHandler) HandleFinishSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := lh.readSession(w, r)
+	if !ok {
+		return
+	}
+
+	unit, ok := users.ReadWeightUnit(w, r, lh.userStore, int64(session.UserID), lh.logger)
+	if !ok {
+		return
+	}
+
+	workout, err := lh.store.FinishSession(int64(session.ID))
+	switch {
+	case errors.Is(err, ErrSessionNotActive):
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+		return
+	case errors.Is(err, ErrNothingCompleted), errors.Is(err, workouts.ErrInvalidSet):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	case err != nil:
+		lh.logger.Printf("Error finishSession: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to finish session"}) // 500
+		return
+	}
+
+	workout.ConvertWeights(unit)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": workout, "new_records": workout.NewRecords})
+}
+
+func (lh *LiveHandler) HandleDiscardSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := lh.readSession(w, r)
+	if !ok {
+		return
+	}
+
+	err := lh.store.DiscardSession(int64(session.ID))
+	if errors.Is(err, ErrSessionNotActive) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+		return
+	}
+
+	if err != nil {
+		lh.logger.Printf("Error discardSession: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to discard session"}) // 500
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Responds to a change of the session with its new state
+func (lh *LiveHandler) writeSession(w http.ResponseWriter, sessionID int, err error, status int) {
+	switch {
+	case errors.Is(err, ErrSessionNotActive):
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+		return
+	case errors.Is(err, ErrUnknownSet):
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": err.Error()}) // 404
+		return
+	case err != nil:
+		lh.logger.Printf("Error updating session: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update session"}) // 500
+		return
+	}
+
+	session, err := lh.store.GetSessionByID(int64(sessionID))
+	if err != nil || session == nil {
+		lh.logger.Printf("Error getSessionByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, status, utils.Envelope{"session": session})
+}
+
+// Reads the session in the path, answering 404 unless it's the caller's
+func (lh *LiveHandler) readSession(w http.ResponseWriter, r *http.Request) (*Session, bool) {
+	sessionID, err := utils.ReadIDParam(r)
+	if err != nil {
+		lh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid session id"}) // 400
+		return nil, false
+	}
+
+	session, err := lh.store.GetSessionByID(sessionID)
+	if err != nil {
+		lh.logger.Printf("Error getSessionByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return nil, false
+	}
+
+	if session == nil || session.UserID != users.CurrentUser(r).ID {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "session not found"}) // 404
+		return nil, false
+	}
+
+	return session, true
+}
@@ -0,0 +1,171 @@
+// Package live lets a user log a workout set by set while training, from any of their
+// devices. Sessions are saved on every change so a device that reconnects picks up
+// where it left off, and become a regular workout when finished.
+package live
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/units"
+)
+
+const (
+	StatusActive   = "active"
+	StatusFinished = "finished"
+)
+
+// A workout being logged. Version grows with every change so devices can tell which
+// of two snapshots is newer.
+type Session struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Title      string     `json:"title"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	WorkoutID  *int       `json:"workout_id,omitempty"` // The workout the session was finished into
+	Rest       *RestTimer `json:"rest"`                 // nil unless a rest timer was started
+	Sets       []Set      `json:"sets"`
+	Version    int        `json:"version"`
+}
+
+type RestTimer struct {
+	StartedAt time.Time `json:"started_at"`
+	Seconds   int       `json:"seconds"`
+	EndsAt    time.Time `json:"ends_at"`
+}
+
+// Whole seconds left on the timer at now, 0 once it ran out
+func (t *RestTimer) Remaining(now time.Time) int {
+	return max(0, int(math.Ceil(t.EndsAt.Sub(now).Seconds())))
+}
+
+// A set as planned, filled in with what was actually done when it's completed
+type Set struct {
+	ID              int              `json:"id"`
+	ExerciseName    string           `json:"exercise_name"`
+	SetType         string           `json:"set_type"`
+	Reps            *int             `json:"reps"`
+	DurationSeconds *int             `json:"duration_seconds"`
+	Weight          *float64         `json:"weight"`
+	WeightUnit      units.WeightUnit `json:"weight_unit"` // The owner's preferred unit when the set was added unless given
+	RPE             *float64         `json:"rpe"`
+	RestSeconds     *int             `json:"rest_seconds"` // Rest taken after the set, measured by the timer
+	CompletedAt     *time.Time       `json:"completed_at"`
+}
+
+// Rest timers run for at most an hour
+const maxRestSeconds = 60 * 60
+
+var (
+	ErrSessionActive    = errors.New("a live session is already in progress")
+	ErrSessionNotActive = errors.New("the session is no longer active")
+	ErrUnknownSet       = errors.New("no such set in the session")
+	ErrNothingCompleted = errors.New("complete a set before finishing, or discard the session")
+	ErrInvalidRest      = fmt.Errorf("rest seconds must be between 1 and %d", maxRestSeconds)
+)
+
+func (s *Session) Validate() error {
+	s.Title = strings.TrimSpace(s.Title)
+	if s.Title == "" {
+		return errors.New("title is required")
+	}
+	if len(s.Title) > 255 {
+		return errors.New("title is limited to 255 characters")
+	}
+	return nil
+}
+
+// Validate fills in the set type and checks the set could be saved in a workout
+func (s *Set) Validate() error {
+	s.ExerciseName = strings.TrimSpace(s.ExerciseName)
+	if s.ExerciseName == "" {
+		return fmt.Errorf("%w: exercise_name is required", workouts.ErrInvalidSet)
+	}
+	if s.SetType == "" {
+		s.SetType = workouts.SetWorking
+	}
+	if s.WeightUnit != "" {
+		unit, err := units.ParseWeightUnit(string(s.WeightUnit))
+		if err != nil {
+			return fmt.Errorf("%w: %v", workouts.ErrInvalidWeightUnit, err)
+		}
+		s.WeightUnit = unit
+	}
+
+	set := workouts.EntrySet{SetNumber: 1, Reps: s.Reps, DurationSeconds: s.DurationSeconds, Weight: s.Weight,
+		RPE: s.RPE, SetType: s.SetType}
+	return set.Validate()
+}
+
+func ValidateRest(seconds int) error {
+	if seconds < 1 || seconds > maxRestSeconds {
+		return ErrInvalidRest
+	}
+	return nil
+}
+
+// ToWorkout turns a session finished at now into a workout started when the session was.
+// Consecutive sets of an exercise become one entry, and sets left incomplete are kept as
+// missed sets of exercises that were done. Exercises without a completed set are dropped.
+// An entry is in the unit of its first set, the weights of sets logged in another unit
+// are converted to it.
+func (s *Session) ToWorkout(now time.Time) (*workouts.Workout, error) {
+	workout := &workouts.Workout{
+		UserID:          s.UserID,
+		Title:           s.Title,
+		DurationMinutes: max(1, int(math.Ceil(now.Sub(s.StartedAt).Minutes()))),
+		CreatedAt:       s.StartedAt,
+		Entries:         []workouts.WorkoutEntry{},
+	}
+
+	var entry *workouts.WorkoutEntry
+	completed := map[*workouts.WorkoutEntry]bool{}
+	var entries []*workouts.WorkoutEntry
+	for _, set := range s.Sets {
+		// Sets by reps and by time can't share an entry
+		byDuration := set.DurationSeconds != nil
+		if entry == nil || !strings.EqualFold(entry.ExerciseName, set.ExerciseName) ||
+			(entry.SetsDetail[0].DurationSeconds != nil) != byDuration {
+			entry = &workouts.WorkoutEntry{ExerciseName: set.ExerciseName, WeightUnit: set.WeightUnit}
+			entries = append(entries, entry)
+		}
+
+		weight := set.Weight
+		if weight != nil && set.WeightUnit != entry.WeightUnit {
+			converted := units.FromKilograms(units.ToKilograms(*weight, set.WeightUnit), entry.WeightUnit)
+			weight = &converted
+		}
+
+		done := set.CompletedAt != nil
+		entry.SetsDetail = append(entry.SetsDetail, workouts.EntrySet{
+			SetNumber:       len(entry.SetsDetail) + 1,
+			Reps:            set.Reps,
+			DurationSeconds: set.DurationSeconds,
+			Weight:          weight,
+			RPE:             set.RPE,
+			SetType:         set.SetType,
+			RestSeconds:     set.RestSeconds,
+			Completed:       &done,
+		})
+		completed[entry] = completed[entry] || done
+	}
+
+	for _, entry := range entries {
+		if !completed[entry] {
+			continue
+		}
+		entry.OrderIndex = len(workout.Entries) + 1
+		workout.Entries = append(workout.Entries, *entry)
+	}
+
+	if len(workout.Entries) == 0 {
+		return nil, ErrNothingCompleted
+	}
+	return workout, nil
+}
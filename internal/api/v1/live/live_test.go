@@ -0,0 +1,106 @@
+package live
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/units"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(i int) *int { return &i }
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestToWorkout(t *testing.T) {
+	start := time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+	done := start.Add(10 * time.Minute)
+	session := &Session{UserID: 3, Title: "Upper", StartedAt: start, Sets: []Set{
+		{ExerciseName: "Bench Press", SetType: workouts.SetWarmup, Reps: intPtr(10), Weight: floatPtr(40), CompletedAt: &done},
+		{ExerciseName: "bench press", SetType: workouts.SetWorking, Reps: intPtr(5), Weight: floatPtr(80), CompletedAt: &done,
+			RestSeconds: intPtr(150)},
+		{ExerciseName: "Bench Press", SetType: workouts.SetWorking, Reps: intPtr(5), Weight: floatPtr(80)},
+		{ExerciseName: "Plank", SetType: workouts.SetWorking, DurationSeconds: intPtr(60), CompletedAt: &done},
+		{ExerciseName: "Dips", SetType: workouts.SetWorking, Reps: intPtr(12)},
+	}}
+
+	workout, err := session.ToWorkout(start.Add(40*time.Minute + time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, "Upper", workout.Title)
+	assert.Equal(t, 41, workout.DurationMinutes, "partial minutes count")
+	assert.Equal(t, start, workout.CreatedAt)
+
+	require.Len(t, workout.Entries, 2, "exercises without a completed set are dropped")
+	bench := workout.Entries[0]
+	assert.Equal(t, 1, bench.OrderIndex)
+	require.Len(t, bench.SetsDetail, 3)
+	assert.Equal(t, 3, bench.SetsDetail[2].SetNumber)
+	assert.False(t, *bench.SetsDetail[2].Completed, "incomplete sets are kept as missed")
+	assert.Equal(t, intPtr(150), bench.SetsDetail[1].RestSeconds)
+	assert.Equal(t, "Plank", workout.Entries[1].ExerciseName)
+	assert.Equal(t, 2, workout.Entries[1].OrderIndex)
+
+	_, err = (&Session{Sets: []Set{{ExerciseName: "Dips", Reps: intPtr(12)}}}).ToWorkout(start)
+	assert.ErrorIs(t, err, ErrNothingCompleted)
+}
+
+func TestToWorkoutSplitsMeasurements(t *testing.T) {
+	done := time.Now()
+	session := &Session{StartedAt: done, Sets: []Set{
+		{ExerciseName: "Hollow Hold", Reps: intPtr(10), CompletedAt: &done},
+		{ExerciseName: "Hollow Hold", DurationSeconds: intPtr(30), CompletedAt: &done},
+	}}
+
+	workout, err := session.ToWorkout(done)
+	require.NoError(t, err)
+	assert.Len(t, workout.Entries, 2, "sets by reps and by time can't share an entry")
+	assert.Equal(t, 1, workout.DurationMinutes)
+}
+
+func TestToWorkoutWeightUnits(t *testing.T) {
+	done := time.Now()
+	session := &Session{StartedAt: done, Sets: []Set{
+		{ExerciseName: "Squat", Reps: intPtr(5), Weight: floatPtr(225), WeightUnit: units.Pounds, CompletedAt: &done},
+		{ExerciseName: "Squat", Reps: intPtr(5), Weight: floatPtr(100), WeightUnit: units.Kilograms, CompletedAt: &done},
+	}}
+
+	workout, err := session.ToWorkout(done)
+	require.NoError(t, err)
+	require.Len(t, workout.Entries, 1)
+	squat := workout.Entries[0]
+	assert.Equal(t, units.Pounds, squat.WeightUnit, "the entry takes the unit of its first set")
+	assert.Equal(t, floatPtr(225), squat.SetsDetail[0].Weight)
+	assert.Equal(t, floatPtr(220.46), squat.SetsDetail[1].Weight, "sets logged in kilograms are converted")
+}
+
+func TestValidateSet(t *testing.T) {
+	set := Set{ExerciseName: " Squat ", Reps: intPtr(5)}
+	require.NoError(t, set.Validate())
+	assert.Equal(t, "Squat", set.ExerciseName)
+	assert.Equal(t, workouts.SetWorking, set.SetType)
+
+	assert.ErrorIs(t, (&Set{Reps: intPtr(5)}).Validate(), workouts.ErrInvalidSet)
+	assert.ErrorIs(t, (&Set{ExerciseName: "Squat"}).Validate(), workouts.ErrInvalidSet, "reps or a duration")
+	assert.ErrorIs(t, (&Set{ExerciseName: "Squat", Reps: intPtr(5), SetType: "heavy"}).Validate(),
+		workouts.ErrInvalidSet)
+
+	set = Set{ExerciseName: "Squat", Reps: intPtr(5), WeightUnit: "LBS"}
+	require.NoError(t, set.Validate())
+	assert.Equal(t, units.Pounds, set.WeightUnit)
+	assert.ErrorIs(t, (&Set{ExerciseName: "Squat", Reps: intPtr(5), WeightUnit: "stone"}).Validate(),
+		workouts.ErrInvalidWeightUnit)
+}
+
+func TestRestTimer(t *testing.T) {
+	start := time.Now()
+	timer := &RestTimer{StartedAt: start, Seconds: 90, EndsAt: start.Add(90 * time.Second)}
+	assert.Equal(t, 90, timer.Remaining(start))
+	assert.Equal(t, 60, timer.Remaining(start.Add(30500*time.Millisecond)), "rounds up")
+	assert.Zero(t, timer.Remaining(start.Add(2*time.Minute)))
+
+	assert.NoError(t, ValidateRest(90))
+	assert.ErrorIs(t, ValidateRest(0), ErrInvalidRest)
+	assert.ErrorIs(t, ValidateRest(maxRestSeconds+1), ErrInvalidRest)
+}
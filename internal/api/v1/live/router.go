@@ -0,0 +1,33 @@
+package live
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/events"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/go-chi/chi/v5"
+)
+
+func LiveRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and stores
+	r := chi.NewRouter()
+	store := NewPostgresLiveStore(app.DB)
+	userStore := users.NewPostgresUserStore(app.DB)
+	handler := NewLiveHandler(store, userStore, events.NewBroker(app.DB, Channel, app.Logger), app.Logger)
+
+	r.Use(users.RequireUser(userStore))
+
+	// Define subroutes
+	r.Post("/", handler.HandleStartSession)
+	r.Get("/active", handler.HandleGetActiveSession)
+	r.Get("/ws", handler.HandleSocket)
+	r.Get("/{id}", handler.HandleGetSessionByID)
+	r.Delete("/{id}", handler.HandleDiscardSession)
+	r.Post("/{id}/sets", handler.HandleAddSet)
+	r.Post("/{id}/sets/{setID}/complete", handler.HandleCompleteSet)
+	r.Post("/{id}/rest", handler.HandleStartRest)
+	r.Delete("/{id}/rest", handler.HandleStopRest)
+	r.Post("/{id}/finish", handler.HandleFinishSession)
+
+	return r
+}
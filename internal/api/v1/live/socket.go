@@ -0,0 +1,166 @@
+package live
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Pings keep idle sockets open through proxies, a socket is dropped when pongs stop
+	pingInterval = 30 * time.Second
+	writeWait    = 10 * time.Second
+	// Devices only listen, anything they send beyond control frames is small
+	maxMessageBytes = 1024
+)
+
+// Messages pushed to devices
+const (
+	MessageSession  = "session"   // the session's current state, null when none is in progress
+	MessageRestTick = "rest.tick" // every second while a rest timer runs
+	MessageRestOver = "rest.over" // once when the timer runs out
+)
+
+type sessionMessage struct {
+	Type    string   `json:"type"`
+	Session *Session `json:"session"`
+}
+
+type restMessage struct {
+	Type             string    `json:"type"`
+	SessionID        int       `json:"session_id"`
+	RemainingSeconds int       `json:"remaining_seconds"`
+	EndsAt           time.Time `json:"ends_at"`
+}
+
+// Same-origin requests and clients that send no Origin, such as mobile apps, are accepted
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// Pushes the caller's live session to one of their devices: its state on connect and
+// after every change made from any device, and the ticks of its rest timer. A device
+// that reconnects gets the current state first, so it recovers whatever it missed.
+func (lh *LiveHandler) HandleSocket(w http.ResponseWriter, r *http.Request) {
+	userID := int64(users.CurrentUser(r).ID)
+
+	// Subscribing before the first read means no change in between is missed
+	wake, unsubscribe := lh.notifier.Subscribe(userID)
+	defer unsubscribe()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader already responded
+	}
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	go readSocket(conn, 2*lh.pingInterval, closed)
+
+	ticker := time.NewTicker(lh.tickInterval)
+	defer ticker.Stop()
+	ping := time.NewTicker(lh.pingInterval)
+	defer ping.Stop()
+
+	socket := &socketState{userID: userID}
+	err = lh.pushSession(conn, socket)
+	for err == nil {
+		select {
+		case <-closed:
+			return
+		case <-wake:
+			err = lh.pushSession(conn, socket)
+		case <-ticker.C:
+			err = pushRest(conn, socket, time.Now())
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+		}
+	}
+
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		lh.logger.Printf("Error live socket: %v", err)
+	}
+}
+
+// What a socket pushed last and the session it follows
+type socketState struct {
+	userID    int64
+	pushed    *Session
+	hasPushed bool
+	following *Session  // nil once the pushed session ended
+	restOver  time.Time // end of the last timer rest.over was sent for
+}
+
+// Pushes the session when it changed since the last push. A socket keeps following a
+// session until it's finished or discarded, so devices see the workout it became.
+func (lh *LiveHandler) pushSession(conn *websocket.Conn, socket *socketState) error {
+	session, err := lh.store.GetActiveSession(socket.userID)
+	if err == nil && session == nil && socket.following != nil {
+		session, err = lh.store.GetSessionByID(int64(socket.following.ID))
+	}
+	if err != nil {
+		return err
+	}
+
+	if socket.hasPushed && sameVersion(socket.pushed, session) {
+		return nil
+	}
+	socket.pushed, socket.hasPushed = session, true
+	socket.following = session
+	if session != nil && session.Status != StatusActive {
+		socket.following = nil // the next change shows the session that follows, if any
+	}
+
+	return writeMessage(conn, sessionMessage{Type: MessageSession, Session: session})
+}
+
+func sameVersion(a, b *Session) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID && a.Version == b.Version
+}
+
+// Sends the seconds left on a running rest timer, then once that it ran out
+func pushRest(conn *websocket.Conn, socket *socketState, now time.Time) error {
+	if socket.following == nil || socket.following.Rest == nil {
+		return nil
+	}
+
+	rest := socket.following.Rest
+	message := restMessage{Type: MessageRestTick, SessionID: socket.following.ID,
+		RemainingSeconds: rest.Remaining(now), EndsAt: rest.EndsAt}
+	if message.RemainingSeconds == 0 {
+		if socket.restOver.Equal(rest.EndsAt) {
+			return nil
+		}
+		socket.restOver = rest.EndsAt
+		message.Type = MessageRestOver
+	}
+
+	return writeMessage(conn, message)
+}
+
+func writeMessage(conn *websocket.Conn, message any) error {
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return conn.WriteJSON(message)
+}
+
+// Reads until the device goes away, which closes closed. Reading is also what answers
+// pings and extends the deadline on every pong.
+func readSocket(conn *websocket.Conn, pongWait time.Duration, closed chan<- struct{}) {
+	defer close(closed)
+
+	conn.SetReadLimit(maxMessageBytes)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, _, err := conn.NextReader()
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package live
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUsers struct {
+	users.UserStore
+}
+
+func (fakeUsers) GetUser(userID int64) (*users.User, error) {
+	return &users.User{ID: int(userID)}, nil
+}
+
+// Holds one session in memory and wakes sockets when it's replaced
+type fakeSessions struct {
+	LiveStore
+	mu      sync.Mutex
+	session *Session
+	wake    chan struct{}
+}
+
+func (fs *fakeSessions) GetActiveSession(userID int64) (*Session, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.session == nil || fs.session.Status != StatusActive {
+		return nil, nil
+	}
+	return fs.session, nil
+}
+
+func (fs *fakeSessions) GetSessionByID(id int64) (*Session, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.session, nil
+}
+
+func (fs *fakeSessions) Subscribe(userID int64) (<-chan struct{}, func()) {
+	return fs.wake, func() {}
+}
+
+func (fs *fakeSessions) set(session *Session) {
+	fs.mu.Lock()
+	fs.session = session
+	fs.mu.Unlock()
+	fs.wake <- struct{}{}
+}
+
+type socketMessage struct {
+	Type             string   `json:"type"`
+	Session          *Session `json:"session"`
+	RemainingSeconds int      `json:"remaining_seconds"`
+}
+
+func TestSocket(t *testing.T) {
+	sessions := &fakeSessions{wake: make(chan struct{}, 1),
+		session: &Session{ID: 1, UserID: 7, Title: "Upper", Status: StatusActive, Version: 1, Sets: []Set{}}}
+
+	handler := NewLiveHandler(sessions, fakeUsers{}, sessions, log.New(io.Discard, "", 0))
+	handler.tickInterval = 20 * time.Millisecond
+	server := httptest.NewServer(users.RequireUser(fakeUsers{})(http.HandlerFunc(handler.HandleSocket)))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"),
+		http.Header{"X-User-ID": {"7"}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	read := func() socketMessage {
+		var message socketMessage
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		require.NoError(t, conn.ReadJSON(&message))
+		return message
+	}
+
+	message := read()
+	assert.Equal(t, MessageSession, message.Type, "the state is sent on connect")
+	assert.Equal(t, 1, message.Session.Version)
+
+	// A set added on another device
+	reps := 5
+	sessions.set(&Session{ID: 1, UserID: 7, Title: "Upper", Status: StatusActive, Version: 2,
+		Sets: []Set{{ID: 1, ExerciseName: "Bench Press", Reps: &reps}}})
+	message = read()
+	assert.Equal(t, MessageSession, message.Type)
+	require.Len(t, message.Session.Sets, 1)
+
+	now := time.Now()
+	sessions.set(&Session{ID: 1, UserID: 7, Title: "Upper", Status: StatusActive, Version: 3, Sets: []Set{},
+		Rest: &RestTimer{StartedAt: now, Seconds: 1, EndsAt: now.Add(100 * time.Millisecond)}})
+	assert.Equal(t, 3, read().Session.Version)
+
+	message = read()
+	assert.Equal(t, MessageRestTick, message.Type)
+	assert.Equal(t, 1, message.RemainingSeconds)
+	for message.Type == MessageRestTick {
+		message = read()
+	}
+	assert.Equal(t, MessageRestOver, message.Type)
+
+	workoutID := 9
+	sessions.set(&Session{ID: 1, UserID: 7, Title: "Upper", Status: StatusFinished, Version: 4, WorkoutID: &workoutID})
+	message = read()
+	assert.Equal(t, StatusFinished, message.Session.Status, "devices see the workout the session became")
+	assert.Equal(t, &workoutID, message.Session.WorkoutID)
+
+	sessions.wake <- struct{}{}
+	message = read()
+	assert.Equal(t, MessageSession, message.Type)
+	assert.Nil(t, message.Session, "no session is in progress anymore")
+}
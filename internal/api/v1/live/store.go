@@ -0,0 +1,323 @@
+package live
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/jackc/pgconn"
+)
+
+// The Postgres channel notified with the owner's id whenever a session changes
+const Channel = "live_sessions"
+
+// DB connector struct
+type PostgresLiveStore struct {
+	db *sql.DB
+}
+
+func NewPostgresLiveStore(db *sql.DB) *PostgresLiveStore {
+	return &PostgresLiveStore{db: db}
+}
+
+type LiveStore interface {
+	StartSession(session *Session) (*Session, error)
+	GetSessionByID(id int64) (*Session, error)
+	GetActiveSession(userID int64) (*Session, error)
+	AddSet(sessionID int64, set *Set) error
+	CompleteSet(sessionID int64, set *Set) error
+	StartRest(sessionID int64, seconds int) error
+	StopRest(sessionID int64) error
+	FinishSession(sessionID int64) (*workouts.Workout, error)
+	DiscardSession(sessionID int64) error
+}
+
+// Satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Returns ErrSessionActive when the user already has a session in progress
+func (pgStore *PostgresLiveStore) StartSession(session *Session) (*Session, error) {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // rollback transaction if not committed
+
+	query := `
+	INSERT INTO live_sessions (user_id, title)
+	VALUES ($1, $2)
+	RETURNING id, status, started_at, version
+	`
+	err = tx.QueryRow(query, session.UserID, session.Title).Scan(&session.ID, &session.Status, &session.StartedAt,
+		&session.Version)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	err = notify(tx, int64(session.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	session.Sets = []Set{}
+	return session, nil
+}
+
+// Returns nil when the session doesn't exist
+func (pgStore *PostgresLiveStore) GetSessionByID(id int64) (*Session, error) {
+	return loadSession(pgStore.db, `WHERE id = $1`, id)
+}
+
+// Returns nil when the user has no session in progress
+func (pgStore *PostgresLiveStore) GetActiveSession(userID int64) (*Session, error) {
+	return loadSession(pgStore.db, `WHERE user_id = $1 AND status = 'active'`, userID)
+}
+
+func loadSession(q queryer, where string, arg int64) (*Session, error) {
+	session := &Session{}
+	var restStartedAt sql.NullTime
+	var restSeconds sql.NullInt64
+	query := `
+	SELECT id, user_id, title, status, started_at, finished_at, workout_id, rest_started_at, rest_seconds, version
+	FROM live_sessions
+	` + where
+	err := q.QueryRow(query, arg).Scan(&session.ID, &session.UserID, &session.Title, &session.Status,
+		&session.StartedAt, &session.FinishedAt, &session.WorkoutID, &restStartedAt, &restSeconds, &session.Version)
+	if err == sql.ErrNoRows {
+		return nil, nil // No session found
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if restStartedAt.Valid {
+		session.Rest = &RestTimer{StartedAt: restStartedAt.Time, Seconds: int(restSeconds.Int64),
+			EndsAt: restStartedAt.Time.Add(time.Duration(restSeconds.Int64) * time.Second)}
+	}
+
+	rows, err := q.Query(`
+	SELECT id, exercise_name, set_type, reps, duration_seconds, weight, weight_unit, rpe, rest_seconds, completed_at
+	FROM live_session_sets
+	WHERE session_id = $1
+	ORDER BY id
+	`, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	session.Sets = []Set{}
+	for rows.Next() {
+		var set Set
+		err := rows.Scan(&set.ID, &set.ExerciseName, &set.SetType, &set.Reps, &set.DurationSeconds, &set.Weight,
+			&set.WeightUnit, &set.RPE, &set.RestSeconds, &set.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+		session.Sets = append(session.Sets, set)
+	}
+
+	return session, rows.Err()
+}
+
+// Adds a planned set, in the owner's preferred weight unit when it doesn't name one
+func (pgStore *PostgresLiveStore) AddSet(sessionID int64, set *Set) error {
+	return pgStore.change(sessionID, func(tx *sql.Tx) error {
+		query := `
+		INSERT INTO live_session_sets (session_id, exercise_name, set_type, reps, duration_seconds, weight, rpe,
+			weight_unit)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE(NULLIF($8, ''), (
+			SELECT u.preferred_weight_unit FROM live_sessions s JOIN users u ON u.id = s.user_id WHERE s.id = $1
+		), 'kg'))
+		RETURNING id, weight_unit
+		`
+		return tx.QueryRow(query, sessionID, set.ExerciseName, set.SetType, set.Reps, set.DurationSeconds,
+			set.Weight, set.RPE, set.WeightUnit).Scan(&set.ID, &set.WeightUnit)
+	})
+}
+
+// Completes a set with what was actually done. A running rest timer stops, the rest
+// counting towards the set completed before.
+func (pgStore *PostgresLiveStore) CompleteSet(sessionID int64, set *Set) error {
+	return pgStore.change(sessionID, func(tx *sql.Tx) error {
+		err := stopRest(tx, sessionID)
+		if err != nil {
+			return err
+		}
+
+		query := `
+		UPDATE live_session_sets
+		SET set_type = $3, reps = $4, duration_seconds = $5, weight = $6, rpe = $7,
+			weight_unit = COALESCE(NULLIF($8, ''), weight_unit), completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND session_id = $2
+		RETURNING completed_at, weight_unit
+		`
+		err = tx.QueryRow(query, set.ID, sessionID, set.SetType, set.Reps, set.DurationSeconds, set.Weight,
+			set.RPE, set.WeightUnit).Scan(&set.CompletedAt, &set.WeightUnit)
+		if err == sql.ErrNoRows {
+			return ErrUnknownSet
+		}
+		return err
+	})
+}
+
+// Starts a rest timer, replacing the one running
+func (pgStore *PostgresLiveStore) StartRest(sessionID int64, seconds int) error {
+	return pgStore.change(sessionID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+		UPDATE live_sessions
+		SET rest_started_at = CURRENT_TIMESTAMP, rest_seconds = $2
+		WHERE id = $1`, sessionID, seconds)
+		return err
+	})
+}
+
+func (pgStore *PostgresLiveStore) StopRest(sessionID int64) error {
+	return pgStore.change(sessionID, func(tx *sql.Tx) error {
+		return stopRest(tx, sessionID)
+	})
+}
+
+// Clears the rest timer and records how long the rest lasted on the latest completed set
+func stopRest(tx *sql.Tx, sessionID int64) error {
+	_, err := tx.Exec(`
+	UPDATE live_session_sets
+	SET rest_seconds = EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - s.rest_started_at)::INTEGER
+	FROM live_sessions s
+	WHERE s.id = $1 AND s.rest_started_at IS NOT NULL AND live_session_sets.id = (
+		SELECT id FROM live_session_sets
+		WHERE session_id = $1 AND completed_at IS NOT NULL
+		ORDER BY completed_at DESC, id DESC
+		LIMIT 1
+	)`, sessionID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE live_sessions SET rest_started_at = NULL, rest_seconds = NULL WHERE id = $1`, sessionID)
+	return err
+}
+
+// Saves the session as a workout and marks it finished, both or neither
+func (pgStore *PostgresLiveStore) FinishSession(sessionID int64) (*workouts.Workout, error) {
+	var workout *workouts.Workout
+	err := pgStore.change(sessionID, func(tx *sql.Tx) error {
+		session, err := loadSession(tx, `WHERE id = $1`, sessionID)
+		if err != nil {
+			return err
+		}
+
+		var now time.Time
+		err = tx.QueryRow(`SELECT CURRENT_TIMESTAMP`).Scan(&now)
+		if err != nil {
+			return err
+		}
+
+		workout, err = session.ToWorkout(now)
+		if err != nil {
+			return err
+		}
+
+		err = workouts.CreateWorkoutInTx(tx, workout)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+		UPDATE live_sessions
+		SET status = 'finished', finished_at = $2, workout_id = $3, rest_started_at = NULL, rest_seconds = NULL
+		WHERE id = $1`, sessionID, now, workout.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return workout, nil
+}
+
+// Deletes a session in progress without saving anything
+func (pgStore *PostgresLiveStore) DiscardSession(sessionID int64) error {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRow(`DELETE FROM live_sessions WHERE id = $1 AND status = 'active' RETURNING user_id`,
+		sessionID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return ErrSessionNotActive
+	}
+	if err != nil {
+		return err
+	}
+
+	err = notify(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Runs fn in a transaction holding the session's row, which must still be active. The
+// version is bumped and the user's devices are notified when the transaction commits,
+// so concurrent changes from two devices apply one after the other.
+func (pgStore *PostgresLiveStore) change(sessionID int64, fn func(tx *sql.Tx) error) error {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRow(`
+	UPDATE live_sessions
+	SET version = version + 1
+	WHERE id = $1 AND status = 'active'
+	RETURNING user_id`, sessionID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return ErrSessionNotActive
+	}
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	err = notify(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Notifications are delivered when the transaction commits, and not at all if it doesn't
+func notify(tx *sql.Tx, userID int64) error {
+	_, err := tx.Exec(`SELECT pg_notify($1, $2)`, Channel, strconv.FormatInt(userID, 10))
+	return err
+}
+
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "live_sessions_active_idx" {
+		return ErrSessionActive
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package live
+
+import (
+	"testing"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/store"
+	"github.com/Josesx506/gofems/internal/units"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveSession(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresLiveStore(db)
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+
+	var userID int64
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('lifter', 'lifter@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	session, err := pgStore.StartSession(&Session{UserID: int(userID), Title: "Upper"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusActive, session.Status)
+
+	_, err = pgStore.StartSession(&Session{UserID: int(userID), Title: "Again"})
+	assert.ErrorIs(t, err, ErrSessionActive, "one session at a time")
+
+	sessionID := int64(session.ID)
+	bench := &Set{ExerciseName: "Bench Press", SetType: workouts.SetWorking, Reps: intPtr(5), Weight: floatPtr(80)}
+	require.NoError(t, pgStore.AddSet(sessionID, bench))
+	assert.Equal(t, units.Kilograms, bench.WeightUnit)
+
+	// Sets keep the unit they were added in when the preference changes mid-session
+	_, err = db.Exec(`UPDATE users SET preferred_weight_unit = 'lb' WHERE id = $1`, userID)
+	require.NoError(t, err)
+	second := &Set{ExerciseName: "Bench Press", SetType: workouts.SetWorking, Reps: intPtr(5), Weight: floatPtr(80)}
+	require.NoError(t, pgStore.AddSet(sessionID, second))
+	assert.Equal(t, units.Pounds, second.WeightUnit)
+
+	bench.Reps = intPtr(6)
+	require.NoError(t, pgStore.CompleteSet(sessionID, bench))
+	assert.ErrorIs(t, pgStore.CompleteSet(sessionID, &Set{ID: bench.ID + 1000, SetType: workouts.SetWorking,
+		Reps: intPtr(1)}), ErrUnknownSet)
+
+	require.NoError(t, pgStore.StartRest(sessionID, 120))
+	active, err := pgStore.GetActiveSession(userID)
+	require.NoError(t, err)
+	require.NotNil(t, active.Rest)
+	assert.Equal(t, 120, active.Rest.Seconds)
+	assert.Greater(t, active.Version, session.Version)
+
+	require.NoError(t, pgStore.StopRest(sessionID))
+	active, err = pgStore.GetActiveSession(userID)
+	require.NoError(t, err)
+	assert.Nil(t, active.Rest)
+	require.NotNil(t, active.Sets[0].RestSeconds, "the rest is recorded on the completed set")
+	assert.Nil(t, active.Sets[1].RestSeconds)
+
+	workout, err := pgStore.FinishSession(sessionID)
+	require.NoError(t, err)
+	saved, err := workoutStore.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	require.Len(t, saved.Entries, 1)
+	assert.Equal(t, 1, saved.Entries[0].Sets, "the missed set isn't counted")
+	assert.Equal(t, intPtr(6), saved.Entries[0].Reps)
+	require.Len(t, saved.Entries[0].SetsDetail, 2)
+	assert.Equal(t, floatPtr(80), saved.Entries[0].SetsDetail[0].Weight)
+	assert.InDelta(t, 36.29, *saved.Entries[0].SetsDetail[1].Weight, 0.01, "80 lb, stored in kilograms")
+
+	finished, err := pgStore.GetSessionByID(sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFinished, finished.Status)
+	assert.Equal(t, &workout.ID, finished.WorkoutID)
+	assert.ErrorIs(t, pgStore.AddSet(sessionID, second), ErrSessionNotActive)
+
+	active, err = pgStore.GetActiveSession(userID)
+	require.NoError(t, err)
+	assert.Nil(t, active)
+
+	// Another session can start, and be thrown away
+	discarded, err := pgStore.StartSession(&Session{UserID: int(userID), Title: "Legs"})
+	require.NoError(t, err)
+	_, err = pgStore.FinishSession(int64(discarded.ID))
+	assert.ErrorIs(t, err, ErrNothingCompleted)
+	require.NoError(t, pgStore.DiscardSession(int64(discarded.ID)))
+	missing, err := pgStore.GetSessionByID(int64(discarded.ID))
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
@@ -0,0 +1,384 @@
+package programs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/templates"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type ProgramHandler struct {
+	store         ProgramStore
+	templateStore templates.TemplateStore
+	workoutStore  workouts.WorkoutStore
+	logger        *log.Logger
+}
+
+func NewProgramHandler(store ProgramStore, templateStore templates.TemplateStore, workoutStore workouts.WorkoutStore,
+	logger *log.Logger) *ProgramHandler {
+	return &ProgramHandler{
+		store:         store,
+		templateStore: templateStore,
+		workoutStore:  workoutStore,
+		logger:        logger,
+	}
+}
+
+type completeDayRequest struct {
+	ProgramDayID int64 `json:"program_day_id"`
+	WorkoutID    int64 `json:"workout_id"`
+}
+
+func (ph *ProgramHandler) HandleListPrograms(w http.ResponseWriter, r *http.Request) {
+	programs, err := ph.store.ListPrograms()
+	if err != nil {
+		ph.logger.Printf("Error listPrograms: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"programs": programs})
+}
+
+func (ph *ProgramHandler) HandleGetProgramByID(w http.ResponseWriter, r *http.Request) {
+	programID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ph.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid program id"})
+		return
+	}
+
+	program, err := ph.store.GetProgramByID(programID)
+	if err != nil {
+		ph.logger.Printf("Error getProgramByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if program == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "program not found"}) // 404
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"program": program})
+}
+
+func (ph *ProgramHandler) HandleCreateProgram(w http.ResponseWriter, r *http.Request) {
+	var program Program
+
+	err := json.NewDecoder(r.Body).Decode(&program)
+	if err != nil {
+		ph.logger.Printf("Error decodingCreateProgram: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	err = program.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	createdProgram, err := ph.store.CreateProgram(&program)
+	if errors.Is(err, ErrUnknownTemplate) || errors.Is(err, ErrUnknownUser) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	if errors.Is(err, ErrDuplicateDay) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+		return
+	}
+
+	if err != nil {
+		ph.logger.Printf("Error createProgram: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create program"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"program": createdProgram})
+}
+
+func (ph *ProgramHandler) HandleDeleteProgramByID(w http.ResponseWriter, r *http.Request) {
+	programID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ph.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid program id"})
+		return
+	}
+
+	err = ph.store.DeleteProgram(programID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "program not found"}) // 404
+		return
+	}
+
+	if err != nil {
+		ph.logger.Printf("Error deleteProgram: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete program"}) // 500
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ph *ProgramHandler) HandleEnrol(w http.ResponseWriter, r *http.Request) {
+	programID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ph.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid program id"})
+		return
+	}
+
+	var enrolment Enrolment
+
+	err = json.NewDecoder(r.Body).Decode(&enrolment)
+	if err != nil {
+		ph.logger.Printf("Error decodingEnrol: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	if enrolment.UserID == 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "user_id is required"}) // 400
+		return
+	}
+
+	if enrolment.StartDate == "" {
+		enrolment.StartDate = time.Now().Format(dateLayout)
+	}
+	if _, err := time.Parse(dateLayout, enrolment.StartDate); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "start_date must be YYYY-MM-DD"}) // 400
+		return
+	}
+
+	if enrolment.TrainingMaxes == nil {
+		enrolment.TrainingMaxes = map[string]float64{}
+	}
+
+	program, err := ph.store.GetProgramByID(programID)
+	if err != nil {
+		ph.logger.Printf("Error getProgramByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if program == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "program not found"}) // 404
+		return
+	}
+
+	enrolment.ProgramID = program.ID
+
+	createdEnrolment, err := ph.store.CreateEnrolment(&enrolment)
+	if errors.Is(err, ErrUnknownUser) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	if err != nil {
+		ph.logger.Printf("Error createEnrolment: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to enrol user"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"enrolment": createdEnrolment})
+}
+
+// Lists the enrolments of a program, narrowed by ?status=
+func (ph *ProgramHandler) HandleListEnrolments(w http.ResponseWriter, r *http.Request) {
+	programID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ph.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid program id"})
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", EnrolmentActive, EnrolmentCompleted, EnrolmentCancelled:
+	default:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid status"}) // 400
+		return
+	}
+
+	program, err := ph.store.GetProgramByID(programID)
+	if err != nil {
+		ph.logger.Printf("Error getProgramByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if program == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "program not found"}) // 404
+		return
+	}
+
+	enrolments, err := ph.store.ListEnrolments(programID, status)
+	if err != nil {
+		ph.logger.Printf("Error listEnrolments: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"enrolments": enrolments})
+}
+
+func (ph *ProgramHandler) HandleGetEnrolmentByID(w http.ResponseWriter, r *http.Request) {
+	enrolment, ok := ph.readEnrolment(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"enrolment": enrolment})
+}
+
+// Stops following the program, the completed days are kept
+func (ph *ProgramHandler) HandleCancelEnrolment(w http.ResponseWriter, r *http.Request) {
+	enrolment, ok := ph.readEnrolment(w, r)
+	if !ok {
+		return
+	}
+
+	err := ph.store.CancelEnrolment(int64(enrolment.ID))
+	if errors.Is(err, ErrEnrolmentNotActive) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+		return
+	}
+
+	if err != nil {
+		ph.logger.Printf("Error cancelEnrolment: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to cancel enrolment"}) // 500
+		return
+	}
+
+	enrolment.Status = EnrolmentCancelled
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"enrolment": enrolment})
+}
+
+// Computes the workout prescribed for the next uncompleted day of the week the
+// enrolment is in. There is none before the start date, once the week's days are done,
+// or when the enrolment isn't active.
+func (ph *ProgramHandler) HandleGetTodaysWorkout(w http.ResponseWriter, r *http.Request) {
+	enrolment, ok := ph.readEnrolment(w, r)
+	if !ok {
+		return
+	}
+
+	week := enrolment.Week(time.Now())
+	if week == 0 || enrolment.Status != EnrolmentActive {
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{"enrolment": enrolment, "week": week, "program_day": nil,
+			"workout": nil})
+		return
+	}
+
+	day, err := ph.store.NextProgramDay(int64(enrolment.ID), week)
+	if err != nil {
+		ph.logger.Printf("Error nextProgramDay: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if day == nil {
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{"enrolment": enrolment, "week": week, "program_day": nil,
+			"workout": nil})
+		return
+	}
+
+	template, err := ph.templateStore.GetTemplateByID(int64(day.TemplateID))
+	if err != nil || template == nil {
+		ph.logger.Printf("Error getTemplateByID %d: %v", day.TemplateID, err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	workout := Prescribe(day, template, enrolment.TrainingMaxes)
+	workout.UserID = enrolment.UserID
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"enrolment": enrolment, "week": week, "program_day": day,
+		"workout": workout})
+}
+
+// Links a logged workout to a program day of the enrolment
+func (ph *ProgramHandler) HandleCompleteProgramDay(w http.ResponseWriter, r *http.Request) {
+	enrolment, ok := ph.readEnrolment(w, r)
+	if !ok {
+		return
+	}
+
+	if enrolment.Status == EnrolmentCancelled {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": ErrEnrolmentNotActive.Error()}) // 409
+		return
+	}
+
+	var req completeDayRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ph.logger.Printf("Error decodingCompleteProgramDay: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	workout, err := ph.workoutStore.GetWorkoutByID(req.WorkoutID)
+	if err != nil {
+		ph.logger.Printf("Error getWorkoutByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if workout == nil || workout.UserID != enrolment.UserID {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": ErrUnknownWorkout.Error()}) // 400
+		return
+	}
+
+	// The enrolment or the workout may have changed since they were read
+	completion, err := ph.store.CompleteProgramDay(int64(enrolment.ID), req.ProgramDayID, req.WorkoutID)
+	if errors.Is(err, ErrDayNotInProgram) || errors.Is(err, ErrUnknownWorkout) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	if errors.Is(err, ErrEnrolmentNotActive) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+		return
+	}
+
+	if err != nil {
+		ph.logger.Printf("Error completeProgramDay: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to complete program day"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"completion": completion})
+}
+
+// Reads the {id} enrolment, writing the error response when it can't be loaded
+func (ph *ProgramHandler) readEnrolment(w http.ResponseWriter, r *http.Request) (*Enrolment, bool) {
+	enrolmentID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ph.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid enrolment id"})
+		return nil, false
+	}
+
+	enrolment, err := ph.store.GetEnrolmentByID(enrolmentID)
+	if err != nil {
+		ph.logger.Printf("Error getEnrolmentByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return nil, false
+	}
+
+	if enrolment == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "enrolment not found"}) // 404
+		return nil, false
+	}
+
+	return enrolment, true
+}
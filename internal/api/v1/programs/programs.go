@@ -0,0 +1,125 @@
+package programs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A multi-week training program made of template days
+type Program struct {
+	ID          int           `json:"id"`
+	UserID      int           `json:"user_id"` // Coach who authored the program
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Weeks       []ProgramWeek `json:"weeks"`
+}
+
+type ProgramWeek struct {
+	WeekNumber int          `json:"week_number"`
+	Days       []ProgramDay `json:"days"`
+}
+
+// A day references the template to perform and how heavy to perform it
+type ProgramDay struct {
+	ID            int      `json:"id"`
+	WeekNumber    int      `json:"week_number"`
+	DayNumber     int      `json:"day_number"`
+	TemplateID    int      `json:"template_id"`
+	IntensityType *string  `json:"intensity_type"` // percent_1rm or rpe
+	Intensity     *float64 `json:"intensity"`
+	Notes         string   `json:"notes"`
+}
+
+// A user following a program. Training maxes are keyed by exercise name and
+// drive the weights prescribed by percent_1rm days.
+type Enrolment struct {
+	ID            int                `json:"id"`
+	ProgramID     int                `json:"program_id"`
+	UserID        int                `json:"user_id"`
+	StartDate     string             `json:"start_date"` // YYYY-MM-DD
+	TrainingMaxes map[string]float64 `json:"training_maxes"`
+	Status        string             `json:"status"`
+	Completions   []DayCompletion    `json:"completions"`
+	TotalDays     int                `json:"total_days"`
+}
+
+// Links a logged workout to the program day it fulfilled
+type DayCompletion struct {
+	ProgramDayID int       `json:"program_day_id"`
+	WorkoutID    int       `json:"workout_id"`
+	CompletedAt  time.Time `json:"completed_at"`
+}
+
+const (
+	IntensityPercent1RM = "percent_1rm"
+	IntensityRPE        = "rpe"
+
+	EnrolmentActive    = "active"
+	EnrolmentCompleted = "completed"
+	EnrolmentCancelled = "cancelled"
+)
+
+const dateLayout = "2006-01-02"
+
+// Checks the program structure before it reaches the db
+func (p *Program) Validate() error {
+	if p.Name == "" {
+		return errors.New("program name is required")
+	}
+
+	if len(p.Weeks) == 0 {
+		return errors.New("program needs at least one week")
+	}
+
+	weeks := map[int]bool{}
+	for _, week := range p.Weeks {
+		if week.WeekNumber <= 0 {
+			return errors.New("week_number must be positive")
+		}
+		if weeks[week.WeekNumber] {
+			return fmt.Errorf("week %d is listed twice", week.WeekNumber)
+		}
+		weeks[week.WeekNumber] = true
+		if len(week.Days) == 0 {
+			return fmt.Errorf("week %d has no days", week.WeekNumber)
+		}
+
+		days := map[int]bool{}
+		for _, day := range week.Days {
+			if day.DayNumber <= 0 {
+				return fmt.Errorf("week %d: day_number must be positive", week.WeekNumber)
+			}
+			if days[day.DayNumber] {
+				return fmt.Errorf("week %d day %d is listed twice", week.WeekNumber, day.DayNumber)
+			}
+			days[day.DayNumber] = true
+			if day.TemplateID == 0 {
+				return fmt.Errorf("week %d day %d: template_id is required", week.WeekNumber, day.DayNumber)
+			}
+			if (day.IntensityType == nil) != (day.Intensity == nil) {
+				return fmt.Errorf("week %d day %d: intensity_type and intensity must be set together", week.WeekNumber, day.DayNumber)
+			}
+			if day.IntensityType != nil && *day.IntensityType != IntensityPercent1RM && *day.IntensityType != IntensityRPE {
+				return fmt.Errorf("week %d day %d: intensity_type must be %s or %s", week.WeekNumber, day.DayNumber,
+					IntensityPercent1RM, IntensityRPE)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Week returns the week of the program the enrolment is in on the given day, counting
+// weeks from its start date. It's 0 before the program starts.
+func (e *Enrolment) Week(on time.Time) int {
+	start, err := time.Parse(dateLayout, e.StartDate)
+	if err != nil {
+		return 0
+	}
+	day, _ := time.Parse(dateLayout, on.Format(dateLayout))
+	if day.Before(start) {
+		return 0
+	}
+	return int(day.Sub(start).Hours()/24)/7 + 1
+}
@@ -0,0 +1,56 @@
+package programs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateProgram(t *testing.T) {
+	day := func(number int) ProgramDay { return ProgramDay{DayNumber: number, TemplateID: 1} }
+
+	tests := []struct {
+		name    string
+		weeks   []ProgramWeek
+		wantErr string
+	}{
+		{name: "valid", weeks: []ProgramWeek{
+			{WeekNumber: 1, Days: []ProgramDay{day(1), day(3)}},
+			{WeekNumber: 2, Days: []ProgramDay{day(1)}},
+		}},
+		{name: "repeated week", weeks: []ProgramWeek{
+			{WeekNumber: 1, Days: []ProgramDay{day(1)}},
+			{WeekNumber: 1, Days: []ProgramDay{day(2)}},
+		}, wantErr: "week 1 is listed twice"},
+		{name: "repeated day", weeks: []ProgramWeek{
+			{WeekNumber: 2, Days: []ProgramDay{day(1), day(1)}},
+		}, wantErr: "week 2 day 1 is listed twice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := Program{Name: "Block", Weeks: tt.weeks}
+			err := program.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestEnrolmentWeek(t *testing.T) {
+	enrolment := Enrolment{StartDate: "2026-01-05"}
+	on := func(date string) time.Time {
+		day, _ := time.Parse(dateLayout, date)
+		return day.Add(20 * time.Hour)
+	}
+
+	assert.Equal(t, 0, enrolment.Week(on("2026-01-04")), "not started yet")
+	assert.Equal(t, 1, enrolment.Week(on("2026-01-05")))
+	assert.Equal(t, 1, enrolment.Week(on("2026-01-11")))
+	assert.Equal(t, 2, enrolment.Week(on("2026-01-12")))
+	assert.Equal(t, 5, enrolment.Week(on("2026-02-05")))
+}
@@ -0,0 +1,63 @@
+package programs
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Josesx506/gofems/internal/api/v1/templates"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+)
+
+// Prescribed weights are rounded to the smallest common plate jump
+const weightIncrement = 2.5
+
+// Prescribe builds the workout a user should perform for a program day.
+// percent_1rm days set the weight of rep-based entries from the training max
+// of each exercise, rpe days annotate every entry with the target effort.
+func Prescribe(day *ProgramDay, template *templates.Template, trainingMaxes map[string]float64) *workouts.Workout {
+	workout := template.ToWorkout()
+	workout.Title = fmt.Sprintf("%s - Week %d Day %d", template.Name, day.WeekNumber, day.DayNumber)
+	if day.Notes != "" {
+		workout.Description = strings.TrimSpace(workout.Description + "\n" + day.Notes)
+	}
+
+	if day.IntensityType == nil || day.Intensity == nil {
+		return workout
+	}
+
+	maxes := make(map[string]float64, len(trainingMaxes))
+	for name, weight := range trainingMaxes {
+		maxes[strings.ToLower(name)] = weight
+	}
+
+	for i := range workout.Entries {
+		entry := &workout.Entries[i]
+
+		switch *day.IntensityType {
+		case IntensityPercent1RM:
+			trainingMax, ok := maxes[strings.ToLower(entry.ExerciseName)]
+			if !ok || entry.Reps == nil {
+				continue
+			}
+			weight := roundToIncrement(trainingMax * *day.Intensity / 100)
+			entry.Weight = &weight
+			entry.Notes = appendNote(entry.Notes, fmt.Sprintf("%g%% of %g", *day.Intensity, trainingMax))
+		case IntensityRPE:
+			entry.Notes = appendNote(entry.Notes, fmt.Sprintf("target RPE %g", *day.Intensity))
+		}
+	}
+
+	return workout
+}
+
+func roundToIncrement(weight float64) float64 {
+	return math.Round(weight/weightIncrement) * weightIncrement
+}
+
+func appendNote(notes, note string) string {
+	if notes == "" {
+		return note
+	}
+	return notes + "; " + note
+}
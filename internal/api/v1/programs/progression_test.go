@@ -0,0 +1,77 @@
+package programs
+
+import (
+	"testing"
+
+	"github.com/Josesx506/gofems/internal/api/v1/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrescribe(t *testing.T) {
+	template := &templates.Template{
+		ID:   7,
+		Name: "Lower A",
+		Entries: []templates.TemplateEntry{
+			{ExerciseName: "Back Squat", TargetSets: 5, TargetRepsMin: intPtr(5), OrderIndex: 1},
+			{ExerciseName: "Plank", TargetSets: 3, TargetDurationSeconds: intPtr(60), OrderIndex: 2},
+			{ExerciseName: "Lunges", TargetSets: 3, TargetRepsMin: intPtr(10), TargetWeightMin: floatPtr(20), OrderIndex: 3},
+		},
+	}
+	trainingMaxes := map[string]float64{"back squat": 142}
+
+	tests := []struct {
+		name          string
+		intensityType *string
+		intensity     *float64
+		wantWeights   []*float64
+		wantNotes     []string
+	}{
+		{
+			name:        "No progression rule",
+			wantWeights: []*float64{nil, nil, floatPtr(20)},
+			wantNotes:   []string{"", "", ""},
+		},
+		{
+			name:          "Percentage of training max",
+			intensityType: strPtr(IntensityPercent1RM),
+			intensity:     floatPtr(75),
+			wantWeights:   []*float64{floatPtr(107.5), nil, floatPtr(20)}, // 106.5 rounds to 107.5
+			wantNotes:     []string{"75% of 142", "", ""},
+		},
+		{
+			name:          "RPE target",
+			intensityType: strPtr(IntensityRPE),
+			intensity:     floatPtr(8),
+			wantWeights:   []*float64{nil, nil, floatPtr(20)},
+			wantNotes:     []string{"target RPE 8", "target RPE 8", "target RPE 8"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day := &ProgramDay{WeekNumber: 2, DayNumber: 1, TemplateID: 7, IntensityType: tt.intensityType, Intensity: tt.intensity}
+
+			workout := Prescribe(day, template, trainingMaxes)
+			assert.Equal(t, "Lower A - Week 2 Day 1", workout.Title)
+			assert.Equal(t, 7, *workout.TemplateID)
+			require.Len(t, workout.Entries, 3)
+			for i, entry := range workout.Entries {
+				assert.Equal(t, tt.wantWeights[i], entry.Weight, entry.ExerciseName)
+				assert.Equal(t, tt.wantNotes[i], entry.Notes, entry.ExerciseName)
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func strPtr(s string) *string {
+	return &s
+}
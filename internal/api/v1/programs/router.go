@@ -0,0 +1,33 @@
+package programs
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/templates"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/go-chi/chi/v5"
+)
+
+func ProgramRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and stores
+	r := chi.NewRouter()
+	store := NewPostgresProgramStore(app.DB)
+	templateStore := templates.NewPostgresTemplateStore(app.DB)
+	workoutStore := workouts.NewPostgresWorkoutStore(app.DB)
+	handler := NewProgramHandler(store, templateStore, workoutStore, app.Logger)
+
+	// Define subroutes
+	r.Get("/", handler.HandleListPrograms)
+	r.Post("/", handler.HandleCreateProgram)
+	r.Get("/{id}", handler.HandleGetProgramByID)
+	r.Delete("/{id}", handler.HandleDeleteProgramByID)
+	r.Get("/{id}/enrolments", handler.HandleListEnrolments)
+	r.Post("/{id}/enrolments", handler.HandleEnrol)
+
+	r.Get("/enrolments/{id}", handler.HandleGetEnrolmentByID)
+	r.Post("/enrolments/{id}/cancel", handler.HandleCancelEnrolment)
+	r.Get("/enrolments/{id}/today", handler.HandleGetTodaysWorkout)
+	r.Post("/enrolments/{id}/completions", handler.HandleCompleteProgramDay)
+
+	return r
+}
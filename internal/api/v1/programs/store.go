@@ -0,0 +1,482 @@
+package programs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+var (
+	ErrDayNotInProgram    = errors.New("program day does not belong to the enrolled program")
+	ErrDuplicateDay       = errors.New("program has the same week and day number twice")
+	ErrEnrolmentNotActive = errors.New("enrolment is no longer active")
+	ErrUnknownTemplate    = errors.New("program day references a template that does not exist")
+	ErrUnknownUser        = errors.New("user does not exist")
+	ErrUnknownWorkout     = errors.New("workout not found for the enrolled user")
+)
+
+// DB connector struct
+type PostgresProgramStore struct {
+	db *sql.DB
+}
+
+func NewPostgresProgramStore(db *sql.DB) *PostgresProgramStore {
+	return &PostgresProgramStore{db: db}
+}
+
+type ProgramStore interface {
+	CreateProgram(*Program) (*Program, error)
+	GetProgramByID(id int64) (*Program, error)
+	ListPrograms() ([]Program, error)
+	DeleteProgram(id int64) error
+	CreateEnrolment(*Enrolment) (*Enrolment, error)
+	GetEnrolmentByID(id int64) (*Enrolment, error)
+	ListEnrolments(programID int64, status string) ([]Enrolment, error)
+	CancelEnrolment(id int64) error
+	NextProgramDay(enrolmentID int64, week int) (*ProgramDay, error)
+	CompleteProgramDay(enrolmentID, programDayID, workoutID int64) (*DayCompletion, error)
+}
+
+func (pgStore *PostgresProgramStore) CreateProgram(program *Program) (*Program, error) {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // rollback transaction if not committed
+
+	query := `
+	INSERT INTO programs (user_id, name, description)
+	VALUES ($1, $2, $3)
+	RETURNING id
+	`
+	err = tx.QueryRow(query, nullableID(program.UserID), program.Name, program.Description).Scan(&program.ID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	// Days are stored flat, the week they're nested under sets their week number
+	for i := range program.Weeks {
+		week := &program.Weeks[i]
+		for j := range week.Days {
+			day := &week.Days[j]
+			day.WeekNumber = week.WeekNumber
+
+			dayQuery := `
+			INSERT INTO program_days (program_id, week_number, day_number, template_id, intensity_type, intensity, notes)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+			`
+			err = tx.QueryRow(dayQuery, program.ID, day.WeekNumber, day.DayNumber, day.TemplateID,
+				day.IntensityType, day.Intensity, day.Notes).Scan(&day.ID)
+			if err != nil {
+				return nil, translateError(err)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return program, nil
+}
+
+func (pgStore *PostgresProgramStore) GetProgramByID(id int64) (*Program, error) {
+	program := &Program{}
+	var userID sql.NullInt64
+
+	query := `
+	SELECT id, user_id, name, description
+	FROM programs
+	WHERE id = $1
+	`
+	err := pgStore.db.QueryRow(query, id).Scan(&program.ID, &userID, &program.Name, &program.Description)
+	if err == sql.ErrNoRows {
+		return nil, nil // No program found
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	program.UserID = int(userID.Int64)
+
+	err = pgStore.loadWeeks(program)
+	if err != nil {
+		return nil, err
+	}
+
+	return program, nil
+}
+
+func (pgStore *PostgresProgramStore) ListPrograms() ([]Program, error) {
+	query := `
+	SELECT id, user_id, name, description
+	FROM programs
+	ORDER BY name ASC, id ASC
+	`
+	rows, err := pgStore.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	programs := []Program{}
+	for rows.Next() {
+		var program Program
+		var userID sql.NullInt64
+		err := rows.Scan(&program.ID, &userID, &program.Name, &program.Description)
+		if err != nil {
+			return nil, err
+		}
+		program.UserID = int(userID.Int64)
+		programs = append(programs, program)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range programs {
+		err := pgStore.loadWeeks(&programs[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return programs, nil
+}
+
+func (pgStore *PostgresProgramStore) DeleteProgram(id int64) error {
+	result, err := pgStore.db.Exec(`DELETE FROM programs WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (pgStore *PostgresProgramStore) CreateEnrolment(enrolment *Enrolment) (*Enrolment, error) {
+	trainingMaxes, err := json.Marshal(enrolment.TrainingMaxes)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	INSERT INTO program_enrolments (program_id, user_id, start_date, training_maxes)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, status
+	`
+	err = pgStore.db.QueryRow(query, enrolment.ProgramID, enrolment.UserID, enrolment.StartDate,
+		string(trainingMaxes)).Scan(&enrolment.ID, &enrolment.Status)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return pgStore.GetEnrolmentByID(int64(enrolment.ID))
+}
+
+// Loads an enrolment with its completed days and the number of days in the program
+func (pgStore *PostgresProgramStore) GetEnrolmentByID(id int64) (*Enrolment, error) {
+	enrolment := &Enrolment{}
+	var startDate time.Time
+	var trainingMaxes []byte
+
+	query := `
+	SELECT e.id, e.program_id, e.user_id, e.start_date, e.training_maxes, e.status,
+		(SELECT COUNT(*) FROM program_days d WHERE d.program_id = e.program_id)
+	FROM program_enrolments e
+	WHERE e.id = $1
+	`
+	err := pgStore.db.QueryRow(query, id).Scan(&enrolment.ID, &enrolment.ProgramID, &enrolment.UserID,
+		&startDate, &trainingMaxes, &enrolment.Status, &enrolment.TotalDays)
+	if err == sql.ErrNoRows {
+		return nil, nil // No enrolment found
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	enrolment.StartDate = startDate.Format(dateLayout)
+
+	err = json.Unmarshal(trainingMaxes, &enrolment.TrainingMaxes)
+	if err != nil {
+		return nil, err
+	}
+
+	completionsQuery := `
+	SELECT program_day_id, workout_id, completed_at
+	FROM program_day_completions
+	WHERE enrolment_id = $1
+	ORDER BY completed_at ASC
+	`
+	rows, err := pgStore.db.Query(completionsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrolment.Completions = []DayCompletion{}
+	for rows.Next() {
+		var completion DayCompletion
+		err := rows.Scan(&completion.ProgramDayID, &completion.WorkoutID, &completion.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+		enrolment.Completions = append(enrolment.Completions, completion)
+	}
+
+	return enrolment, rows.Err()
+}
+
+// Lists the enrolments of a program, narrowed to a status unless it's empty
+func (pgStore *PostgresProgramStore) ListEnrolments(programID int64, status string) ([]Enrolment, error) {
+	query := `
+	SELECT id
+	FROM program_enrolments
+	WHERE program_id = $1 AND ($2 = '' OR status = $2)
+	ORDER BY id ASC
+	`
+	rows, err := pgStore.db.Query(query, programID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	enrolments := []Enrolment{}
+	for _, id := range ids {
+		enrolment, err := pgStore.GetEnrolmentByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if enrolment != nil {
+			enrolments = append(enrolments, *enrolment)
+		}
+	}
+
+	return enrolments, nil
+}
+
+// Stops an active enrolment. Returns ErrEnrolmentNotActive when it's already
+// completed or cancelled.
+func (pgStore *PostgresProgramStore) CancelEnrolment(id int64) error {
+	query := `
+	UPDATE program_enrolments
+	SET status = $2
+	WHERE id = $1 AND status = $3
+	`
+	result, err := pgStore.db.Exec(query, id, EnrolmentCancelled, EnrolmentActive)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEnrolmentNotActive
+	}
+
+	return nil
+}
+
+// NextProgramDay returns the first day of the given program week that the enrolment
+// hasn't completed yet. Days of weeks that are over are skipped, so a user who falls
+// behind picks the program up where the calendar is. It returns nil once the week is done.
+func (pgStore *PostgresProgramStore) NextProgramDay(enrolmentID int64, week int) (*ProgramDay, error) {
+	day := &ProgramDay{}
+
+	query := `
+	SELECT d.id, d.week_number, d.day_number, d.template_id, d.intensity_type, d.intensity, d.notes
+	FROM program_enrolments e
+	JOIN program_days d ON d.program_id = e.program_id
+	WHERE e.id = $1 AND d.week_number = $2 AND NOT EXISTS (
+		SELECT 1 FROM program_day_completions c
+		WHERE c.enrolment_id = e.id AND c.program_day_id = d.id
+	)
+	ORDER BY d.day_number ASC
+	LIMIT 1
+	`
+	err := pgStore.db.QueryRow(query, enrolmentID, week).Scan(&day.ID, &day.WeekNumber, &day.DayNumber,
+		&day.TemplateID, &day.IntensityType, &day.Intensity, &day.Notes)
+	if err == sql.ErrNoRows {
+		return nil, nil // Week done
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return day, nil
+}
+
+// CompleteProgramDay links a logged workout to a program day and marks the
+// enrolment completed once every day of the program has a workout. Returns
+// ErrDayNotInProgram, ErrEnrolmentNotActive or ErrUnknownWorkout when nothing is completed.
+func (pgStore *PostgresProgramStore) CompleteProgramDay(enrolmentID, programDayID, workoutID int64) (*DayCompletion, error) {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	completion := &DayCompletion{ProgramDayID: int(programDayID), WorkoutID: int(workoutID)}
+
+	// Only days of the enrolled program can be completed, while the enrolment is active and
+	// with a live workout of the enrolled user. Re-completing swaps the workout
+	query := `
+	INSERT INTO program_day_completions (enrolment_id, program_day_id, workout_id)
+	SELECT e.id, d.id, $3
+	FROM program_enrolments e
+	JOIN program_days d ON d.program_id = e.program_id
+	WHERE e.id = $1 AND d.id = $2 AND e.status = 'active' AND EXISTS (
+		SELECT 1 FROM workouts w
+		WHERE w.id = $3 AND w.user_id = e.user_id AND w.deleted_at IS NULL
+	)
+	ON CONFLICT (enrolment_id, program_day_id)
+	DO UPDATE SET workout_id = EXCLUDED.workout_id, completed_at = CURRENT_TIMESTAMP
+	RETURNING completed_at
+	`
+	err = tx.QueryRow(query, enrolmentID, programDayID, workoutID).Scan(&completion.CompletedAt)
+	if err == sql.ErrNoRows {
+		return nil, completionError(tx, enrolmentID, programDayID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	statusQuery := `
+	UPDATE program_enrolments e
+	SET status = $2
+	WHERE e.id = $1 AND e.status = $3 AND NOT EXISTS (
+		SELECT 1 FROM program_days d
+		WHERE d.program_id = e.program_id AND NOT EXISTS (
+			SELECT 1 FROM program_day_completions c
+			WHERE c.enrolment_id = e.id AND c.program_day_id = d.id
+		)
+	)
+	`
+	_, err = tx.Exec(statusQuery, enrolmentID, EnrolmentCompleted, EnrolmentActive)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return completion, nil
+}
+
+// Tells why a day couldn't be completed: the day isn't part of the enrolled program,
+// the enrolment is no longer active, or else the workout isn't a live one of the enrolled user
+func completionError(tx *sql.Tx, enrolmentID, programDayID int64) error {
+	query := `
+	SELECT e.status, EXISTS (SELECT 1 FROM program_days d WHERE d.program_id = e.program_id AND d.id = $2)
+	FROM program_enrolments e
+	WHERE e.id = $1
+	`
+	var status string
+	var inProgram bool
+	err := tx.QueryRow(query, enrolmentID, programDayID).Scan(&status, &inProgram)
+	switch {
+	case err == sql.ErrNoRows || (err == nil && !inProgram):
+		return ErrDayNotInProgram
+	case err != nil:
+		return err
+	case status != EnrolmentActive:
+		return ErrEnrolmentNotActive
+	}
+
+	return ErrUnknownWorkout
+}
+
+// Groups the flat program days into weeks
+func (pgStore *PostgresProgramStore) loadWeeks(program *Program) error {
+	query := `
+	SELECT id, week_number, day_number, template_id, intensity_type, intensity, notes
+	FROM program_days
+	WHERE program_id = $1
+	ORDER BY week_number ASC, day_number ASC
+	`
+	rows, err := pgStore.db.Query(query, program.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	program.Weeks = []ProgramWeek{}
+	for rows.Next() {
+		var day ProgramDay
+		err := rows.Scan(&day.ID, &day.WeekNumber, &day.DayNumber, &day.TemplateID,
+			&day.IntensityType, &day.Intensity, &day.Notes)
+		if err != nil {
+			return err
+		}
+
+		last := len(program.Weeks) - 1
+		if last < 0 || program.Weeks[last].WeekNumber != day.WeekNumber {
+			program.Weeks = append(program.Weeks, ProgramWeek{WeekNumber: day.WeekNumber})
+			last++
+		}
+		program.Weeks[last].Days = append(program.Weeks[last].Days, day)
+	}
+
+	return rows.Err()
+}
+
+// Programs without an author are stored with a NULL user_id
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// Maps constraint violations onto errors the handlers can report as bad requests
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch {
+	case pgErr.Code == "23505" && pgErr.ConstraintName == "program_days_program_id_week_number_day_number_key": // unique_violation
+		return ErrDuplicateDay
+	case pgErr.Code != "23503": // foreign_key_violation
+		return err
+	case pgErr.ConstraintName == "program_days_template_id_fkey":
+		return ErrUnknownTemplate
+	case pgErr.ConstraintName == "program_enrolments_user_id_fkey", pgErr.ConstraintName == "programs_user_id_fkey":
+		return ErrUnknownUser
+	}
+
+	return err
+}
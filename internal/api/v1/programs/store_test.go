@@ -0,0 +1,133 @@
+package programs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/templates"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/store"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgramEnrolment(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresProgramStore(db)
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+
+	var userID int
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('lifter', 'lifter@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	template, err := templates.NewPostgresTemplateStore(db).CreateTemplate(&templates.Template{
+		Name:    "Squat Day",
+		Entries: []templates.TemplateEntry{{ExerciseName: "Back Squat", TargetSets: 5, TargetRepsMin: intPtr(5), OrderIndex: 1}},
+	})
+	require.NoError(t, err)
+
+	program, err := pgStore.CreateProgram(&Program{
+		Name: "Two Week Squat Block",
+		Weeks: []ProgramWeek{
+			{WeekNumber: 1, Days: []ProgramDay{{DayNumber: 1, TemplateID: template.ID, IntensityType: strPtr(IntensityPercent1RM), Intensity: floatPtr(70)}}},
+			{WeekNumber: 2, Days: []ProgramDay{{DayNumber: 1, TemplateID: template.ID, IntensityType: strPtr(IntensityPercent1RM), Intensity: floatPtr(80)}}},
+		},
+	})
+	require.NoError(t, err)
+
+	retrieved, err := pgStore.GetProgramByID(int64(program.ID))
+	require.NoError(t, err)
+	require.Len(t, retrieved.Weeks, 2)
+
+	enrolment, err := pgStore.CreateEnrolment(&Enrolment{
+		ProgramID:     program.ID,
+		UserID:        userID,
+		StartDate:     "2026-01-05",
+		TrainingMaxes: map[string]float64{"Back Squat": 150},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, EnrolmentActive, enrolment.Status)
+	assert.Equal(t, 2, enrolment.TotalDays)
+	enrolmentID := int64(enrolment.ID)
+
+	// Work through both weeks, completing each day with a logged workout
+	for week := 1; week <= 2; week++ {
+		day, err := pgStore.NextProgramDay(enrolmentID, week)
+		require.NoError(t, err)
+		require.NotNil(t, day)
+		assert.Equal(t, week, day.WeekNumber)
+
+		workout := Prescribe(day, template, enrolment.TrainingMaxes)
+		workout.UserID = userID
+		_, err = workoutStore.CreateWorkout(workout)
+		require.NoError(t, err)
+
+		_, err = pgStore.CompleteProgramDay(enrolmentID, int64(day.ID), int64(workout.ID))
+		require.NoError(t, err)
+	}
+
+	day, err := pgStore.NextProgramDay(enrolmentID, 2)
+	require.NoError(t, err)
+	assert.Nil(t, day, "the week is done")
+
+	enrolment, err = pgStore.GetEnrolmentByID(enrolmentID)
+	require.NoError(t, err)
+	assert.Equal(t, EnrolmentCompleted, enrolment.Status)
+	assert.Len(t, enrolment.Completions, 2)
+
+	// Days from other programs are rejected
+	_, err = pgStore.CompleteProgramDay(enrolmentID, 0, int64(enrolment.Completions[0].WorkoutID))
+	assert.ErrorIs(t, err, ErrDayNotInProgram)
+
+	// Completed enrolments take no more completions
+	_, err = pgStore.CompleteProgramDay(enrolmentID, int64(enrolment.Completions[0].ProgramDayID),
+		int64(enrolment.Completions[0].WorkoutID))
+	assert.ErrorIs(t, err, ErrEnrolmentNotActive)
+
+	// A user falling behind skips the days of weeks that are over
+	late, err := pgStore.CreateEnrolment(&Enrolment{ProgramID: program.ID, UserID: userID, StartDate: "2026-01-05",
+		TrainingMaxes: map[string]float64{}})
+	require.NoError(t, err)
+	day, err = pgStore.NextProgramDay(int64(late.ID), late.Week(time.Date(2026, 1, 14, 9, 0, 0, 0, time.UTC)))
+	require.NoError(t, err)
+	require.NotNil(t, day)
+	assert.Equal(t, 2, day.WeekNumber)
+
+	// Days are completed with live workouts of the enrolled user only
+	var otherID int
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('spotter', 'spotter@example.com', 'hash') RETURNING id`).Scan(&otherID))
+	foreign, err := workoutStore.CreateWorkout(&workouts.Workout{UserID: otherID, Title: "Someone else's squats"})
+	require.NoError(t, err)
+	_, err = pgStore.CompleteProgramDay(int64(late.ID), int64(day.ID), int64(foreign.ID))
+	assert.ErrorIs(t, err, ErrUnknownWorkout)
+
+	trashed, err := workoutStore.CreateWorkout(&workouts.Workout{UserID: userID, Title: "Deleted squats"})
+	require.NoError(t, err)
+	require.NoError(t, workoutStore.DeleteWorkout(int64(trashed.ID)))
+	_, err = pgStore.CompleteProgramDay(int64(late.ID), int64(day.ID), int64(trashed.ID))
+	assert.ErrorIs(t, err, ErrUnknownWorkout)
+
+	// Only active enrolments can be cancelled
+	require.NoError(t, pgStore.CancelEnrolment(int64(late.ID)))
+	assert.ErrorIs(t, pgStore.CancelEnrolment(int64(late.ID)), ErrEnrolmentNotActive)
+	assert.ErrorIs(t, pgStore.CancelEnrolment(enrolmentID), ErrEnrolmentNotActive, "completed enrolments stay completed")
+
+	cancelled, err := pgStore.ListEnrolments(int64(program.ID), EnrolmentCancelled)
+	require.NoError(t, err)
+	require.Len(t, cancelled, 1)
+	assert.Equal(t, late.ID, cancelled[0].ID)
+	all, err := pgStore.ListEnrolments(int64(program.ID), "")
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	// Days repeating a week and day number are a conflict
+	_, err = pgStore.CreateProgram(&Program{Name: "Repeats", Weeks: []ProgramWeek{
+		{WeekNumber: 1, Days: []ProgramDay{{DayNumber: 1, TemplateID: template.ID}}},
+		{WeekNumber: 1, Days: []ProgramDay{{DayNumber: 1, TemplateID: template.ID}}},
+	}})
+	assert.ErrorIs(t, err, ErrDuplicateDay)
+}
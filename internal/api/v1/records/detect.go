@@ -0,0 +1,340 @@
+package records
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+)
+
+// Entries are grouped by their catalog exercise, falling back to the
+// normalized name for entries that aren't linked to the catalog
+const exerciseKeySQL = `COALESCE(we.exercise_id::text, LOWER(TRIM(we.exercise_name)))`
+
+// A logged entry of the workout being checked
+type loggedEntry struct {
+	ID              int
+	ExerciseKey     string
+	ExerciseID      *int
+	ExerciseName    string
+	Sets            int
+	Reps            *int
+	DurationSeconds *int
+	Weight          *float64
+	Volume          float64 // of its sets when they were logged one by one, see entry_volume
+}
+
+// Best values logged for an exercise before the workout being checked
+type previousBests struct {
+	values       map[string]float64 // by record type
+	repsAtWeight map[float64]float64
+}
+
+// DetectInTx recomputes the personal records set by a workout inside the
+// caller's transaction. Existing records of the workout are replaced, so it's
+// safe to call after both creates and updates. Each entry is compared against
+// the same user's live workouts logged before this one.
+func DetectInTx(tx *sql.Tx, workoutID int64) ([]Record, error) {
+	_, err := tx.Exec(`DELETE FROM personal_records WHERE workout_id = $1`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	var userID sql.NullInt64
+	var loggedAt time.Time
+	err = tx.QueryRow(`SELECT user_id, created_at FROM workouts WHERE id = $1`, workoutID).Scan(&userID, &loggedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := loadEntries(tx, workoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return []Record{}, nil
+	}
+
+	bests, err := loadPreviousBests(tx, workoutID, userID, loggedAt, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	records := findRecords(entries, bests)
+	for i := range records {
+		record := &records[i]
+		record.UserID = int(userID.Int64)
+		record.WorkoutID = int(workoutID)
+		record.AchievedAt = loggedAt
+
+		query := `
+		INSERT INTO personal_records (user_id, exercise_key, exercise_id, exercise_name, record_type, value,
+			weight, previous_value, workout_id, entry_id, achieved_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+		`
+		err := tx.QueryRow(query, userID, record.exerciseKey, record.ExerciseID, record.ExerciseName,
+			record.RecordType, record.Value, record.Weight, record.PreviousValue, workoutID, record.EntryID,
+			loggedAt).Scan(&record.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// findRecords compares the entries of a workout against the previous bests of
+// each exercise. Exercises logged for the first time set a record of every
+// type they have values for.
+func findRecords(entries []loggedEntry, bests map[string]*previousBests) []Record {
+	records := []Record{}
+
+	// Keep exercises in the order they were first logged in the workout
+	keys := []string{}
+	byKey := map[string][]loggedEntry{}
+	for _, entry := range entries {
+		if _, ok := byKey[entry.ExerciseKey]; !ok {
+			keys = append(keys, entry.ExerciseKey)
+		}
+		byKey[entry.ExerciseKey] = append(byKey[entry.ExerciseKey], entry)
+	}
+
+	for _, key := range keys {
+		prev := bests[key]
+		if prev == nil {
+			prev = &previousBests{values: map[string]float64{}, repsAtWeight: map[float64]float64{}}
+		}
+
+		var heaviest, strongest, longest *candidate
+		repsAtWeight := map[float64]*candidate{}
+		volume := 0.0
+		first := byKey[key][0]
+
+		for _, entry := range byKey[key] {
+			if entry.Weight != nil && *entry.Weight > 0 {
+				heaviest = better(heaviest, entry, *entry.Weight)
+
+				if entry.Reps != nil && *entry.Reps > 0 {
+					weight := *entry.Weight
+					strongest = better(strongest, entry, estimatedOneRepMax(weight, *entry.Reps))
+					repsAtWeight[weight] = better(repsAtWeight[weight], entry, float64(*entry.Reps))
+					volume += entry.Volume
+				}
+			}
+
+			if entry.DurationSeconds != nil && *entry.DurationSeconds > 0 {
+				longest = better(longest, entry, float64(*entry.DurationSeconds))
+			}
+		}
+
+		newRecord := func(recordType string, c *candidate, previous float64, hasPrevious bool) {
+			if c == nil || (hasPrevious && c.value <= previous) {
+				return
+			}
+			record := Record{
+				ExerciseID:   first.ExerciseID,
+				ExerciseName: first.ExerciseName,
+				RecordType:   recordType,
+				Value:        round(c.value),
+				exerciseKey:  key,
+			}
+			if c.entry != nil {
+				entryID := c.entry.ID
+				record.EntryID = &entryID
+			}
+			if hasPrevious {
+				prevValue := round(previous)
+				record.PreviousValue = &prevValue
+			}
+			records = append(records, record)
+		}
+
+		for _, check := range []struct {
+			recordType string
+			best       *candidate
+		}{
+			{MaxWeight, heaviest},
+			{Estimated1RM, strongest},
+			{LongestDuration, longest},
+		} {
+			previous, ok := prev.values[check.recordType]
+			newRecord(check.recordType, check.best, previous, ok)
+		}
+
+		if volume > 0 {
+			previous, ok := prev.values[SessionVolume]
+			newRecord(SessionVolume, &candidate{value: volume}, previous, ok)
+		}
+
+		weights := make([]float64, 0, len(repsAtWeight))
+		for weight := range repsAtWeight {
+			weights = append(weights, weight)
+		}
+		sort.Float64s(weights)
+		for _, weight := range weights {
+			previous, ok := prev.repsAtWeight[weight]
+			before := len(records)
+			newRecord(RepsAtWeight, repsAtWeight[weight], previous, ok)
+			if len(records) > before {
+				w := weight
+				records[len(records)-1].Weight = &w
+			}
+		}
+	}
+
+	return records
+}
+
+type candidate struct {
+	entry *loggedEntry
+	value float64
+}
+
+// Keeps the first entry with the highest value
+func better(current *candidate, entry loggedEntry, value float64) *candidate {
+	if current != nil && current.value >= value {
+		return current
+	}
+	return &candidate{entry: &entry, value: value}
+}
+
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func loadEntries(tx *sql.Tx, workoutID int64) ([]loggedEntry, error) {
+	query := `
+	SELECT we.id, ` + exerciseKeySQL + `, we.exercise_id, we.exercise_name, we.sets, we.reps,
+		we.duration_seconds, we.weight, COALESCE(entry_volume(we.id, we.sets, we.reps, we.weight), 0)
+	FROM workout_entries we
+	WHERE we.workout_id = $1
+	ORDER BY we.order_index ASC
+	`
+	rows, err := tx.Query(query, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []loggedEntry{}
+	for rows.Next() {
+		var entry loggedEntry
+		err := rows.Scan(&entry.ID, &entry.ExerciseKey, &entry.ExerciseID, &entry.ExerciseName, &entry.Sets,
+			&entry.Reps, &entry.DurationSeconds, &entry.Weight, &entry.Volume)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// Loads the bests of each exercise in entries from the user's earlier live workouts
+func loadPreviousBests(tx *sql.Tx, workoutID int64, userID sql.NullInt64, loggedAt time.Time,
+	entries []loggedEntry) (map[string]*previousBests, error) {
+	keys := []string{}
+	bests := map[string]*previousBests{}
+	for _, entry := range entries {
+		if _, ok := bests[entry.ExerciseKey]; !ok {
+			keys = append(keys, entry.ExerciseKey)
+			bests[entry.ExerciseKey] = &previousBests{values: map[string]float64{}, repsAtWeight: map[float64]float64{}}
+		}
+	}
+
+	history := `
+	FROM workout_entries we
+	JOIN workouts w ON w.id = we.workout_id
+	WHERE w.user_id IS NOT DISTINCT FROM $1 AND w.id <> $2 AND w.deleted_at IS NULL
+		AND w.created_at < $3 AND ` + exerciseKeySQL + ` = ANY($4)
+	`
+
+	bestsQuery := `
+	SELECT ` + exerciseKeySQL + `,
+		MAX(we.weight) FILTER (WHERE we.weight > 0),
+		MAX(CASE WHEN we.reps = 1 THEN we.weight ELSE we.weight * (1 + we.reps / 30.0) END)
+			FILTER (WHERE we.weight > 0 AND we.reps > 0),
+		MAX(we.duration_seconds) FILTER (WHERE we.duration_seconds > 0)
+	` + history + `
+	GROUP BY 1
+	`
+	rows, err := tx.Query(bestsQuery, userID, workoutID, loggedAt, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var maxWeight, best1RM, longest sql.NullFloat64
+		err := rows.Scan(&key, &maxWeight, &best1RM, &longest)
+		if err != nil {
+			return nil, err
+		}
+		setIfValid(bests[key].values, MaxWeight, maxWeight)
+		setIfValid(bests[key].values, Estimated1RM, best1RM)
+		setIfValid(bests[key].values, LongestDuration, longest)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	repsQuery := `
+	SELECT ` + exerciseKeySQL + `, we.weight, MAX(we.reps)
+	` + history + ` AND we.weight > 0 AND we.reps > 0
+	GROUP BY 1, 2
+	`
+	repsRows, err := tx.Query(repsQuery, userID, workoutID, loggedAt, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer repsRows.Close()
+
+	for repsRows.Next() {
+		var key string
+		var weight, reps float64
+		err := repsRows.Scan(&key, &weight, &reps)
+		if err != nil {
+			return nil, err
+		}
+		bests[key].repsAtWeight[weight] = reps
+	}
+	if err := repsRows.Err(); err != nil {
+		return nil, err
+	}
+
+	volumeQuery := `
+	SELECT exercise_key, MAX(volume)
+	FROM (
+		SELECT ` + exerciseKeySQL + ` AS exercise_key, we.workout_id, SUM(entry_volume(we.id, we.sets, we.reps, we.weight)) AS volume
+		` + history + ` AND we.weight > 0 AND we.reps > 0
+		GROUP BY 1, 2
+	) sessions
+	GROUP BY exercise_key
+	`
+	volumeRows, err := tx.Query(volumeQuery, userID, workoutID, loggedAt, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer volumeRows.Close()
+
+	for volumeRows.Next() {
+		var key string
+		var volume sql.NullFloat64
+		err := volumeRows.Scan(&key, &volume)
+		if err != nil {
+			return nil, err
+		}
+		setIfValid(bests[key].values, SessionVolume, volume)
+	}
+
+	return bests, volumeRows.Err()
+}
+
+func setIfValid(values map[string]float64, recordType string, value sql.NullFloat64) {
+	if value.Valid {
+		values[recordType] = value.Float64
+	}
+}
@@ -0,0 +1,74 @@
+package records
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindRecords(t *testing.T) {
+	squatID := 1
+	entries := []loggedEntry{
+		{ID: 10, ExerciseKey: "1", ExerciseID: &squatID, ExerciseName: "Squats", Sets: 3, Reps: intPtr(5), Weight: floatPtr(100),
+			Volume: 1400}, // sets logged one by one at 100, 100 and 80
+		{ID: 11, ExerciseKey: "1", ExerciseID: &squatID, ExerciseName: "Squats", Sets: 1, Reps: intPtr(1), Weight: floatPtr(120),
+			Volume: 120},
+		{ID: 12, ExerciseKey: "plank", ExerciseName: "Plank", Sets: 3, DurationSeconds: intPtr(90)},
+	}
+
+	t.Run("First time every exercise is logged", func(t *testing.T) {
+		records := findRecords(entries, map[string]*previousBests{})
+
+		types := map[string]Record{}
+		for _, record := range records {
+			types[record.ExerciseName+"/"+record.RecordType] = record
+			assert.Nil(t, record.PreviousValue)
+		}
+
+		assert.Equal(t, 120.0, types["Squats/max_weight"].Value)
+		assert.Equal(t, 11, *types["Squats/max_weight"].EntryID)
+		assert.Equal(t, 120.0, types["Squats/estimated_1rm"].Value) // 100x5 estimates 116.67
+		assert.Equal(t, 1520.0, types["Squats/session_volume"].Value, "the volume of the sets, not of the top set")
+		assert.Nil(t, types["Squats/session_volume"].EntryID)
+		assert.Equal(t, 90.0, types["Plank/longest_duration"].Value)
+		assert.Len(t, records, 6) // plus reps_at_weight at 100 and 120
+	})
+
+	t.Run("Only values beating the previous bests", func(t *testing.T) {
+		bests := map[string]*previousBests{
+			"1": {
+				values: map[string]float64{
+					MaxWeight:     125,
+					Estimated1RM:  118,
+					SessionVolume: 2000,
+				},
+				repsAtWeight: map[float64]float64{100: 4, 120: 2},
+			},
+			"plank": {
+				values:       map[string]float64{LongestDuration: 90},
+				repsAtWeight: map[float64]float64{},
+			},
+		}
+
+		records := findRecords(entries, bests)
+		require.Len(t, records, 2)
+
+		assert.Equal(t, Estimated1RM, records[0].RecordType)
+		assert.Equal(t, 120.0, records[0].Value)
+		assert.Equal(t, 118.0, *records[0].PreviousValue)
+
+		assert.Equal(t, RepsAtWeight, records[1].RecordType)
+		assert.Equal(t, 100.0, *records[1].Weight)
+		assert.Equal(t, 5.0, records[1].Value)
+		assert.Equal(t, 4.0, *records[1].PreviousValue)
+	})
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
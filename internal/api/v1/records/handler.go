@@ -0,0 +1,108 @@
+package records
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type RecordHandler struct {
+	store     RecordStore
+	userStore users.UserStore
+	logger    *log.Logger
+}
+
+func NewRecordHandler(store RecordStore, userStore users.UserStore, logger *log.Logger) *RecordHandler {
+	return &RecordHandler{
+		store:     store,
+		userStore: userStore,
+		logger:    logger,
+	}
+}
+
+// Lists the current records of ?user_id= grouped by exercise
+func (rh *RecordHandler) HandleListRecords(w http.ResponseWriter, r *http.Request) {
+	userID, ok := rh.readUserID(w, r)
+	if !ok {
+		return
+	}
+
+	unit, ok := users.ReadWeightUnit(w, r, rh.userStore, userID, rh.logger)
+	if !ok {
+		return
+	}
+
+	exercises, err := rh.store.ListCurrentRecords(userID, 0)
+	if err != nil {
+		rh.logger.Printf("Error listCurrentRecords: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	for i := range exercises {
+		ConvertWeights(exercises[i].Records, unit)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercises": exercises})
+}
+
+// Current records of one catalog exercise along with the history of how they were set
+func (rh *RecordHandler) HandleGetExerciseRecords(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.ReadInt64Param(r, "exerciseID")
+	if err != nil {
+		rh.logger.Printf("Error reading exerciseID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	userID, ok := rh.readUserID(w, r)
+	if !ok {
+		return
+	}
+
+	unit, ok := users.ReadWeightUnit(w, r, rh.userStore, userID, rh.logger)
+	if !ok {
+		return
+	}
+
+	current, err := rh.store.ListCurrentRecords(userID, exerciseID)
+	if err != nil {
+		rh.logger.Printf("Error listCurrentRecords: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	history, err := rh.store.ListRecordHistory(userID, exerciseID)
+	if err != nil {
+		rh.logger.Printf("Error listRecordHistory: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	records := []Record{}
+	if len(current) > 0 {
+		records = current[0].Records
+	}
+	ConvertWeights(records, unit)
+	ConvertWeights(history, unit)
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"records": records, "history": history})
+}
+
+func (rh *RecordHandler) readUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	param := r.URL.Query().Get("user_id")
+	if param == "" {
+		return 0, true
+	}
+
+	userID, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user id"}) // 400
+		return 0, false
+	}
+
+	return userID, true
+}
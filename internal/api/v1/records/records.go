@@ -0,0 +1,61 @@
+package records
+
+import (
+	"time"
+
+	"github.com/Josesx506/gofems/internal/units"
+)
+
+// A personal record event: the workout and entry that beat the previous best
+type Record struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	ExerciseID    *int      `json:"exercise_id"`
+	ExerciseName  string    `json:"exercise_name"`
+	RecordType    string    `json:"record_type"`
+	Value         float64   `json:"value"`
+	Weight        *float64  `json:"weight,omitempty"` // Only set for reps_at_weight
+	PreviousValue *float64  `json:"previous_value"`   // nil the first time an exercise is logged
+	WorkoutID     int       `json:"workout_id"`
+	EntryID       *int      `json:"entry_id"` // nil for session_volume, which spans entries
+	AchievedAt    time.Time `json:"achieved_at"`
+
+	exerciseKey string
+}
+
+// Current bests of one exercise
+type ExerciseRecords struct {
+	ExerciseID   *int     `json:"exercise_id"`
+	ExerciseName string   `json:"exercise_name"`
+	Records      []Record `json:"records"`
+}
+
+const (
+	MaxWeight       = "max_weight"       // heaviest weight lifted
+	RepsAtWeight    = "reps_at_weight"   // most reps at a given weight
+	Estimated1RM    = "estimated_1rm"    // best Epley estimate of the one rep max
+	LongestDuration = "longest_duration" // longest timed hold, in seconds
+	SessionVolume   = "session_volume"   // sets x reps x weight summed over a workout
+)
+
+// Epley estimate, a single rep is taken as the one rep max itself
+func estimatedOneRepMax(weight float64, reps int) float64 {
+	if reps == 1 {
+		return weight
+	}
+	return weight * (1 + float64(reps)/30)
+}
+
+// ConvertWeights converts stored records, in kilograms, into unit for output
+func ConvertWeights(records []Record, unit units.WeightUnit) {
+	for i := range records {
+		record := &records[i]
+		switch record.RecordType {
+		case MaxWeight, Estimated1RM, SessionVolume:
+			record.Value = units.FromKilograms(record.Value, unit)
+			units.ConvertWeight(record.PreviousValue, unit)
+		case RepsAtWeight:
+			units.ConvertWeight(record.Weight, unit)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package records
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/go-chi/chi/v5"
+)
+
+func RecordRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and store
+	r := chi.NewRouter()
+	store := NewPostgresRecordStore(app.DB)
+	handler := NewRecordHandler(store, users.NewPostgresUserStore(app.DB), app.Logger)
+
+	// Define subroutes
+	r.Get("/", handler.HandleListRecords)
+	r.Get("/exercises/{exerciseID}", handler.HandleGetExerciseRecords)
+
+	return r
+}
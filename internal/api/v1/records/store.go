@@ -0,0 +1,94 @@
+package records
+
+import (
+	"database/sql"
+)
+
+// DB connector struct, records are written by DetectInTx during workout saves
+type PostgresRecordStore struct {
+	db *sql.DB
+}
+
+func NewPostgresRecordStore(db *sql.DB) *PostgresRecordStore {
+	return &PostgresRecordStore{db: db}
+}
+
+type RecordStore interface {
+	ListCurrentRecords(userID, exerciseID int64) ([]ExerciseRecords, error)
+	ListRecordHistory(userID, exerciseID int64) ([]Record, error)
+}
+
+const recordColumns = `pr.id, pr.user_id, pr.exercise_key, pr.exercise_id, pr.exercise_name, pr.record_type,
+	pr.value, pr.weight, pr.previous_value, pr.workout_id, pr.entry_id, pr.achieved_at`
+
+// Records of workouts in the trash don't count, the previous record stands in for them.
+// A userID of 0 selects workouts logged without a user and an exerciseID of 0 every exercise.
+const recordFilter = `
+	FROM personal_records pr
+	JOIN workouts w ON w.id = pr.workout_id
+	WHERE pr.user_id IS NOT DISTINCT FROM $1 AND w.deleted_at IS NULL AND ($2 = 0 OR pr.exercise_id = $2)
+`
+
+// Lists the standing best of every record type per exercise
+func (pgStore *PostgresRecordStore) ListCurrentRecords(userID, exerciseID int64) ([]ExerciseRecords, error) {
+	query := `
+	SELECT DISTINCT ON (pr.exercise_key, pr.record_type, COALESCE(pr.weight, 0)) ` + recordColumns +
+		recordFilter + `
+	ORDER BY pr.exercise_key, pr.record_type, COALESCE(pr.weight, 0), pr.value DESC, pr.achieved_at ASC
+	`
+	records, err := pgStore.queryRecords(query, userID, exerciseID)
+	if err != nil {
+		return nil, err
+	}
+
+	exercises := []ExerciseRecords{}
+	index := map[string]int{}
+	for _, record := range records {
+		i, ok := index[record.exerciseKey]
+		if !ok {
+			i = len(exercises)
+			index[record.exerciseKey] = i
+			exercises = append(exercises, ExerciseRecords{
+				ExerciseID:   record.ExerciseID,
+				ExerciseName: record.ExerciseName,
+				Records:      []Record{},
+			})
+		}
+		exercises[i].Records = append(exercises[i].Records, record)
+	}
+
+	return exercises, nil
+}
+
+// Lists every record event, oldest first, to chart how records progressed
+func (pgStore *PostgresRecordStore) ListRecordHistory(userID, exerciseID int64) ([]Record, error) {
+	query := `
+	SELECT ` + recordColumns + recordFilter + `
+	ORDER BY pr.achieved_at ASC, pr.id ASC
+	`
+	return pgStore.queryRecords(query, userID, exerciseID)
+}
+
+func (pgStore *PostgresRecordStore) queryRecords(query string, userID, exerciseID int64) ([]Record, error) {
+	rows, err := pgStore.db.Query(query, sql.NullInt64{Int64: userID, Valid: userID != 0}, exerciseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var record Record
+		var ownerID sql.NullInt64
+		err := rows.Scan(&record.ID, &ownerID, &record.exerciseKey, &record.ExerciseID, &record.ExerciseName,
+			&record.RecordType, &record.Value, &record.Weight, &record.PreviousValue, &record.WorkoutID,
+			&record.EntryID, &record.AchievedAt)
+		if err != nil {
+			return nil, err
+		}
+		record.UserID = int(ownerID.Int64)
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
@@ -1,6 +1,19 @@
 package apiv1
 
 import (
+	"github.com/Josesx506/gofems/internal/api/v1/account"
+	"github.com/Josesx506/gofems/internal/api/v1/admin"
+	"github.com/Josesx506/gofems/internal/api/v1/analytics"
+	"github.com/Josesx506/gofems/internal/api/v1/calendar"
+	"github.com/Josesx506/gofems/internal/api/v1/events"
+	"github.com/Josesx506/gofems/internal/api/v1/exercises"
+	"github.com/Josesx506/gofems/internal/api/v1/live"
+	"github.com/Josesx506/gofems/internal/api/v1/programs"
+	"github.com/Josesx506/gofems/internal/api/v1/records"
+	"github.com/Josesx506/gofems/internal/api/v1/schedule"
+	"github.com/Josesx506/gofems/internal/api/v1/templates"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/api/v1/webhooks"
 	"github.com/Josesx506/gofems/internal/api/v1/workouts"
 	"github.com/Josesx506/gofems/internal/app"
 	"github.com/go-chi/chi/v5"
@@ -14,6 +27,20 @@ func ApiV1Router(app *app.Application) chi.Router {
 	r.Get("/health", v1Handler.Health)
 
 	r.Mount("/workouts", workouts.WorkoutRouter(app))
+	r.Mount("/templates", templates.TemplateRouter(app))
+	r.Mount("/programs", programs.ProgramRouter(app))
+	r.Mount("/exercises", exercises.ExerciseRouter(app))
+	r.Mount("/records", records.RecordRouter(app))
+	r.Mount("/analytics", analytics.AnalyticsRouter(app))
+	r.Mount("/users/me", account.AccountRouter(app)) // before /users so "me" isn't read as an id
+	r.Mount("/users", users.UserRouter(app))
+	r.Mount("/exports", account.DownloadRouter(app))
+	r.Mount("/calendar", calendar.CalendarRouter(app))
+	r.Mount("/schedule", schedule.ScheduleRouter(app))
+	r.Mount("/webhooks", webhooks.WebhookRouter(app))
+	r.Mount("/events", events.EventRouter(app))
+	r.Mount("/live", live.LiveRouter(app))
+	r.Mount("/admin", admin.AdminRouter(app))
 
 	return r
 }
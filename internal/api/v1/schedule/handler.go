@@ -0,0 +1,293 @@
+package schedule
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/templates"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type ScheduleHandler struct {
+	store         ScheduleStore
+	templateStore templates.TemplateStore
+	workoutStore  workouts.WorkoutStore
+	logger        *log.Logger
+}
+
+// Sessions reference templates and are completed by workouts, so the handler checks both
+func NewScheduleHandler(store ScheduleStore, templateStore templates.TemplateStore, workoutStore workouts.WorkoutStore,
+	logger *log.Logger) *ScheduleHandler {
+	return &ScheduleHandler{
+		store:         store,
+		templateStore: templateStore,
+		workoutStore:  workoutStore,
+		logger:        logger,
+	}
+}
+
+type completeSessionRequest struct {
+	WorkoutID    int        `json:"workout_id"`
+	OccurrenceAt *time.Time `json:"occurrence_at"` // Defaults to the start of one-off sessions
+}
+
+// Without ?from= and ?to= the upcoming schedule covers the next two weeks
+const defaultUpcoming = 14 * 24 * time.Hour
+
+// Without ?from= and ?to= adherence covers the last four weeks
+const defaultAdherence = 28 * 24 * time.Hour
+
+func (sh *ScheduleHandler) HandleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var session Session
+	err := json.NewDecoder(r.Body).Decode(&session)
+	if err != nil {
+		sh.logger.Printf("Error decodingCreateSession: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+	session.UserID = users.CurrentUser(r).ID
+
+	// Sessions planned from a template are named after it unless given a title
+	if session.TemplateID != nil {
+		template, err := sh.templateStore.GetTemplateByID(int64(*session.TemplateID))
+		if err != nil {
+			sh.logger.Printf("Error getTemplateByID: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+			return
+		}
+		if template == nil || (template.UserID != 0 && template.UserID != session.UserID) {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": ErrUnknownTemplate.Error()}) // 400
+			return
+		}
+		if session.Title == "" {
+			session.Title = template.Name
+		}
+	}
+
+	err = session.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	createdSession, err := sh.store.CreateSession(&session)
+	if errors.Is(err, ErrUnknownTemplate) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+	if err != nil {
+		sh.logger.Printf("Error createSession: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create session"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"session": createdSession})
+}
+
+func (sh *ScheduleHandler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := sh.store.ListSessions(int64(users.CurrentUser(r).ID))
+	if err != nil {
+		sh.logger.Printf("Error listSessions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"sessions": sessions})
+}
+
+func (sh *ScheduleHandler) HandleGetSessionByID(w http.ResponseWriter, r *http.Request) {
+	session, ok := sh.readSession(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"session": session})
+}
+
+func (sh *ScheduleHandler) HandleDeleteSessionByID(w http.ResponseWriter, r *http.Request) {
+	session, ok := sh.readSession(w, r)
+	if !ok {
+		return
+	}
+
+	err := sh.store.DeleteSession(int64(session.ID))
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "session not found"}) // 404
+		return
+	}
+
+	if err != nil {
+		sh.logger.Printf("Error deleteSession: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete session"}) // 500
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Marks an occurrence of a session done by linking the caller's workout to it
+func (sh *ScheduleHandler) HandleCompleteSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := sh.readSession(w, r)
+	if !ok {
+		return
+	}
+
+	var req completeSessionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		sh.logger.Printf("Error decodingCompleteSession: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	completion := Completion{SessionID: session.ID, WorkoutID: req.WorkoutID}
+	switch {
+	case req.OccurrenceAt != nil:
+		completion.OccurrenceAt = *req.OccurrenceAt
+	case session.RRule == "":
+		completion.OccurrenceAt = session.StartsAt
+	default:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": ErrOccurrenceMissing.Error()}) // 400
+		return
+	}
+	if !session.OccursAt(completion.OccurrenceAt) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": ErrNotAnOccurrence.Error()}) // 400
+		return
+	}
+
+	workout, err := sh.workoutStore.GetWorkoutByID(int64(req.WorkoutID))
+	if err != nil {
+		sh.logger.Printf("Error getWorkoutByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+	if workout == nil || workout.UserID != session.UserID {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": ErrUnknownWorkout.Error()}) // 400
+		return
+	}
+
+	err = sh.store.CompleteOccurrence(&completion)
+	switch {
+	case errors.Is(err, ErrAlreadyCompleted):
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+		return
+	case errors.Is(err, ErrUnknownWorkout):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	case err != nil:
+		sh.logger.Printf("Error completeOccurrence: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to complete session"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"completion": completion})
+}
+
+// Lists the caller's occurrences between ?from= and ?to=, the next two weeks by default
+func (sh *ScheduleHandler) HandleUpcoming(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	from, to, err := readRange(r, now, now.Add(defaultUpcoming))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	occurrences, err := sh.store.ListOccurrences(int64(users.CurrentUser(r).ID), from, to)
+	if err != nil {
+		sh.logger.Printf("Error listOccurrences: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"occurrences": occurrences})
+}
+
+// Compares planned and completed sessions between ?from= and ?to=, the last four weeks by default
+func (sh *ScheduleHandler) HandleAdherence(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	from, to, err := readRange(r, now.Add(-defaultAdherence), now)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	occurrences, err := sh.store.ListOccurrences(int64(users.CurrentUser(r).ID), from, to)
+	if err != nil {
+		sh.logger.Printf("Error listOccurrences: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"adherence": ComputeAdherence(occurrences, from, to)})
+}
+
+// Reads the {id} session, answering 404 for sessions of other users
+func (sh *ScheduleHandler) readSession(w http.ResponseWriter, r *http.Request) (*Session, bool) {
+	sessionID, err := utils.ReadIDParam(r)
+	if err != nil {
+		sh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid session id"}) // 400
+		return nil, false
+	}
+
+	session, err := sh.store.GetSessionByID(sessionID)
+	if err != nil {
+		sh.logger.Printf("Error getSessionByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return nil, false
+	}
+
+	if session == nil || session.UserID != users.CurrentUser(r).ID {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "session not found"}) // 404
+		return nil, false
+	}
+
+	return session, true
+}
+
+// Reads ?from= and ?to= as RFC 3339 times or YYYY-MM-DD dates, to being inclusive for dates
+func readRange(r *http.Request, defaultFrom, defaultTo time.Time) (time.Time, time.Time, error) {
+	params := r.URL.Query()
+	from, to := defaultFrom, defaultTo
+
+	if param := params.Get("from"); param != "" {
+		parsed, err := parseRangeParam(param, false)
+		if err != nil {
+			return from, to, fmt.Errorf("from must be a YYYY-MM-DD date or RFC 3339 time")
+		}
+		from = parsed
+	}
+	if param := params.Get("to"); param != "" {
+		parsed, err := parseRangeParam(param, true)
+		if err != nil {
+			return from, to, fmt.Errorf("to must be a YYYY-MM-DD date or RFC 3339 time")
+		}
+		to = parsed
+	}
+
+	if !from.Before(to) {
+		return from, to, ErrInvalidRange
+	}
+	if to.Sub(from) > MaxRange {
+		return from, to, ErrRangeTooLong
+	}
+	return from, to, nil
+}
+
+func parseRangeParam(param string, endOfDay bool) (time.Time, error) {
+	date, err := time.Parse(time.DateOnly, param)
+	if err == nil {
+		if endOfDay {
+			date = date.AddDate(0, 0, 1)
+		}
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, param)
+}
@@ -0,0 +1,32 @@
+package schedule
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/templates"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/go-chi/chi/v5"
+)
+
+func ScheduleRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and stores
+	r := chi.NewRouter()
+	store := NewPostgresScheduleStore(app.DB)
+	templateStore := templates.NewPostgresTemplateStore(app.DB)
+	workoutStore := workouts.NewPostgresWorkoutStore(app.DB)
+	handler := NewScheduleHandler(store, templateStore, workoutStore, app.Logger)
+
+	r.Use(users.RequireUser(users.NewPostgresUserStore(app.DB)))
+
+	// Define subroutes
+	r.Get("/upcoming", handler.HandleUpcoming)
+	r.Get("/adherence", handler.HandleAdherence)
+	r.Get("/sessions", handler.HandleListSessions)
+	r.Post("/sessions", handler.HandleCreateSession)
+	r.Get("/sessions/{id}", handler.HandleGetSessionByID)
+	r.Delete("/sessions/{id}", handler.HandleDeleteSessionByID)
+	r.Post("/sessions/{id}/completions", handler.HandleCompleteSession)
+
+	return r
+}
@@ -0,0 +1,271 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The subset of RFC 5545 recurrence rules planned sessions support: daily, weekly and
+// monthly frequencies with INTERVAL, COUNT or UNTIL, BYDAY weekdays (without ordinals)
+// and BYMONTHDAY for monthly rules, e.g. FREQ=WEEKLY;BYDAY=MO,WE,FR.
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+type Rule struct {
+	Freq       string
+	Interval   int
+	Count      int        // 0 for no limit
+	Until      *time.Time // Inclusive
+	ByDay      []time.Weekday
+	ByMonthDay []int // Negative days count back from the end of the month
+	WeekStart  time.Weekday
+}
+
+// ParseRule reads an RRULE value, with or without the "RRULE:" prefix
+func ParseRule(value string) (*Rule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	invalid := func(format string, args ...any) (*Rule, error) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRule, fmt.Sprintf(format, args...))
+	}
+
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return invalid("%q is not NAME=VALUE", part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+			if rule.Freq != FreqDaily && rule.Freq != FreqWeekly && rule.Freq != FreqMonthly {
+				return invalid("FREQ must be DAILY, WEEKLY or MONTHLY")
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+			if err != nil || rule.Interval < 1 {
+				return invalid("INTERVAL must be a positive number")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+			if err != nil || rule.Count < 1 {
+				return invalid("COUNT must be a positive number")
+			}
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return invalid("UNTIL must be a date or UTC date-time")
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(val), ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					return invalid("unsupported BYDAY %q, expected weekdays such as MO or FR", day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(val, ",") {
+				monthDay, err := strconv.Atoi(day)
+				if err != nil || monthDay == 0 || monthDay < -31 || monthDay > 31 {
+					return invalid("BYMONTHDAY must be between 1 and 31 or -31 and -1")
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, monthDay)
+			}
+		case "WKST":
+			weekday, ok := weekdays[strings.ToUpper(val)]
+			if !ok {
+				return invalid("WKST must be a weekday")
+			}
+			rule.WeekStart = weekday
+		default:
+			return invalid("%s is not supported", strings.ToUpper(name))
+		}
+	}
+
+	switch {
+	case rule.Freq == "":
+		return invalid("FREQ is required")
+	case rule.Count > 0 && rule.Until != nil:
+		return invalid("COUNT and UNTIL can't be combined")
+	case len(rule.ByMonthDay) > 0 && rule.Freq != FreqMonthly:
+		return invalid("BYMONTHDAY is only supported with FREQ=MONTHLY")
+	case len(rule.ByDay) > 0 && rule.Freq == FreqMonthly:
+		return invalid("BYDAY is not supported with FREQ=MONTHLY")
+	}
+
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		until, err := time.Parse(layout, value)
+		if err == nil {
+			if layout == "20060102" {
+				until = until.Add(24*time.Hour - time.Second) // the whole day
+			}
+			return until, nil
+		}
+	}
+	return time.Time{}, ErrInvalidRule
+}
+
+// String formats the rule in a canonical order, as it's stored
+func (rule *Rule) String() string {
+	parts := []string{"FREQ=" + rule.Freq}
+	if rule.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(rule.Interval))
+	}
+	if rule.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(rule.Count))
+	}
+	if rule.Until != nil {
+		parts = append(parts, "UNTIL="+rule.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(rule.ByDay) > 0 {
+		days := make([]string, len(rule.ByDay))
+		for i, weekday := range rule.ByDay {
+			days[i] = strings.ToUpper(weekday.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(rule.ByMonthDay) > 0 {
+		days := make([]string, len(rule.ByMonthDay))
+		for i, day := range rule.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if rule.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+strings.ToUpper(rule.WeekStart.String()[:2]))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Between returns the occurrences of the rule starting at start that fall in [from, to).
+// Occurrences keep the wall clock time of start in its location, so a 07:00 session
+// stays at 07:00 across daylight saving changes. The first occurrence is start itself.
+func (rule *Rule) Between(start, from, to time.Time) []time.Time {
+	occurrences := []time.Time{}
+	count := 0
+	// emit reports whether expansion should go on
+	emit := func(occurrence time.Time) bool {
+		if occurrence.Before(start) {
+			return true
+		}
+		if !occurrence.Before(to) || (rule.Until != nil && occurrence.After(*rule.Until)) {
+			return false
+		}
+		count++
+		if !occurrence.Before(from) {
+			occurrences = append(occurrences, occurrence)
+		}
+		return rule.Count == 0 || count < rule.Count
+	}
+
+	year, month, day := start.Date()
+	hour, minute, second := start.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, start.Location())
+	}
+
+	switch rule.Freq {
+	case FreqDaily:
+		for n := 0; ; n += rule.Interval {
+			occurrence := at(year, month, day+n)
+			if len(rule.ByDay) > 0 && !containsWeekday(rule.ByDay, occurrence.Weekday()) {
+				if !occurrence.Before(to) {
+					return occurrences
+				}
+				continue
+			}
+			if !emit(occurrence) {
+				return occurrences
+			}
+		}
+
+	case FreqWeekly:
+		byDay := rule.ByDay
+		if len(byDay) == 0 {
+			byDay = []time.Weekday{start.Weekday()}
+		}
+		// Days of the week counted from the week start, in the order they occur
+		offsets := make([]int, len(byDay))
+		for i, weekday := range byDay {
+			offsets[i] = (int(weekday) - int(rule.WeekStart) + 7) % 7
+		}
+		sort.Ints(offsets)
+		weekStart := day - (int(start.Weekday())-int(rule.WeekStart)+7)%7
+
+		for n := 0; ; n += rule.Interval {
+			for _, offset := range offsets {
+				occurrence := at(year, month, weekStart+7*n+offset)
+				if !emit(occurrence) {
+					return occurrences
+				}
+			}
+		}
+
+	case FreqMonthly:
+		monthDays := rule.ByMonthDay
+		if len(monthDays) == 0 {
+			monthDays = []int{day}
+		}
+
+		for n := 0; ; n += rule.Interval {
+			first := at(year, month+time.Month(n), 1)
+			if !first.Before(to) {
+				return occurrences
+			}
+			length := time.Date(first.Year(), first.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+			days := []int{}
+			for _, monthDay := range monthDays {
+				if monthDay < 0 {
+					monthDay = length + 1 + monthDay
+				}
+				// Months without the day are skipped, as RFC 5545 requires
+				if monthDay >= 1 && monthDay <= length {
+					days = append(days, monthDay)
+				}
+			}
+			sort.Ints(days)
+
+			for i, monthDay := range days {
+				if i > 0 && days[i-1] == monthDay { // e.g. 31 and -1 in a 31 day month
+					continue
+				}
+				if !emit(at(first.Year(), first.Month(), monthDay)) {
+					return occurrences
+				}
+			}
+		}
+	}
+
+	return occurrences
+}
+
+func containsWeekday(days []time.Weekday, weekday time.Weekday) bool {
+	for _, day := range days {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		want    string
+		wantErr bool
+	}{
+		{name: "weekly days", rule: "RRULE:freq=weekly;byday=mo,we,fr", want: "FREQ=WEEKLY;BYDAY=MO,WE,FR"},
+		{name: "every other day", rule: "FREQ=DAILY;INTERVAL=2;COUNT=10", want: "FREQ=DAILY;INTERVAL=2;COUNT=10"},
+		{name: "until date", rule: "FREQ=MONTHLY;BYMONTHDAY=1,-1;UNTIL=20261231",
+			want: "FREQ=MONTHLY;UNTIL=20261231T235959Z;BYMONTHDAY=1,-1"},
+		{name: "week start", rule: "FREQ=WEEKLY;INTERVAL=2;WKST=SU", want: "FREQ=WEEKLY;INTERVAL=2;WKST=SU"},
+		{name: "missing freq", rule: "INTERVAL=2", wantErr: true},
+		{name: "yearly", rule: "FREQ=YEARLY", wantErr: true},
+		{name: "count and until", rule: "FREQ=DAILY;COUNT=2;UNTIL=20260101", wantErr: true},
+		{name: "ordinal weekday", rule: "FREQ=WEEKLY;BYDAY=1MO", wantErr: true},
+		{name: "month day on weekly", rule: "FREQ=WEEKLY;BYMONTHDAY=3", wantErr: true},
+		{name: "zero interval", rule: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{name: "unsupported part", rule: "FREQ=DAILY;BYHOUR=7", wantErr: true},
+		{name: "malformed", rule: "FREQ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRule(tt.rule)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidRule)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rule.String())
+		})
+	}
+}
+
+func TestRuleBetween(t *testing.T) {
+	lagos, err := time.LoadLocation("Africa/Lagos")
+	require.NoError(t, err)
+	start := time.Date(2026, 10, 5, 7, 0, 0, 0, lagos) // a Monday
+
+	days := func(occurrences []time.Time) []string {
+		formatted := []string{}
+		for _, occurrence := range occurrences {
+			formatted = append(formatted, occurrence.Format("2006-01-02 Mon 15:04"))
+		}
+		return formatted
+	}
+
+	tests := []struct {
+		name string
+		rule string
+		from time.Time
+		to   time.Time
+		want []string
+	}{
+		{
+			name: "weekly on three days",
+			rule: "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			from: start, to: start.AddDate(0, 0, 14),
+			want: []string{"2026-10-05 Mon 07:00", "2026-10-07 Wed 07:00", "2026-10-09 Fri 07:00",
+				"2026-10-12 Mon 07:00", "2026-10-14 Wed 07:00", "2026-10-16 Fri 07:00"},
+		},
+		{
+			name: "days before the start are skipped",
+			rule: "FREQ=WEEKLY;BYDAY=SU,TU",
+			from: start, to: start.AddDate(0, 0, 8),
+			want: []string{"2026-10-06 Tue 07:00", "2026-10-11 Sun 07:00"},
+		},
+		{
+			name: "every other week",
+			rule: "FREQ=WEEKLY;INTERVAL=2",
+			from: start, to: start.AddDate(0, 0, 35),
+			want: []string{"2026-10-05 Mon 07:00", "2026-10-19 Mon 07:00", "2026-11-02 Mon 07:00"},
+		},
+		{
+			name: "count includes occurrences before the range",
+			rule: "FREQ=DAILY;COUNT=4",
+			from: start.AddDate(0, 0, 2), to: start.AddDate(0, 1, 0),
+			want: []string{"2026-10-07 Wed 07:00", "2026-10-08 Thu 07:00"},
+		},
+		{
+			name: "daily on weekdays until",
+			rule: "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;UNTIL=20261012T060000Z",
+			from: start, to: start.AddDate(0, 1, 0),
+			want: []string{"2026-10-05 Mon 07:00", "2026-10-06 Tue 07:00", "2026-10-07 Wed 07:00",
+				"2026-10-08 Thu 07:00", "2026-10-09 Fri 07:00", "2026-10-12 Mon 07:00"},
+		},
+		{
+			name: "monthly on the last day",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			from: start, to: time.Date(2027, 3, 1, 0, 0, 0, 0, lagos),
+			want: []string{"2026-10-31 Sat 07:00", "2026-11-30 Mon 07:00", "2026-12-31 Thu 07:00",
+				"2027-01-31 Sun 07:00", "2027-02-28 Sun 07:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRule(tt.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, days(rule.Between(start, tt.from, tt.to)))
+		})
+	}
+}
+
+func TestRuleKeepsWallClockAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	start := time.Date(2026, 10, 26, 6, 30, 0, 0, newYork)
+
+	rule, err := ParseRule("FREQ=WEEKLY")
+	require.NoError(t, err)
+	occurrences := rule.Between(start, start, start.AddDate(0, 0, 14))
+	require.Len(t, occurrences, 2)
+	assert.Equal(t, 6, occurrences[1].Hour(), "still 06:30 after clocks go back")
+	assert.Equal(t, 30, occurrences[1].Minute())
+	assert.Equal(t, 7*24*time.Hour+time.Hour, occurrences[1].Sub(occurrences[0]))
+}
+
+func TestMonthlySkipsShortMonths(t *testing.T) {
+	start := time.Date(2027, 1, 31, 18, 0, 0, 0, time.UTC)
+	rule, err := ParseRule("FREQ=MONTHLY;COUNT=3")
+	require.NoError(t, err)
+
+	occurrences := rule.Between(start, start, start.AddDate(1, 0, 0))
+	require.Len(t, occurrences, 3)
+	assert.Equal(t, time.March, occurrences[1].Month(), "February has no 31st")
+	assert.Equal(t, time.May, occurrences[2].Month())
+}
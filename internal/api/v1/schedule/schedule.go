@@ -0,0 +1,199 @@
+// Package schedule plans future workouts: one-off or recurring sessions that are
+// marked done by linking the workout that was logged for them.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A planned session, recurring when it has a rule. StartsAt is the first occurrence,
+// later ones keep its wall clock time in Timezone.
+type Session struct {
+	ID              int          `json:"id"`
+	UserID          int          `json:"user_id"`
+	Title           string       `json:"title"`
+	Notes           string       `json:"notes"`
+	TemplateID      *int         `json:"template_id"`
+	StartsAt        time.Time    `json:"starts_at"`
+	Timezone        string       `json:"timezone"`
+	DurationMinutes int          `json:"duration_minutes"`
+	RRule           string       `json:"rrule,omitempty"`
+	Completions     []Completion `json:"completions"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+// Links the workout logged for one occurrence of a session
+type Completion struct {
+	SessionID    int       `json:"session_id"`
+	OccurrenceAt time.Time `json:"occurrence_at"`
+	WorkoutID    int       `json:"workout_id"`
+	CompletedAt  time.Time `json:"completed_at"`
+}
+
+const (
+	OccurrencePlanned = "planned" // still ahead
+	OccurrenceDone    = "done"
+	OccurrenceMissed  = "missed" // passed without a linked workout
+)
+
+// One occurrence of a session
+type Occurrence struct {
+	SessionID  int       `json:"session_id"`
+	Title      string    `json:"title"`
+	Notes      string    `json:"notes"`
+	TemplateID *int      `json:"template_id"`
+	StartsAt   time.Time `json:"starts_at"`
+	EndsAt     time.Time `json:"ends_at"`
+	Status     string    `json:"status"`
+	WorkoutID  *int      `json:"workout_id"`
+}
+
+const (
+	defaultDurationMinutes = 60
+	// Longest range a schedule or adherence request can cover
+	MaxRange = 366 * 24 * time.Hour
+)
+
+var (
+	ErrNotAnOccurrence   = errors.New("the session doesn't occur at occurrence_at")
+	ErrAlreadyCompleted  = errors.New("occurrence is already completed or the workout is linked to another session")
+	ErrUnknownWorkout    = errors.New("workout not found")
+	ErrUnknownTemplate   = errors.New("template not found")
+	ErrInvalidRange      = errors.New("from must be before to")
+	ErrRangeTooLong      = errors.New("schedules are limited to a 366 day range")
+	ErrOccurrenceMissing = errors.New("occurrence_at is required for recurring sessions")
+)
+
+// Checks a new session and fills in defaults before it reaches the db. The rule is
+// stored in its canonical form.
+func (s *Session) Validate() error {
+	s.Title = strings.TrimSpace(s.Title)
+	if s.Title == "" {
+		return errors.New("title is required")
+	}
+	if s.StartsAt.IsZero() {
+		return errors.New("starts_at is required")
+	}
+	if s.DurationMinutes < 0 {
+		return errors.New("duration_minutes can't be negative")
+	}
+	if s.DurationMinutes == 0 {
+		s.DurationMinutes = defaultDurationMinutes
+	}
+
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+	s.StartsAt = s.StartsAt.In(location)
+
+	if s.RRule != "" {
+		rule, err := ParseRule(s.RRule)
+		if err != nil {
+			return err
+		}
+		s.RRule = rule.String()
+	}
+
+	return nil
+}
+
+// Occurrences of the session in [from, to), in its timezone
+func (s *Session) Occurrences(from, to time.Time) []time.Time {
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	start := s.StartsAt.In(location)
+
+	if s.RRule == "" {
+		if start.Before(from) || !start.Before(to) {
+			return []time.Time{}
+		}
+		return []time.Time{start}
+	}
+
+	rule, err := ParseRule(s.RRule)
+	if err != nil {
+		return []time.Time{} // Rules are validated before they're stored
+	}
+	return rule.Between(start, from, to)
+}
+
+// Reports whether the session occurs exactly at t
+func (s *Session) OccursAt(t time.Time) bool {
+	occurrences := s.Occurrences(t, t.Add(time.Second))
+	return len(occurrences) == 1 && occurrences[0].Equal(t)
+}
+
+// Expand lists the occurrences of sessions in [from, to) in time order. Occurrences
+// before now without a linked workout are missed.
+func Expand(sessions []Session, from, to, now time.Time) []Occurrence {
+	occurrences := []Occurrence{}
+	for _, session := range sessions {
+		completed := map[int64]int{}
+		for _, completion := range session.Completions {
+			completed[completion.OccurrenceAt.Unix()] = completion.WorkoutID
+		}
+
+		for _, startsAt := range session.Occurrences(from, to) {
+			occurrence := Occurrence{
+				SessionID:  session.ID,
+				Title:      session.Title,
+				Notes:      session.Notes,
+				TemplateID: session.TemplateID,
+				StartsAt:   startsAt,
+				EndsAt:     startsAt.Add(time.Duration(session.DurationMinutes) * time.Minute),
+				Status:     OccurrencePlanned,
+			}
+			if workoutID, ok := completed[startsAt.Unix()]; ok {
+				occurrence.Status, occurrence.WorkoutID = OccurrenceDone, &workoutID
+			} else if startsAt.Before(now) {
+				occurrence.Status = OccurrenceMissed
+			}
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool { return occurrences[i].StartsAt.Before(occurrences[j].StartsAt) })
+	return occurrences
+}
+
+// Planned against completed sessions over a range
+type Adherence struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Planned   int       `json:"planned"`
+	Completed int       `json:"completed"`
+	Missed    int       `json:"missed"`
+	Upcoming  int       `json:"upcoming"`
+	Rate      *float64  `json:"rate"` // completed over the sessions that are due, nil before any is
+}
+
+func ComputeAdherence(occurrences []Occurrence, from, to time.Time) Adherence {
+	adherence := Adherence{From: from, To: to, Planned: len(occurrences)}
+	for _, occurrence := range occurrences {
+		switch occurrence.Status {
+		case OccurrenceDone:
+			adherence.Completed++
+		case OccurrenceMissed:
+			adherence.Missed++
+		default:
+			adherence.Upcoming++
+		}
+	}
+
+	if due := adherence.Completed + adherence.Missed; due > 0 {
+		rate := math.Round(float64(adherence.Completed)/float64(due)*1000) / 1000
+		adherence.Rate = &rate
+	}
+	return adherence
+}
@@ -0,0 +1,61 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionValidate(t *testing.T) {
+	session := &Session{Title: " Legs ", StartsAt: time.Date(2026, 10, 5, 6, 0, 0, 0, time.UTC),
+		Timezone: "Africa/Lagos", RRule: "freq=weekly;byday=mo"}
+	require.NoError(t, session.Validate())
+	assert.Equal(t, "Legs", session.Title)
+	assert.Equal(t, defaultDurationMinutes, session.DurationMinutes)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO", session.RRule)
+	assert.Equal(t, 7, session.StartsAt.Hour(), "starts_at is read in the session timezone")
+
+	assert.Error(t, (&Session{StartsAt: time.Now()}).Validate(), "title is required")
+	assert.Error(t, (&Session{Title: "x"}).Validate(), "starts_at is required")
+	assert.Error(t, (&Session{Title: "x", StartsAt: time.Now(), Timezone: "Mars/Olympus"}).Validate())
+	assert.ErrorIs(t, (&Session{Title: "x", StartsAt: time.Now(), RRule: "FREQ=HOURLY"}).Validate(), ErrInvalidRule)
+}
+
+func TestExpandAndAdherence(t *testing.T) {
+	monday := time.Date(2026, 10, 5, 7, 0, 0, 0, time.UTC)
+	workoutID := 42
+	sessions := []Session{
+		{ID: 1, Title: "Strength", StartsAt: monday, Timezone: "UTC", DurationMinutes: 60,
+			RRule:       "FREQ=WEEKLY;BYDAY=MO,TH",
+			Completions: []Completion{{SessionID: 1, OccurrenceAt: monday, WorkoutID: workoutID}}},
+		{ID: 2, Title: "Long run", StartsAt: monday.AddDate(0, 0, 5), Timezone: "UTC", DurationMinutes: 90},
+	}
+	now := monday.AddDate(0, 0, 6) // Sunday
+
+	occurrences := Expand(sessions, monday, monday.AddDate(0, 0, 7), now)
+	require.Len(t, occurrences, 3)
+
+	assert.Equal(t, OccurrenceDone, occurrences[0].Status)
+	assert.Equal(t, &workoutID, occurrences[0].WorkoutID)
+	assert.Equal(t, OccurrenceMissed, occurrences[1].Status, "Thursday passed without a workout")
+	assert.Equal(t, "Long run", occurrences[2].Title, "occurrences are in time order")
+	assert.Equal(t, 90*time.Minute, occurrences[2].EndsAt.Sub(occurrences[2].StartsAt))
+
+	adherence := ComputeAdherence(occurrences, monday, now)
+	assert.Equal(t, 3, adherence.Planned)
+	assert.Equal(t, 1, adherence.Completed)
+	assert.Equal(t, 2, adherence.Missed)
+	require.NotNil(t, adherence.Rate)
+	assert.Equal(t, 0.333, *adherence.Rate)
+
+	upcoming := ComputeAdherence(Expand(sessions, now, now.AddDate(0, 0, 3), now), now, now.AddDate(0, 0, 3))
+	assert.Equal(t, 1, upcoming.Upcoming, "next Monday")
+	assert.Nil(t, upcoming.Rate, "no rate before anything is due")
+
+	assert.True(t, sessions[0].OccursAt(monday.AddDate(0, 0, 3)))
+	assert.False(t, sessions[0].OccursAt(monday.AddDate(0, 0, 2)))
+	assert.False(t, sessions[0].OccursAt(monday.Add(time.Hour)))
+	assert.True(t, sessions[1].OccursAt(sessions[1].StartsAt))
+}
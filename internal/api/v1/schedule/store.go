@@ -0,0 +1,215 @@
+package schedule
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// DB connector struct
+type PostgresScheduleStore struct {
+	db *sql.DB
+}
+
+func NewPostgresScheduleStore(db *sql.DB) *PostgresScheduleStore {
+	return &PostgresScheduleStore{db: db}
+}
+
+type ScheduleStore interface {
+	CreateSession(session *Session) (*Session, error)
+	GetSessionByID(id int64) (*Session, error)
+	ListSessions(userID int64) ([]Session, error)
+	DeleteSession(id int64) error
+	CompleteOccurrence(completion *Completion) error
+	ListOccurrences(userID int64, from, to time.Time) ([]Occurrence, error)
+}
+
+const sessionColumns = `id, user_id, title, notes, template_id, starts_at, timezone, duration_minutes,
+	COALESCE(rrule, ''), created_at`
+
+func scanSession(row interface{ Scan(...any) error }) (*Session, error) {
+	session := &Session{}
+	err := row.Scan(&session.ID, &session.UserID, &session.Title, &session.Notes, &session.TemplateID,
+		&session.StartsAt, &session.Timezone, &session.DurationMinutes, &session.RRule, &session.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (pgStore *PostgresScheduleStore) CreateSession(session *Session) (*Session, error) {
+	query := `
+	INSERT INTO planned_sessions (user_id, title, notes, template_id, starts_at, timezone, duration_minutes, rrule)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))
+	RETURNING id, created_at
+	`
+	err := pgStore.db.QueryRow(query, session.UserID, session.Title, session.Notes, session.TemplateID,
+		session.StartsAt, session.Timezone, session.DurationMinutes, session.RRule).Scan(&session.ID, &session.CreatedAt)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	session.Completions = []Completion{}
+	return session, nil
+}
+
+// Returns nil when the session doesn't exist
+func (pgStore *PostgresScheduleStore) GetSessionByID(id int64) (*Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM planned_sessions WHERE id = $1`
+	session, err := scanSession(pgStore.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil // No session found
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []Session{*session}
+	err = pgStore.loadCompletions(sessions)
+	if err != nil {
+		return nil, err
+	}
+	return &sessions[0], nil
+}
+
+// Lists the sessions of a user by their first occurrence
+func (pgStore *PostgresScheduleStore) ListSessions(userID int64) ([]Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM planned_sessions WHERE user_id = $1 ORDER BY starts_at, id`
+	return pgStore.querySessions(query, userID)
+}
+
+func (pgStore *PostgresScheduleStore) DeleteSession(id int64) error {
+	result, err := pgStore.db.Exec(`DELETE FROM planned_sessions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Links a workout to an occurrence. Callers check the occurrence belongs to the session.
+// An occurrence whose workout went to the trash can be completed again, the new
+// workout takes its place.
+func (pgStore *PostgresScheduleStore) CompleteOccurrence(completion *Completion) error {
+	query := `
+	INSERT INTO planned_session_completions (session_id, occurrence_at, workout_id)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (session_id, occurrence_at) DO UPDATE
+	SET workout_id = EXCLUDED.workout_id, completed_at = CURRENT_TIMESTAMP
+	WHERE EXISTS (
+		SELECT 1 FROM workouts w
+		WHERE w.id = planned_session_completions.workout_id AND w.deleted_at IS NOT NULL
+	)
+	RETURNING completed_at
+	`
+	err := pgStore.db.QueryRow(query, completion.SessionID, completion.OccurrenceAt, completion.WorkoutID).
+		Scan(&completion.CompletedAt)
+	if err == sql.ErrNoRows {
+		return ErrAlreadyCompleted // by a workout that's still live
+	}
+	return translateError(err)
+}
+
+// Expands the sessions of a user into their occurrences in [from, to). Only sessions
+// that started before to can occur in the range.
+func (pgStore *PostgresScheduleStore) ListOccurrences(userID int64, from, to time.Time) ([]Occurrence, error) {
+	query := `SELECT ` + sessionColumns + ` FROM planned_sessions WHERE user_id = $1 AND starts_at < $2 ORDER BY id`
+	sessions, err := pgStore.querySessions(query, userID, to)
+	if err != nil {
+		return nil, err
+	}
+	return Expand(sessions, from, to, time.Now()), nil
+}
+
+func (pgStore *PostgresScheduleStore) querySessions(query string, args ...any) ([]Session, error) {
+	rows, err := pgStore.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = pgStore.loadCompletions(sessions)
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Loads the completions of every session with one query. Completions of workouts in
+// the trash don't count.
+func (pgStore *PostgresScheduleStore) loadCompletions(sessions []Session) error {
+	ids := make([]int64, len(sessions))
+	index := map[int]int{}
+	for i := range sessions {
+		ids[i] = int64(sessions[i].ID)
+		index[sessions[i].ID] = i
+		sessions[i].Completions = []Completion{}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+	SELECT c.session_id, c.occurrence_at, c.workout_id, c.completed_at
+	FROM planned_session_completions c
+	JOIN workouts w ON w.id = c.workout_id
+	WHERE c.session_id = ANY($1::bigint[]) AND w.deleted_at IS NULL
+	ORDER BY c.occurrence_at
+	`
+	rows, err := pgStore.db.Query(query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var completion Completion
+		err := rows.Scan(&completion.SessionID, &completion.OccurrenceAt, &completion.WorkoutID, &completion.CompletedAt)
+		if err != nil {
+			return err
+		}
+		session := &sessions[index[completion.SessionID]]
+		session.Completions = append(session.Completions, completion)
+	}
+
+	return rows.Err()
+}
+
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch {
+	case pgErr.Code == "23505": // unique_violation, on the occurrence or the workout
+		return ErrAlreadyCompleted
+	case pgErr.Code == "23503" && pgErr.ConstraintName == "planned_session_completions_workout_id_fkey": // foreign_key_violation
+		return ErrUnknownWorkout
+	case pgErr.Code == "23503" && pgErr.ConstraintName == "planned_sessions_template_id_fkey":
+		return ErrUnknownTemplate
+	}
+
+	return err
+}
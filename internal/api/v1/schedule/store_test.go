@@ -0,0 +1,83 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/store"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlannedSessions(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresScheduleStore(db)
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+
+	var userID int64
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('lifter', 'lifter@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	start := time.Now().UTC().Truncate(time.Hour).AddDate(0, 0, -7)
+	session := &Session{UserID: int(userID), Title: "Strength", StartsAt: start, RRule: "FREQ=DAILY;INTERVAL=7"}
+	require.NoError(t, session.Validate())
+	_, err := pgStore.CreateSession(session)
+	require.NoError(t, err)
+
+	reps := 5
+	workout, err := workoutStore.CreateWorkout(&workouts.Workout{UserID: int(userID), Title: "Strength day",
+		Entries: []workouts.WorkoutEntry{{ExerciseName: "Squat", Sets: 5, Reps: &reps}}})
+	require.NoError(t, err)
+
+	completion := &Completion{SessionID: session.ID, OccurrenceAt: start, WorkoutID: workout.ID}
+	require.NoError(t, pgStore.CompleteOccurrence(completion))
+	assert.ErrorIs(t, pgStore.CompleteOccurrence(&Completion{SessionID: session.ID,
+		OccurrenceAt: start.AddDate(0, 0, 7), WorkoutID: workout.ID}), ErrAlreadyCompleted,
+		"a workout fulfils one occurrence")
+	assert.ErrorIs(t, pgStore.CompleteOccurrence(&Completion{SessionID: session.ID,
+		OccurrenceAt: start.AddDate(0, 0, 7), WorkoutID: workout.ID + 1000}), ErrUnknownWorkout)
+
+	occurrences, err := pgStore.ListOccurrences(userID, start, start.AddDate(0, 0, 15))
+	require.NoError(t, err)
+	require.Len(t, occurrences, 3)
+	assert.Equal(t, OccurrenceDone, occurrences[0].Status)
+	assert.Equal(t, OccurrencePlanned, occurrences[2].Status)
+
+	fetched, err := pgStore.GetSessionByID(int64(session.ID))
+	require.NoError(t, err)
+	require.Len(t, fetched.Completions, 1)
+
+	require.NoError(t, workoutStore.DeleteWorkout(int64(workout.ID)))
+	fetched, err = pgStore.GetSessionByID(int64(session.ID))
+	require.NoError(t, err)
+	assert.Empty(t, fetched.Completions, "trashed workouts don't complete sessions")
+
+	// The occurrence can then be completed by another workout, but not while that one is live
+	redone, err := workoutStore.CreateWorkout(&workouts.Workout{UserID: int(userID), Title: "Strength day, redone",
+		Entries: []workouts.WorkoutEntry{{ExerciseName: "Squat", Sets: 5, Reps: &reps}}})
+	require.NoError(t, err)
+	require.NoError(t, pgStore.CompleteOccurrence(&Completion{SessionID: session.ID, OccurrenceAt: start,
+		WorkoutID: redone.ID}))
+	another, err := workoutStore.CreateWorkout(&workouts.Workout{UserID: int(userID), Title: "Extra",
+		Entries: []workouts.WorkoutEntry{{ExerciseName: "Squat", Sets: 5, Reps: &reps}}})
+	require.NoError(t, err)
+	assert.ErrorIs(t, pgStore.CompleteOccurrence(&Completion{SessionID: session.ID, OccurrenceAt: start,
+		WorkoutID: another.ID}), ErrAlreadyCompleted)
+	fetched, err = pgStore.GetSessionByID(int64(session.ID))
+	require.NoError(t, err)
+	require.Len(t, fetched.Completions, 1)
+	assert.Equal(t, redone.ID, fetched.Completions[0].WorkoutID)
+
+	sessions, err := pgStore.ListSessions(userID)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+
+	require.NoError(t, pgStore.DeleteSession(int64(session.ID)))
+	missing, err := pgStore.GetSessionByID(int64(session.ID))
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
@@ -0,0 +1,331 @@
+package templates
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/units"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type TemplateHandler struct {
+	store        TemplateStore
+	workoutStore workouts.WorkoutStore
+	userStore    users.UserStore
+	logger       *log.Logger
+}
+
+// Starting a template creates a workout, so the handler needs both stores
+func NewTemplateHandler(store TemplateStore, workoutStore workouts.WorkoutStore, userStore users.UserStore,
+	logger *log.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		store:        store,
+		workoutStore: workoutStore,
+		userStore:    userStore,
+		logger:       logger,
+	}
+}
+
+// Optional overrides when starting a template
+type startTemplateRequest struct {
+	UserID *int    `json:"user_id"`
+	Title  *string `json:"title"`
+}
+
+func (th *TemplateHandler) HandleListTemplates(w http.ResponseWriter, r *http.Request) {
+	var userID int64
+	if param := r.URL.Query().Get("user_id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user id"}) // 400
+			return
+		}
+		userID = id
+	}
+
+	templates, err := th.store.ListTemplates(userID)
+	if err != nil {
+		th.logger.Printf("Error listTemplates: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	for i := range templates {
+		if !th.convertWeights(w, r, &templates[i]) {
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"templates": templates})
+}
+
+func (th *TemplateHandler) HandleGetTemplateByID(w http.ResponseWriter, r *http.Request) {
+	templateID, err := utils.ReadIDParam(r)
+	if err != nil {
+		th.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return
+	}
+
+	template, err := th.store.GetTemplateByID(templateID)
+	if err != nil {
+		th.logger.Printf("Error getTemplateByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if template == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "template not found"}) // 404
+		return
+	}
+
+	if !th.convertWeights(w, r, template) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var template Template
+
+	err := json.NewDecoder(r.Body).Decode(&template)
+	if err != nil {
+		th.logger.Printf("Error decodingCreateTemplate: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	err = template.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	createdTemplate, err := th.store.CreateTemplate(&template)
+	if err != nil {
+		th.logger.Printf("Error createTemplate: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create template"}) // 500
+		return
+	}
+
+	if !th.convertWeights(w, r, createdTemplate) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"template": createdTemplate})
+}
+
+func (th *TemplateHandler) HandleUpdateTemplateByID(w http.ResponseWriter, r *http.Request) {
+	templateID, err := utils.ReadIDParam(r)
+	if err != nil {
+		th.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return
+	}
+
+	var template Template
+
+	err = json.NewDecoder(r.Body).Decode(&template)
+	if err != nil {
+		th.logger.Printf("Error decodingUpdateTemplate: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	err = template.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	template.ID = int(templateID)
+
+	err = th.store.UpdateTemplate(&template)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "template not found"}) // 404
+		return
+	}
+
+	if err != nil {
+		th.logger.Printf("Error updateTemplate: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update template"}) // 500
+		return
+	}
+
+	if !th.convertWeights(w, r, &template) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleDeleteTemplateByID(w http.ResponseWriter, r *http.Request) {
+	templateID, err := utils.ReadIDParam(r)
+	if err != nil {
+		th.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return
+	}
+
+	err = th.store.DeleteTemplate(templateID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "template not found"}) // 404
+		return
+	}
+
+	if errors.Is(err, ErrTemplateInUse) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+		return
+	}
+
+	if err != nil {
+		th.logger.Printf("Error deleteTemplate: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete template"}) // 500
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Creates a new workout prefilled from the template and linked back to it
+func (th *TemplateHandler) HandleStartTemplate(w http.ResponseWriter, r *http.Request) {
+	templateID, err := utils.ReadIDParam(r)
+	if err != nil {
+		th.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return
+	}
+
+	// The body is optional, an empty one starts the template as is
+	var req startTemplateRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		th.logger.Printf("Error decodingStartTemplate: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	template, err := th.store.GetTemplateByID(templateID)
+	if err != nil {
+		th.logger.Printf("Error getTemplateByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if template == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "template not found"}) // 404
+		return
+	}
+
+	workout := template.ToWorkout()
+	if req.UserID != nil {
+		workout.UserID = *req.UserID
+	}
+	if req.Title != nil {
+		workout.Title = *req.Title
+	}
+
+	// The overrides are checked like any other new workout
+	err = workout.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	createdWorkout, err := th.workoutStore.CreateWorkout(workout)
+	if writeStartConflict(w, err) {
+		return
+	}
+
+	if err != nil {
+		th.logger.Printf("Error createWorkout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to start template"}) // 500
+		return
+	}
+
+	unit, ok := th.outputUnit(w, r, createdWorkout.UserID)
+	if !ok {
+		return
+	}
+	createdWorkout.ConvertWeights(unit)
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": createdWorkout})
+}
+
+// Lists the workouts started from the template so progress can be compared session to session
+func (th *TemplateHandler) HandleListTemplateWorkouts(w http.ResponseWriter, r *http.Request) {
+	templateID, err := utils.ReadIDParam(r)
+	if err != nil {
+		th.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return
+	}
+
+	template, err := th.store.GetTemplateByID(templateID)
+	if err != nil {
+		th.logger.Printf("Error getTemplateByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if template == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "template not found"}) // 404
+		return
+	}
+
+	history, err := th.workoutStore.ListWorkoutsByTemplate(templateID)
+	if err != nil {
+		th.logger.Printf("Error listWorkoutsByTemplate: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	// The history is compared against the targets, so both are in the template owner's unit
+	unit, ok := th.outputUnit(w, r, template.UserID)
+	if !ok {
+		return
+	}
+	template.ConvertWeights(unit)
+	for i := range history {
+		history[i].ConvertWeights(unit)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"template": template, "workouts": history})
+}
+
+// Weights are returned in ?units= when given, otherwise in the owner's preferred unit
+func (th *TemplateHandler) outputUnit(w http.ResponseWriter, r *http.Request, userID int) (units.WeightUnit, bool) {
+	return users.ReadWeightUnit(w, r, th.userStore, int64(userID), th.logger)
+}
+
+func (th *TemplateHandler) convertWeights(w http.ResponseWriter, r *http.Request, template *Template) bool {
+	unit, ok := th.outputUnit(w, r, template.UserID)
+	if ok {
+		template.ConvertWeights(unit)
+	}
+	return ok
+}
+
+// Maps the errors of saving a started workout the way the workout handlers do
+func writeStartConflict(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, workouts.ErrDuplicateSlug):
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+	case errors.Is(err, workouts.ErrUnknownUser), errors.Is(err, workouts.ErrUnknownExercise),
+		errors.Is(err, workouts.ErrInvalidSet), errors.Is(err, workouts.ErrInvalidGroup),
+		errors.Is(err, workouts.ErrInvalidCardio), errors.Is(err, workouts.ErrInvalidWeightUnit),
+		errors.Is(err, workouts.ErrInvalidSlug), errors.Is(err, workouts.ErrInvalidTitle):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+	default:
+		return false
+	}
+	return true
+}
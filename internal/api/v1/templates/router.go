@@ -0,0 +1,28 @@
+package templates
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/go-chi/chi/v5"
+)
+
+func TemplateRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and stores
+	r := chi.NewRouter()
+	store := NewPostgresTemplateStore(app.DB)
+	workoutStore := workouts.NewPostgresWorkoutStore(app.DB)
+	handler := NewTemplateHandler(store, workoutStore, users.NewPostgresUserStore(app.DB), app.Logger)
+
+	// Define subroutes
+	r.Get("/", handler.HandleListTemplates)
+	r.Post("/", handler.HandleCreateTemplate)
+	r.Get("/{id}", handler.HandleGetTemplateByID)
+	r.Put("/{id}", handler.HandleUpdateTemplateByID)
+	r.Delete("/{id}", handler.HandleDeleteTemplateByID)
+	r.Post("/{id}/start", handler.HandleStartTemplate)
+	r.Get("/{id}/workouts", handler.HandleListTemplateWorkouts)
+
+	return r
+}
@@ -0,0 +1,266 @@
+package templates
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Josesx506/gofems/internal/units"
+	"github.com/jackc/pgconn"
+)
+
+var ErrTemplateInUse = errors.New("template is used by a program, delete the program first")
+
+// DB connector struct
+type PostgresTemplateStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTemplateStore(db *sql.DB) *PostgresTemplateStore {
+	return &PostgresTemplateStore{db: db}
+}
+
+type TemplateStore interface {
+	CreateTemplate(*Template) (*Template, error)
+	GetTemplateByID(id int64) (*Template, error)
+	ListTemplates(userID int64) ([]Template, error)
+	UpdateTemplate(*Template) error
+	DeleteTemplate(id int64) error
+}
+
+func (pgStore *PostgresTemplateStore) CreateTemplate(template *Template) (*Template, error) {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // rollback transaction if not committed
+
+	query := `
+	INSERT INTO workout_templates (user_id, name, description)
+	VALUES ($1, $2, $3)
+	RETURNING id
+	`
+	err = tx.QueryRow(query, nullableID(template.UserID), template.Name, template.Description).Scan(&template.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertEntries(tx, template)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+func (pgStore *PostgresTemplateStore) GetTemplateByID(id int64) (*Template, error) {
+	template := &Template{}
+	var userID sql.NullInt64
+
+	query := `
+	SELECT id, user_id, name, description
+	FROM workout_templates
+	WHERE id = $1
+	`
+	err := pgStore.db.QueryRow(query, id).Scan(&template.ID, &userID, &template.Name, &template.Description)
+	if err == sql.ErrNoRows {
+		return nil, nil // No template found
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	template.UserID = int(userID.Int64)
+
+	err = pgStore.loadEntries(template)
+	if err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// Lists the templates owned by a user, or every template when userID is 0
+func (pgStore *PostgresTemplateStore) ListTemplates(userID int64) ([]Template, error) {
+	query := `
+	SELECT id, user_id, name, description
+	FROM workout_templates
+	WHERE $1 = 0 OR user_id = $1
+	ORDER BY name ASC, id ASC
+	`
+	rows, err := pgStore.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []Template{}
+	for rows.Next() {
+		var template Template
+		var ownerID sql.NullInt64
+		err := rows.Scan(&template.ID, &ownerID, &template.Name, &template.Description)
+		if err != nil {
+			return nil, err
+		}
+		template.UserID = int(ownerID.Int64)
+		templates = append(templates, template)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range templates {
+		err := pgStore.loadEntries(&templates[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return templates, nil
+}
+
+func (pgStore *PostgresTemplateStore) UpdateTemplate(template *Template) error {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE workout_templates
+	SET name = $1, description = $2, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $3
+	RETURNING user_id
+	`
+	var userID sql.NullInt64
+	err = tx.QueryRow(query, template.Name, template.Description, template.ID).Scan(&userID)
+	if err != nil {
+		return err // sql.ErrNoRows if the template doesn't exist
+	}
+	template.UserID = int(userID.Int64)
+
+	// Replace the entries just like workout updates do
+	_, err = tx.Exec(`DELETE FROM template_entries WHERE template_id = $1`, template.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertEntries(tx, template)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Workouts started from the template are kept, their template_id is cleared
+// Returns ErrTemplateInUse while a program day references the template
+func (pgStore *PostgresTemplateStore) DeleteTemplate(id int64) error {
+	result, err := pgStore.db.Exec(`DELETE FROM workout_templates WHERE id = $1`, id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Target weights are stored in kilograms, weight_unit keeps the unit they were entered in
+func insertEntries(tx *sql.Tx, template *Template) error {
+	defaultUnit, err := preferredWeightUnit(tx, template.UserID)
+	if err != nil {
+		return err
+	}
+
+	for i := range template.Entries {
+		entry := &template.Entries[i]
+		enteredUnit := entry.normalizeWeights(defaultUnit)
+
+		query := `
+		INSERT INTO template_entries (template_id, exercise_name, target_sets, target_reps_min, target_reps_max,
+			target_duration_seconds, target_weight_min, target_weight_max, weight_unit, rest_seconds, notes, order_index)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+		`
+		err := tx.QueryRow(query, template.ID, entry.ExerciseName, entry.TargetSets, entry.TargetRepsMin,
+			entry.TargetRepsMax, entry.TargetDurationSeconds, entry.TargetWeightMin, entry.TargetWeightMax,
+			enteredUnit, entry.RestSeconds, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (pgStore *PostgresTemplateStore) loadEntries(template *Template) error {
+	query := `
+	SELECT id, exercise_name, target_sets, target_reps_min, target_reps_max, target_duration_seconds,
+		target_weight_min, target_weight_max, rest_seconds, notes, order_index
+	FROM template_entries
+	WHERE template_id = $1
+	ORDER BY order_index ASC
+	`
+	rows, err := pgStore.db.Query(query, template.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	template.Entries = []TemplateEntry{}
+	for rows.Next() {
+		entry := TemplateEntry{WeightUnit: units.Kilograms}
+		err := rows.Scan(&entry.ID, &entry.ExerciseName, &entry.TargetSets, &entry.TargetRepsMin,
+			&entry.TargetRepsMax, &entry.TargetDurationSeconds, &entry.TargetWeightMin, &entry.TargetWeightMax,
+			&entry.RestSeconds, &entry.Notes, &entry.OrderIndex)
+		if err != nil {
+			return err
+		}
+		template.Entries = append(template.Entries, entry)
+	}
+
+	return rows.Err()
+}
+
+// Targets without a weight_unit are taken to be in the owner's preferred unit
+func preferredWeightUnit(tx *sql.Tx, userID int) (units.WeightUnit, error) {
+	if userID == 0 {
+		return units.Kilograms, nil
+	}
+
+	var unit units.WeightUnit
+	err := tx.QueryRow(`SELECT preferred_weight_unit FROM users WHERE id = $1`, userID).Scan(&unit)
+	if err == sql.ErrNoRows {
+		return units.Kilograms, nil // The user foreign key reports the missing user
+	}
+
+	return unit, err
+}
+
+// Templates without an owner are stored with a NULL user_id
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// Maps the program days restricting a delete onto an error the handler reports as a conflict
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" && // foreign_key_violation
+		pgErr.ConstraintName == "program_days_template_id_fkey" {
+		return ErrTemplateInUse
+	}
+	return err
+}
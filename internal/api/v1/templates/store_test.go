@@ -0,0 +1,167 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/store"
+	"github.com/Josesx506/gofems/internal/units"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartTemplate(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresTemplateStore(db)
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+
+	template, err := pgStore.CreateTemplate(&Template{
+		Name:        "Leg Day",
+		Description: "Weekly lower body session",
+		Entries: []TemplateEntry{
+			{ExerciseName: "Squats", TargetSets: 4, TargetRepsMin: intPtr(8), TargetRepsMax: intPtr(12),
+				TargetWeightMin: floatPtr(90), TargetWeightMax: floatPtr(100), OrderIndex: 1},
+			{ExerciseName: "Plank", TargetSets: 3, TargetDurationSeconds: intPtr(60), OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+
+	retrieved, err := pgStore.GetTemplateByID(int64(template.ID))
+	require.NoError(t, err)
+	require.Len(t, retrieved.Entries, 2)
+
+	// Start the template twice, as a user repeating the session weekly would
+	for range 2 {
+		_, err := workoutStore.CreateWorkout(retrieved.ToWorkout())
+		require.NoError(t, err)
+	}
+
+	history, err := workoutStore.ListWorkoutsByTemplate(int64(template.ID))
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	for _, workout := range history {
+		assert.Equal(t, "Leg Day", workout.Title)
+		assert.Equal(t, template.ID, *workout.TemplateID)
+		require.Len(t, workout.Entries, 2)
+		assert.Equal(t, intPtr(8), workout.Entries[0].Reps)
+		assert.Equal(t, floatPtr(90), workout.Entries[0].Weight)
+		assert.Equal(t, intPtr(60), workout.Entries[1].DurationSeconds)
+	}
+
+	// Programs built on the template hold up its deletion
+	var programID int64
+	require.NoError(t, db.QueryRow(`INSERT INTO programs (name) VALUES ('Block') RETURNING id`).Scan(&programID))
+	_, err = db.Exec(`INSERT INTO program_days (program_id, week_number, day_number, template_id)
+		VALUES ($1, 1, 1, $2)`, programID, template.ID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, pgStore.DeleteTemplate(int64(template.ID)), ErrTemplateInUse)
+	_, err = db.Exec(`DELETE FROM programs WHERE id = $1`, programID)
+	require.NoError(t, err)
+
+	// Deleting the template keeps the logged workouts
+	require.NoError(t, pgStore.DeleteTemplate(int64(template.ID)))
+	workout, err := workoutStore.GetWorkoutByID(int64(history[0].ID))
+	require.NoError(t, err)
+	assert.Nil(t, workout.TemplateID)
+}
+
+func TestTemplateWeightUnits(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresTemplateStore(db)
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+
+	var userID int
+	err := db.QueryRow(`INSERT INTO users (username, email, password_hash, preferred_weight_unit)
+		VALUES ('lifter', 'lifter@example.com', 'hash', 'lb') RETURNING id`).Scan(&userID)
+	require.NoError(t, err)
+
+	// Targets without a unit are in the owner's preferred unit, explicit ones keep theirs
+	template, err := pgStore.CreateTemplate(&Template{
+		UserID: userID,
+		Name:   "Press Day",
+		Entries: []TemplateEntry{
+			{ExerciseName: "Bench Press", TargetSets: 3, TargetRepsMin: intPtr(5),
+				TargetWeightMin: floatPtr(135), TargetWeightMax: floatPtr(145), OrderIndex: 1},
+			{ExerciseName: "Overhead Press", TargetSets: 3, TargetRepsMin: intPtr(5),
+				TargetWeightMin: floatPtr(40), WeightUnit: "kg", OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+
+	retrieved, err := pgStore.GetTemplateByID(int64(template.ID))
+	require.NoError(t, err)
+	assert.Equal(t, floatPtr(61.235), retrieved.Entries[0].TargetWeightMin)
+	assert.Equal(t, floatPtr(65.771), retrieved.Entries[0].TargetWeightMax)
+	assert.Equal(t, floatPtr(40), retrieved.Entries[1].TargetWeightMin)
+
+	// The started workout logs the same weights as the targets
+	workout, err := workoutStore.CreateWorkout(retrieved.ToWorkout())
+	require.NoError(t, err)
+	assert.Equal(t, floatPtr(61.235), workout.Entries[0].Weight)
+
+	retrieved.ConvertWeights(units.Pounds)
+	assert.InDelta(t, 135, *retrieved.Entries[0].TargetWeightMin, 0.01)
+	assert.Equal(t, units.Pounds, retrieved.Entries[1].WeightUnit)
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   TemplateEntry
+		wantErr bool
+	}{
+		{
+			name:  "Rep range",
+			entry: TemplateEntry{ExerciseName: "Squats", TargetSets: 3, TargetRepsMin: intPtr(8), TargetRepsMax: intPtr(12), OrderIndex: 1},
+		},
+		{
+			name:  "Timed hold",
+			entry: TemplateEntry{ExerciseName: "Plank", TargetSets: 3, TargetDurationSeconds: intPtr(60), OrderIndex: 1},
+		},
+		{
+			name:    "Both reps and duration",
+			entry:   TemplateEntry{ExerciseName: "Plank", TargetSets: 3, TargetRepsMin: intPtr(8), TargetDurationSeconds: intPtr(60), OrderIndex: 1},
+			wantErr: true,
+		},
+		{
+			name:    "Inverted rep range",
+			entry:   TemplateEntry{ExerciseName: "Squats", TargetSets: 3, TargetRepsMin: intPtr(12), TargetRepsMax: intPtr(8), OrderIndex: 1},
+			wantErr: true,
+		},
+		{
+			name:    "Missing sets",
+			entry:   TemplateEntry{ExerciseName: "Squats", TargetRepsMin: intPtr(8), OrderIndex: 1},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown weight unit",
+			entry:   TemplateEntry{ExerciseName: "Squats", TargetSets: 3, TargetRepsMin: intPtr(8), WeightUnit: "st", OrderIndex: 1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &Template{Name: "Routine", Entries: []TemplateEntry{tt.entry}}
+			err := template.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,127 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/units"
+)
+
+// A reusable routine that can be started as a new logged workout
+type Template struct {
+	ID          int             `json:"id"`
+	UserID      int             `json:"user_id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Entries     []TemplateEntry `json:"entries"`
+}
+
+// Targets are ranges, e.g. 3 sets of 8-12 reps at 60-70kg
+type TemplateEntry struct {
+	ID                    int              `json:"id"`
+	ExerciseName          string           `json:"exercise_name"`
+	TargetSets            int              `json:"target_sets"`
+	TargetRepsMin         *int             `json:"target_reps_min"`
+	TargetRepsMax         *int             `json:"target_reps_max"`
+	TargetDurationSeconds *int             `json:"target_duration_seconds"`
+	TargetWeightMin       *float64         `json:"target_weight_min"`
+	TargetWeightMax       *float64         `json:"target_weight_max"`
+	WeightUnit            units.WeightUnit `json:"weight_unit"` // Unit of the target weights, the owner's preference when omitted
+	RestSeconds           *int             `json:"rest_seconds"`
+	Notes                 string           `json:"notes"`
+	OrderIndex            int              `json:"order_index"`
+}
+
+// Checks the template before it reaches the db so clients get a readable error
+func (t *Template) Validate() error {
+	if t.Name == "" {
+		return errors.New("template name is required")
+	}
+
+	for _, entry := range t.Entries {
+		if entry.ExerciseName == "" {
+			return fmt.Errorf("entry %d: exercise_name is required", entry.OrderIndex)
+		}
+		if entry.TargetSets <= 0 {
+			return fmt.Errorf("entry %d: target_sets must be positive", entry.OrderIndex)
+		}
+		if (entry.TargetRepsMin == nil) == (entry.TargetDurationSeconds == nil) {
+			return fmt.Errorf("entry %d: exactly one of target_reps_min or target_duration_seconds is required", entry.OrderIndex)
+		}
+		if entry.TargetRepsMin == nil && entry.TargetRepsMax != nil {
+			return fmt.Errorf("entry %d: target_reps_max requires target_reps_min", entry.OrderIndex)
+		}
+		if entry.TargetRepsMin != nil && entry.TargetRepsMax != nil && *entry.TargetRepsMax < *entry.TargetRepsMin {
+			return fmt.Errorf("entry %d: target_reps_max is below target_reps_min", entry.OrderIndex)
+		}
+		if entry.TargetWeightMin != nil && entry.TargetWeightMax != nil && *entry.TargetWeightMax < *entry.TargetWeightMin {
+			return fmt.Errorf("entry %d: target_weight_max is below target_weight_min", entry.OrderIndex)
+		}
+		if entry.WeightUnit != "" {
+			if _, err := units.ParseWeightUnit(string(entry.WeightUnit)); err != nil {
+				return fmt.Errorf("entry %d: %v", entry.OrderIndex, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ToWorkout builds a new workout prefilled from the template. Ranges are
+// filled with their lower bound so the logged values start at the minimum target.
+func (t *Template) ToWorkout() *workouts.Workout {
+	templateID := t.ID
+	workout := &workouts.Workout{
+		UserID:      t.UserID,
+		Title:       t.Name,
+		Description: t.Description,
+		TemplateID:  &templateID,
+		Entries:     make([]workouts.WorkoutEntry, 0, len(t.Entries)),
+	}
+
+	for _, entry := range t.Entries {
+		workout.Entries = append(workout.Entries, workouts.WorkoutEntry{
+			ExerciseName:    entry.ExerciseName,
+			Sets:            entry.TargetSets,
+			Reps:            entry.TargetRepsMin,
+			DurationSeconds: entry.TargetDurationSeconds,
+			Weight:          entry.TargetWeightMin,
+			WeightUnit:      units.Kilograms,
+			Notes:           entry.Notes,
+			OrderIndex:      entry.OrderIndex,
+		})
+	}
+
+	return workout
+}
+
+// normalizeWeights converts the target weights of an entry to kilograms for storage
+// and returns the unit they were entered in. Validate has already checked the unit.
+func (entry *TemplateEntry) normalizeWeights(defaultUnit units.WeightUnit) units.WeightUnit {
+	unit := defaultUnit
+	if entry.WeightUnit != "" {
+		unit, _ = units.ParseWeightUnit(string(entry.WeightUnit))
+	}
+
+	toKilograms := func(weight *float64) {
+		if weight != nil {
+			*weight = math.Round(units.ToKilograms(*weight, unit)*1000) / 1000
+		}
+	}
+	toKilograms(entry.TargetWeightMin)
+	toKilograms(entry.TargetWeightMax)
+	entry.WeightUnit = units.Kilograms
+
+	return unit
+}
+
+// ConvertWeights converts a stored template, in kilograms, into unit for output
+func (t *Template) ConvertWeights(unit units.WeightUnit) {
+	for i := range t.Entries {
+		units.ConvertWeight(t.Entries[i].TargetWeightMin, unit)
+		units.ConvertWeight(t.Entries[i].TargetWeightMax, unit)
+		t.Entries[i].WeightUnit = unit
+	}
+}
@@ -0,0 +1,123 @@
+package users
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Josesx506/gofems/internal/units"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type UserHandler struct {
+	store  UserStore
+	logger *log.Logger
+}
+
+func NewUserHandler(store UserStore, logger *log.Logger) *UserHandler {
+	return &UserHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+func (uh *UserHandler) HandleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.ReadIDParam(r)
+	if err != nil {
+		uh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user id"})
+		return
+	}
+
+	preferences, err := uh.store.GetPreferences(userID)
+	if err != nil {
+		uh.logger.Printf("Error getPreferences: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if preferences == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "user not found"}) // 404
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"preferences": preferences})
+}
+
+func (uh *UserHandler) HandleUpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.ReadIDParam(r)
+	if err != nil {
+		uh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user id"})
+		return
+	}
+
+	var preferences Preferences
+	err = json.NewDecoder(r.Body).Decode(&preferences)
+	if err != nil {
+		uh.logger.Printf("Error decodingUpdatePreferences: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	preferences.WeightUnit, err = units.ParseWeightUnit(string(preferences.WeightUnit))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+	preferences.UserID = int(userID)
+
+	err = uh.store.UpdatePreferences(&preferences)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "user not found"}) // 404
+		return
+	}
+
+	if err != nil {
+		uh.logger.Printf("Error updatePreferences: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update preferences"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"preferences": preferences})
+}
+
+// ResolveWeightUnit picks the unit weights are returned in: an explicit ?units= wins,
+// then the preference of userID, and kilograms for workouts logged without a user.
+func ResolveWeightUnit(store UserStore, param string, userID int64) (units.WeightUnit, error) {
+	if param != "" {
+		return units.ParseWeightUnit(param)
+	}
+
+	if userID == 0 {
+		return units.Kilograms, nil
+	}
+
+	preferences, err := store.GetPreferences(userID)
+	if err != nil || preferences == nil {
+		return units.Kilograms, err
+	}
+
+	return preferences.WeightUnit, nil
+}
+
+// ReadWeightUnit resolves the output unit of a request for userID, writing the error
+// response and returning false when ?units= is invalid or the preference can't be read
+func ReadWeightUnit(w http.ResponseWriter, r *http.Request, store UserStore, userID int64,
+	logger *log.Logger) (units.WeightUnit, bool) {
+	param := r.URL.Query().Get("units")
+	unit, err := ResolveWeightUnit(store, param, userID)
+	if err != nil && param != "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return "", false
+	}
+
+	if err != nil {
+		logger.Printf("Error resolveWeightUnit: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return "", false
+	}
+
+	return unit, true
+}
@@ -0,0 +1,47 @@
+package users
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type contextKey string
+
+const userContextKey = contextKey("user")
+
+// RequireUser identifies the caller of the /me routes from the X-User-ID header and
+// rejects requests without an existing user. It stands in for token authentication,
+// which will set the same context value once it lands.
+func RequireUser(store UserStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := strconv.ParseInt(r.Header.Get("X-User-ID"), 10, 64)
+			if err != nil || userID <= 0 {
+				utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "missing or invalid X-User-ID header"}) // 401
+				return
+			}
+
+			user, err := store.GetUser(userID)
+			if err != nil {
+				utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+				return
+			}
+			if user == nil {
+				utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "unknown user"}) // 401
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// CurrentUser returns the user RequireUser identified, nil outside of it
+func CurrentUser(r *http.Request) *User {
+	user, _ := r.Context().Value(userContextKey).(*User)
+	return user
+}
@@ -0,0 +1,20 @@
+package users
+
+import (
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/go-chi/chi/v5"
+)
+
+func UserRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and store
+	r := chi.NewRouter()
+	store := NewPostgresUserStore(app.DB)
+	handler := NewUserHandler(store, app.Logger)
+
+	// Define subroutes
+	r.Get("/{id}/preferences", handler.HandleGetPreferences)
+	r.Put("/{id}/preferences", handler.HandleUpdatePreferences)
+
+	return r
+}
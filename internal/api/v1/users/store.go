@@ -0,0 +1,81 @@
+package users
+
+import (
+	"database/sql"
+)
+
+// DB connector struct
+type PostgresUserStore struct {
+	db *sql.DB
+}
+
+func NewPostgresUserStore(db *sql.DB) *PostgresUserStore {
+	return &PostgresUserStore{db: db}
+}
+
+type UserStore interface {
+	GetUser(userID int64) (*User, error)
+	GetPreferences(userID int64) (*Preferences, error)
+	UpdatePreferences(*Preferences) error
+}
+
+// Returns nil when the user doesn't exist or their account was anonymized
+func (pgStore *PostgresUserStore) GetUser(userID int64) (*User, error) {
+	user := &User{}
+	query := `
+	SELECT id, username, email, COALESCE(bio, ''), created_at, updated_at
+	FROM users
+	WHERE id = $1 AND anonymized_at IS NULL
+	`
+	err := pgStore.db.QueryRow(query, userID).Scan(&user.ID, &user.Username, &user.Email, &user.Bio,
+		&user.CreatedAt, &user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil // No user found
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Returns nil when the user doesn't exist
+func (pgStore *PostgresUserStore) GetPreferences(userID int64) (*Preferences, error) {
+	preferences := &Preferences{}
+	query := `SELECT id, preferred_weight_unit FROM users WHERE id = $1`
+
+	err := pgStore.db.QueryRow(query, userID).Scan(&preferences.UserID, &preferences.WeightUnit)
+	if err == sql.ErrNoRows {
+		return nil, nil // No user found
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+func (pgStore *PostgresUserStore) UpdatePreferences(preferences *Preferences) error {
+	query := `
+	UPDATE users
+	SET preferred_weight_unit = $1, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $2
+	`
+	result, err := pgStore.db.Exec(query, preferences.WeightUnit, preferences.UserID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package users
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/Josesx506/gofems/internal/store"
+	"github.com/Josesx506/gofems/internal/units"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferences(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresUserStore(db)
+
+	var userID int64
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('lifter', 'lifter@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	preferences, err := pgStore.GetPreferences(userID)
+	require.NoError(t, err)
+	assert.Equal(t, units.Kilograms, preferences.WeightUnit, "kilograms by default")
+
+	require.NoError(t, pgStore.UpdatePreferences(&Preferences{UserID: int(userID), WeightUnit: units.Pounds}))
+
+	unit, err := ResolveWeightUnit(pgStore, "", userID)
+	require.NoError(t, err)
+	assert.Equal(t, units.Pounds, unit)
+
+	unit, err = ResolveWeightUnit(pgStore, "kg", userID)
+	require.NoError(t, err)
+	assert.Equal(t, units.Kilograms, unit, "?units= overrides the preference")
+
+	missing, err := pgStore.GetPreferences(userID + 1)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+	assert.ErrorIs(t, pgStore.UpdatePreferences(&Preferences{UserID: int(userID) + 1, WeightUnit: units.Pounds}),
+		sql.ErrNoRows)
+}
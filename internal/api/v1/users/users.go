@@ -0,0 +1,23 @@
+package users
+
+import (
+	"time"
+
+	"github.com/Josesx506/gofems/internal/units"
+)
+
+// Profile of a user, credentials are never returned
+type User struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Bio       string    `json:"bio"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Display and input settings of a user
+type Preferences struct {
+	UserID     int              `json:"user_id"`
+	WeightUnit units.WeightUnit `json:"weight_unit"` // unit weights are logged and shown in by default
+}
@@ -0,0 +1,199 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/outbox"
+)
+
+const (
+	// How long a claimed delivery is reserved for its worker
+	deliveryLease = 2 * time.Minute
+	// Response bodies read so the connection can be reused
+	maxDrainBytes = 512
+)
+
+// Dispatcher sends the deliveries that are due. They're queued by the subscriber
+// QueueEvent returns.
+type Dispatcher struct {
+	store    WebhookStore
+	client   *http.Client
+	logger   *log.Logger
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewDispatcher(store WebhookStore, client *http.Client, logger *log.Logger, interval time.Duration) *Dispatcher {
+	return &Dispatcher{
+		store:    store,
+		client:   client,
+		logger:   logger,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// NewClient returns the client deliveries are sent with. Its dialer refuses the
+// addresses Validate does, so receivers can't reach the internal network by changing
+// their DNS records or redirecting.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // a proxy would dial the receiver for us, unchecked
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// Runs on the resolved address of every connection before it's made
+func dialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !allowedIP(ip) {
+		return ErrPrivateURL
+	}
+	return nil
+}
+
+// Run dispatches once immediately and then on every interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		d.DispatchOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sends due deliveries until none are left
+func (d *Dispatcher) DispatchOnce(ctx context.Context) {
+	for ctx.Err() == nil {
+		jobs, err := d.store.ClaimDeliveries(d.batchSize(), deliveryLease)
+		if err != nil {
+			d.logger.Printf("Error claimDeliveries: %v", err)
+			return
+		}
+		if len(jobs) == 0 {
+			return
+		}
+
+		for _, job := range jobs {
+			err := d.store.RecordAttempt(job, d.deliver(ctx, job))
+			if err == sql.ErrNoRows {
+				d.logger.Printf("Webhook delivery %d outlived its lease, its outcome is dropped", job.DeliveryID)
+				continue
+			}
+			if err != nil {
+				d.logger.Printf("Error recordAttempt: %v", err)
+			}
+		}
+	}
+}
+
+// Deliveries claimed per round: as many as can time out one after the other within
+// the lease, so none is claimed again while this worker still means to send it
+func (d *Dispatcher) batchSize() int {
+	if d.client.Timeout <= 0 {
+		return 1
+	}
+	return max(1, int(deliveryLease/d.client.Timeout))
+}
+
+// QueueEvent returns the bus subscriber creating the deliveries of every event
+func QueueEvent(store WebhookStore) outbox.Handler {
+	return func(ctx context.Context, event outbox.Event) error {
+		return store.QueueDeliveries(event)
+	}
+}
+
+// Sends a job and decides what happens next: done on a 2xx response, otherwise a
+// retry after the backoff or the dead letter once attempts run out
+func (d *Dispatcher) deliver(ctx context.Context, job Job) Attempt {
+	attempt := Attempt{Status: DeliverySucceeded, NextAttemptAt: d.now()}
+
+	responseStatus, err := d.send(ctx, job)
+	if responseStatus != 0 {
+		attempt.ResponseStatus = &responseStatus
+	}
+	if err == nil {
+		return attempt
+	}
+
+	attempt.Error = attemptError(responseStatus, err)
+	attempts := job.Attempts + 1
+	if attempts >= MaxAttempts {
+		attempt.Status = DeliveryDead
+		d.logger.Printf("Webhook delivery %d dead after %d attempts: %v", job.DeliveryID, attempts, err)
+		return attempt
+	}
+
+	attempt.Status = DeliveryPending
+	attempt.NextAttemptAt = d.now().Add(Backoff(attempts))
+	return attempt
+}
+
+func (d *Dispatcher) send(ctx context.Context, job Job) (int, error) {
+	body, err := json.Marshal(Payload{ID: job.Event.ID, Type: job.Event.Type, CreatedAt: job.Event.CreatedAt,
+		Data: job.Event.Data})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gofems-webhooks/1")
+	req.Header.Set("X-Webhook-Event", job.Event.Type)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(job.DeliveryID, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(job.Secret, d.now(), body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxDrainBytes)) // lets the connection be reused
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver responded %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// The error kept in the delivery log. Users read it, so it never holds what the
+// receiver sent back or the details of a failed connection.
+func attemptError(responseStatus int, err error) string {
+	var netErr net.Error
+	switch {
+	case responseStatus != 0:
+		return fmt.Sprintf("receiver responded %d", responseStatus)
+	case errors.Is(err, ErrPrivateURL):
+		return ErrPrivateURL.Error()
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	default:
+		return "request failed"
+	}
+}
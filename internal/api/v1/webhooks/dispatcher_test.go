@@ -0,0 +1,125 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Hands out its jobs once, at most limit of them per claim, and keeps the attempts
+// recorded for them
+type fakeStore struct {
+	WebhookStore
+	jobs     []Job
+	limits   []int
+	attempts map[int64]Attempt
+}
+
+func (fs *fakeStore) ClaimDeliveries(limit int, lease time.Duration) ([]Job, error) {
+	fs.limits = append(fs.limits, limit)
+	jobs := fs.jobs[:min(limit, len(fs.jobs))]
+	fs.jobs = fs.jobs[len(jobs):]
+	return jobs, nil
+}
+
+func (fs *fakeStore) RecordAttempt(job Job, attempt Attempt) error {
+	fs.attempts[job.DeliveryID] = attempt
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	now := time.Now()
+	var received []Payload
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if VerifySignature("whsec_good", r.Header.Get("X-Webhook-Signature"), body, time.Minute, now) != nil {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+
+		var payload Payload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, payload.Type, r.Header.Get("X-Webhook-Event"))
+		received = append(received, payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	event := outbox.Event{ID: 7, Type: outbox.WorkoutCreated, Data: json.RawMessage(`{"id":3}`), CreatedAt: now}
+	store := &fakeStore{attempts: map[int64]Attempt{}, jobs: []Job{
+		{DeliveryID: 1, URL: receiver.URL, Secret: "whsec_good", Event: event},
+		{DeliveryID: 2, Attempts: 2, URL: receiver.URL, Secret: "whsec_stale", Event: event},
+		{DeliveryID: 3, Attempts: MaxAttempts - 1, URL: receiver.URL, Secret: "whsec_stale", Event: event},
+	}}
+
+	dispatcher := NewDispatcher(store, receiver.Client(), log.New(io.Discard, "", 0), time.Minute)
+	dispatcher.now = func() time.Time { return now }
+	dispatcher.DispatchOnce(context.Background())
+
+	require.Len(t, received, 1)
+	assert.Equal(t, int64(7), received[0].ID)
+	assert.Equal(t, map[string]any{"id": float64(3)}, received[0].Data)
+
+	assert.Equal(t, DeliverySucceeded, store.attempts[1].Status)
+	assert.Equal(t, http.StatusNoContent, *store.attempts[1].ResponseStatus)
+
+	retried := store.attempts[2]
+	assert.Equal(t, DeliveryPending, retried.Status)
+	assert.Equal(t, now.Add(Backoff(3)), retried.NextAttemptAt, "waits longer after every failure")
+	assert.Equal(t, http.StatusUnauthorized, *retried.ResponseStatus)
+	assert.Equal(t, "receiver responded 401", retried.Error, "the response body isn't kept")
+
+	assert.Equal(t, DeliveryDead, store.attempts[3].Status, "dead-lettered once attempts run out")
+}
+
+func TestDispatcherUnreachable(t *testing.T) {
+	store := &fakeStore{attempts: map[int64]Attempt{}, jobs: []Job{
+		{DeliveryID: 1, URL: "http://127.0.0.1:1/hooks", Secret: "whsec_test", Event: outbox.Event{ID: 1}},
+	}}
+
+	dispatcher := NewDispatcher(store, &http.Client{Timeout: time.Second}, log.New(io.Discard, "", 0), time.Minute)
+	dispatcher.DispatchOnce(context.Background())
+
+	assert.Equal(t, DeliveryPending, store.attempts[1].Status)
+	assert.Nil(t, store.attempts[1].ResponseStatus)
+	assert.Equal(t, "request failed", store.attempts[1].Error)
+}
+
+func TestDispatcherPrivateAddress(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request reached a loopback receiver")
+	}))
+	defer receiver.Close()
+
+	store := &fakeStore{attempts: map[int64]Attempt{}, jobs: []Job{
+		{DeliveryID: 1, URL: receiver.URL, Secret: "whsec_test", Event: outbox.Event{ID: 1}},
+	}}
+
+	dispatcher := NewDispatcher(store, NewClient(time.Second), log.New(io.Discard, "", 0), time.Minute)
+	dispatcher.DispatchOnce(context.Background())
+
+	assert.Equal(t, DeliveryPending, store.attempts[1].Status)
+	assert.Equal(t, ErrPrivateURL.Error(), store.attempts[1].Error)
+}
+
+func TestDispatcherBatchFitsLease(t *testing.T) {
+	store := &fakeStore{attempts: map[int64]Attempt{}}
+	for id := range int64(30) {
+		store.jobs = append(store.jobs, Job{DeliveryID: id, URL: "http://127.0.0.1:1/hooks", Event: outbox.Event{ID: 1}})
+	}
+
+	dispatcher := NewDispatcher(store, &http.Client{Timeout: deliveryLease / 10}, log.New(io.Discard, "", 0), time.Minute)
+	dispatcher.DispatchOnce(context.Background())
+
+	assert.Len(t, store.attempts, 30)
+	assert.Equal(t, []int{10, 10, 10, 10}, store.limits, "every claim can time out within the lease")
+}
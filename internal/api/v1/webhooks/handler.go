@@ -0,0 +1,168 @@
+package webhooks
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/utils"
+)
+
+type WebhookHandler struct {
+	store  WebhookStore
+	logger *log.Logger
+}
+
+func NewWebhookHandler(store WebhookStore, logger *log.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// The secret is in the response so the receiver can verify signatures, and isn't shown again
+func (wh *WebhookHandler) HandleCreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var subscription Subscription
+	err := json.NewDecoder(r.Body).Decode(&subscription)
+	if err != nil {
+		wh.logger.Printf("Error decodingCreateSubscription: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+	subscription.UserID = users.CurrentUser(r).ID
+
+	err = subscription.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	createdSubscription, err := wh.store.CreateSubscription(&subscription)
+	if err != nil {
+		wh.logger.Printf("Error createSubscription: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create subscription"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"subscription": createdSubscription})
+}
+
+func (wh *WebhookHandler) HandleListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := wh.store.ListSubscriptions(int64(users.CurrentUser(r).ID))
+	if err != nil {
+		wh.logger.Printf("Error listSubscriptions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"subscriptions": subscriptions})
+}
+
+func (wh *WebhookHandler) HandleGetSubscriptionByID(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := wh.readSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"subscription": subscription})
+}
+
+// Deleting a subscription drops its pending deliveries too
+func (wh *WebhookHandler) HandleDeleteSubscriptionByID(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := wh.readSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	err := wh.store.DeleteSubscription(int64(subscription.ID))
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "subscription not found"}) // 404
+		return
+	}
+
+	if err != nil {
+		wh.logger.Printf("Error deleteSubscription: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete subscription"}) // 500
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The delivery log of a subscription, newest first, filtered with ?status=
+func (wh *WebhookHandler) HandleListDeliveries(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := wh.readSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != DeliveryPending && status != DeliverySucceeded && status != DeliveryDead {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "status must be pending, succeeded or dead"}) // 400
+		return
+	}
+
+	deliveries, err := wh.store.ListDeliveries(int64(subscription.ID), status)
+	if err != nil {
+		wh.logger.Printf("Error listDeliveries: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"deliveries": deliveries})
+}
+
+// Sends a dead-lettered delivery again with a fresh set of attempts
+func (wh *WebhookHandler) HandleRetryDelivery(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := wh.readSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := utils.ReadInt64Param(r, "deliveryID")
+	if err != nil {
+		wh.logger.Printf("Error reading deliveryID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid delivery id"}) // 400
+		return
+	}
+
+	err = wh.store.RetryDelivery(int64(subscription.ID), deliveryID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "no dead delivery found"}) // 404
+		return
+	}
+
+	if err != nil {
+		wh.logger.Printf("Error retryDelivery: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to retry delivery"}) // 500
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Reads the subscription in the path, answering 404 unless it's the caller's
+func (wh *WebhookHandler) readSubscription(w http.ResponseWriter, r *http.Request) (*Subscription, bool) {
+	subscriptionID, err := utils.ReadIDParam(r)
+	if err != nil {
+		wh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid subscription id"}) // 400
+		return nil, false
+	}
+
+	subscription, err := wh.store.GetSubscriptionByID(subscriptionID)
+	if err != nil {
+		wh.logger.Printf("Error getSubscriptionByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return nil, false
+	}
+
+	if subscription == nil || subscription.UserID != users.CurrentUser(r).ID {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "subscription not found"}) // 404
+		return nil, false
+	}
+
+	return subscription, true
+}
@@ -0,0 +1,27 @@
+package webhooks
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/go-chi/chi/v5"
+)
+
+func WebhookRouter(app *app.Application) chi.Router {
+
+	// Initialize router, handler, and store
+	r := chi.NewRouter()
+	store := NewPostgresWebhookStore(app.DB)
+	handler := NewWebhookHandler(store, app.Logger)
+
+	r.Use(users.RequireUser(users.NewPostgresUserStore(app.DB)))
+
+	// Define subroutes
+	r.Get("/", handler.HandleListSubscriptions)
+	r.Post("/", handler.HandleCreateSubscription)
+	r.Get("/{id}", handler.HandleGetSubscriptionByID)
+	r.Delete("/{id}", handler.HandleDeleteSubscriptionByID)
+	r.Get("/{id}/deliveries", handler.HandleListDeliveries)
+	r.Post("/{id}/deliveries/{deliveryID}/retry", handler.HandleRetryDelivery)
+
+	return r
+}
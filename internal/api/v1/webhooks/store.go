@@ -0,0 +1,269 @@
+package webhooks
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/outbox"
+	"github.com/jackc/pgtype"
+)
+
+// DB connector struct
+type PostgresWebhookStore struct {
+	db *sql.DB
+}
+
+func NewPostgresWebhookStore(db *sql.DB) *PostgresWebhookStore {
+	return &PostgresWebhookStore{db: db}
+}
+
+type WebhookStore interface {
+	CreateSubscription(subscription *Subscription) (*Subscription, error)
+	GetSubscriptionByID(id int64) (*Subscription, error)
+	ListSubscriptions(userID int64) ([]Subscription, error)
+	DeleteSubscription(id int64) error
+	ListDeliveries(subscriptionID int64, status string) ([]Delivery, error)
+	RetryDelivery(subscriptionID, deliveryID int64) error
+	QueueDeliveries(event outbox.Event) error
+	ClaimDeliveries(limit int, lease time.Duration) ([]Job, error)
+	RecordAttempt(job Job, attempt Attempt) error
+}
+
+// A claimed delivery with what's needed to send it
+type Job struct {
+	DeliveryID  int64
+	Attempts    int       // Made before this one
+	LeasedUntil time.Time // The claim's lease, it's ours while next_attempt_at still holds it
+	URL         string
+	Secret      string
+	Event       outbox.Event
+}
+
+// The outcome of sending a job
+type Attempt struct {
+	Status         string // pending to retry at NextAttemptAt
+	ResponseStatus *int
+	Error          string
+	NextAttemptAt  time.Time
+}
+
+func (pgStore *PostgresWebhookStore) CreateSubscription(subscription *Subscription) (*Subscription, error) {
+	query := `
+	INSERT INTO webhook_subscriptions (user_id, app_name, url, events, secret)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at
+	`
+	err := pgStore.db.QueryRow(query, subscription.UserID, subscription.AppName, subscription.URL,
+		subscription.Events, subscription.Secret).Scan(&subscription.ID, &subscription.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// Returns nil when the subscription doesn't exist. The secret is left out.
+func (pgStore *PostgresWebhookStore) GetSubscriptionByID(id int64) (*Subscription, error) {
+	subscriptions, err := pgStore.querySubscriptions(`
+	SELECT id, user_id, app_name, url, events, created_at
+	FROM webhook_subscriptions
+	WHERE id = $1
+	`, id)
+	if err != nil || len(subscriptions) == 0 {
+		return nil, err
+	}
+	return &subscriptions[0], nil
+}
+
+// The secrets are left out
+func (pgStore *PostgresWebhookStore) ListSubscriptions(userID int64) ([]Subscription, error) {
+	return pgStore.querySubscriptions(`
+	SELECT id, user_id, app_name, url, events, created_at
+	FROM webhook_subscriptions
+	WHERE user_id = $1
+	ORDER BY id
+	`, userID)
+}
+
+func (pgStore *PostgresWebhookStore) querySubscriptions(query string, args ...any) ([]Subscription, error) {
+	rows, err := pgStore.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []Subscription{}
+	for rows.Next() {
+		var subscription Subscription
+		var events pgtype.TextArray
+		err := rows.Scan(&subscription.ID, &subscription.UserID, &subscription.AppName, &subscription.URL,
+			&events, &subscription.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		err = events.AssignTo(&subscription.Events)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// Pending deliveries of the subscription are dropped with it
+func (pgStore *PostgresWebhookStore) DeleteSubscription(id int64) error {
+	result, err := pgStore.db.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Lists the latest deliveries of a subscription, newest first, optionally of one status
+func (pgStore *PostgresWebhookStore) ListDeliveries(subscriptionID int64, status string) ([]Delivery, error) {
+	query := `
+	SELECT d.id, d.subscription_id, d.event_id, e.event_type, d.status, d.attempts, d.next_attempt_at,
+		d.last_attempt_at, d.response_status, COALESCE(d.last_error, ''), d.created_at, d.delivered_at
+	FROM webhook_deliveries d
+	JOIN outbox_events e ON e.id = d.event_id
+	WHERE d.subscription_id = $1 AND ($2 = '' OR d.status = $2)
+	ORDER BY d.id DESC
+	LIMIT 100
+	`
+	rows, err := pgStore.db.Query(query, subscriptionID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var delivery Delivery
+		var nextAttemptAt time.Time
+		err := rows.Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.EventID, &delivery.EventType,
+			&delivery.Status, &delivery.Attempts, &nextAttemptAt, &delivery.LastAttemptAt, &delivery.ResponseStatus,
+			&delivery.LastError, &delivery.CreatedAt, &delivery.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		if delivery.Status == DeliveryPending {
+			delivery.NextAttemptAt = &nextAttemptAt
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Requeues a dead delivery with a fresh set of attempts. Returns sql.ErrNoRows
+// unless the delivery belongs to the subscription and is dead.
+func (pgStore *PostgresWebhookStore) RetryDelivery(subscriptionID, deliveryID int64) error {
+	query := `
+	UPDATE webhook_deliveries
+	SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND subscription_id = $2 AND status = 'dead'
+	`
+	result, err := pgStore.db.Exec(query, deliveryID, subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Creates the deliveries of an event for the subscriptions of its user that listen to
+// it. Subscriptions only receive events from after they were created, and queueing an
+// event twice doesn't deliver it twice.
+func (pgStore *PostgresWebhookStore) QueueDeliveries(event outbox.Event) error {
+	query := `
+	INSERT INTO webhook_deliveries (subscription_id, event_id)
+	SELECT id, $1
+	FROM webhook_subscriptions
+	WHERE user_id = $2 AND $3 = ANY(events) AND created_at <= $4
+	ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`
+	_, err := pgStore.db.Exec(query, event.ID, event.UserID, event.Type, event.CreatedAt)
+	return err
+}
+
+// Claims up to limit due deliveries. Claimed deliveries are leased rather than locked:
+// their next attempt moves lease into the future, so a worker that dies mid-request
+// leaves them to be retried instead of stuck.
+func (pgStore *PostgresWebhookStore) ClaimDeliveries(limit int, lease time.Duration) ([]Job, error) {
+	query := `
+	UPDATE webhook_deliveries d
+	SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+	FROM webhook_subscriptions s, outbox_events e
+	WHERE d.id IN (
+		SELECT id
+		FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+		ORDER BY next_attempt_at, id
+		FOR UPDATE SKIP LOCKED
+		LIMIT $1
+	) AND s.id = d.subscription_id AND e.id = d.event_id
+	RETURNING d.id, d.attempts, d.next_attempt_at, s.url, s.secret, e.id, e.event_type, COALESCE(e.user_id, 0), e.workout_id,
+		e.payload, e.created_at
+	`
+	rows, err := pgStore.db.Query(query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		var job Job
+		var payload []byte
+		err := rows.Scan(&job.DeliveryID, &job.Attempts, &job.LeasedUntil, &job.URL, &job.Secret, &job.Event.ID, &job.Event.Type,
+			&job.Event.UserID, &job.Event.WorkoutID, &payload, &job.Event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		job.Event.Data = payload
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// Stores the outcome of a claimed job. Returns sql.ErrNoRows when the lease ran out
+// and the delivery was claimed again, the new claim records its own outcome.
+func (pgStore *PostgresWebhookStore) RecordAttempt(job Job, attempt Attempt) error {
+	query := `
+	UPDATE webhook_deliveries
+	SET status = $2, attempts = attempts + 1, last_attempt_at = CURRENT_TIMESTAMP, response_status = $3,
+		last_error = NULLIF($4, ''), next_attempt_at = $5,
+		delivered_at = CASE WHEN $2 = 'succeeded' THEN CURRENT_TIMESTAMP END
+	WHERE id = $1 AND status = 'pending' AND attempts = $6 AND next_attempt_at = $7
+	`
+	result, err := pgStore.db.Exec(query, job.DeliveryID, attempt.Status, attempt.ResponseStatus, attempt.Error,
+		attempt.NextAttemptAt, job.Attempts, job.LeasedUntil)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
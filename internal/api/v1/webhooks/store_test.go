@@ -0,0 +1,112 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
+	"github.com/Josesx506/gofems/internal/outbox"
+	"github.com/Josesx506/gofems/internal/store"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookDeliveries(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWebhookStore(db)
+	workoutStore := workouts.NewPostgresWorkoutStore(db)
+
+	var userID int64
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('lifter', 'lifter@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	var received []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("X-Webhook-Event"))
+	}))
+	defer receiver.Close()
+
+	// Validate turns loopback receivers down, so the subscription is stored as is
+	subscription := &Subscription{UserID: int(userID), URL: receiver.URL,
+		Events: []string{outbox.WorkoutCreated, outbox.WorkoutDeleted}, Secret: "whsec_test"}
+	_, err := pgStore.CreateSubscription(subscription)
+	require.NoError(t, err)
+
+	reps := 5
+	workout, err := workoutStore.CreateWorkout(&workouts.Workout{UserID: int(userID), Title: "Push day",
+		Entries: []workouts.WorkoutEntry{{ExerciseName: "Bench Press", Sets: 5, Reps: &reps}}})
+	require.NoError(t, err)
+	workout.Title = "Push day, heavy"
+	require.NoError(t, workoutStore.UpdateWorkout(workout))
+	require.NoError(t, workoutStore.DeleteWorkout(int64(workout.ID)))
+
+	var events int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM outbox_events WHERE workout_id = $1
+		AND event_type LIKE 'workout.%'`, workout.ID).Scan(&events))
+	assert.Equal(t, 3, events, "every change is written to the outbox")
+
+	bus := outbox.NewBus()
+	bus.Subscribe("webhooks", QueueEvent(pgStore))
+	_, err = outbox.NewRelay(db, bus, log.New(io.Discard, "", 0), time.Minute).RelayOnce(context.Background())
+	require.NoError(t, err)
+
+	dispatcher := NewDispatcher(pgStore, receiver.Client(), log.New(io.Discard, "", 0), time.Minute)
+	dispatcher.DispatchOnce(context.Background())
+	assert.Equal(t, []string{outbox.WorkoutCreated, outbox.WorkoutDeleted}, received,
+		"only subscribed events are delivered")
+
+	deliveries, err := pgStore.ListDeliveries(int64(subscription.ID), DeliverySucceeded)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, outbox.WorkoutDeleted, deliveries[0].EventType, "newest first")
+	assert.Nil(t, deliveries[0].NextAttemptAt)
+
+	require.NoError(t, pgStore.QueueDeliveries(outbox.Event{ID: deliveries[0].EventID, Type: outbox.WorkoutDeleted,
+		UserID: int(userID), CreatedAt: time.Now()}))
+	dispatcher.DispatchOnce(context.Background())
+	assert.Len(t, received, 2, "events are delivered once")
+
+	// A dead delivery can be sent again
+	_, err = db.Exec(`UPDATE webhook_deliveries SET status = 'dead', attempts = $1 WHERE id = $2`,
+		MaxAttempts, deliveries[0].ID)
+	require.NoError(t, err)
+	require.NoError(t, pgStore.RetryDelivery(int64(subscription.ID), int64(deliveries[0].ID)))
+	jobs, err := pgStore.ClaimDeliveries(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, 0, jobs[0].Attempts)
+	assert.Equal(t, subscription.Secret, jobs[0].Secret)
+
+	var data map[string]any
+	require.NoError(t, json.Unmarshal(jobs[0].Event.Data, &data))
+	assert.Equal(t, float64(workout.ID), data["id"])
+
+	leased, err := pgStore.ClaimDeliveries(10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, leased, "claimed deliveries are leased")
+
+	// Once the lease runs out the delivery is claimed again and the first claim can't record
+	_, err = db.Exec(`UPDATE webhook_deliveries SET next_attempt_at = CURRENT_TIMESTAMP WHERE id = $1`,
+		deliveries[0].ID)
+	require.NoError(t, err)
+	reclaimed, err := pgStore.ClaimDeliveries(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, reclaimed, 1)
+	outcome := Attempt{Status: DeliverySucceeded, NextAttemptAt: time.Now()}
+	assert.Equal(t, sql.ErrNoRows, pgStore.RecordAttempt(jobs[0], outcome), "the stale claim is dropped")
+	require.NoError(t, pgStore.RecordAttempt(reclaimed[0], outcome))
+
+	fetched, err := pgStore.GetSubscriptionByID(int64(subscription.ID))
+	require.NoError(t, err)
+	assert.Empty(t, fetched.Secret)
+}
@@ -0,0 +1,191 @@
+// Package webhooks delivers workout events to the URLs users subscribe, signed with
+// the subscription's secret and retried with exponential backoff.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/outbox"
+)
+
+// A URL that receives the events it subscribed to. The secret is only returned
+// when the subscription is created.
+type Subscription struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	AppName   string    `json:"app_name"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead" // gave up after MaxAttempts
+)
+
+// One event to deliver to one subscription, and how its attempts went
+type Delivery struct {
+	ID             int        `json:"id"`
+	SubscriptionID int        `json:"subscription_id"`
+	EventID        int64      `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"` // Only while pending
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus *int       `json:"response_status"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+// The request body receivers get
+type Payload struct {
+	ID        int64     `json:"id"` // Event id, the same across retries so receivers can drop duplicates
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+const (
+	// Deliveries are dead-lettered after this many failed attempts
+	MaxAttempts = 8
+	// Wait before the first retry, doubled after every failure
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 2 * time.Hour
+)
+
+var (
+	ErrInvalidURL       = errors.New("url must be an absolute http or https url")
+	ErrUnresolvedURL    = errors.New("url host doesn't resolve")
+	ErrPrivateURL       = errors.New("url must not point to a loopback, private or link-local address")
+	ErrUnknownEvent     = fmt.Errorf("unknown event, expected one of %s", strings.Join(outbox.EventTypes, ", "))
+	ErrNoEvents         = errors.New("subscribe to at least one event")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Checks a new subscription and generates its secret unless one was given
+func (s *Subscription) Validate() error {
+	s.AppName = strings.TrimSpace(s.AppName)
+	parsed, err := url.Parse(s.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidURL
+	}
+	err = checkHost(parsed.Hostname())
+	if err != nil {
+		return err
+	}
+
+	if len(s.Events) == 0 {
+		return ErrNoEvents
+	}
+	for _, event := range s.Events {
+		if !outbox.IsEventType(event) {
+			return fmt.Errorf("%w: %q", ErrUnknownEvent, event)
+		}
+	}
+
+	if s.Secret == "" {
+		secret := make([]byte, 24)
+		rand.Read(secret)
+		s.Secret = "whsec_" + hex.EncodeToString(secret)
+	}
+	if len(s.Secret) > 100 {
+		return errors.New("secret is limited to 100 characters")
+	}
+
+	return nil
+}
+
+// Resolves host names, swapped in tests that can't reach DNS
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// Rejects hosts with an address webhooks may not be sent to. The dispatcher's dialer
+// checks again, since the records can change after the subscription was made.
+func checkHost(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addrs, err := lookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return ErrUnresolvedURL
+	}
+	for _, addr := range addrs {
+		if !allowedIP(addr.IP) {
+			return ErrPrivateURL
+		}
+	}
+	return nil
+}
+
+// Whether deliveries may reach ip, anything on the server's own network is off limits
+func allowedIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+// Backoff is the wait after a delivery failed for the attempts-th time
+func Backoff(attempts int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
+
+// Sign returns the X-Webhook-Signature header of body sent at timestamp: the unix
+// time and an HMAC-SHA256 of "<time>.<body>" keyed with the secret.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), signature(secret, timestamp.Unix(), body))
+}
+
+func signature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a signature header the way receivers should: the HMAC must
+// match and the timestamp be within tolerance of now, so captured requests can't be replayed.
+func VerifySignature(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp int64
+	var given []byte
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			given, _ = hex.DecodeString(value)
+		}
+	}
+	if timestamp == 0 || given == nil {
+		return ErrInvalidSignature
+	}
+
+	sentAt := time.Unix(timestamp, 0)
+	if now.Sub(sentAt) > tolerance || sentAt.Sub(now) > tolerance {
+		return ErrInvalidSignature
+	}
+
+	expected, _ := hex.DecodeString(signature(secret, timestamp, body))
+	if !hmac.Equal(given, expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
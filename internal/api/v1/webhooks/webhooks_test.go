@@ -0,0 +1,83 @@
+package webhooks
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSubscription(t *testing.T) {
+	hosts := map[string]string{"example.com": "93.184.215.14", "internal.example.com": "10.0.0.5"}
+	defer func(lookup func(context.Context, string) ([]net.IPAddr, error)) { lookupIPAddr = lookup }(lookupIPAddr)
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		if ip := net.ParseIP(host); ip != nil {
+			return []net.IPAddr{{IP: ip}}, nil
+		}
+		if addr, ok := hosts[host]; ok {
+			return []net.IPAddr{{IP: net.ParseIP(addr)}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	subscription := Subscription{AppName: " Coach ", URL: "https://example.com/hooks", Events: []string{outbox.WorkoutCreated}}
+	require.NoError(t, subscription.Validate())
+	assert.Equal(t, "Coach", subscription.AppName)
+	assert.True(t, strings.HasPrefix(subscription.Secret, "whsec_"), "a secret is generated")
+
+	tests := []struct {
+		name         string
+		subscription Subscription
+		wantErr      error
+	}{
+		{name: "relative url", subscription: Subscription{URL: "/hooks", Events: []string{outbox.WorkoutCreated}},
+			wantErr: ErrInvalidURL},
+		{name: "other scheme", subscription: Subscription{URL: "ftp://example.com", Events: []string{outbox.WorkoutCreated}},
+			wantErr: ErrInvalidURL},
+		{name: "unknown host", subscription: Subscription{URL: "https://nowhere.invalid", Events: []string{outbox.WorkoutCreated}},
+			wantErr: ErrUnresolvedURL},
+		{name: "loopback", subscription: Subscription{URL: "http://127.0.0.1:8080", Events: []string{outbox.WorkoutCreated}},
+			wantErr: ErrPrivateURL},
+		{name: "metadata service", subscription: Subscription{URL: "http://169.254.169.254/latest",
+			Events: []string{outbox.WorkoutCreated}}, wantErr: ErrPrivateURL},
+		{name: "private network", subscription: Subscription{URL: "https://internal.example.com/hooks",
+			Events: []string{outbox.WorkoutCreated}}, wantErr: ErrPrivateURL},
+		{name: "unspecified", subscription: Subscription{URL: "http://[::]:80", Events: []string{outbox.WorkoutCreated}},
+			wantErr: ErrPrivateURL},
+		{name: "no events", subscription: Subscription{URL: "https://example.com"}, wantErr: ErrNoEvents},
+		{name: "unknown event", subscription: Subscription{URL: "https://example.com", Events: []string{"workout.liked"}},
+			wantErr: ErrUnknownEvent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.subscription.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 8*time.Minute, Backoff(5))
+	assert.Equal(t, maxBackoff, Backoff(20), "capped")
+}
+
+func TestSignature(t *testing.T) {
+	now := time.Unix(1791788400, 0)
+	body := []byte(`{"id":1}`)
+	header := Sign("whsec_test", now, body)
+	assert.True(t, strings.HasPrefix(header, "t=1791788400,v1="))
+
+	assert.NoError(t, VerifySignature("whsec_test", header, body, 5*time.Minute, now.Add(time.Minute)))
+	assert.ErrorIs(t, VerifySignature("whsec_other", header, body, 5*time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySignature("whsec_test", header, []byte(`{"id":2}`), 5*time.Minute, now), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySignature("whsec_test", header, body, 5*time.Minute, now.Add(time.Hour)),
+		ErrInvalidSignature, "replays are rejected")
+	assert.ErrorIs(t, VerifySignature("whsec_test", "v1=abc", body, 5*time.Minute, now), ErrInvalidSignature)
+}
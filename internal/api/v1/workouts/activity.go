@@ -0,0 +1,57 @@
+package workouts
+
+import (
+	"math"
+
+	"github.com/Josesx506/gofems/internal/activity"
+)
+
+// Exercise names of the entries activity uploads create
+var sportExercises = map[activity.Sport]string{
+	activity.Running:  "Running",
+	activity.Walking:  "Walking",
+	activity.Hiking:   "Hiking",
+	activity.Cycling:  "Cycling",
+	activity.Swimming: "Swimming",
+	activity.Other:    "Cardio",
+}
+
+// NewActivityWorkout turns a recorded activity into a workout of a single cardio entry
+// holding the summary of its track. The workout starts when the track does.
+func NewActivityWorkout(recorded *activity.Activity, sport activity.Sport, summary activity.Summary,
+	title string) *Workout {
+	exerciseName, ok := sportExercises[sport]
+	if !ok {
+		exerciseName = sportExercises[activity.Other]
+	}
+	if title == "" {
+		title = recorded.Name
+	}
+	if title == "" {
+		title = exerciseName + " activity"
+	}
+
+	distance := roundTo(summary.DistanceMeters/1000, 3)
+	elevation := summary.ElevationGainMeters
+	duration := summary.DurationSeconds
+	return &Workout{
+		Title:           title,
+		DurationMinutes: int(math.Round(float64(summary.DurationSeconds) / 60)),
+		CaloriesBurned:  summary.Calories,
+		CreatedAt:       summary.StartedAt,
+		Entries: []WorkoutEntry{{
+			ExerciseName:    exerciseName,
+			Sets:            1,
+			DurationSeconds: &duration,
+			OrderIndex:      1,
+			Cardio: &CardioMetrics{
+				Distance:            &distance,
+				DistanceUnit:        "km",
+				ElevationGainMeters: &elevation,
+				AvgHeartRate:        summary.AvgHeartRate,
+				MaxHeartRate:        summary.MaxHeartRate,
+				AvgCadence:          summary.AvgCadence,
+			},
+		}},
+	}
+}
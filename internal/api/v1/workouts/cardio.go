@@ -0,0 +1,90 @@
+package workouts
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Josesx506/gofems/internal/units"
+)
+
+// Endurance metrics of an entry. Distance is per set like duration_seconds, in
+// distance_unit, and is stored in meters.
+type CardioMetrics struct {
+	Distance            *float64 `json:"distance"`
+	DistanceUnit        string   `json:"distance_unit"` // m, km or mi, defaults to km
+	ElevationGainMeters *float64 `json:"elevation_gain_meters"`
+	AvgHeartRate        *int     `json:"avg_heart_rate"`
+	MaxHeartRate        *int     `json:"max_heart_rate"`
+	AvgCadence          *int     `json:"avg_cadence"` // steps or revolutions per minute
+
+	// Derived from the distance and the entry's duration_seconds, ignored on input
+	PaceSecondsPerKm   *float64 `json:"pace_seconds_per_km,omitempty"`
+	PaceSecondsPerMile *float64 `json:"pace_seconds_per_mile,omitempty"`
+	SpeedKmh           *float64 `json:"speed_kmh,omitempty"`
+
+	distanceMeters *float64
+}
+
+var ErrInvalidCardio = errors.New("invalid cardio metrics")
+
+// normalizeCardio validates an entry's cardio metrics and converts its distance to meters
+func normalizeCardio(entry *WorkoutEntry) error {
+	cardio := entry.Cardio
+	if cardio == nil {
+		return nil
+	}
+
+	unit, err := units.ParseDistanceUnit(cardio.DistanceUnit)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCardio, err)
+	}
+	cardio.DistanceUnit = string(unit)
+
+	switch {
+	case cardio.Distance != nil && *cardio.Distance < 0:
+		return fmt.Errorf("%w: distance can't be negative", ErrInvalidCardio)
+	case cardio.ElevationGainMeters != nil && *cardio.ElevationGainMeters < 0:
+		return fmt.Errorf("%w: elevation_gain_meters can't be negative", ErrInvalidCardio)
+	case !validHeartRate(cardio.AvgHeartRate) || !validHeartRate(cardio.MaxHeartRate):
+		return fmt.Errorf("%w: heart rates must be between 30 and 250 bpm", ErrInvalidCardio)
+	case cardio.AvgHeartRate != nil && cardio.MaxHeartRate != nil && *cardio.AvgHeartRate > *cardio.MaxHeartRate:
+		return fmt.Errorf("%w: avg_heart_rate can't exceed max_heart_rate", ErrInvalidCardio)
+	case cardio.AvgCadence != nil && *cardio.AvgCadence < 0:
+		return fmt.Errorf("%w: avg_cadence can't be negative", ErrInvalidCardio)
+	}
+
+	cardio.distanceMeters = nil
+	if cardio.Distance != nil {
+		meters := units.ToMeters(*cardio.Distance, unit)
+		cardio.distanceMeters = &meters
+	}
+	deriveCardio(entry)
+
+	return nil
+}
+
+func validHeartRate(bpm *int) bool {
+	return bpm == nil || (*bpm >= 30 && *bpm <= 250)
+}
+
+// deriveCardio fills in pace and speed when both the distance and the duration are known
+func deriveCardio(entry *WorkoutEntry) {
+	cardio := entry.Cardio
+	cardio.PaceSecondsPerKm, cardio.PaceSecondsPerMile, cardio.SpeedKmh = nil, nil, nil
+	if cardio.distanceMeters == nil || *cardio.distanceMeters <= 0 ||
+		entry.DurationSeconds == nil || *entry.DurationSeconds <= 0 {
+		return
+	}
+
+	seconds := float64(*entry.DurationSeconds)
+	perKm := roundTo(seconds/units.FromMeters(*cardio.distanceMeters, units.Kilometers), 1)
+	perMile := roundTo(seconds/units.FromMeters(*cardio.distanceMeters, units.Miles), 1)
+	speed := roundTo(units.FromMeters(*cardio.distanceMeters, units.Kilometers)/(seconds/3600), 2)
+	cardio.PaceSecondsPerKm, cardio.PaceSecondsPerMile, cardio.SpeedKmh = &perKm, &perMile, &speed
+}
+
+func roundTo(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
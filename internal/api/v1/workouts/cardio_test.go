@@ -0,0 +1,51 @@
+package workouts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeCardio(t *testing.T) {
+	run := WorkoutEntry{
+		ExerciseName:    "Running",
+		Sets:            1,
+		DurationSeconds: IntPtr(1500),
+		Cardio:          &CardioMetrics{Distance: FloatPtr(5), AvgHeartRate: IntPtr(155), MaxHeartRate: IntPtr(178)},
+	}
+
+	require.NoError(t, normalizeCardio(&run))
+	assert.Equal(t, "km", run.Cardio.DistanceUnit)
+	assert.Equal(t, 5000.0, *run.Cardio.distanceMeters)
+	assert.Equal(t, 300.0, *run.Cardio.PaceSecondsPerKm)
+	assert.InDelta(t, 482.8, *run.Cardio.PaceSecondsPerMile, 0.1)
+	assert.Equal(t, 12.0, *run.Cardio.SpeedKmh)
+
+	miles := WorkoutEntry{
+		ExerciseName:    "Running",
+		Sets:            1,
+		DurationSeconds: IntPtr(480),
+		Cardio:          &CardioMetrics{Distance: FloatPtr(1), DistanceUnit: "MI"},
+	}
+	require.NoError(t, normalizeCardio(&miles))
+	assert.Equal(t, "mi", miles.Cardio.DistanceUnit)
+	assert.InDelta(t, 1609.34, *miles.Cardio.distanceMeters, 0.01)
+	assert.Equal(t, 480.0, *miles.Cardio.PaceSecondsPerMile)
+
+	// Without a duration there's nothing to derive pace from
+	rowed := WorkoutEntry{ExerciseName: "Rowing", Sets: 1, Reps: IntPtr(1), Cardio: &CardioMetrics{Distance: FloatPtr(2)}}
+	require.NoError(t, normalizeCardio(&rowed))
+	assert.Nil(t, rowed.Cardio.PaceSecondsPerKm)
+
+	invalid := []*CardioMetrics{
+		{Distance: FloatPtr(5), DistanceUnit: "yd"},
+		{Distance: FloatPtr(-1)},
+		{AvgHeartRate: IntPtr(300)},
+		{AvgHeartRate: IntPtr(170), MaxHeartRate: IntPtr(160)},
+	}
+	for _, cardio := range invalid {
+		err := normalizeCardio(&WorkoutEntry{ExerciseName: "Running", Cardio: cardio})
+		assert.ErrorIs(t, err, ErrInvalidCardio)
+	}
+}
@@ -0,0 +1,393 @@
+package workouts
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/units"
+)
+
+// Columns of an exported CSV, one row per entry. Imports read the same columns by default.
+var CSVColumns = []string{
+	"workout_id", "date", "title", "description", "duration_minutes", "calories_burned",
+	"exercise_name", "sets", "reps", "duration_seconds", "weight", "weight_unit", "notes",
+}
+
+// Rows beyond MaxImportRows are rejected, an import is inserted in a single transaction
+const MaxImportRows = 10000
+
+var (
+	ErrInvalidMapping = errors.New("invalid column mapping")
+	ErrTooManyRows    = fmt.Errorf("imports are limited to %d rows", MaxImportRows)
+)
+
+// Date formats accepted by imports, the first is the one exports use
+var importDateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// Entries to export and the workout each one belongs to, the zero filter exports every live workout
+type ExportFilter struct {
+	UserID int64
+	From   *time.Time
+	To     *time.Time // Exclusive
+}
+
+// EncodeCSVRow formats an entry of workout as a row of CSVColumns, weights in the entry's unit
+func EncodeCSVRow(workout *Workout, entry *WorkoutEntry) []string {
+	return []string{
+		strconv.Itoa(workout.ID),
+		workout.CreatedAt.UTC().Format(time.RFC3339),
+		workout.Title,
+		workout.Description,
+		strconv.Itoa(workout.DurationMinutes),
+		strconv.Itoa(workout.CaloriesBurned),
+		entry.ExerciseName,
+		strconv.Itoa(entry.Sets),
+		formatOptionalInt(entry.Reps),
+		formatOptionalInt(entry.DurationSeconds),
+		formatOptionalFloat(entry.Weight),
+		string(entry.WeightUnit),
+		entry.Notes,
+	}
+}
+
+// ColumnMapping maps import fields, named as in CSVColumns, onto the headers of the uploaded file.
+// Fields left out are read from the header with their own name, if there is one.
+type ColumnMapping map[string]string
+
+// Validate rejects mappings of unknown fields
+func (mapping ColumnMapping) Validate() error {
+	for field := range mapping {
+		if !isCSVColumn(field) {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidMapping, field)
+		}
+	}
+	return nil
+}
+
+// A row that failed validation, Row is the line of the file it starts on
+type RowError struct {
+	Row    int      `json:"row"`
+	Errors []string `json:"errors"`
+}
+
+// Outcome of parsing an import
+type ImportResult struct {
+	Format   Format     `json:"format"`
+	Workouts []*Workout `json:"-"`
+	Rows     int        `json:"rows"`
+	Errors   []RowError `json:"errors"`
+}
+
+// ParseCSV streams rows from r, validates them one by one and groups them into workouts.
+// Rows sharing a workout_id belong to the same workout; without that column rows are
+// grouped by date and title. Workouts come back oldest first so records are detected in
+// the order they were set. Row problems are collected in the result, the error is only
+// set when the file itself can't be read.
+func ParseCSV(r io.Reader, mapping ColumnMapping) (*ImportResult, error) {
+	return ParseImport(r, FormatCSV, mapping, ImportOptions{})
+}
+
+func parseCSVRows(reader *csv.Reader, header []string, mapping ColumnMapping) (*ImportResult, error) {
+	err := mapping.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := resolveColumns(header, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{Errors: []RowError{}}
+	workouts := map[string]*Workout{}
+	err = eachRow(reader, columns, result, func(row *csvRow) {
+		workout, entry, key := row.parse()
+		if len(row.errors) > 0 {
+			return
+		}
+
+		existing, ok := workouts[key]
+		if !ok {
+			existing = workout
+			workouts[key] = existing
+			result.Workouts = append(result.Workouts, existing)
+		}
+		entry.OrderIndex = len(existing.Entries) + 1
+		existing.Entries = append(existing.Entries, *entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortByDate(result.Workouts)
+	return result, nil
+}
+
+// Opens a CSV for import and reads its header, lower-cased. Files whose header is split by
+// semicolons, as spreadsheets in some locales save them, are read with that delimiter.
+func newImportReader(r io.Reader) (*csv.Reader, []string, error) {
+	buffered := bufio.NewReader(r)
+	firstLine, err := buffered.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	reader := csv.NewReader(io.MultiReader(strings.NewReader(firstLine), buffered))
+	reader.FieldsPerRecord = -1 // Short rows are reported per row instead of failing the file
+	reader.TrimLeadingSpace = true
+	if strings.Contains(firstLine, ";") && !strings.Contains(firstLine, ",") {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("%w: the file is empty", ErrInvalidMapping)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, name := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) // Spreadsheets like a BOM
+	}
+	reader.ReuseRecord = true
+
+	return reader, header, nil
+}
+
+// Calls fn with every non-blank row of reader, collecting the errors fn reports against the
+// line the row starts on
+func eachRow(reader *csv.Reader, columns map[string]int, result *ImportResult, fn func(row *csvRow)) error {
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				result.Errors = append(result.Errors, RowError{Row: parseErr.StartLine, Errors: []string{parseErr.Err.Error()}})
+				continue
+			}
+			return err
+		}
+		if isBlank(record) {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		result.Rows++
+		if result.Rows > MaxImportRows {
+			return ErrTooManyRows
+		}
+
+		row := csvRow{record: record, columns: columns}
+		fn(&row)
+		if len(row.errors) > 0 {
+			result.Errors = append(result.Errors, RowError{Row: line, Errors: row.errors})
+		}
+	}
+}
+
+// Oldest first, so records are detected in the order they were set
+func sortByDate(workouts []*Workout) {
+	sort.SliceStable(workouts, func(i, j int) bool {
+		return workouts[i].CreatedAt.Before(workouts[j].CreatedAt)
+	})
+}
+
+// Position of each field in the file, -1 when the file doesn't have it
+func resolveColumns(header []string, mapping ColumnMapping) (map[string]int, error) {
+	positions := headerPositions(header)
+	columns := map[string]int{}
+	for _, field := range CSVColumns {
+		source, mapped := mapping[field]
+		if !mapped {
+			source = field
+		}
+		position, ok := positions[strings.ToLower(strings.TrimSpace(source))]
+		if !ok {
+			if mapped {
+				return nil, fmt.Errorf("%w: column %q mapped to %s is not in the file", ErrInvalidMapping, source, field)
+			}
+			position = -1
+		}
+		columns[field] = position
+	}
+
+	for _, required := range []string{"date", "exercise_name"} {
+		if columns[required] < 0 {
+			return nil, fmt.Errorf("%w: no column for %s", ErrInvalidMapping, required)
+		}
+	}
+
+	return columns, nil
+}
+
+// Position of each column of a lower-cased header, the first one wins when names repeat
+func headerPositions(header []string) map[string]int {
+	positions := map[string]int{}
+	for i, name := range header {
+		if _, ok := positions[name]; !ok {
+			positions[name] = i
+		}
+	}
+	return positions
+}
+
+// A record being parsed, collecting every problem rather than stopping at the first
+type csvRow struct {
+	record  []string
+	columns map[string]int
+	errors  []string
+}
+
+func (row *csvRow) value(field string) string {
+	position, ok := row.columns[field]
+	if !ok || position < 0 || position >= len(row.record) {
+		return ""
+	}
+	return strings.TrimSpace(row.record[position])
+}
+
+func (row *csvRow) fail(format string, args ...any) {
+	row.errors = append(row.errors, fmt.Sprintf(format, args...))
+}
+
+// Parses an optional non-negative integer
+func (row *csvRow) int(field string) *int {
+	value := row.value(field)
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		row.fail("%s must be a non-negative integer, got %q", field, value)
+		return nil
+	}
+	return &n
+}
+
+// Parses an optional non-negative number
+func (row *csvRow) float(field string) *float64 {
+	value := row.value(field)
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		row.fail("%s must be a non-negative number, got %q", field, value)
+		return nil
+	}
+	return &n
+}
+
+// Parses the row into its workout, the entry and the key of the workout it belongs to
+func (row *csvRow) parse() (*Workout, *WorkoutEntry, string) {
+	workout := &Workout{
+		Title:       row.value("title"),
+		Description: row.value("description"),
+	}
+
+	date := row.value("date")
+	if date == "" {
+		row.fail("date is required")
+	} else {
+		parsed, err := parseImportDate(date)
+		if err != nil {
+			row.fail("date %q is not a date, use YYYY-MM-DD or RFC 3339", date)
+		}
+		workout.CreatedAt = parsed
+	}
+	if workout.Title == "" {
+		workout.Title = "Imported workout"
+	}
+	if duration := row.int("duration_minutes"); duration != nil {
+		workout.DurationMinutes = *duration
+	}
+	if calories := row.int("calories_burned"); calories != nil {
+		workout.CaloriesBurned = *calories
+	}
+
+	entry := &WorkoutEntry{
+		ExerciseName:    row.value("exercise_name"),
+		Sets:            1,
+		Reps:            row.int("reps"),
+		DurationSeconds: row.int("duration_seconds"),
+		Weight:          row.float("weight"),
+		Notes:           row.value("notes"),
+	}
+	if entry.ExerciseName == "" {
+		row.fail("exercise_name is required")
+	}
+	if sets := row.int("sets"); sets != nil {
+		entry.Sets = *sets
+	}
+	if (entry.Reps == nil) == (entry.DurationSeconds == nil) {
+		row.fail("exactly one of reps or duration_seconds is required")
+	}
+	if unit := row.value("weight_unit"); unit != "" {
+		parsed, err := units.ParseWeightUnit(unit)
+		if err != nil {
+			row.fail("%v", err)
+		}
+		entry.WeightUnit = parsed
+	}
+
+	key := row.value("workout_id")
+	if key == "" {
+		key = workout.CreatedAt.Format(time.RFC3339) + "\x00" + strings.ToLower(workout.Title)
+	}
+
+	return workout, entry, key
+}
+
+func parseImportDate(value string) (time.Time, error) {
+	var err error
+	for _, layout := range importDateLayouts {
+		var parsed time.Time
+		parsed, err = time.Parse(layout, value)
+		if err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func isCSVColumn(field string) bool {
+	for _, column := range CSVColumns {
+		if column == field {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func formatOptionalInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func formatOptionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
@@ -0,0 +1,111 @@
+package workouts
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/units"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSV(t *testing.T) {
+	file := `Date,Workout Name,Exercise,Reps,Seconds,Weight,Unit,Sets
+2024-03-01,Push,Bench Press,5,,100,kg,3
+2024-03-01,Push,Overhead Press,8,,95,lb,3
+
+2024-02-28,Legs,Back Squat,5,,140,,5
+2024-02-28,Legs,Plank,,60,,,
+not a date,Legs,,5,60,-1,st,x
+`
+	mapping := ColumnMapping{
+		"title":            "Workout Name",
+		"exercise_name":    "exercise",
+		"duration_seconds": "Seconds",
+		"weight_unit":      "Unit",
+	}
+
+	result, err := ParseCSV(strings.NewReader(file), mapping)
+	require.NoError(t, err)
+	assert.Equal(t, 5, result.Rows, "blank lines aren't rows")
+
+	// Oldest workout first, entries in file order
+	require.Len(t, result.Workouts, 2)
+	legs, push := result.Workouts[0], result.Workouts[1]
+	assert.Equal(t, "Legs", legs.Title)
+	assert.Equal(t, time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), legs.CreatedAt)
+	require.Len(t, legs.Entries, 2)
+	assert.Equal(t, 1, legs.Entries[1].Sets, "one set when the column is empty")
+	assert.Equal(t, 60, *legs.Entries[1].DurationSeconds)
+	assert.Equal(t, 2, legs.Entries[1].OrderIndex)
+
+	require.Len(t, push.Entries, 2)
+	assert.Equal(t, "Overhead Press", push.Entries[1].ExerciseName)
+	assert.Equal(t, units.Pounds, push.Entries[1].WeightUnit)
+	assert.Equal(t, 95.0, *push.Entries[1].Weight)
+
+	// Every problem of the bad row is reported against its line in the file
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 7, result.Errors[0].Row)
+	assert.Len(t, result.Errors[0].Errors, 6)
+}
+
+func TestParseCSVGroupsByWorkoutID(t *testing.T) {
+	file := "workout_id,date,title,exercise_name,sets,reps\n" +
+		"7,2024-03-01T07:30:00Z,AM,Pull Up,3,8\n" +
+		"8,2024-03-01T07:30:00Z,AM,Pull Up,3,8\n" +
+		"7,2024-03-01T07:30:00Z,AM,Dip,3,10\n"
+
+	result, err := ParseCSV(strings.NewReader(file), nil)
+	require.NoError(t, err)
+	require.Len(t, result.Workouts, 2)
+	assert.Len(t, result.Workouts[0].Entries, 2)
+	assert.Len(t, result.Workouts[1].Entries, 1)
+}
+
+func TestParseCSVMappingErrors(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("date,exercise_name\n"), ColumnMapping{"exercise": "Exercise"})
+	assert.ErrorIs(t, err, ErrInvalidMapping, "unknown field")
+
+	_, err = ParseCSV(strings.NewReader("date,exercise_name\n"), ColumnMapping{"reps": "Repetitions"})
+	assert.ErrorIs(t, err, ErrInvalidMapping, "mapped column missing from the file")
+
+	_, err = ParseCSV(strings.NewReader("date,title\n"), nil)
+	assert.ErrorIs(t, err, ErrInvalidMapping, "no exercise column")
+
+	_, err = ParseCSV(strings.NewReader(""), nil)
+	assert.ErrorIs(t, err, ErrInvalidMapping)
+}
+
+func TestEncodeCSVRowRoundTrip(t *testing.T) {
+	workout := &Workout{ID: 3, Title: "Push, heavy", CreatedAt: time.Date(2024, 3, 1, 7, 30, 0, 0, time.UTC)}
+	entry := &WorkoutEntry{ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(102.5),
+		WeightUnit: units.Kilograms, Notes: "paused\nfirst rep"}
+
+	var file strings.Builder
+	file.WriteString(strings.Join(CSVColumns, ",") + "\n")
+	row := EncodeCSVRow(workout, entry)
+	assert.Len(t, row, len(CSVColumns))
+	assert.Equal(t, "2024-03-01T07:30:00Z", row[1])
+	assert.Equal(t, "", row[9], "no duration")
+
+	// Quoted through encoding/csv so the comma and newline survive
+	result, err := ParseCSV(strings.NewReader(file.String()+csvLine(row)), nil)
+	require.NoError(t, err)
+	require.Empty(t, result.Errors)
+	require.Len(t, result.Workouts, 1)
+	assert.Equal(t, workout.Title, result.Workouts[0].Title)
+	assert.Equal(t, workout.CreatedAt, result.Workouts[0].CreatedAt)
+	assert.Equal(t, entry.Notes, result.Workouts[0].Entries[0].Notes)
+	assert.Equal(t, 102.5, *result.Workouts[0].Entries[0].Weight)
+}
+
+func csvLine(record []string) string {
+	var b strings.Builder
+	writer := csv.NewWriter(&b)
+	writer.Write(record)
+	writer.Flush()
+	return b.String()
+}
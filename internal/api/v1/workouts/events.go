@@ -0,0 +1,108 @@
+package workouts
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Josesx506/gofems/internal/api/v1/records"
+	"github.com/Josesx506/gofems/internal/outbox"
+)
+
+// The data of entry.added events
+type EntryAddedEvent struct {
+	WorkoutID int `json:"workout_id"`
+	WorkoutEntry
+}
+
+// What a workout held before an update, to tell which entries and records are new
+type savedState struct {
+	keptEntries map[int]bool // positions in the update of the entries it already held
+	records     map[string]bool
+}
+
+// Loads the saved state of workout before its entries are replaced, while the entries
+// of the update still carry the ids they were saved under. Entries without a saved id
+// are new wherever they were moved to.
+func loadSavedState(tx *sql.Tx, workout *Workout) (*savedState, error) {
+	state := &savedState{keptEntries: map[int]bool{}, records: map[string]bool{}}
+
+	rows, err := tx.Query(`SELECT id FROM workout_entries WHERE workout_id = $1`, workout.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	savedIDs := map[int]bool{}
+	for rows.Next() {
+		var entryID int
+		err := rows.Scan(&entryID)
+		if err != nil {
+			return nil, err
+		}
+		savedIDs[entryID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, entry := range workout.Entries {
+		state.keptEntries[i] = entry.ID != 0 && savedIDs[entry.ID]
+	}
+
+	rows, err = tx.Query(`SELECT exercise_name, record_type, value, weight FROM personal_records WHERE workout_id = $1`,
+		workout.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var record records.Record
+		err := rows.Scan(&record.ExerciseName, &record.RecordType, &record.Value, &record.Weight)
+		if err != nil {
+			return nil, err
+		}
+		state.records[recordKey(record)] = true
+	}
+
+	return state, rows.Err()
+}
+
+// Records are stored to two decimals, so values are compared at that precision
+func recordKey(record records.Record) string {
+	weight := ""
+	if record.Weight != nil {
+		weight = fmt.Sprintf("%.2f", *record.Weight)
+	}
+	return fmt.Sprintf("%s|%s|%.2f|%s", strings.ToLower(record.ExerciseName), record.RecordType, record.Value, weight)
+}
+
+// Writes the events of a saved workout to the outbox: the workout itself, then its new
+// entries and personal records. Everything is new when previous is nil.
+func writeWorkoutEvents(tx *sql.Tx, eventType string, workout *Workout, previous *savedState) error {
+	err := outbox.Write(tx, eventType, workout.UserID, workout.ID, workout)
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range workout.Entries {
+		if previous != nil && previous.keptEntries[i] {
+			continue
+		}
+		err = outbox.Write(tx, outbox.EntryAdded, workout.UserID, workout.ID,
+			EntryAddedEvent{WorkoutID: workout.ID, WorkoutEntry: entry})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, record := range workout.NewRecords {
+		if previous != nil && previous.records[recordKey(record)] {
+			continue
+		}
+		err = outbox.Write(tx, outbox.PRAchieved, workout.UserID, workout.ID, record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
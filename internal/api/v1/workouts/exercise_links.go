@@ -0,0 +1,52 @@
+package workouts
+
+import (
+	"github.com/Josesx506/gofems/internal/api/v1/exercises"
+)
+
+// How an entry's free-text name was resolved to a catalog exercise
+type ExerciseLink struct {
+	OrderIndex   int     `json:"order_index"`
+	ExerciseName string  `json:"exercise_name"`
+	ExerciseID   int     `json:"exercise_id"`
+	MatchedName  string  `json:"matched_name"`
+	Confidence   float64 `json:"confidence"`
+}
+
+// Entries that couldn't be matched above the confidence threshold
+type UnresolvedExercise struct {
+	OrderIndex   int    `json:"order_index"`
+	ExerciseName string `json:"exercise_name"`
+}
+
+// linkExercises sets the exercise_id of entries that don't have one to their
+// best fuzzy match in the catalog when it scores at least threshold.
+func linkExercises(workout *Workout, catalog []exercises.Exercise, threshold float64) ([]ExerciseLink, []UnresolvedExercise) {
+	links := []ExerciseLink{}
+	unresolved := []UnresolvedExercise{}
+
+	for i := range workout.Entries {
+		entry := &workout.Entries[i]
+		if entry.ExerciseID != nil {
+			continue
+		}
+
+		match := exercises.BestMatch(entry.ExerciseName, catalog, threshold)
+		if match == nil {
+			unresolved = append(unresolved, UnresolvedExercise{OrderIndex: entry.OrderIndex, ExerciseName: entry.ExerciseName})
+			continue
+		}
+
+		exerciseID := match.Exercise.ID
+		entry.ExerciseID = &exerciseID
+		links = append(links, ExerciseLink{
+			OrderIndex:   entry.OrderIndex,
+			ExerciseName: entry.ExerciseName,
+			ExerciseID:   exerciseID,
+			MatchedName:  match.MatchedName,
+			Confidence:   match.Score,
+		})
+	}
+
+	return links, unresolved
+}
@@ -0,0 +1,133 @@
+package workouts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Entries performed back-to-back for a number of rounds. Entries join a group
+// through their group_id, which numbers the groups within the workout.
+type EntryGroup struct {
+	ID                       int            `json:"-"`
+	GroupID                  int            `json:"group_id"`
+	GroupType                string         `json:"group_type"`
+	Rounds                   int            `json:"rounds"` // defaults to 1, the minutes of an EMOM
+	RestBetweenRoundsSeconds *int           `json:"rest_between_rounds_seconds"`
+	DurationSeconds          *int           `json:"duration_seconds"`  // time cap, required for an AMRAP
+	Entries                  []WorkoutEntry `json:"entries,omitempty"` // Members in order, only filled in responses
+}
+
+const (
+	GroupSuperset = "superset"
+	GroupCircuit  = "circuit"
+	GroupEMOM     = "emom"
+	GroupAMRAP    = "amrap"
+)
+
+var ErrInvalidGroup = errors.New("invalid entry group")
+
+func (g *EntryGroup) Validate() error {
+	if g.GroupID <= 0 {
+		return fmt.Errorf("%w: group_id must be positive", ErrInvalidGroup)
+	}
+
+	switch g.GroupType {
+	case GroupSuperset, GroupCircuit, GroupEMOM, GroupAMRAP:
+	default:
+		return fmt.Errorf("%w %d: group_type must be superset, circuit, emom or amrap", ErrInvalidGroup, g.GroupID)
+	}
+
+	if g.Rounds < 1 {
+		return fmt.Errorf("%w %d: rounds must be at least 1", ErrInvalidGroup, g.GroupID)
+	}
+	if g.RestBetweenRoundsSeconds != nil && *g.RestBetweenRoundsSeconds < 0 {
+		return fmt.Errorf("%w %d: rest_between_rounds_seconds can't be negative", ErrInvalidGroup, g.GroupID)
+	}
+	if g.GroupType == GroupAMRAP && (g.DurationSeconds == nil || *g.DurationSeconds <= 0) {
+		return fmt.Errorf("%w %d: an amrap needs a positive duration_seconds", ErrInvalidGroup, g.GroupID)
+	}
+
+	return nil
+}
+
+// validateGroups checks the groups of a workout against its entries before they're saved.
+// Every group needs members, a superset at least two, and members must follow one another
+// in order_index order since they're performed back-to-back.
+func validateGroups(workout *Workout) error {
+	groups := map[int]*EntryGroup{}
+	for i := range workout.Groups {
+		group := &workout.Groups[i]
+		group.Entries = nil // Nested entries are output only
+		if group.Rounds == 0 {
+			group.Rounds = 1
+		}
+
+		err := group.Validate()
+		if err != nil {
+			return err
+		}
+		if groups[group.GroupID] != nil {
+			return fmt.Errorf("%w %d: group_id must be unique", ErrInvalidGroup, group.GroupID)
+		}
+		groups[group.GroupID] = group
+	}
+
+	entries := make([]WorkoutEntry, len(workout.Entries))
+	copy(entries, workout.Entries)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].OrderIndex < entries[j].OrderIndex })
+
+	members := map[int]int{}
+	finished := map[int]bool{}
+	previous := 0
+	for _, entry := range entries {
+		groupID := 0
+		if entry.GroupID != nil {
+			groupID = *entry.GroupID
+			if groups[groupID] == nil {
+				return fmt.Errorf("%w %d: entry %q references an undeclared group", ErrInvalidGroup, groupID, entry.ExerciseName)
+			}
+			if finished[groupID] {
+				return fmt.Errorf("%w %d: grouped entries must be consecutive", ErrInvalidGroup, groupID)
+			}
+			members[groupID]++
+		}
+		if previous != 0 && previous != groupID {
+			finished[previous] = true
+		}
+		previous = groupID
+	}
+
+	for _, group := range workout.Groups {
+		switch {
+		case members[group.GroupID] == 0:
+			return fmt.Errorf("%w %d: group has no entries", ErrInvalidGroup, group.GroupID)
+		case group.GroupType == GroupSuperset && members[group.GroupID] < 2:
+			return fmt.Errorf("%w %d: a superset needs at least two entries", ErrInvalidGroup, group.GroupID)
+		}
+	}
+
+	return nil
+}
+
+// nestGroups orders the groups by their first entry and nests their members for responses
+func nestGroups(workout *Workout) {
+	first := map[int]int{}
+	for i := range workout.Groups {
+		group := &workout.Groups[i]
+		group.Entries = []WorkoutEntry{}
+		for _, entry := range workout.Entries {
+			if entry.GroupID == nil || *entry.GroupID != group.GroupID {
+				continue
+			}
+			if len(group.Entries) == 0 {
+				first[group.GroupID] = entry.OrderIndex
+			}
+			group.Entries = append(group.Entries, entry)
+		}
+	}
+
+	sort.SliceStable(workout.Groups, func(i, j int) bool {
+		return first[workout.Groups[i].GroupID] < first[workout.Groups[j].GroupID]
+	})
+}
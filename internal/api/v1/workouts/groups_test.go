@@ -0,0 +1,91 @@
+package workouts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateGroups(t *testing.T) {
+	groupedWorkout := func(groups []EntryGroup, groupIDs ...*int) *Workout {
+		workout := &Workout{Title: "HIIT", Groups: groups}
+		for i, groupID := range groupIDs {
+			workout.Entries = append(workout.Entries, WorkoutEntry{
+				ExerciseName: "Burpee", Sets: 1, Reps: IntPtr(10), OrderIndex: i + 1, GroupID: groupID,
+			})
+		}
+		return workout
+	}
+
+	valid := groupedWorkout([]EntryGroup{
+		{GroupID: 1, GroupType: GroupSuperset, Rounds: 3, RestBetweenRoundsSeconds: IntPtr(90)},
+		{GroupID: 2, GroupType: GroupAMRAP, DurationSeconds: IntPtr(600)},
+	}, nil, IntPtr(1), IntPtr(1), IntPtr(2), nil)
+	require.NoError(t, validateGroups(valid))
+	assert.Equal(t, 1, valid.Groups[1].Rounds, "rounds default to one")
+
+	tests := []struct {
+		name    string
+		workout *Workout
+	}{
+		{
+			name:    "Unknown group type",
+			workout: groupedWorkout([]EntryGroup{{GroupID: 1, GroupType: "tabata"}}, IntPtr(1)),
+		},
+		{
+			name:    "AMRAP without a time cap",
+			workout: groupedWorkout([]EntryGroup{{GroupID: 1, GroupType: GroupAMRAP}}, IntPtr(1)),
+		},
+		{
+			name:    "Undeclared group",
+			workout: groupedWorkout(nil, IntPtr(1)),
+		},
+		{
+			name:    "Empty group",
+			workout: groupedWorkout([]EntryGroup{{GroupID: 1, GroupType: GroupCircuit}}, nil),
+		},
+		{
+			name:    "Superset of one",
+			workout: groupedWorkout([]EntryGroup{{GroupID: 1, GroupType: GroupSuperset}}, IntPtr(1), nil),
+		},
+		{
+			name: "Interrupted group",
+			workout: groupedWorkout([]EntryGroup{{GroupID: 1, GroupType: GroupCircuit}},
+				IntPtr(1), nil, IntPtr(1)),
+		},
+		{
+			name: "Duplicate group id",
+			workout: groupedWorkout([]EntryGroup{{GroupID: 1, GroupType: GroupCircuit}, {GroupID: 1, GroupType: GroupEMOM}},
+				IntPtr(1)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, validateGroups(tt.workout), ErrInvalidGroup)
+		})
+	}
+}
+
+func TestNestGroups(t *testing.T) {
+	workout := &Workout{
+		Groups: []EntryGroup{
+			{GroupID: 1, GroupType: GroupCircuit},
+			{GroupID: 2, GroupType: GroupSuperset},
+		},
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bench Press", OrderIndex: 1, GroupID: IntPtr(2)},
+			{ExerciseName: "Barbell Row", OrderIndex: 2, GroupID: IntPtr(2)},
+			{ExerciseName: "Plank", OrderIndex: 3},
+			{ExerciseName: "Burpee", OrderIndex: 4, GroupID: IntPtr(1)},
+		},
+	}
+
+	nestGroups(workout)
+	require.Len(t, workout.Groups, 2)
+	assert.Equal(t, 2, workout.Groups[0].GroupID, "groups follow the order of their first entry")
+	require.Len(t, workout.Groups[0].Entries, 2)
+	assert.Equal(t, "Barbell Row", workout.Groups[0].Entries[1].ExerciseName)
+	assert.Len(t, workout.Groups[1].Entries, 1)
+}
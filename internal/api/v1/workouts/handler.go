@@ -2,23 +2,43 @@ package workouts
 
 import (
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
 
+	"github.com/Josesx506/gofems/internal/activity"
+	"github.com/Josesx506/gofems/internal/api/v1/exercises"
+	"github.com/Josesx506/gofems/internal/api/v1/records"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
+	"github.com/Josesx506/gofems/internal/units"
 	"github.com/Josesx506/gofems/internal/utils"
 )
 
 type WorkoutHandler struct {
-	store  WorkoutStore
-	logger *log.Logger
+	store         WorkoutStore
+	exerciseStore exercises.ExerciseStore
+	userStore     users.UserStore
+	logger        *log.Logger
 }
 
-// Accepts a WorkoutStore interface to interact with the db layer
-func NewWorkoutHandler(store WorkoutStore, logger *log.Logger) *WorkoutHandler {
+// Accepts a WorkoutStore interface to interact with the db layer. The exercise
+// store provides the catalog used to auto-link entries by name and the user
+// store the unit weights are returned in.
+func NewWorkoutHandler(store WorkoutStore, exerciseStore exercises.ExerciseStore, userStore users.UserStore,
+	logger *log.Logger) *WorkoutHandler {
 	return &WorkoutHandler{
-		store:  store,
-		logger: logger,
+		store:         store,
+		exerciseStore: exerciseStore,
+		userStore:     userStore,
+		logger:        logger,
 	}
 }
 
@@ -37,6 +57,10 @@ func (wh *WorkoutHandler) HandleGetWorkoutByID(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if workout != nil && !wh.convertWeights(w, r, workout) {
+		return
+	}
+
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
 }
 
@@ -51,14 +75,51 @@ func (wh *WorkoutHandler) HandleCreateWorkout(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	unit, ok := wh.outputUnit(w, r, workout.UserID)
+	if !ok {
+		return
+	}
+
+	// ?auto_link=true links entries to their closest catalog exercise when the
+	// match confidence reaches ?min_confidence (defaults to exercises.DefaultMatchThreshold)
+	response := utils.Envelope{}
+	if r.URL.Query().Get("auto_link") == "true" {
+		threshold := exercises.DefaultMatchThreshold
+		if param := r.URL.Query().Get("min_confidence"); param != "" {
+			threshold, err = strconv.ParseFloat(param, 64)
+			if err != nil || threshold <= 0 || threshold > 1 {
+				utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "min_confidence must be in (0, 1]"}) // 400
+				return
+			}
+		}
+
+		catalog, err := wh.exerciseStore.ListExercises(int64(workout.UserID), "")
+		if err != nil {
+			wh.logger.Printf("Error listExercises: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create workout"}) // 500
+			return
+		}
+
+		links, unresolved := linkExercises(&workout, catalog, threshold)
+		response["linked_exercises"] = links
+		response["unresolved_exercises"] = unresolved
+	}
+
 	createdWorkout, err := wh.store.CreateWorkout(&workout)
+	if wh.writeConflict(w, err) {
+		return
+	}
+
 	if err != nil {
 		wh.logger.Printf("Error createWorkout: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create workout"}) // 500
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": createdWorkout})
+	createdWorkout.ConvertWeights(unit)
+	response["workout"] = createdWorkout
+	response["new_records"] = createdWorkout.NewRecords
+	utils.WriteJSON(w, http.StatusCreated, response)
 }
 
 func (wh *WorkoutHandler) HandleUpdateWorkoutByID(w http.ResponseWriter, r *http.Request) {
@@ -93,14 +154,24 @@ func (wh *WorkoutHandler) HandleUpdateWorkoutByID(w http.ResponseWriter, r *http
 
 	workout.ID = int(workoutID)
 
+	unit, ok := wh.outputUnit(w, r, existingWorkout.UserID)
+	if !ok {
+		return
+	}
+
 	err = wh.store.UpdateWorkout(&workout)
+	if wh.writeConflict(w, err) {
+		return
+	}
+
 	if err != nil {
 		wh.logger.Printf("Error updateWorkout: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update workout"}) // 500
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
+	workout.ConvertWeights(unit)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout, "new_records": workout.NewRecords})
 }
 
 func (wh *WorkoutHandler) HandleDeleteWorkoutByID(w http.ResponseWriter, r *http.Request) {
@@ -111,6 +182,12 @@ func (wh *WorkoutHandler) HandleDeleteWorkoutByID(w http.ResponseWriter, r *http
 		return
 	}
 
+	// Deletes are soft by default, ?permanent=true purges a workout of the caller's trash
+	if r.URL.Query().Get("permanent") == "true" {
+		users.RequireUser(wh.userStore)(http.HandlerFunc(wh.handlePurgeWorkout)).ServeHTTP(w, r)
+		return
+	}
+
 	err = wh.store.DeleteWorkout(workoutID)
 	if err == sql.ErrNoRows {
 		http.Error(w, "Workout not found", http.StatusNotFound)
@@ -125,3 +202,586 @@ func (wh *WorkoutHandler) HandleDeleteWorkoutByID(w http.ResponseWriter, r *http
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (wh *WorkoutHandler) handlePurgeWorkout(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		wh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	err = wh.store.PurgeWorkout(workoutID, int64(users.CurrentUser(r).ID))
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found in trash"}) // 404
+		return
+	}
+
+	if err != nil {
+		wh.logger.Printf("Error purgeWorkout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete workout"}) // 500
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists the caller's trash
+func (wh *WorkoutHandler) HandleListTrash(w http.ResponseWriter, r *http.Request) {
+	workouts, err := wh.store.ListDeletedWorkouts(int64(users.CurrentUser(r).ID))
+	if err != nil {
+		wh.logger.Printf("Error listDeletedWorkouts: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to list trash"}) // 500
+		return
+	}
+
+	for i := range workouts {
+		if !wh.convertWeights(w, r, &workouts[i]) {
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workouts": workouts})
+}
+
+func (wh *WorkoutHandler) HandleRestoreWorkoutByID(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		wh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	err = wh.store.RestoreWorkout(workoutID, int64(users.CurrentUser(r).ID))
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found in trash"}) // 404
+		return
+	}
+
+	if wh.writeConflict(w, err) {
+		return
+	}
+
+	if err != nil {
+		wh.logger.Printf("Error restoreWorkout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to restore workout"}) // 500
+		return
+	}
+
+	workout, err := wh.store.GetWorkoutByID(workoutID)
+	if err != nil {
+		wh.logger.Printf("Error getWorkoutByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	// Purged again in the meantime
+	if workout == nil {
+		http.Error(w, "Workout not found", http.StatusNotFound)
+		return
+	}
+
+	if !wh.convertWeights(w, r, workout) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
+}
+
+func (wh *WorkoutHandler) HandleListWorkoutRevisions(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		wh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	revisions, err := wh.store.ListWorkoutRevisions(workoutID)
+	if err != nil {
+		wh.logger.Printf("Error listWorkoutRevisions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if len(revisions) == 0 {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"}) // 404
+		return
+	}
+
+	unit, ok := wh.outputUnit(w, r, revisions[0].Snapshot.UserID)
+	if !ok {
+		return
+	}
+	for i := range revisions {
+		revisions[i].Snapshot.ConvertWeights(unit)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"revisions": revisions})
+}
+
+// Compares two revisions given as ?from=<rev>&to=<rev>
+func (wh *WorkoutHandler) HandleDiffWorkoutRevisions(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		wh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	fromRev, errFrom := strconv.Atoi(r.URL.Query().Get("from"))
+	toRev, errTo := strconv.Atoi(r.URL.Query().Get("to"))
+	if errFrom != nil || errTo != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "from and to revisions are required"}) // 400
+		return
+	}
+
+	from, err := wh.store.GetWorkoutRevision(workoutID, fromRev)
+	if err != nil {
+		wh.logger.Printf("Error getWorkoutRevision: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	to, err := wh.store.GetWorkoutRevision(workoutID, toRev)
+	if err != nil {
+		wh.logger.Printf("Error getWorkoutRevision: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	if from == nil || to == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "revision not found"}) // 404
+		return
+	}
+
+	unit, ok := wh.outputUnit(w, r, to.Snapshot.UserID)
+	if !ok {
+		return
+	}
+	from.Snapshot.ConvertWeights(unit)
+	to.Snapshot.ConvertWeights(unit)
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"diff": DiffRevisions(from, to)})
+}
+
+func (wh *WorkoutHandler) HandleRevertWorkout(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		wh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	revision, err := utils.ReadInt64Param(r, "rev")
+	if err != nil {
+		wh.logger.Printf("Error reading rev param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid revision"})
+		return
+	}
+
+	workout, err := wh.store.RevertWorkout(workoutID, int(revision))
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "revision not found"}) // 404
+		return
+	}
+
+	if wh.writeConflict(w, err) {
+		return
+	}
+
+	if err != nil {
+		wh.logger.Printf("Error revertWorkout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to revert workout"}) // 500
+		return
+	}
+
+	if !wh.convertWeights(w, r, workout) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
+}
+
+// Streams the caller's live workouts as CSV, one row per entry, between the inclusive
+// ?from= and ?to= dates when given. Weights are in ?units= or the caller's preferred unit.
+func (wh *WorkoutHandler) HandleExportCSV(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := ExportFilter{UserID: int64(users.CurrentUser(r).ID)}
+	if param := params.Get("from"); param != "" {
+		from, err := time.Parse(time.DateOnly, param)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "from must be a YYYY-MM-DD date"}) // 400
+			return
+		}
+		filter.From = &from
+	}
+	if param := params.Get("to"); param != "" {
+		to, err := time.Parse(time.DateOnly, param)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "to must be a YYYY-MM-DD date"}) // 400
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+
+	unit, ok := wh.outputUnit(w, r, int(filter.UserID))
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="workouts.csv"`)
+	writer := csv.NewWriter(w)
+	writer.Write(CSVColumns)
+
+	// The status is sent with the first row, a failure past that point can only cut the file short
+	err := wh.store.ExportEntries(filter, func(workout *Workout, entry *WorkoutEntry) error {
+		convertEntryWeights(entry, unit)
+		return writer.Write(EncodeCSVRow(workout, entry))
+	})
+	if err != nil {
+		wh.logger.Printf("Error exportEntries: %v", err)
+		return
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		wh.logger.Printf("Error writing export: %v", err)
+	}
+}
+
+// Imports workouts for the caller from a CSV upload. The file is sent either as the body with a
+// text/csv content type, or as the "file" part of a multipart form whose optional "mapping"
+// part, sent before the file, holds the column mapping as JSON. Text bodies take the mapping
+// from ?mapping=. ?format= picks our own layout or a Strong, Hevy or FitNotes export, it's
+// detected from the header otherwise; ?weight_unit= and ?distance_unit= give the units of
+// files that don't record them. Workouts already logged are skipped unless
+// ?duplicates=import. Nothing is stored when any row is invalid; ?dry_run=true only validates.
+func (wh *WorkoutHandler) HandleImportCSV(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	userID := int64(users.CurrentUser(r).ID)
+	dryRun := params.Get("dry_run") == "true"
+	importDuplicates := params.Get("duplicates") == "import"
+
+	format, err := ParseFormat(params.Get("format"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	var options ImportOptions
+	if param := params.Get("weight_unit"); param != "" {
+		options.WeightUnit, err = units.ParseWeightUnit(param)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+			return
+		}
+	}
+	options.DistanceUnit, err = units.ParseDistanceUnit(params.Get("distance_unit"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	result, err := readImport(r, format, options)
+	if errors.Is(err, ErrInvalidMapping) || errors.Is(err, ErrTooManyRows) || errors.Is(err, ErrUnknownFormat) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		utils.WriteJSON(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "file too large"}) // 413
+		return
+	}
+
+	if err != nil {
+		wh.logger.Printf("Error reading import: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	found, err := wh.store.FindDuplicateWorkouts(userID, result.Workouts)
+	if err != nil {
+		wh.logger.Printf("Error findDuplicateWorkouts: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to import workouts"}) // 500
+		return
+	}
+
+	duplicates := []DuplicateWorkout{}
+	workouts := []*Workout{}
+	for i, workout := range result.Workouts {
+		workout.UserID = int(userID)
+		if existingID, ok := found[i]; ok {
+			duplicates = append(duplicates, DuplicateWorkout{Title: workout.Title, Date: workout.CreatedAt,
+				ExistingWorkoutID: existingID})
+			if !importDuplicates {
+				continue
+			}
+		}
+		workouts = append(workouts, workout)
+	}
+
+	report := utils.Envelope{
+		"dry_run":    dryRun,
+		"format":     result.Format,
+		"rows":       result.Rows,
+		"duplicates": duplicates,
+		"errors":     result.Errors,
+	}
+	for key, count := range countImport(workouts) {
+		report[key] = count
+	}
+
+	if dryRun {
+		utils.WriteJSON(w, http.StatusOK, report)
+		return
+	}
+
+	if len(result.Errors) > 0 {
+		utils.WriteJSON(w, http.StatusUnprocessableEntity, report) // 422
+		return
+	}
+
+	err = wh.store.ImportWorkouts(workouts)
+	if wh.writeConflict(w, err) {
+		return
+	}
+
+	if err != nil {
+		wh.logger.Printf("Error importWorkouts: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to import workouts"}) // 500
+		return
+	}
+
+	workoutIDs := make([]int, 0, len(workouts))
+	newRecords := []records.Record{}
+	for _, workout := range workouts {
+		workoutIDs = append(workoutIDs, workout.ID)
+		newRecords = append(newRecords, workout.NewRecords...)
+	}
+	report["workout_ids"] = workoutIDs
+	report["new_records"] = newRecords
+	utils.WriteJSON(w, http.StatusCreated, report)
+}
+
+// Uploads are streamed through the parser, only the parsed workouts are held in memory
+const maxImportBytes = 10 << 20
+
+func readImport(r *http.Request, format Format, options ImportOptions) (*ImportResult, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		mapping := ColumnMapping{}
+		if param := r.URL.Query().Get("mapping"); param != "" {
+			err := json.Unmarshal([]byte(param), &mapping)
+			if err != nil {
+				return nil, fmt.Errorf("%w: mapping must be a JSON object of field to column", ErrInvalidMapping)
+			}
+		}
+		return ParseImport(r.Body, format, mapping, options)
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := ColumnMapping{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: the file part is missing", ErrInvalidMapping)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch part.FormName() {
+		case "mapping":
+			err = json.NewDecoder(part).Decode(&mapping)
+			if err != nil {
+				return nil, fmt.Errorf("%w: mapping must be a JSON object of field to column", ErrInvalidMapping)
+			}
+		case "file":
+			return ParseImport(part, format, mapping, options)
+		}
+	}
+}
+
+// Creates a workout for the caller from a GPX, TCX or FIT file, sent as the body or as the
+// "file" part of a multipart form. ?format= is taken from the file name or the content when
+// omitted and ?sport= from the file, running when the file doesn't say. Calories are
+// estimated for ?body_weight= in kilograms, 70 by default. Uploading an activity already
+// logged is a conflict unless ?duplicates=import.
+func (wh *WorkoutHandler) HandleUploadActivity(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	userID := int64(users.CurrentUser(r).ID)
+
+	var format activity.Format
+	var sport activity.Sport
+	var err error
+	if param := params.Get("format"); param != "" {
+		format, err = activity.ParseFormat(param)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+			return
+		}
+	}
+	if param := params.Get("sport"); param != "" {
+		sport, err = activity.ParseSport(param)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+			return
+		}
+	}
+	bodyWeight := defaultBodyWeightKg
+	if param := params.Get("body_weight"); param != "" {
+		bodyWeight, err = strconv.ParseFloat(param, 64)
+		if err != nil || bodyWeight <= 0 || bodyWeight > 500 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "body_weight must be in kilograms"}) // 400
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxActivityBytes)
+	recorded, err := readActivity(r, format)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		utils.WriteJSON(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "file too large"}) // 413
+		return
+	case errors.Is(err, activity.ErrUnknownFormat), errors.Is(err, activity.ErrInvalidFile),
+		errors.Is(err, activity.ErrNoTrack):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+		return
+	case err != nil:
+		wh.logger.Printf("Error reading activity: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"}) // 400
+		return
+	}
+
+	if sport == "" {
+		sport = recorded.Sport
+	}
+	if sport == "" {
+		sport = activity.Running
+	}
+	summary := activity.Summarize(recorded.Points, sport, bodyWeight)
+	workout := NewActivityWorkout(recorded, sport, summary, params.Get("title"))
+	workout.UserID = int(userID)
+
+	if params.Get("duplicates") != "import" {
+		existingID, err := wh.store.FindDuplicateActivity(userID, summary)
+		if err != nil {
+			wh.logger.Printf("Error findDuplicateActivity: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to upload activity"}) // 500
+			return
+		}
+		if existingID != 0 {
+			utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "activity already logged",
+				"existing_workout_id": existingID}) // 409
+			return
+		}
+	}
+
+	err = wh.store.CreateActivityWorkout(workout, recorded.Points)
+	if wh.writeConflict(w, err) {
+		return
+	}
+
+	if err != nil {
+		wh.logger.Printf("Error createActivityWorkout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to upload activity"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{
+		"workout":      workout,
+		"summary":      summary,
+		"sport":        sport,
+		"track_points": len(recorded.Points),
+		"new_records":  workout.NewRecords,
+	})
+}
+
+func (wh *WorkoutHandler) HandleGetTrack(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		wh.logger.Printf("Error reading ID param: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	points, err := wh.store.ListTrackPoints(workoutID)
+	if err != nil {
+		wh.logger.Printf("Error listTrackPoints: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"}) // 500
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"track_points": points})
+}
+
+// Calories are estimated for this body weight when the upload doesn't give one
+const defaultBodyWeightKg = 70.0
+
+// A few hours of one second samples, FIT files are far smaller than the XML formats
+const maxActivityBytes = 25 << 20
+
+func readActivity(r *http.Request, format activity.Format) (*activity.Activity, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return activity.Parse(r.Body, format)
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: the file part is missing", activity.ErrInvalidFile)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == "file" {
+			if format == "" {
+				format, _ = activity.ParseFormat(filepath.Ext(part.FileName()))
+			}
+			return activity.Parse(part, format)
+		}
+	}
+}
+
+// Writes a client error for uniqueness and ownership violations reported by the store
+func (wh *WorkoutHandler) writeConflict(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, ErrDuplicateSlug), errors.Is(err, ErrRevisionConflict):
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()}) // 409
+	case errors.Is(err, ErrUnknownUser), errors.Is(err, ErrUnknownExercise), errors.Is(err, ErrInvalidSet),
+		errors.Is(err, ErrInvalidGroup), errors.Is(err, ErrInvalidCardio), errors.Is(err, ErrInvalidWeightUnit),
+		errors.Is(err, ErrInvalidSlug), errors.Is(err, ErrInvalidTitle):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()}) // 400
+	default:
+		return false
+	}
+	return true
+}
+
+// Weights are returned in ?units= when given, otherwise in the owner's preferred unit
+func (wh *WorkoutHandler) outputUnit(w http.ResponseWriter, r *http.Request, userID int) (units.WeightUnit, bool) {
+	return users.ReadWeightUnit(w, r, wh.userStore, int64(userID), wh.logger)
+}
+
+func (wh *WorkoutHandler) convertWeights(w http.ResponseWriter, r *http.Request, workout *Workout) bool {
+	unit, ok := wh.outputUnit(w, r, workout.UserID)
+	if ok {
+		workout.ConvertWeights(unit)
+	}
+	return ok
+}
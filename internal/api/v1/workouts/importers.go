@@ -0,0 +1,540 @@
+package workouts
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/units"
+)
+
+// Layout of an imported CSV. FormatCSV is our own export, the others are the exports of
+// the lifting apps users migrate from.
+type Format string
+
+const (
+	FormatAuto     Format = "" // Detected from the header
+	FormatCSV      Format = "csv"
+	FormatStrong   Format = "strong"
+	FormatHevy     Format = "hevy"
+	FormatFitNotes Format = "fitnotes"
+)
+
+var ErrUnknownFormat = errors.New("unknown import format")
+
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(name))); format {
+	case FormatAuto, FormatCSV, FormatStrong, FormatHevy, FormatFitNotes:
+		return format, nil
+	}
+	return "", fmt.Errorf("%w %q: use csv, strong, hevy or fitnotes", ErrUnknownFormat, name)
+}
+
+// Units of files that don't record them. Strong exports weights and distances in whatever
+// the app was set to, empty units fall back to the owner's preference and kilometers.
+type ImportOptions struct {
+	WeightUnit   units.WeightUnit
+	DistanceUnit units.DistanceUnit
+}
+
+// ParseImport reads workouts from a CSV in format, detecting it from the header when
+// FormatAuto. The mapping only applies to FormatCSV. App exports have a row per set;
+// consecutive rows of an exercise become one entry with its sets_detail.
+func ParseImport(r io.Reader, format Format, mapping ColumnMapping, options ImportOptions) (*ImportResult, error) {
+	reader, header, err := newImportReader(r)
+	if err != nil {
+		return nil, err
+	}
+	if options.DistanceUnit == "" {
+		options.DistanceUnit = units.Kilometers
+	}
+
+	positions := headerPositions(header)
+	if format == FormatAuto {
+		format = detectFormat(positions, mapping)
+	}
+
+	var parse func(row *csvRow) *setRow
+	switch format {
+	case FormatCSV:
+		result, err := parseCSVRows(reader, header, mapping)
+		if err != nil {
+			return nil, err
+		}
+		result.Format = FormatCSV
+		for _, workout := range result.Workouts {
+			for i := range workout.Entries {
+				if workout.Entries[i].WeightUnit == "" {
+					workout.Entries[i].WeightUnit = options.WeightUnit
+				}
+			}
+		}
+		return result, nil
+	case FormatStrong:
+		parse = parseStrongRow(positions, options)
+	case FormatHevy:
+		parse = parseHevyRow(positions)
+	case FormatFitNotes:
+		parse = parseFitNotesRow(positions, options)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+
+	for _, required := range requiredColumns[format] {
+		if _, ok := positions[required]; !ok {
+			return nil, fmt.Errorf("%w: a %s export needs a %q column", ErrInvalidMapping, format, required)
+		}
+	}
+
+	result := &ImportResult{Format: format, Errors: []RowError{}}
+	builder := newWorkoutBuilder()
+	err = eachRow(reader, positions, result, func(row *csvRow) {
+		set := parse(row)
+		if len(row.errors) == 0 && set != nil {
+			builder.add(set)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Workouts = builder.build()
+	sortByDate(result.Workouts)
+	return result, nil
+}
+
+// A workout of an import that matches one already logged
+type DuplicateWorkout struct {
+	Title             string    `json:"title"`
+	Date              time.Time `json:"date"`
+	ExistingWorkoutID int       `json:"existing_workout_id"`
+}
+
+// Workouts, entries and sets about to be imported, for the import summary
+func countImport(workouts []*Workout) map[string]int {
+	counts := map[string]int{"workouts": len(workouts), "entries": 0, "sets": 0}
+	for _, workout := range workouts {
+		counts["entries"] += len(workout.Entries)
+		for _, entry := range workout.Entries {
+			if len(entry.SetsDetail) > 0 {
+				counts["sets"] += len(entry.SetsDetail)
+			} else {
+				counts["sets"] += entry.Sets
+			}
+		}
+	}
+	return counts
+}
+
+// Columns an export can't be read without, named as in the app's header
+var requiredColumns = map[Format][]string{
+	FormatStrong:   {"date", "workout name", "exercise name", "set order"},
+	FormatHevy:     {"title", "start_time", "exercise_title", "set_index"},
+	FormatFitNotes: {"date", "exercise", "reps"},
+}
+
+// Recognizes the apps by columns only their exports have, anything else is our own layout
+func detectFormat(positions map[string]int, mapping ColumnMapping) Format {
+	if len(mapping) > 0 {
+		return FormatCSV
+	}
+
+	has := func(columns ...string) bool {
+		for _, column := range columns {
+			if _, ok := positions[column]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case has("exercise name", "set order"):
+		return FormatStrong
+	case has("exercise_title", "set_index"):
+		return FormatHevy
+	case has("exercise", "category"):
+		return FormatFitNotes
+	}
+	return FormatCSV
+}
+
+// One set as read from an app export, with the workout and exercise it belongs to
+type setRow struct {
+	workoutKey      string
+	title           string
+	description     string
+	startedAt       time.Time
+	durationMinutes int
+	exerciseName    string
+	notes           string
+	supersetKey     string // Rows of the workout sharing it were performed as a superset
+
+	setType         string
+	reps            float64
+	durationSeconds float64
+	weight          float64
+	weightUnit      units.WeightUnit
+	distance        float64
+	distanceUnit    units.DistanceUnit
+	rpe             *float64
+}
+
+// Strong: Date, Workout Name, Duration, Exercise Name, Set Order, Weight, Reps, Distance,
+// Seconds, Notes, Workout Notes and RPE. Older exports add Weight Unit and Distance Unit
+// and call the duration Workout Duration. Set Order numbers working sets and marks warmups,
+// drops and failures with W, D and F; rest timer rows carry no set.
+func parseStrongRow(positions map[string]int, options ImportOptions) func(row *csvRow) *setRow {
+	durationColumn := "duration"
+	if _, ok := positions[durationColumn]; !ok {
+		durationColumn = "workout duration"
+	}
+
+	return func(row *csvRow) *setRow {
+		order := strings.ToUpper(row.value("set order"))
+		if strings.HasPrefix(order, "REST") {
+			return nil
+		}
+
+		set := &setRow{
+			title:        row.value("workout name"),
+			description:  row.value("workout notes"),
+			exerciseName: row.value("exercise name"),
+			notes:        row.value("notes"),
+			setType:      SetWorking,
+			weightUnit:   options.WeightUnit,
+			distanceUnit: options.DistanceUnit,
+		}
+		switch order {
+		case "W":
+			set.setType = SetWarmup
+		case "D":
+			set.setType = SetDrop
+		case "F":
+			set.setType = SetFailure
+		}
+
+		set.startedAt = row.date("date", "2006-01-02 15:04:05", "2006-01-02 15:04", time.RFC3339)
+		set.workoutKey = set.startedAt.Format(time.RFC3339) + "\x00" + set.title
+		set.durationMinutes = row.minutes(durationColumn)
+		row.readSet(set, "weight", "reps", "seconds", "distance", "rpe")
+		if unit := row.value("weight unit"); unit != "" {
+			set.weightUnit = row.weightUnit(unit)
+		}
+		if unit := row.value("distance unit"); unit != "" {
+			set.distanceUnit = row.distanceUnit(unit)
+		}
+		return set
+	}
+}
+
+// Hevy: title, start_time, end_time, description, exercise_title, superset_id,
+// exercise_notes, set_index, set_type, weight_kg or weight_lbs, reps, distance_km or
+// distance_miles, duration_seconds and rpe. The column names carry the units.
+func parseHevyRow(positions map[string]int) func(row *csvRow) *setRow {
+	weightColumn, weightUnit := "weight_kg", units.Kilograms
+	if _, ok := positions["weight_lbs"]; ok {
+		weightColumn, weightUnit = "weight_lbs", units.Pounds
+	}
+	distanceColumn, distanceUnit := "distance_km", units.Kilometers
+	if _, ok := positions["distance_miles"]; ok {
+		distanceColumn, distanceUnit = "distance_miles", units.Miles
+	}
+	layouts := []string{"2 Jan 2006, 15:04", "2 Jan 2006 15:04", "2006-01-02 15:04:05", time.RFC3339}
+
+	return func(row *csvRow) *setRow {
+		set := &setRow{
+			title:        row.value("title"),
+			description:  row.value("description"),
+			exerciseName: row.value("exercise_title"),
+			notes:        row.value("exercise_notes"),
+			supersetKey:  row.value("superset_id"),
+			weightUnit:   weightUnit,
+			distanceUnit: distanceUnit,
+		}
+
+		switch setType := strings.ToLower(row.value("set_type")); setType {
+		case "", "normal":
+			set.setType = SetWorking
+		case "warmup":
+			set.setType = SetWarmup
+		case "dropset":
+			set.setType = SetDrop
+		case "failure":
+			set.setType = SetFailure
+		default:
+			row.fail("set_type %q is not normal, warmup, dropset or failure", setType)
+		}
+
+		set.startedAt = row.date("start_time", layouts...)
+		set.workoutKey = set.startedAt.Format(time.RFC3339) + "\x00" + set.title
+		if row.value("end_time") != "" {
+			endedAt := row.date("end_time", layouts...)
+			if endedAt.After(set.startedAt) {
+				set.durationMinutes = int(endedAt.Sub(set.startedAt).Round(time.Minute) / time.Minute)
+			}
+		}
+		row.readSet(set, weightColumn, "reps", "duration_seconds", distanceColumn, "rpe")
+		return set
+	}
+}
+
+// FitNotes: Date, Exercise, Category, Weight (kgs) or Weight (lbs), Reps, Distance,
+// Distance Unit, Time and Comment. Newer exports have Weight and Weight Unit instead.
+// FitNotes has no workout names, a day's sets make one workout.
+func parseFitNotesRow(positions map[string]int, options ImportOptions) func(row *csvRow) *setRow {
+	weightColumn, weightUnit := "weight", options.WeightUnit
+	for column, unit := range map[string]units.WeightUnit{"weight (kgs)": units.Kilograms, "weight (lbs)": units.Pounds} {
+		if _, ok := positions[column]; ok {
+			weightColumn, weightUnit = column, unit
+		}
+	}
+
+	return func(row *csvRow) *setRow {
+		set := &setRow{
+			title:        "FitNotes workout",
+			exerciseName: row.value("exercise"),
+			notes:        row.value("comment"),
+			setType:      SetWorking,
+			weightUnit:   weightUnit,
+			distanceUnit: options.DistanceUnit,
+		}
+
+		set.startedAt = row.date("date", "2006-01-02")
+		set.workoutKey = set.startedAt.Format(time.DateOnly)
+		row.readSet(set, weightColumn, "reps", "", "distance", "")
+		if unit := row.value("weight unit"); unit != "" {
+			set.weightUnit = row.weightUnit(unit)
+		}
+		if unit := row.value("distance unit"); unit != "" {
+			set.distanceUnit = row.distanceUnit(unit)
+		}
+		if clock := row.value("time"); clock != "" {
+			seconds, err := parseClock(clock)
+			if err != nil {
+				row.fail("time %q must be h:mm:ss", clock)
+			}
+			set.durationSeconds = float64(seconds)
+		}
+		return set
+	}
+}
+
+// Reads the numbers of a set, columns left empty are skipped
+func (row *csvRow) readSet(set *setRow, weight, reps, seconds, distance, rpe string) {
+	if set.exerciseName == "" {
+		row.fail("the exercise name is required")
+	}
+
+	for _, field := range []struct {
+		column string
+		dst    *float64
+	}{
+		{weight, &set.weight},
+		{reps, &set.reps},
+		{seconds, &set.durationSeconds},
+		{distance, &set.distance},
+	} {
+		if field.column == "" {
+			continue
+		}
+		if value := row.float(field.column); value != nil {
+			*field.dst = *value
+		}
+	}
+
+	// RPE is on the 1 to 10 scale of the set log, anything else is dropped rather than failing the row
+	if rpe != "" {
+		if value := row.float(rpe); value != nil && *value >= 1 && *value <= 10 {
+			set.rpe = value
+		}
+	}
+}
+
+// Parses the column as a date in the first of layouts that fits
+func (row *csvRow) date(field string, layouts ...string) time.Time {
+	value := row.value(field)
+	if value == "" {
+		row.fail("%s is required", field)
+		return time.Time{}
+	}
+	for _, layout := range layouts {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			return parsed
+		}
+	}
+	row.fail("%s %q is not a date", field, value)
+	return time.Time{}
+}
+
+// Parses durations written as Strong does, "1h 5m", "45m" or "50s", or as plain seconds
+func (row *csvRow) minutes(field string) int {
+	value := strings.ReplaceAll(row.value(field), " ", "")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return seconds / 60
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		row.fail("%s %q is not a duration", field, row.value(field))
+		return 0
+	}
+	return int(duration / time.Minute)
+}
+
+func (row *csvRow) weightUnit(value string) units.WeightUnit {
+	unit, err := units.ParseWeightUnit(value)
+	if err != nil {
+		row.fail("%v", err)
+	}
+	return unit
+}
+
+func (row *csvRow) distanceUnit(value string) units.DistanceUnit {
+	unit, err := units.ParseDistanceUnit(strings.TrimSuffix(strings.ToLower(value), "s")) // kms, miles
+	if err != nil {
+		if strings.HasPrefix(strings.ToLower(value), "mile") {
+			return units.Miles
+		}
+		row.fail("%v", err)
+	}
+	return unit
+}
+
+// Parses h:mm:ss or mm:ss into seconds
+func parseClock(value string) (int, error) {
+	seconds := 0
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("too many fields")
+	}
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid field %q", part)
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, nil
+}
+
+// Groups set rows into workouts, and the consecutive sets of an exercise into entries
+type workoutBuilder struct {
+	workouts []*Workout
+	byKey    map[string]*Workout
+	sets     map[*Workout][][]*setRow // Sets of each entry, in order
+}
+
+func newWorkoutBuilder() *workoutBuilder {
+	return &workoutBuilder{byKey: map[string]*Workout{}, sets: map[*Workout][][]*setRow{}}
+}
+
+func (b *workoutBuilder) add(set *setRow) {
+	workout, ok := b.byKey[set.workoutKey]
+	if !ok {
+		workout = &Workout{
+			Title:           set.title,
+			Description:     set.description,
+			DurationMinutes: set.durationMinutes,
+			CreatedAt:       set.startedAt,
+		}
+		if workout.Title == "" {
+			workout.Title = "Imported workout"
+		}
+		b.byKey[set.workoutKey] = workout
+		b.workouts = append(b.workouts, workout)
+	}
+
+	entries := b.sets[workout]
+	if last := len(entries) - 1; last >= 0 && entries[last][0].exerciseName == set.exerciseName &&
+		entries[last][0].supersetKey == set.supersetKey {
+		entries[last] = append(entries[last], set)
+	} else {
+		entries = append(entries, []*setRow{set})
+	}
+	b.sets[workout] = entries
+}
+
+func (b *workoutBuilder) build() []*Workout {
+	for _, workout := range b.workouts {
+		groupIDs := map[string]int{}
+		members := map[string]int{}
+		for _, sets := range b.sets[workout] {
+			if key := sets[0].supersetKey; key != "" {
+				members[key]++
+			}
+		}
+
+		for i, sets := range b.sets[workout] {
+			entry := buildEntry(sets)
+			entry.OrderIndex = i + 1
+
+			// Supersets of a single exercise are just that exercise
+			if key := sets[0].supersetKey; members[key] > 1 {
+				groupID, ok := groupIDs[key]
+				if !ok {
+					groupID = len(groupIDs) + 1
+					groupIDs[key] = groupID
+					workout.Groups = append(workout.Groups, EntryGroup{GroupID: groupID, GroupType: GroupSuperset, Rounds: 1})
+				}
+				entry.GroupID = &groupID
+			}
+			workout.Entries = append(workout.Entries, entry)
+		}
+	}
+	return b.workouts
+}
+
+// Builds the entry of an exercise from its sets. Sets are timed when none has reps but
+// some have a time, the sets of an entry can't mix the two. The furthest distance is kept
+// as the entry's cardio distance, which is per set.
+func buildEntry(sets []*setRow) WorkoutEntry {
+	first := sets[0]
+	entry := WorkoutEntry{ExerciseName: first.exerciseName, WeightUnit: first.weightUnit}
+
+	timed := false
+	for _, set := range sets {
+		if set.reps > 0 {
+			timed = false
+			break
+		}
+		timed = timed || set.durationSeconds > 0
+	}
+
+	notes := []string{}
+	distance := 0.0
+	for i, set := range sets {
+		detail := EntrySet{SetNumber: i + 1, SetType: set.setType, RPE: set.rpe}
+		if timed {
+			seconds := int(set.durationSeconds)
+			detail.DurationSeconds = &seconds
+		} else {
+			reps := int(set.reps)
+			detail.Reps = &reps
+		}
+		if set.weight > 0 {
+			weight := set.weight
+			if set.weightUnit != entry.WeightUnit {
+				weight = units.FromKilograms(units.ToKilograms(weight, set.weightUnit), entry.WeightUnit)
+			}
+			detail.Weight = &weight
+		}
+		entry.SetsDetail = append(entry.SetsDetail, detail)
+
+		if set.notes != "" && (len(notes) == 0 || notes[len(notes)-1] != set.notes) {
+			notes = append(notes, set.notes)
+		}
+		distance = max(distance, units.FromMeters(units.ToMeters(set.distance, set.distanceUnit), first.distanceUnit))
+	}
+	entry.Notes = strings.Join(notes, "; ")
+
+	if distance > 0 {
+		entry.Cardio = &CardioMetrics{Distance: &distance, DistanceUnit: string(first.distanceUnit)}
+	}
+	return entry
+}
@@ -0,0 +1,144 @@
+package workouts
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/units"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStrongExport(t *testing.T) {
+	file := `Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE
+2024-01-08 18:02:11,Evening Push,1h 5m,Bench Press (Barbell),W,135,10,0,0,,Felt strong,
+2024-01-08 18:02:11,Evening Push,1h 5m,Bench Press (Barbell),1,225,5,0,0,,Felt strong,8
+2024-01-08 18:02:11,Evening Push,1h 5m,Bench Press (Barbell),Rest Timer,0,0,0,90,,Felt strong,
+2024-01-08 18:02:11,Evening Push,1h 5m,Bench Press (Barbell),2,225,4,0,0,grindy,Felt strong,9.5
+2024-01-08 18:02:11,Evening Push,1h 5m,Treadmill,1,0,0,3.1,1500,,Felt strong,
+2024-01-06 09:00:00,Legs,45m,Squat (Barbell),1,315,5,0,0,,,
+`
+	result, err := ParseImport(strings.NewReader(file), FormatAuto, nil,
+		ImportOptions{WeightUnit: units.Pounds, DistanceUnit: units.Miles})
+	require.NoError(t, err)
+	assert.Equal(t, FormatStrong, result.Format)
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, 6, result.Rows)
+
+	require.Len(t, result.Workouts, 2)
+	legs, push := result.Workouts[0], result.Workouts[1]
+	assert.Equal(t, "Legs", legs.Title)
+	assert.Equal(t, 45, legs.DurationMinutes)
+
+	assert.Equal(t, time.Date(2024, 1, 8, 18, 2, 11, 0, time.UTC), push.CreatedAt)
+	assert.Equal(t, 65, push.DurationMinutes)
+	assert.Equal(t, "Felt strong", push.Description)
+	require.Len(t, push.Entries, 2)
+
+	bench := push.Entries[0]
+	assert.Equal(t, units.Pounds, bench.WeightUnit)
+	require.Len(t, bench.SetsDetail, 3, "rest timer rows aren't sets")
+	assert.Equal(t, SetWarmup, bench.SetsDetail[0].SetType)
+	assert.Equal(t, 225.0, *bench.SetsDetail[2].Weight)
+	assert.Equal(t, 9.5, *bench.SetsDetail[2].RPE)
+	assert.Equal(t, "grindy", bench.Notes)
+
+	treadmill := push.Entries[1]
+	assert.Nil(t, treadmill.SetsDetail[0].Reps, "a set without reps is timed")
+	assert.Equal(t, 1500, *treadmill.SetsDetail[0].DurationSeconds)
+	assert.Nil(t, treadmill.SetsDetail[0].Weight)
+	require.NotNil(t, treadmill.Cardio)
+	assert.Equal(t, 3.1, *treadmill.Cardio.Distance)
+	assert.Equal(t, "mi", treadmill.Cardio.DistanceUnit)
+	require.NoError(t, summarizeSets(&bench))
+	assert.Equal(t, 2, bench.Sets)
+}
+
+func TestParseStrongSemicolonExport(t *testing.T) {
+	file := "Date;Workout Name;Exercise Name;Set Order;Weight;Weight Unit;Reps;RPE;Distance;Distance Unit;Seconds;Notes;Workout Notes;Workout Duration\n" +
+		"2019-05-02 07:00:00;Morning;Deadlift;1;180;kg;3;;;;;;;3600\n"
+
+	result, err := ParseImport(strings.NewReader(file), FormatStrong, nil, ImportOptions{})
+	require.NoError(t, err)
+	require.Empty(t, result.Errors)
+	require.Len(t, result.Workouts, 1)
+	assert.Equal(t, 60, result.Workouts[0].DurationMinutes)
+	assert.Equal(t, units.Kilograms, result.Workouts[0].Entries[0].WeightUnit)
+}
+
+func TestParseHevyExport(t *testing.T) {
+	file := `"title","start_time","end_time","description","exercise_title","superset_id","exercise_notes","set_index","set_type","weight_lbs","reps","distance_miles","duration_seconds","rpe"
+"Upper A","15 Jan 2024, 18:30","15 Jan 2024, 19:42","","Pull Up","","",0,"warmup",,5,,,
+"Upper A","15 Jan 2024, 18:30","15 Jan 2024, 19:42","","Pull Up","","",1,"normal",25,8,,,8
+"Upper A","15 Jan 2024, 18:30","15 Jan 2024, 19:42","","Dumbbell Curl","0","",0,"normal",35,12,,,
+"Upper A","15 Jan 2024, 18:30","15 Jan 2024, 19:42","","Triceps Pushdown","0","",0,"dropset",50,15,,,
+"Upper A","15 Jan 2024, 18:30","15 Jan 2024, 19:42","","Plank","","",0,"normal",,,,60,
+"Upper A","15 Jan 2024, 18:30","15 Jan 2024, 19:42","","Face Pull","1","",0,"bogus",30,15,,,
+`
+	result, err := ParseImport(strings.NewReader(file), FormatAuto, nil, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, FormatHevy, result.Format)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 7, result.Errors[0].Row)
+
+	require.Len(t, result.Workouts, 1)
+	workout := result.Workouts[0]
+	assert.Equal(t, 72, workout.DurationMinutes)
+	require.Len(t, workout.Entries, 4)
+	assert.Equal(t, units.Pounds, workout.Entries[0].WeightUnit)
+	assert.Len(t, workout.Entries[0].SetsDetail, 2)
+	assert.Nil(t, workout.Entries[0].SetsDetail[0].Weight, "bodyweight warmup")
+	assert.Equal(t, 60, *workout.Entries[3].SetsDetail[0].DurationSeconds)
+
+	// The curl and pushdown shared a superset_id
+	require.Len(t, workout.Groups, 1)
+	assert.Equal(t, GroupSuperset, workout.Groups[0].GroupType)
+	assert.Equal(t, 1, *workout.Entries[1].GroupID)
+	assert.Equal(t, 1, *workout.Entries[2].GroupID)
+	assert.Nil(t, workout.Entries[3].GroupID)
+	assert.Equal(t, SetDrop, workout.Entries[2].SetsDetail[0].SetType)
+	require.NoError(t, validateGroups(workout))
+}
+
+func TestParseFitNotesExport(t *testing.T) {
+	file := `Date,Exercise,Category,Weight (kgs),Reps,Distance,Distance Unit,Time,Comment
+2023-11-02,Flat Barbell Bench Press,Chest,80.0,8,,,,
+2023-11-02,Flat Barbell Bench Press,Chest,80.0,7,,,,last rep paused
+2023-11-02,Running (Outdoor),Cardio,,,5.0,km,0:25:30,
+2023-11-04,Deadlift,Back,140.0,5,,,,
+2023-11-04,Deadlift,Back,heavy,5,,,,
+`
+	result, err := ParseImport(strings.NewReader(file), FormatAuto, nil, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, FormatFitNotes, result.Format)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 6, result.Errors[0].Row)
+
+	require.Len(t, result.Workouts, 2, "one workout per day")
+	first := result.Workouts[0]
+	assert.Equal(t, "FitNotes workout", first.Title)
+	require.Len(t, first.Entries, 2)
+	assert.Equal(t, units.Kilograms, first.Entries[0].WeightUnit)
+	assert.Len(t, first.Entries[0].SetsDetail, 2)
+	assert.Equal(t, 1530, *first.Entries[1].SetsDetail[0].DurationSeconds)
+	assert.Equal(t, 5.0, *first.Entries[1].Cardio.Distance)
+
+	counts := countImport(result.Workouts)
+	assert.Equal(t, map[string]int{"workouts": 2, "entries": 3, "sets": 4}, counts)
+}
+
+func TestParseImportErrors(t *testing.T) {
+	_, err := ParseFormat("myfitnesspal")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = ParseImport(strings.NewReader("Date,Exercise Name\n"), FormatStrong, nil, ImportOptions{})
+	assert.ErrorIs(t, err, ErrInvalidMapping, "missing required Strong columns")
+
+	// Without an app's columns the file is read as our own layout
+	result, err := ParseImport(strings.NewReader("date,exercise_name,reps\n2024-01-01,Row,10\n"), FormatAuto, nil,
+		ImportOptions{WeightUnit: units.Pounds})
+	require.NoError(t, err)
+	assert.Equal(t, FormatCSV, result.Format)
+	assert.Equal(t, units.Pounds, result.Workouts[0].Entries[0].WeightUnit)
+}
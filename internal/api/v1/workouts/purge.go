@@ -0,0 +1,30 @@
+package workouts
+
+import (
+	"context"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/app"
+	"github.com/Josesx506/gofems/internal/jobs"
+)
+
+// Removes workouts that have been in the trash for longer than the retention period
+const PurgeTrashJob = "workouts.purge_trash"
+
+// RegisterJobs schedules the hourly trash purge
+func RegisterJobs(app *app.Application, retention time.Duration) {
+	store := NewPostgresWorkoutStore(app.DB)
+
+	purge := jobs.Register(app.Jobs, PurgeTrashJob, jobs.Options{Queue: jobs.MaintenanceQueue},
+		func(ctx context.Context, _ struct{}) error {
+			purged, err := store.PurgeDeletedWorkouts(time.Now().Add(-retention))
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				app.Logger.Printf("Purged %d workouts from the trash", purged)
+			}
+			return nil
+		})
+	purge.Schedule("purge-trash", "@hourly", struct{}{})
+}
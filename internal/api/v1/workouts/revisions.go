@@ -0,0 +1,180 @@
+package workouts
+
+import (
+	"reflect"
+	"sort"
+	"time"
+)
+
+// A full copy of a workout and its entries taken after every create or update
+type WorkoutRevision struct {
+	ID        int       `json:"id"`
+	WorkoutID int       `json:"workout_id"`
+	Revision  int       `json:"revision"`
+	Snapshot  Workout   `json:"snapshot"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// Entries are matched across revisions by their order_index
+type EntryChange struct {
+	OrderIndex int           `json:"order_index"`
+	Change     string        `json:"change"` // added, removed or modified
+	Fields     []FieldChange `json:"fields,omitempty"`
+}
+
+type RevisionDiff struct {
+	WorkoutID int           `json:"workout_id"`
+	From      int           `json:"from"`
+	To        int           `json:"to"`
+	Fields    []FieldChange `json:"fields"`
+	Entries   []EntryChange `json:"entries"`
+}
+
+const (
+	EntryAdded    = "added"
+	EntryRemoved  = "removed"
+	EntryModified = "modified"
+)
+
+// DiffRevisions compares two workout revisions field by field
+func DiffRevisions(from, to *WorkoutRevision) *RevisionDiff {
+	diff := &RevisionDiff{
+		WorkoutID: from.WorkoutID,
+		From:      from.Revision,
+		To:        to.Revision,
+		Fields:    []FieldChange{},
+		Entries:   []EntryChange{},
+	}
+
+	a, b := from.Snapshot, to.Snapshot
+	diff.Fields = appendChange(diff.Fields, "title", a.Title, b.Title)
+	diff.Fields = appendChange(diff.Fields, "description", a.Description, b.Description)
+	diff.Fields = appendChange(diff.Fields, "duration_minutes", a.DurationMinutes, b.DurationMinutes)
+	diff.Fields = appendChange(diff.Fields, "calories_burned", a.CaloriesBurned, b.CaloriesBurned)
+	if !groupsEqual(a.Groups, b.Groups) {
+		diff.Fields = append(diff.Fields, FieldChange{Field: "groups", From: a.Groups, To: b.Groups})
+	}
+
+	fromEntries := entriesByOrder(a.Entries)
+	toEntries := entriesByOrder(b.Entries)
+
+	orderIndexes := []int{}
+	for idx := range fromEntries {
+		orderIndexes = append(orderIndexes, idx)
+	}
+	for idx := range toEntries {
+		if _, ok := fromEntries[idx]; !ok {
+			orderIndexes = append(orderIndexes, idx)
+		}
+	}
+	sort.Ints(orderIndexes)
+
+	for _, idx := range orderIndexes {
+		oldEntry, inFrom := fromEntries[idx]
+		newEntry, inTo := toEntries[idx]
+
+		switch {
+		case !inFrom:
+			diff.Entries = append(diff.Entries, EntryChange{OrderIndex: idx, Change: EntryAdded})
+		case !inTo:
+			diff.Entries = append(diff.Entries, EntryChange{OrderIndex: idx, Change: EntryRemoved})
+		default:
+			fields := diffEntries(oldEntry, newEntry)
+			if len(fields) > 0 {
+				diff.Entries = append(diff.Entries, EntryChange{OrderIndex: idx, Change: EntryModified, Fields: fields})
+			}
+		}
+	}
+
+	return diff
+}
+
+func diffEntries(a, b WorkoutEntry) []FieldChange {
+	changes := []FieldChange{}
+	changes = appendChange(changes, "exercise_id", derefInt(a.ExerciseID), derefInt(b.ExerciseID))
+	changes = appendChange(changes, "exercise_name", a.ExerciseName, b.ExerciseName)
+	changes = appendChange(changes, "sets", a.Sets, b.Sets)
+	changes = appendChange(changes, "reps", derefInt(a.Reps), derefInt(b.Reps))
+	changes = appendChange(changes, "duration_seconds", derefInt(a.DurationSeconds), derefInt(b.DurationSeconds))
+	changes = appendChange(changes, "weight", derefFloat(a.Weight), derefFloat(b.Weight))
+	changes = appendChange(changes, "notes", a.Notes, b.Notes)
+	changes = appendChange(changes, "group_id", derefInt(a.GroupID), derefInt(b.GroupID))
+	if !reflect.DeepEqual(a.Cardio, b.Cardio) {
+		changes = append(changes, FieldChange{Field: "cardio", From: a.Cardio, To: b.Cardio})
+	}
+	if !setsEqual(a.SetsDetail, b.SetsDetail) {
+		changes = append(changes, FieldChange{Field: "sets_detail", From: a.SetsDetail, To: b.SetsDetail})
+	}
+	return changes
+}
+
+// Sets get new ids whenever a workout is saved, so they're compared by content alone
+func setsEqual(a, b []EntrySet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		x.ID, y.ID = 0, 0
+		if !reflect.DeepEqual(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+func entriesByOrder(entries []WorkoutEntry) map[int]WorkoutEntry {
+	byOrder := make(map[int]WorkoutEntry, len(entries))
+	for _, entry := range entries {
+		byOrder[entry.OrderIndex] = entry
+	}
+	return byOrder
+}
+
+func appendChange(changes []FieldChange, field string, from, to any) []FieldChange {
+	if from == to {
+		return changes
+	}
+	return append(changes, FieldChange{Field: field, From: from, To: to})
+}
+
+// Groups are compared by their settings, membership shows up as entry group_id changes
+func groupsEqual(a, b []EntryGroup) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	byGroupID := make(map[int]EntryGroup, len(a))
+	for _, group := range a {
+		group.ID, group.Entries = 0, nil
+		byGroupID[group.GroupID] = group
+	}
+	for _, group := range b {
+		group.ID, group.Entries = 0, nil
+		other, ok := byGroupID[group.GroupID]
+		if !ok || !reflect.DeepEqual(other, group) {
+			return false
+		}
+	}
+	return true
+}
+
+// Pointer fields are compared by value so nil stays distinguishable from zero
+func derefInt(i *int) any {
+	if i == nil {
+		return nil
+	}
+	return *i
+}
+
+func derefFloat(f *float64) any {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
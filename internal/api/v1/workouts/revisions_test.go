@@ -0,0 +1,72 @@
+package workouts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRevisions(t *testing.T) {
+	base := Workout{
+		Title:           "Leg Day Workout",
+		Description:     "A focused workout for building leg strength",
+		DurationMinutes: 60,
+		CaloriesBurned:  450,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Squats", Sets: 4, Reps: IntPtr(12), Weight: FloatPtr(90.0), OrderIndex: 1},
+			{ExerciseName: "Lunges", Sets: 3, Reps: IntPtr(10), Weight: FloatPtr(50.0), OrderIndex: 2},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		to          Workout
+		wantFields  []FieldChange
+		wantEntries []EntryChange
+	}{
+		{
+			name:        "Identical revisions",
+			to:          base,
+			wantFields:  []FieldChange{},
+			wantEntries: []EntryChange{},
+		},
+		{
+			name: "Workout fields and entry changes",
+			to: Workout{
+				Title:           "Updated Leg Day Workout",
+				Description:     base.Description,
+				DurationMinutes: 75,
+				CaloriesBurned:  450,
+				Entries: []WorkoutEntry{
+					{ExerciseName: "Squats", Sets: 5, Reps: IntPtr(10), Weight: FloatPtr(90.0), OrderIndex: 1},
+					{ExerciseName: "Plank", Sets: 3, DurationSeconds: IntPtr(90), OrderIndex: 3},
+				},
+			},
+			wantFields: []FieldChange{
+				{Field: "title", From: "Leg Day Workout", To: "Updated Leg Day Workout"},
+				{Field: "duration_minutes", From: 60, To: 75},
+			},
+			wantEntries: []EntryChange{
+				{OrderIndex: 1, Change: EntryModified, Fields: []FieldChange{
+					{Field: "sets", From: 4, To: 5},
+					{Field: "reps", From: 12, To: 10},
+				}},
+				{OrderIndex: 2, Change: EntryRemoved},
+				{OrderIndex: 3, Change: EntryAdded},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := &WorkoutRevision{WorkoutID: 1, Revision: 1, Snapshot: base}
+			to := &WorkoutRevision{WorkoutID: 1, Revision: 2, Snapshot: tt.to}
+
+			diff := DiffRevisions(from, to)
+			assert.Equal(t, 1, diff.From)
+			assert.Equal(t, 2, diff.To)
+			assert.Equal(t, tt.wantFields, diff.Fields)
+			assert.Equal(t, tt.wantEntries, diff.Entries)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package workouts
 
 import (
+	"github.com/Josesx506/gofems/internal/api/v1/exercises"
+	"github.com/Josesx506/gofems/internal/api/v1/users"
 	"github.com/Josesx506/gofems/internal/app"
 	"github.com/go-chi/chi/v5"
 )
@@ -11,13 +13,28 @@ func WorkoutRouter(app *app.Application) chi.Router {
 	r := chi.NewRouter()
 	// Store requires global db connection
 	store := &PostgresWorkoutStore{db: app.DB}
-	handler := NewWorkoutHandler(store, app.Logger)
+	userStore := users.NewPostgresUserStore(app.DB)
+	handler := NewWorkoutHandler(store, exercises.NewPostgresExerciseStore(app.DB), userStore, app.Logger)
+
+	// The trash, exports, imports and uploads are the caller's own
+	r.Group(func(r chi.Router) {
+		r.Use(users.RequireUser(userStore))
+		r.Get("/trash", handler.HandleListTrash)
+		r.Post("/{id}/restore", handler.HandleRestoreWorkoutByID)
+		r.Get("/export.csv", handler.HandleExportCSV)
+		r.Post("/import", handler.HandleImportCSV)
+		r.Post("/upload", handler.HandleUploadActivity)
+	})
 
 	// Define subroutes
 	r.Get("/{id}", handler.HandleGetWorkoutByID)
 	r.Put("/{id}", handler.HandleUpdateWorkoutByID)
 	r.Post("/", handler.HandleCreateWorkout)
 	r.Delete("/{id}", handler.HandleDeleteWorkoutByID)
+	r.Get("/{id}/track", handler.HandleGetTrack)
+	r.Get("/{id}/revisions", handler.HandleListWorkoutRevisions)
+	r.Get("/{id}/revisions/diff", handler.HandleDiffWorkoutRevisions)
+	r.Post("/{id}/revisions/{rev}/revert", handler.HandleRevertWorkout)
 
 	return r
 }
@@ -0,0 +1,132 @@
+package workouts
+
+import (
+	"errors"
+	"fmt"
+)
+
+// One set of an entry. Entries that carry sets_detail have their legacy
+// sets, reps, duration_seconds and weight derived from these rows.
+type EntrySet struct {
+	ID              int      `json:"id"`
+	SetNumber       int      `json:"set_number"` // 1-based, defaults to the position in sets_detail
+	Reps            *int     `json:"reps"`
+	DurationSeconds *int     `json:"duration_seconds"`
+	Weight          *float64 `json:"weight"`
+	RPE             *float64 `json:"rpe"` // rate of perceived exertion, 1-10
+	RIR             *int     `json:"rir"` // reps in reserve
+	SetType         string   `json:"set_type"`
+	RestSeconds     *int     `json:"rest_seconds"`
+	Completed       *bool    `json:"completed"` // defaults to true, false for missed sets
+}
+
+const (
+	SetWarmup  = "warmup"
+	SetWorking = "working"
+	SetDrop    = "drop"
+	SetFailure = "failure"
+)
+
+var ErrInvalidSet = errors.New("invalid set")
+
+func (s *EntrySet) Validate() error {
+	if (s.Reps == nil) == (s.DurationSeconds == nil) {
+		return fmt.Errorf("%w %d: exactly one of reps or duration_seconds is required", ErrInvalidSet, s.SetNumber)
+	}
+	if (s.Reps != nil && *s.Reps < 0) || (s.DurationSeconds != nil && *s.DurationSeconds < 0) ||
+		(s.Weight != nil && *s.Weight < 0) || (s.RestSeconds != nil && *s.RestSeconds < 0) {
+		return fmt.Errorf("%w %d: reps, duration, weight and rest can't be negative", ErrInvalidSet, s.SetNumber)
+	}
+	if s.RPE != nil && (*s.RPE < 1 || *s.RPE > 10) {
+		return fmt.Errorf("%w %d: rpe must be between 1 and 10", ErrInvalidSet, s.SetNumber)
+	}
+	if s.RIR != nil && *s.RIR < 0 {
+		return fmt.Errorf("%w %d: rir can't be negative", ErrInvalidSet, s.SetNumber)
+	}
+
+	switch s.SetType {
+	case SetWarmup, SetWorking, SetDrop, SetFailure:
+	default:
+		return fmt.Errorf("%w %d: set_type must be warmup, working, drop or failure", ErrInvalidSet, s.SetNumber)
+	}
+
+	return nil
+}
+
+// summarizeSets fills in the defaults of an entry's sets, validates them and derives the
+// legacy fields for old clients: sets counts the completed sets other than warmups, and
+// reps, duration_seconds and weight come from the top set, the heaviest and then longest.
+func summarizeSets(entry *WorkoutEntry) error {
+	if len(entry.SetsDetail) == 0 {
+		return nil
+	}
+
+	numbers := map[int]bool{}
+	byDuration := entry.SetsDetail[0].DurationSeconds != nil
+	for i := range entry.SetsDetail {
+		set := &entry.SetsDetail[i]
+		if set.SetNumber == 0 {
+			set.SetNumber = i + 1
+		}
+		if set.SetType == "" {
+			set.SetType = SetWorking
+		}
+		if set.Completed == nil {
+			completed := true
+			set.Completed = &completed
+		}
+
+		err := set.Validate()
+		if err != nil {
+			return err
+		}
+		if numbers[set.SetNumber] {
+			return fmt.Errorf("%w %d: set numbers must be unique", ErrInvalidSet, set.SetNumber)
+		}
+		numbers[set.SetNumber] = true
+		if (set.DurationSeconds != nil) != byDuration {
+			return fmt.Errorf("%w %d: sets of an entry can't mix reps and durations", ErrInvalidSet, set.SetNumber)
+		}
+	}
+
+	counted := []EntrySet{}
+	for _, set := range entry.SetsDetail {
+		if *set.Completed && set.SetType != SetWarmup {
+			counted = append(counted, set)
+		}
+	}
+	entry.Sets = len(counted)
+
+	// Entries of only warmups or missed sets still need reps or a duration
+	if len(counted) == 0 {
+		counted = entry.SetsDetail
+	}
+
+	top := counted[0]
+	for _, set := range counted[1:] {
+		if isHeavier(set, top) {
+			top = set
+		}
+	}
+	entry.Reps, entry.DurationSeconds, entry.Weight = top.Reps, top.DurationSeconds, top.Weight
+
+	return nil
+}
+
+func isHeavier(a, b EntrySet) bool {
+	weightA, weightB := valueOrZero(a.Weight), valueOrZero(b.Weight)
+	if weightA != weightB {
+		return weightA > weightB
+	}
+	if a.Reps != nil && b.Reps != nil {
+		return *a.Reps > *b.Reps
+	}
+	return a.DurationSeconds != nil && b.DurationSeconds != nil && *a.DurationSeconds > *b.DurationSeconds
+}
+
+func valueOrZero(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
@@ -0,0 +1,55 @@
+package workouts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeSets(t *testing.T) {
+	missed := false
+	entry := WorkoutEntry{
+		ExerciseName: "Bench Press",
+		SetsDetail: []EntrySet{
+			{Reps: IntPtr(10), Weight: FloatPtr(40), SetType: SetWarmup},
+			{Reps: IntPtr(8), Weight: FloatPtr(80)},
+			{Reps: IntPtr(5), Weight: FloatPtr(90), RPE: FloatPtr(9)},
+			{Reps: IntPtr(2), Weight: FloatPtr(95), Completed: &missed},
+			{Reps: IntPtr(12), Weight: FloatPtr(60), SetType: SetDrop},
+		},
+	}
+
+	require.NoError(t, summarizeSets(&entry))
+	assert.Equal(t, 3, entry.Sets, "warmups and missed sets aren't counted")
+	assert.Equal(t, 5, *entry.Reps)
+	assert.Equal(t, 90.0, *entry.Weight, "the top set is the heaviest completed one")
+	assert.Nil(t, entry.DurationSeconds)
+
+	assert.Equal(t, 4, entry.SetsDetail[3].SetNumber)
+	assert.Equal(t, SetWorking, entry.SetsDetail[1].SetType)
+	assert.True(t, *entry.SetsDetail[1].Completed)
+
+	// Legacy entries are left alone
+	legacy := WorkoutEntry{ExerciseName: "Squat", Sets: 5, Reps: IntPtr(5), Weight: FloatPtr(100)}
+	require.NoError(t, summarizeSets(&legacy))
+	assert.Equal(t, 5, legacy.Sets)
+
+	tests := []struct {
+		name string
+		sets []EntrySet
+	}{
+		{name: "Reps and duration", sets: []EntrySet{{Reps: IntPtr(5), DurationSeconds: IntPtr(30)}}},
+		{name: "Mixed measurements", sets: []EntrySet{{Reps: IntPtr(5)}, {DurationSeconds: IntPtr(30)}}},
+		{name: "RPE out of range", sets: []EntrySet{{Reps: IntPtr(5), RPE: FloatPtr(11)}}},
+		{name: "Unknown set type", sets: []EntrySet{{Reps: IntPtr(5), SetType: "cluster"}}},
+		{name: "Duplicate set numbers", sets: []EntrySet{{SetNumber: 1, Reps: IntPtr(5)}, {SetNumber: 1, Reps: IntPtr(5)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := summarizeSets(&WorkoutEntry{ExerciseName: "Squat", SetsDetail: tt.sets})
+			assert.ErrorIs(t, err, ErrInvalidSet)
+		})
+	}
+}
@@ -2,6 +2,25 @@ package workouts
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/activity"
+	"github.com/Josesx506/gofems/internal/api/v1/records"
+	"github.com/Josesx506/gofems/internal/outbox"
+	"github.com/Josesx506/gofems/internal/units"
+	"github.com/jackc/pgconn"
+)
+
+var (
+	ErrDuplicateSlug    = errors.New("workout slug already in use")
+	ErrUnknownUser      = errors.New("workout user does not exist")
+	ErrUnknownExercise  = errors.New("workout entry references an exercise that does not exist")
+	ErrRevisionConflict = errors.New("workout was saved concurrently, reload it and try again")
 )
 
 // DB connector struct
@@ -20,63 +39,245 @@ type WorkoutStore interface {
 	GetWorkoutByID(id int64) (*Workout, error)
 	UpdateWorkout(*Workout) error
 	DeleteWorkout(id int64) error
+	ListDeletedWorkouts(userID int64) ([]Workout, error)
+	RestoreWorkout(id, userID int64) error
+	PurgeWorkout(id, userID int64) error
+	PurgeDeletedWorkouts(deletedBefore time.Time) (int64, error)
+	ListWorkoutRevisions(workoutID int64) ([]WorkoutRevision, error)
+	GetWorkoutRevision(workoutID int64, revision int) (*WorkoutRevision, error)
+	RevertWorkout(workoutID int64, revision int) (*Workout, error)
+	ListWorkoutsByTemplate(templateID int64) ([]Workout, error)
+	ListWorkoutsByUser(userID int64) ([]Workout, error)
+	ImportWorkouts(workouts []*Workout) error
+	FindDuplicateWorkouts(userID int64, workouts []*Workout) (map[int]int, error)
+	CreateActivityWorkout(workout *Workout, points []activity.TrackPoint) error
+	FindDuplicateActivity(userID int64, summary activity.Summary) (int, error)
+	ListTrackPoints(workoutID int64) ([]activity.TrackPoint, error)
+	ExportEntries(filter ExportFilter, fn func(*Workout, *WorkoutEntry) error) error
 	// GetWorkoutOwner(id int64) (int, error)
 }
 
 // Define methods for PostgresWorkoutStore to implement WorkoutStore interface
 func (pgStore *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error) {
+	err := validateWorkout(workout)
+	if err != nil {
+		return nil, err
+	}
+
 	tx, err := pgStore.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback() // rollback transaction if not committed
 
-	// Insert workout
+	err = createWorkout(tx, workout, sql.NullTime{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	nestGroups(workout)
+	return workout, nil
+}
+
+// CreateWorkoutInTx creates a workout inside the caller's transaction, so whatever the
+// caller saves with it is stored together with the workout or not at all. Like imports
+// it keeps the workout's created_at when set.
+func CreateWorkoutInTx(tx *sql.Tx, workout *Workout) error {
+	err := validateWorkout(workout)
+	if err != nil {
+		return err
+	}
+
+	err = createWorkout(tx, workout, sql.NullTime{Time: workout.CreatedAt, Valid: !workout.CreatedAt.IsZero()})
+	if err != nil {
+		return err
+	}
+
+	nestGroups(workout)
+	return nil
+}
+
+// Creates every workout in a single transaction, either all of them are stored or none.
+// Unlike CreateWorkout the created_at of each workout is kept, so history can be backfilled.
+func (pgStore *PostgresWorkoutStore) ImportWorkouts(workouts []*Workout) error {
+	for _, workout := range workouts {
+		err := validateWorkout(workout)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, workout := range workouts {
+		createdAt := sql.NullTime{Time: workout.CreatedAt, Valid: !workout.CreatedAt.IsZero()}
+		err = createWorkout(tx, workout, createdAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	for _, workout := range workouts {
+		nestGroups(workout)
+	}
+	return nil
+}
+
+// Creates a workout from an uploaded activity together with its track, started at the
+// workout's created_at
+func (pgStore *PostgresWorkoutStore) CreateActivityWorkout(workout *Workout, points []activity.TrackPoint) error {
+	err := validateWorkout(workout)
+	if err != nil {
+		return err
+	}
+
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = createWorkout(tx, workout, sql.NullTime{Time: workout.CreatedAt, Valid: !workout.CreatedAt.IsZero()})
+	if err != nil {
+		return err
+	}
+
+	err = insertTrackPoints(tx, workout.ID, points)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Rows per insert statement, postgres takes at most 65535 parameters
+const trackPointBatch = 1000
+
+func insertTrackPoints(tx *sql.Tx, workoutID int, points []activity.TrackPoint) error {
+	for start := 0; start < len(points); start += trackPointBatch {
+		end := min(start+trackPointBatch, len(points))
+
+		var query strings.Builder
+		query.WriteString(`INSERT INTO workout_track_points (workout_id, point_index, recorded_at, latitude, longitude,
+			elevation_meters, heart_rate, cadence, distance_meters) VALUES `)
+		args := make([]any, 0, (end-start)*9)
+		for i, point := range points[start:end] {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+				n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9)
+			args = append(args, workoutID, start+i, point.Time, point.Latitude, point.Longitude,
+				point.ElevationMeters, point.HeartRate, point.Cadence, point.DistanceMeters)
+		}
+
+		_, err := tx.Exec(query.String(), args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The recorded track of a live workout in time order, empty for workouts logged by hand
+func (pgStore *PostgresWorkoutStore) ListTrackPoints(workoutID int64) ([]activity.TrackPoint, error) {
 	query := `
-	INSERT INTO workouts (title, description, duration_minutes, calories_burned) 
-	VALUES ($1, $2, $3, $4) 
-	RETURNING id
+	SELECT tp.recorded_at, tp.latitude, tp.longitude, tp.elevation_meters, tp.heart_rate, tp.cadence,
+		tp.distance_meters
+	FROM workout_track_points tp
+	JOIN workouts w ON w.id = tp.workout_id
+	WHERE tp.workout_id = $1 AND w.deleted_at IS NULL
+	ORDER BY tp.point_index
 	`
-	err = tx.QueryRow(query, workout.Title, workout.Description, workout.DurationMinutes,
-		workout.CaloriesBurned).Scan(&workout.ID)
+	rows, err := pgStore.db.Query(query, workoutID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	// Insert each workout entry as a row
-	for _, entry := range workout.Entries {
-		entryQuery := `
-		INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id
-		`
-		// Uses the returned workout id from the previous insert and scans returned entry id
-		err = tx.QueryRow(entryQuery, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps,
-			entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+	points := []activity.TrackPoint{}
+	for rows.Next() {
+		var point activity.TrackPoint
+		err := rows.Scan(&point.Time, &point.Latitude, &point.Longitude, &point.ElevationMeters, &point.HeartRate,
+			&point.Cadence, &point.DistanceMeters)
 		if err != nil {
 			return nil, err
 		}
+		points = append(points, point)
 	}
 
-	// Commit transaction
-	err = tx.Commit()
+	return points, rows.Err()
+}
+
+// Inserts the workout with its groups, entries and first revision, created now unless createdAt is set
+func createWorkout(tx *sql.Tx, workout *Workout, createdAt sql.NullTime) error {
+	// Insert workout
+	query := `
+	INSERT INTO workouts (user_id, title, slug, description, duration_minutes, calories_burned, template_id, created_at) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, CURRENT_TIMESTAMP)) 
+	RETURNING id, created_at
+	`
+	err := tx.QueryRow(query, nullableID(workout.UserID), workout.Title, workout.Slug, workout.Description,
+		workout.DurationMinutes, workout.CaloriesBurned, workout.TemplateID, createdAt).Scan(&workout.ID,
+		&workout.CreatedAt)
 	if err != nil {
-		return nil, err
+		return translateError(err)
 	}
 
-	return workout, nil
+	// Groups go first so that entries can reference them
+	err = insertGroups(tx, workout)
+	if err != nil {
+		return err
+	}
+
+	// Insert each workout entry as a row
+	err = insertEntries(tx, workout)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(tx, workout)
+	if err != nil {
+		return err
+	}
+
+	workout.NewRecords, err = records.DetectInTx(tx, int64(workout.ID))
+	if err != nil {
+		return err
+	}
+
+	return writeWorkoutEvents(tx, outbox.WorkoutCreated, workout, nil)
 }
 
 func (pgStore *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
 	workout := &Workout{} // Initialize empty workout
+	var userID sql.NullInt64
 
 	query := `
-	SELECT id, title, description, duration_minutes, calories_burned
+	SELECT id, user_id, title, slug, description, duration_minutes, calories_burned, template_id, created_at
 	FROM workouts
-	WHERE id = $1
+	WHERE id = $1 AND deleted_at IS NULL
 	`
-	err := pgStore.db.QueryRow(query, id).Scan(&workout.ID, &workout.Title, &workout.Description,
-		&workout.DurationMinutes, &workout.CaloriesBurned)
+	err := pgStore.db.QueryRow(query, id).Scan(&workout.ID, &userID, &workout.Title, &workout.Slug,
+		&workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned, &workout.TemplateID,
+		&workout.CreatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil // No workout found
@@ -85,96 +286,870 @@ func (pgStore *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error)
 	if err != nil {
 		return nil, err
 	}
+	workout.UserID = int(userID.Int64)
+
+	err = pgStore.loadEntries(workout)
+	if err != nil {
+		return nil, err
+	}
+
+	return workout, nil
+}
+
+// Every workout of a user in the order they were logged, including those in the trash
+func (pgStore *PostgresWorkoutStore) ListWorkoutsByUser(userID int64) ([]Workout, error) {
+	query := `
+	SELECT id, user_id, title, slug, description, duration_minutes, calories_burned, template_id, created_at, deleted_at
+	FROM workouts
+	WHERE user_id = $1
+	ORDER BY created_at ASC, id ASC
+	`
+	rows, err := pgStore.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := []Workout{}
+	for rows.Next() {
+		var workout Workout
+		err := rows.Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Slug, &workout.Description,
+			&workout.DurationMinutes, &workout.CaloriesBurned, &workout.TemplateID, &workout.CreatedAt, &workout.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, workout)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range workouts {
+		err := pgStore.loadEntries(&workouts[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return workouts, nil
+}
+
+// Lists the live workouts started from a template, oldest first, for progress comparisons
+func (pgStore *PostgresWorkoutStore) ListWorkoutsByTemplate(templateID int64) ([]Workout, error) {
+	query := `
+	SELECT id, user_id, title, slug, description, duration_minutes, calories_burned, template_id, created_at
+	FROM workouts
+	WHERE template_id = $1 AND deleted_at IS NULL
+	ORDER BY created_at ASC
+	`
+	rows, err := pgStore.db.Query(query, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := []Workout{}
+	for rows.Next() {
+		var workout Workout
+		var userID sql.NullInt64
+		err := rows.Scan(&workout.ID, &userID, &workout.Title, &workout.Slug, &workout.Description,
+			&workout.DurationMinutes, &workout.CaloriesBurned, &workout.TemplateID, &workout.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		workout.UserID = int(userID.Int64)
+		workouts = append(workouts, workout)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
+	for i := range workouts {
+		err := pgStore.loadEntries(&workouts[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return workouts, nil
+}
+
+// Loads the entries of a workout ordered by their order index, along with their sets and groups
+func (pgStore *PostgresWorkoutStore) loadEntries(workout *Workout) error {
 	entriesQuery := `
-	SELECT id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index
-	FROM workout_entries
-	WHERE workout_id = $1
-	ORDER BY order_index ASC
+	SELECT we.id, we.exercise_id, we.exercise_name, we.sets, we.reps, we.duration_seconds, we.weight,
+		we.notes, we.order_index, eg.group_number, ec.entry_id IS NOT NULL, ec.distance_meters,
+		COALESCE(ec.distance_unit, ''), ec.elevation_gain_meters, ec.avg_heart_rate, ec.max_heart_rate,
+		ec.avg_cadence
+	FROM workout_entries we
+	LEFT JOIN entry_groups eg ON eg.id = we.group_id
+	LEFT JOIN entry_cardio ec ON ec.entry_id = we.id
+	WHERE we.workout_id = $1
+	ORDER BY we.order_index ASC
 	`
 	rows, err := pgStore.db.Query(entriesQuery, workout.ID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close() // Ensure rows are closed after processing
 
 	for rows.Next() {
-		entry := WorkoutEntry{}
-		err := rows.Scan(&entry.ID, &entry.ExerciseName, &entry.Sets, &entry.Reps,
-			&entry.DurationSeconds, &entry.Weight, &entry.Notes, &entry.OrderIndex)
+		entry := WorkoutEntry{WeightUnit: units.Kilograms}
+		var hasCardio bool
+		cardio := &CardioMetrics{}
+		err := rows.Scan(&entry.ID, &entry.ExerciseID, &entry.ExerciseName, &entry.Sets, &entry.Reps,
+			&entry.DurationSeconds, &entry.Weight, &entry.Notes, &entry.OrderIndex, &entry.GroupID, &hasCardio,
+			&cardio.distanceMeters, &cardio.DistanceUnit, &cardio.ElevationGainMeters, &cardio.AvgHeartRate,
+			&cardio.MaxHeartRate, &cardio.AvgCadence)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if hasCardio {
+			entry.Cardio = cardio
+			if cardio.distanceMeters != nil {
+				distance := roundTo(units.FromMeters(*cardio.distanceMeters, units.DistanceUnit(cardio.DistanceUnit)), 3)
+				cardio.Distance = &distance
+			}
+			deriveCardio(&entry)
 		}
 		workout.Entries = append(workout.Entries, entry)
 	}
 
-	return workout, nil
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	err = pgStore.loadSets(workout)
+	if err != nil {
+		return err
+	}
+
+	return pgStore.loadGroups(workout)
+}
+
+// Loads the groups of a workout and nests their entries, which must be loaded first
+func (pgStore *PostgresWorkoutStore) loadGroups(workout *Workout) error {
+	query := `
+	SELECT id, group_number, group_type, rounds, rest_between_rounds_seconds, duration_seconds
+	FROM entry_groups
+	WHERE workout_id = $1
+	ORDER BY group_number ASC
+	`
+	rows, err := pgStore.db.Query(query, workout.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		group := EntryGroup{}
+		err := rows.Scan(&group.ID, &group.GroupID, &group.GroupType, &group.Rounds,
+			&group.RestBetweenRoundsSeconds, &group.DurationSeconds)
+		if err != nil {
+			return err
+		}
+		workout.Groups = append(workout.Groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	nestGroups(workout)
+	return nil
+}
+
+// Attaches the per-set rows to the entries that have them
+func (pgStore *PostgresWorkoutStore) loadSets(workout *Workout) error {
+	query := `
+	SELECT es.entry_id, es.id, es.set_number, es.reps, es.duration_seconds, es.weight, es.rpe, es.rir,
+		es.set_type, es.rest_seconds, es.completed
+	FROM entry_sets es
+	JOIN workout_entries we ON we.id = es.entry_id
+	WHERE we.workout_id = $1
+	ORDER BY es.entry_id ASC, es.set_number ASC
+	`
+	rows, err := pgStore.db.Query(query, workout.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	entries := make(map[int]*WorkoutEntry, len(workout.Entries))
+	for i := range workout.Entries {
+		entries[workout.Entries[i].ID] = &workout.Entries[i]
+	}
+
+	for rows.Next() {
+		var entryID int
+		set := EntrySet{}
+		err := rows.Scan(&entryID, &set.ID, &set.SetNumber, &set.Reps, &set.DurationSeconds, &set.Weight,
+			&set.RPE, &set.RIR, &set.SetType, &set.RestSeconds, &set.Completed)
+		if err != nil {
+			return err
+		}
+		if entry, ok := entries[entryID]; ok {
+			entry.SetsDetail = append(entry.SetsDetail, set)
+		}
+	}
+
+	return rows.Err()
 }
 
 func (pgStore *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
+	err := validateWorkout(workout)
+	if err != nil {
+		return err
+	}
+
 	tx, err := pgStore.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	// The owner and template can't change through an update, they're returned for the revision snapshot
 	updateQuery := `
 	UPDATE workouts
-	SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4
-	WHERE id = $5
+	SET title = $1, slug = $2, description = $3, duration_minutes = $4, calories_burned = $5
+	WHERE id = $6 AND deleted_at IS NULL
+	RETURNING user_id, template_id, created_at
 	`
-	result, err := tx.Exec(updateQuery, workout.Title, workout.Description,
-		workout.DurationMinutes, workout.CaloriesBurned, workout.ID)
+	var userID sql.NullInt64
+	err = tx.QueryRow(updateQuery, workout.Title, workout.Slug, workout.Description,
+		workout.DurationMinutes, workout.CaloriesBurned, workout.ID).Scan(&userID, &workout.TemplateID,
+		&workout.CreatedAt)
+	if err != nil {
+		return translateError(err) // sql.ErrNoRows if the workout doesn't exist
+	}
+	workout.UserID = int(userID.Int64)
+
+	previous, err := loadSavedState(tx, workout)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	// Delete existing workout entries and their groups
+	_, err = tx.Exec(`DELETE FROM workout_entries WHERE workout_id = $1`, workout.ID)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	_, err = tx.Exec(`DELETE FROM entry_groups WHERE workout_id = $1`, workout.ID)
+	if err != nil {
+		return err
 	}
 
-	// Delete existing workout entries
-	_, err = tx.Exec(`DELETE FROM workout_entries WHERE workout_id = $1`, workout.ID)
+	err = insertGroups(tx, workout)
 	if err != nil {
 		return err
 	}
 
 	// Insert updated workout entries
-	for _, entry := range workout.Entries {
-		entryQuery := `
-		INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		`
-		// Uses the returned workout id from the previous insert and scans returned entry id
-		_, err := tx.Exec(entryQuery, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps,
-			entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex)
-		if err != nil {
-			return err
-		}
+	err = insertEntries(tx, workout)
+	if err != nil {
+		return err
 	}
 
-	return tx.Commit()
-}
-
-func (pgStore *PostgresWorkoutStore) DeleteWorkout(id int64) error {
-	result, err := pgStore.db.Exec(`DELETE FROM workouts WHERE id = $1`, id)
+	err = insertRevision(tx, workout)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	workout.NewRecords, err = records.DetectInTx(tx, int64(workout.ID))
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	err = writeWorkoutEvents(tx, outbox.WorkoutUpdated, workout, previous)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	nestGroups(workout)
+	return nil
+}
+
+// Matches workouts about to be imported against the live workouts of userID. A workout is
+// a duplicate of one with the same title, ignoring case, started within the same minute,
+// so importing a file twice finds the whole first import. The result maps the index of
+// each duplicate in workouts to the id of the workout it duplicates.
+func (pgStore *PostgresWorkoutStore) FindDuplicateWorkouts(userID int64, workouts []*Workout) (map[int]int, error) {
+	duplicates := map[int]int{}
+	if len(workouts) == 0 {
+		return duplicates, nil
+	}
+
+	titles := make([]string, len(workouts))
+	startedAt := make([]time.Time, len(workouts))
+	for i, workout := range workouts {
+		titles[i], startedAt[i] = workout.Title, workout.CreatedAt
+	}
+
+	query := `
+	SELECT DISTINCT ON (c.idx) c.idx, w.id
+	FROM UNNEST($2::text[], $3::timestamptz[]) WITH ORDINALITY AS c (title, created_at, idx)
+	JOIN workouts w ON LOWER(w.title) = LOWER(c.title)
+		AND date_trunc('minute', w.created_at) = date_trunc('minute', c.created_at)
+	WHERE w.user_id IS NOT DISTINCT FROM $1 AND w.deleted_at IS NULL
+	ORDER BY c.idx, w.id
+	`
+	rows, err := pgStore.db.Query(query, sql.NullInt64{Int64: userID, Valid: userID != 0}, titles, startedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var index, workoutID int
+		err := rows.Scan(&index, &workoutID)
+		if err != nil {
+			return nil, err
+		}
+		duplicates[index-1] = workoutID // ORDINALITY counts from 1
+	}
+
+	return duplicates, rows.Err()
+}
+
+// Finds a live workout of userID logged from the same activity, whatever it was titled:
+// one started within the same minute that lasted as long or covered the same distance,
+// give or take 1% or 10 meters. Returns 0 when there's none.
+func (pgStore *PostgresWorkoutStore) FindDuplicateActivity(userID int64, summary activity.Summary) (int, error) {
+	query := `
+	SELECT w.id
+	FROM workouts w
+	WHERE w.user_id IS NOT DISTINCT FROM $1 AND w.deleted_at IS NULL
+		AND date_trunc('minute', w.created_at) = date_trunc('minute', $2::timestamptz)
+		AND (w.duration_minutes = $3 OR EXISTS (
+			SELECT 1 FROM workout_entries we
+			JOIN entry_cardio c ON c.entry_id = we.id
+			WHERE we.workout_id = w.id AND ABS(c.distance_meters - $4) <= GREATEST(10, $4 * 0.01)
+		))
+	ORDER BY w.id
+	LIMIT 1
+	`
+	var workoutID int
+	err := pgStore.db.QueryRow(query, sql.NullInt64{Int64: userID, Valid: userID != 0}, summary.StartedAt,
+		int(math.Round(float64(summary.DurationSeconds)/60)), summary.DistanceMeters).Scan(&workoutID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return workoutID, err
+}
+
+// Streams the entries of the live workouts matching filter to fn, oldest workout first.
+// Weights are in kilograms. The workout passed along is reused between calls and has no entries.
+func (pgStore *PostgresWorkoutStore) ExportEntries(filter ExportFilter, fn func(*Workout, *WorkoutEntry) error) error {
+	query := `
+	SELECT w.id, w.title, COALESCE(w.description, ''), w.duration_minutes, COALESCE(w.calories_burned, 0), w.created_at,
+		we.id, we.exercise_id, we.exercise_name, we.sets, we.reps, we.duration_seconds, we.weight,
+		COALESCE(we.notes, ''), we.order_index
+	FROM workouts w
+	JOIN workout_entries we ON we.workout_id = w.id
+	WHERE w.user_id IS NOT DISTINCT FROM $1 AND w.deleted_at IS NULL
+		AND ($2::timestamptz IS NULL OR w.created_at >= $2)
+		AND ($3::timestamptz IS NULL OR w.created_at < $3)
+	ORDER BY w.created_at, w.id, we.order_index, we.id
+	`
+	rows, err := pgStore.db.Query(query, sql.NullInt64{Int64: filter.UserID, Valid: filter.UserID != 0},
+		filter.From, filter.To)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	workout := &Workout{UserID: int(filter.UserID)}
+	for rows.Next() {
+		entry := WorkoutEntry{WeightUnit: units.Kilograms}
+		err := rows.Scan(&workout.ID, &workout.Title, &workout.Description, &workout.DurationMinutes,
+			&workout.CaloriesBurned, &workout.CreatedAt, &entry.ID, &entry.ExerciseID, &entry.ExerciseName,
+			&entry.Sets, &entry.Reps, &entry.DurationSeconds, &entry.Weight, &entry.Notes, &entry.OrderIndex)
+		if err != nil {
+			return err
+		}
+
+		err = fn(workout, &entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Inserts each workout entry as a row and records the generated entry ids
+func insertEntries(tx *sql.Tx, workout *Workout) error {
+	groupIDs := make(map[int]int, len(workout.Groups))
+	for _, group := range workout.Groups {
+		groupIDs[group.GroupID] = group.ID
+	}
+
+	defaultUnit, err := preferredWeightUnit(tx, workout.UserID)
+	if err != nil {
+		return err
+	}
+
+	for i := range workout.Entries {
+		entry := &workout.Entries[i]
+		loggedUnit, err := normalizeWeights(entry, defaultUnit)
+		if err != nil {
+			return err
+		}
+
+		err = summarizeSets(entry)
+		if err != nil {
+			return err
+		}
+
+		err = normalizeCardio(entry)
+		if err != nil {
+			return err
+		}
+
+		err = checkExercise(tx, entry.ExerciseID, workout.UserID)
+		if err != nil {
+			return err
+		}
+
+		// Entries without an exercise_id are linked to the catalog exercise whose name or
+		// alias matches exactly, preferring the user's custom exercises over built-in ones
+		entryQuery := `
+		INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, weight_unit, notes, order_index, group_id, exercise_id) 
+		VALUES ($1, $2, $3, $4, $5, $6, $12, $7, $8, $11, COALESCE($9, (
+			SELECT e.id FROM exercises e
+			WHERE (e.user_id IS NULL OR e.user_id = $10) AND (
+				LOWER(e.name) = LOWER(TRIM($2)) OR
+				EXISTS (SELECT 1 FROM UNNEST(e.aliases) alias WHERE LOWER(alias) = LOWER(TRIM($2)))
+			)
+			ORDER BY e.user_id IS NULL, e.id
+			LIMIT 1
+		)))
+		RETURNING id, exercise_id
+		`
+		var groupID sql.NullInt64
+		if entry.GroupID != nil {
+			groupID = sql.NullInt64{Int64: int64(groupIDs[*entry.GroupID]), Valid: true}
+		}
+
+		// Uses the workout id from the parent insert/update and scans returned entry id
+		err = tx.QueryRow(entryQuery, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps,
+			entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex, entry.ExerciseID,
+			workout.UserID, groupID, loggedUnit).Scan(&entry.ID, &entry.ExerciseID)
+		if err != nil {
+			return translateError(err)
+		}
+
+		err = insertSets(tx, entry)
+		if err != nil {
+			return err
+		}
+
+		err = insertCardio(tx, entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Inserts the cardio metrics of an entry, normalizeCardio has already converted the distance
+func insertCardio(tx *sql.Tx, entry *WorkoutEntry) error {
+	cardio := entry.Cardio
+	if cardio == nil {
+		return nil
+	}
+
+	query := `
+	INSERT INTO entry_cardio (entry_id, distance_meters, distance_unit, elevation_gain_meters, avg_heart_rate,
+		max_heart_rate, avg_cadence)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := tx.Exec(query, entry.ID, cardio.distanceMeters, cardio.DistanceUnit, cardio.ElevationGainMeters,
+		cardio.AvgHeartRate, cardio.MaxHeartRate, cardio.AvgCadence)
+	return err
+}
+
+// An exercise_id given with an entry must be a built-in exercise or one of the user's own
+func checkExercise(tx *sql.Tx, exerciseID *int, userID int) error {
+	if exerciseID == nil {
+		return nil
+	}
+
+	var visible bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM exercises WHERE id = $1 AND (user_id IS NULL OR user_id = $2))`,
+		*exerciseID, userID).Scan(&visible)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return ErrUnknownExercise
+	}
+	return nil
+}
+
+// Inserts the groups of a workout, validateGroups has already checked them
+func insertGroups(tx *sql.Tx, workout *Workout) error {
+	for i := range workout.Groups {
+		group := &workout.Groups[i]
+		query := `
+		INSERT INTO entry_groups (workout_id, group_number, group_type, rounds, rest_between_rounds_seconds,
+			duration_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+		`
+		err := tx.QueryRow(query, workout.ID, group.GroupID, group.GroupType, group.Rounds,
+			group.RestBetweenRoundsSeconds, group.DurationSeconds).Scan(&group.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Inserts the per-set rows of an entry, summarizeSets has already validated them
+func insertSets(tx *sql.Tx, entry *WorkoutEntry) error {
+	for i := range entry.SetsDetail {
+		set := &entry.SetsDetail[i]
+		query := `
+		INSERT INTO entry_sets (entry_id, set_number, reps, duration_seconds, weight, rpe, rir, set_type,
+			rest_seconds, completed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+		`
+		err := tx.QueryRow(query, entry.ID, set.SetNumber, set.Reps, set.DurationSeconds, set.Weight, set.RPE,
+			set.RIR, set.SetType, set.RestSeconds, set.Completed).Scan(&set.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Records a snapshot of the workout as the next revision within the caller's transaction
+func insertRevision(tx *sql.Tx, workout *Workout) error {
+	snapshot, err := json.Marshal(workout)
+	if err != nil {
+		return err
+	}
+
+	// Saves of the same workout take their revision numbers one after the other
+	_, err = tx.Exec(`SELECT 1 FROM workouts WHERE id = $1 FOR UPDATE`, workout.ID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO workout_revisions (workout_id, revision, snapshot)
+	SELECT $1, COALESCE(MAX(revision), 0) + 1, $2
+	FROM workout_revisions
+	WHERE workout_id = $1
+	`
+	_, err = tx.Exec(query, workout.ID, string(snapshot))
+	return translateError(err)
+}
+
+// DeleteWorkout moves a workout into the trash. Its entries are kept so that
+// the workout can be restored until the purge job removes it for good.
+func (pgStore *PostgresWorkoutStore) DeleteWorkout(id int64) error {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // rollback transaction if not committed
+
+	query := `
+	UPDATE workouts
+	SET deleted_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND deleted_at IS NULL
+	RETURNING user_id
+	`
+	var userID sql.NullInt64
+	err = tx.QueryRow(query, id).Scan(&userID)
+	if err != nil {
+		return err // sql.ErrNoRows if the workout doesn't exist
+	}
+
+	err = outbox.Write(tx, outbox.WorkoutDeleted, int(userID.Int64), int(id), map[string]int64{"id": id})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Lists the trash of userID, most recently deleted first
+func (pgStore *PostgresWorkoutStore) ListDeletedWorkouts(userID int64) ([]Workout, error) {
+	query := `
+	SELECT id, user_id, title, slug, description, duration_minutes, calories_burned, deleted_at
+	FROM workouts
+	WHERE deleted_at IS NOT NULL AND user_id IS NOT DISTINCT FROM $1
+	ORDER BY deleted_at DESC
+	`
+	rows, err := pgStore.db.Query(query, nullableID(int(userID)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := []Workout{}
+	for rows.Next() {
+		var workout Workout
+		var userID sql.NullInt64
+		err := rows.Scan(&workout.ID, &userID, &workout.Title, &workout.Slug, &workout.Description,
+			&workout.DurationMinutes, &workout.CaloriesBurned, &workout.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		workout.UserID = int(userID.Int64)
+		workouts = append(workouts, workout)
+	}
+
+	return workouts, rows.Err()
+}
+
+// Restores a workout from the trash of userID, sql.ErrNoRows when it isn't there
+func (pgStore *PostgresWorkoutStore) RestoreWorkout(id, userID int64) error {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // rollback transaction if not committed
+
+	query := `
+	UPDATE workouts
+	SET deleted_at = NULL
+	WHERE id = $1 AND deleted_at IS NOT NULL AND user_id IS NOT DISTINCT FROM $2
+	`
+	result, err := tx.Exec(query, id, nullableID(int(userID)))
+	if err != nil {
+		return translateError(err) // Another live workout may have taken the slug
+	}
+	restored, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if restored == 0 {
+		return sql.ErrNoRows // the workout isn't in the trash
+	}
+
+	err = outbox.Write(tx, outbox.WorkoutRestored, int(userID), int(id), map[string]int64{"id": id})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeWorkout permanently removes a workout from the trash of userID, cascading to
+// its entries. sql.ErrNoRows when it isn't in the trash.
+func (pgStore *PostgresWorkoutStore) PurgeWorkout(id, userID int64) error {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // rollback transaction if not committed
+
+	query := `
+	DELETE FROM workouts
+	WHERE id = $1 AND deleted_at IS NOT NULL AND user_id IS NOT DISTINCT FROM $2
+	`
+	result, err := tx.Exec(query, id, nullableID(int(userID)))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	err = outbox.Write(tx, outbox.WorkoutPurged, int(userID), int(id), map[string]int64{"id": id})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeDeletedWorkouts permanently removes trashed workouts that were deleted
+// before the cutoff and returns how many were removed.
+func (pgStore *PostgresWorkoutStore) PurgeDeletedWorkouts(deletedBefore time.Time) (int64, error) {
+	tx, err := pgStore.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // rollback transaction if not committed
+
+	query := `
+	DELETE FROM workouts
+	WHERE deleted_at IS NOT NULL AND deleted_at < $1
+	RETURNING id, user_id
+	`
+	rows, err := tx.Query(query, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	type purgedWorkout struct {
+		id     int
+		userID sql.NullInt64
+	}
+	var purged []purgedWorkout
+	for rows.Next() {
+		var workout purgedWorkout
+		err := rows.Scan(&workout.id, &workout.userID)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		purged = append(purged, workout)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, workout := range purged {
+		err = outbox.Write(tx, outbox.WorkoutPurged, int(workout.userID.Int64), workout.id,
+			map[string]int{"id": workout.id})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(purged)), tx.Commit()
+}
+
+func (pgStore *PostgresWorkoutStore) ListWorkoutRevisions(workoutID int64) ([]WorkoutRevision, error) {
+	query := `
+	SELECT id, workout_id, revision, snapshot, created_at
+	FROM workout_revisions
+	WHERE workout_id = $1
+	ORDER BY revision ASC
+	`
+	rows, err := pgStore.db.Query(query, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []WorkoutRevision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (pgStore *PostgresWorkoutStore) GetWorkoutRevision(workoutID int64, revision int) (*WorkoutRevision, error) {
+	query := `
+	SELECT id, workout_id, revision, snapshot, created_at
+	FROM workout_revisions
+	WHERE workout_id = $1 AND revision = $2
+	`
+	workoutRevision, err := scanRevision(pgStore.db.QueryRow(query, workoutID, revision))
+	if err == sql.ErrNoRows {
+		return nil, nil // No revision found
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return workoutRevision, nil
+}
+
+// RevertWorkout restores the workout to an earlier snapshot. The revert is
+// saved as a new revision so the history is never rewritten.
+func (pgStore *PostgresWorkoutStore) RevertWorkout(workoutID int64, revision int) (*Workout, error) {
+	workoutRevision, err := pgStore.GetWorkoutRevision(workoutID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if workoutRevision == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	workout := workoutRevision.Snapshot
+	workout.ID = int(workoutID)
+
+	// Snapshots hold stored weights, which are kilograms even where older snapshots don't say so
+	for i := range workout.Entries {
+		workout.Entries[i].WeightUnit = units.Kilograms
+	}
+
+	err = pgStore.UpdateWorkout(&workout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &workout, nil
+}
+
+// Shared scanner for *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRevision(row rowScanner) (*WorkoutRevision, error) {
+	revision := &WorkoutRevision{}
+	var snapshot []byte
+
+	err := row.Scan(&revision.ID, &revision.WorkoutID, &revision.Revision, &snapshot, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(snapshot, &revision.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// Workouts without an owner are stored with a NULL user_id
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// Maps postgres constraint violations onto errors the handlers can act on
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch {
+	case pgErr.Code == "23505" && pgErr.ConstraintName == "workouts_user_slug_key": // unique_violation
+		return ErrDuplicateSlug
+	case pgErr.Code == "23503" && pgErr.ConstraintName == "workouts_user_id_fkey": // foreign_key_violation
+		return ErrUnknownUser
+	case pgErr.Code == "23503" && pgErr.ConstraintName == "workout_entries_exercise_id_fkey":
+		return ErrUnknownExercise
+	case pgErr.Code == "23505" && pgErr.ConstraintName == "workout_revisions_workout_id_revision_key":
+		return ErrRevisionConflict
+	}
+
+	return err
 }
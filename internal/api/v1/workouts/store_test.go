@@ -1,9 +1,18 @@
 package workouts
 
 import (
+	"database/sql"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Josesx506/gofems/internal/activity"
+	"github.com/Josesx506/gofems/internal/api/v1/exercises"
+	"github.com/Josesx506/gofems/internal/api/v1/records"
+	"github.com/Josesx506/gofems/internal/outbox"
 	"github.com/Josesx506/gofems/internal/store"
+	"github.com/Josesx506/gofems/internal/units"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -101,6 +110,591 @@ func TestCreateWorkout(t *testing.T) {
 	}
 }
 
+func TestSoftDeleteWorkout(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWorkoutStore(db)
+
+	workout, err := pgStore.CreateWorkout(&Workout{
+		Title:           "Evening Stretch",
+		Description:     "Light mobility work",
+		DurationMinutes: 20,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Hamstring Stretch", Sets: 2, DurationSeconds: IntPtr(45), OrderIndex: 1},
+		},
+	})
+	require.NoError(t, err)
+	workoutID := int64(workout.ID)
+
+	// Deleting moves the workout to the trash and hides it from reads
+	require.NoError(t, pgStore.DeleteWorkout(workoutID))
+	retrieved, err := pgStore.GetWorkoutByID(workoutID)
+	require.NoError(t, err)
+	assert.Nil(t, retrieved)
+	assert.ErrorIs(t, pgStore.DeleteWorkout(workoutID), sql.ErrNoRows)
+
+	trash, err := pgStore.ListDeletedWorkouts(0)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, workout.ID, trash[0].ID)
+	assert.NotNil(t, trash[0].DeletedAt)
+
+	// The trash is its owner's only
+	trash, err = pgStore.ListDeletedWorkouts(42)
+	require.NoError(t, err)
+	assert.Empty(t, trash)
+	assert.ErrorIs(t, pgStore.RestoreWorkout(workoutID, 42), sql.ErrNoRows)
+	assert.ErrorIs(t, pgStore.PurgeWorkout(workoutID, 42), sql.ErrNoRows)
+
+	// Restoring brings back the workout with its entries
+	require.NoError(t, pgStore.RestoreWorkout(workoutID, 0))
+	retrieved, err = pgStore.GetWorkoutByID(workoutID)
+	require.NoError(t, err)
+	require.NotNil(t, retrieved)
+	assert.Len(t, retrieved.Entries, 1)
+	assert.ErrorIs(t, pgStore.RestoreWorkout(workoutID, 0), sql.ErrNoRows)
+	assert.ErrorIs(t, pgStore.PurgeWorkout(workoutID, 0), sql.ErrNoRows, "live workouts go through the trash")
+
+	// Only workouts trashed before the cutoff are purged
+	require.NoError(t, pgStore.DeleteWorkout(workoutID))
+	purged, err := pgStore.PurgeDeletedWorkouts(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), purged)
+
+	purged, err = pgStore.PurgeDeletedWorkouts(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.ErrorIs(t, pgStore.RestoreWorkout(workoutID, 0), sql.ErrNoRows)
+}
+
+func TestWorkoutRevisions(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWorkoutStore(db)
+
+	workout, err := pgStore.CreateWorkout(&Workout{
+		Title:           "Push Day",
+		DurationMinutes: 45,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(80.0), OrderIndex: 1},
+		},
+	})
+	require.NoError(t, err)
+	workoutID := int64(workout.ID)
+
+	workout.Title = "Heavy Push Day"
+	workout.Entries[0].Weight = FloatPtr(90.0)
+	require.NoError(t, pgStore.UpdateWorkout(workout))
+
+	revisions, err := pgStore.ListWorkoutRevisions(workoutID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "Push Day", revisions[0].Snapshot.Title)
+	assert.Equal(t, "Heavy Push Day", revisions[1].Snapshot.Title)
+
+	// Reverting applies the old snapshot and records it as a new revision
+	reverted, err := pgStore.RevertWorkout(workoutID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Push Day", reverted.Title)
+
+	retrieved, err := pgStore.GetWorkoutByID(workoutID)
+	require.NoError(t, err)
+	assert.Equal(t, FloatPtr(80.0), retrieved.Entries[0].Weight)
+
+	latest, err := pgStore.GetWorkoutRevision(workoutID, 3)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, "Push Day", latest.Snapshot.Title)
+
+	_, err = pgStore.RevertWorkout(workoutID, 42)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Concurrent saves wait for each other rather than racing for a revision number
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = pgStore.RevertWorkout(workoutID, 2)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	revisions, err = pgStore.ListWorkoutRevisions(workoutID)
+	require.NoError(t, err)
+	assert.Len(t, revisions, 7)
+}
+
+func TestWorkoutTitleAndSlugScope(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWorkoutStore(db)
+
+	var aliceID, bobID int
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('alice', 'alice@example.com', 'hash-a') RETURNING id`).Scan(&aliceID))
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('bob', 'bob@example.com', 'hash-b') RETURNING id`).Scan(&bobID))
+
+	slug := "leg-day"
+	newWorkout := func(userID int, slug *string) *Workout {
+		return &Workout{
+			UserID:          userID,
+			Title:           "Leg Day",
+			Slug:            slug,
+			DurationMinutes: 60,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Squats", Sets: 3, Reps: IntPtr(10), OrderIndex: 1},
+			},
+		}
+	}
+
+	// Repeated titles are allowed for the same and different users
+	_, err := pgStore.CreateWorkout(newWorkout(aliceID, nil))
+	require.NoError(t, err)
+	_, err = pgStore.CreateWorkout(newWorkout(aliceID, nil))
+	require.NoError(t, err)
+	_, err = pgStore.CreateWorkout(newWorkout(bobID, nil))
+	require.NoError(t, err)
+
+	// Slugs are unique per user only
+	first, err := pgStore.CreateWorkout(newWorkout(aliceID, &slug))
+	require.NoError(t, err)
+	_, err = pgStore.CreateWorkout(newWorkout(bobID, &slug))
+	require.NoError(t, err)
+	_, err = pgStore.CreateWorkout(newWorkout(aliceID, &slug))
+	assert.ErrorIs(t, err, ErrDuplicateSlug)
+
+	// A trashed workout frees its slug, restoring it conflicts with the new owner of the slug
+	require.NoError(t, pgStore.DeleteWorkout(int64(first.ID)))
+	_, err = pgStore.CreateWorkout(newWorkout(aliceID, &slug))
+	require.NoError(t, err)
+	assert.ErrorIs(t, pgStore.RestoreWorkout(int64(first.ID), int64(aliceID)), ErrDuplicateSlug)
+
+	_, err = pgStore.CreateWorkout(newWorkout(aliceID+bobID+1, nil))
+	assert.ErrorIs(t, err, ErrUnknownUser)
+}
+
+func TestEntryExerciseLinking(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWorkoutStore(db)
+	exerciseStore := exercises.NewPostgresExerciseStore(db)
+
+	var userID int
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('linker', 'linker@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	custom, err := exerciseStore.CreateExercise(&exercises.Exercise{
+		UserID: userID, Name: "Zercher Squat", Aliases: []string{"Zerchers"}, Category: "strength",
+		MeasurementType: exercises.MeasurementReps, Equipment: "barbell",
+		PrimaryMuscles: []string{}, SecondaryMuscles: []string{},
+	})
+	require.NoError(t, err)
+
+	catalog, err := exerciseStore.ListExercises(int64(userID), "")
+	require.NoError(t, err)
+
+	workout := &Workout{
+		UserID:          userID,
+		Title:           "Leg Day Workout",
+		DurationMinutes: 60,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Squats", Sets: 4, Reps: IntPtr(12), OrderIndex: 1},
+			{ExerciseName: "Zerchers", Sets: 3, Reps: IntPtr(8), OrderIndex: 2},
+			{ExerciseName: "Dedlift", Sets: 3, Reps: IntPtr(5), OrderIndex: 3},
+			{ExerciseName: "Something Made Up", Sets: 3, Reps: IntPtr(8), OrderIndex: 4},
+		},
+	}
+
+	// Only the typo needs the fuzzy pass, exact names and aliases are linked on insert
+	links, unresolved := linkExercises(workout, catalog, exercises.DefaultMatchThreshold)
+	require.Len(t, unresolved, 1)
+	assert.Equal(t, "Something Made Up", unresolved[0].ExerciseName)
+	assert.Len(t, links, 3)
+	workout.Entries[0].ExerciseID = nil
+	workout.Entries[1].ExerciseID = nil
+
+	created, err := pgStore.CreateWorkout(workout)
+	require.NoError(t, err)
+
+	retrieved, err := pgStore.GetWorkoutByID(int64(created.ID))
+	require.NoError(t, err)
+	require.NotNil(t, retrieved.Entries[0].ExerciseID)
+	assert.Equal(t, custom.ID, *retrieved.Entries[1].ExerciseID)
+	require.NotNil(t, retrieved.Entries[2].ExerciseID)
+	assert.Nil(t, retrieved.Entries[3].ExerciseID)
+
+	backSquat, err := exerciseStore.GetExerciseByID(int64(*retrieved.Entries[0].ExerciseID))
+	require.NoError(t, err)
+	assert.Equal(t, "Back Squat", backSquat.Name)
+
+	deadlift, err := exerciseStore.GetExerciseByID(int64(*retrieved.Entries[2].ExerciseID))
+	require.NoError(t, err)
+	assert.Equal(t, "Deadlift", deadlift.Name)
+
+	// Another user's custom exercise can't be referenced by id
+	var otherID int
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('other', 'other@example.com', 'hash') RETURNING id`).Scan(&otherID))
+	_, err = pgStore.CreateWorkout(&Workout{UserID: otherID, Title: "Borrowed", DurationMinutes: 30,
+		Entries: []WorkoutEntry{{ExerciseName: "Zercher Squat", ExerciseID: &custom.ID, Sets: 3, Reps: IntPtr(8)}}})
+	assert.ErrorIs(t, err, ErrUnknownExercise)
+	_, err = pgStore.CreateWorkout(&Workout{UserID: otherID, Title: "Squats", DurationMinutes: 30,
+		Entries: []WorkoutEntry{{ExerciseName: "Back Squat", ExerciseID: &backSquat.ID, Sets: 3, Reps: IntPtr(8)}}})
+	assert.NoError(t, err, "catalog exercises are everyone's")
+}
+
+func TestPersonalRecordDetection(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWorkoutStore(db)
+	recordStore := records.NewPostgresRecordStore(db)
+
+	benchDay := func(weight float64) *Workout {
+		return &Workout{
+			Title:           "Push Day",
+			DurationMinutes: 45,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(weight), OrderIndex: 1},
+			},
+		}
+	}
+
+	first, err := pgStore.CreateWorkout(benchDay(80))
+	require.NoError(t, err)
+	assert.NotEmpty(t, first.NewRecords)
+
+	// A lighter session sets no records
+	lighter, err := pgStore.CreateWorkout(benchDay(70))
+	require.NoError(t, err)
+	assert.Empty(t, lighter.NewRecords)
+
+	heavier, err := pgStore.CreateWorkout(benchDay(85))
+	require.NoError(t, err)
+	recordTypes := map[string]records.Record{}
+	for _, record := range heavier.NewRecords {
+		recordTypes[record.RecordType] = record
+	}
+	require.Contains(t, recordTypes, records.MaxWeight)
+	assert.Equal(t, 85.0, recordTypes[records.MaxWeight].Value)
+	assert.Equal(t, 80.0, *recordTypes[records.MaxWeight].PreviousValue)
+	assert.Equal(t, heavier.ID, recordTypes[records.MaxWeight].WorkoutID)
+
+	current, err := recordStore.ListCurrentRecords(0, 0)
+	require.NoError(t, err)
+	require.Len(t, current, 1)
+	for _, record := range current[0].Records {
+		if record.RecordType == records.MaxWeight {
+			assert.Equal(t, 85.0, record.Value)
+		}
+	}
+
+	// Trashing the record-setting workout lets the previous record stand again
+	require.NoError(t, pgStore.DeleteWorkout(int64(heavier.ID)))
+	current, err = recordStore.ListCurrentRecords(0, 0)
+	require.NoError(t, err)
+	for _, record := range current[0].Records {
+		if record.RecordType == records.MaxWeight {
+			assert.Equal(t, 80.0, record.Value)
+		}
+	}
+}
+
+func TestEntrySets(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWorkoutStore(db)
+
+	workout := &Workout{
+		Title:           "Pyramid Day",
+		DurationMinutes: 50,
+		Entries: []WorkoutEntry{
+			{
+				ExerciseName: "Deadlift",
+				OrderIndex:   1,
+				SetsDetail: []EntrySet{
+					{Reps: IntPtr(5), Weight: FloatPtr(100), SetType: SetWarmup, RestSeconds: IntPtr(90)},
+					{Reps: IntPtr(3), Weight: FloatPtr(140), RPE: FloatPtr(8.5)},
+					{Reps: IntPtr(1), Weight: FloatPtr(160), RIR: IntPtr(0), SetType: SetFailure},
+				},
+			},
+			{ExerciseName: "Plank", Sets: 3, DurationSeconds: IntPtr(60), OrderIndex: 2},
+		},
+	}
+
+	createdWorkout, err := pgStore.CreateWorkout(workout)
+	require.NoError(t, err)
+	assert.Equal(t, 2, createdWorkout.Entries[0].Sets)
+	assert.Equal(t, 160.0, *createdWorkout.Entries[0].Weight)
+
+	retrieved, err := pgStore.GetWorkoutByID(int64(createdWorkout.ID))
+	require.NoError(t, err)
+	require.Len(t, retrieved.Entries[0].SetsDetail, 3)
+	assert.Empty(t, retrieved.Entries[1].SetsDetail, "legacy entries have no per-set rows")
+
+	set := retrieved.Entries[0].SetsDetail[1]
+	assert.Equal(t, 2, set.SetNumber)
+	assert.Equal(t, 8.5, *set.RPE)
+	assert.Equal(t, SetWorking, set.SetType)
+	assert.True(t, *set.Completed)
+	assert.Equal(t, 1, *retrieved.Entries[0].Reps)
+
+	// A set the database would accept but the summary can't represent is rejected
+	retrieved.Entries[0].SetsDetail = append(retrieved.Entries[0].SetsDetail, EntrySet{DurationSeconds: IntPtr(30)})
+	assert.ErrorIs(t, pgStore.UpdateWorkout(retrieved), ErrInvalidSet)
+}
+
+func TestEntryGroups(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWorkoutStore(db)
+
+	workout := &Workout{
+		Title:           "Upper Body Supersets",
+		DurationMinutes: 40,
+		Groups: []EntryGroup{
+			{GroupID: 1, GroupType: GroupSuperset, Rounds: 4, RestBetweenRoundsSeconds: IntPtr(120)},
+		},
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bench Press", Sets: 4, Reps: IntPtr(8), OrderIndex: 1, GroupID: IntPtr(1)},
+			{ExerciseName: "Barbell Row", Sets: 4, Reps: IntPtr(8), OrderIndex: 2, GroupID: IntPtr(1)},
+			{ExerciseName: "Plank", Sets: 3, DurationSeconds: IntPtr(60), OrderIndex: 3},
+		},
+	}
+
+	createdWorkout, err := pgStore.CreateWorkout(workout)
+	require.NoError(t, err)
+
+	retrieved, err := pgStore.GetWorkoutByID(int64(createdWorkout.ID))
+	require.NoError(t, err)
+	require.Len(t, retrieved.Groups, 1)
+	assert.Equal(t, GroupSuperset, retrieved.Groups[0].GroupType)
+	assert.Equal(t, 4, retrieved.Groups[0].Rounds)
+	require.Len(t, retrieved.Groups[0].Entries, 2)
+	assert.Equal(t, "Barbell Row", retrieved.Groups[0].Entries[1].ExerciseName)
+	assert.Nil(t, retrieved.Entries[2].GroupID)
+
+	// Turning the superset into a circuit that includes the plank
+	retrieved.Groups[0].GroupType = GroupCircuit
+	retrieved.Entries[2].GroupID = IntPtr(1)
+	require.NoError(t, pgStore.UpdateWorkout(retrieved))
+
+	updated, err := pgStore.GetWorkoutByID(int64(createdWorkout.ID))
+	require.NoError(t, err)
+	require.Len(t, updated.Groups, 1)
+	assert.Equal(t, GroupCircuit, updated.Groups[0].GroupType)
+	assert.Len(t, updated.Groups[0].Entries, 3)
+
+	// Splitting the group is rejected before anything is written
+	updated.Entries[1].GroupID = nil
+	assert.ErrorIs(t, pgStore.UpdateWorkout(updated), ErrInvalidGroup)
+}
+
+func TestWeightUnits(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWorkoutStore(db)
+
+	var userID int
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash, preferred_weight_unit)
+		VALUES ('imperial', 'imperial@example.com', 'hash', 'lb') RETURNING id`).Scan(&userID))
+
+	workout := &Workout{
+		UserID:          userID,
+		Title:           "Heavy Singles",
+		DurationMinutes: 30,
+		Entries: []WorkoutEntry{
+			// No weight_unit, so the owner's pounds
+			{ExerciseName: "Deadlift", Sets: 1, Reps: IntPtr(1), Weight: FloatPtr(1005), OrderIndex: 1},
+			{ExerciseName: "Back Squat", Sets: 1, Reps: IntPtr(1), Weight: FloatPtr(200), WeightUnit: units.Kilograms, OrderIndex: 2},
+		},
+	}
+
+	createdWorkout, err := pgStore.CreateWorkout(workout)
+	require.NoError(t, err)
+
+	var stored float64
+	var loggedUnit string
+	require.NoError(t, db.QueryRow(`SELECT weight, weight_unit FROM workout_entries WHERE id = $1`,
+		createdWorkout.Entries[0].ID).Scan(&stored, &loggedUnit))
+	assert.Equal(t, 455.86, stored, "stored in kilograms")
+	assert.Equal(t, "lb", loggedUnit)
+
+	retrieved, err := pgStore.GetWorkoutByID(int64(createdWorkout.ID))
+	require.NoError(t, err)
+	retrieved.ConvertWeights(units.Pounds)
+	assert.Equal(t, 1005.0, *retrieved.Entries[0].Weight)
+	assert.Equal(t, 440.92, *retrieved.Entries[1].Weight)
+	assert.Equal(t, units.Pounds, retrieved.Entries[1].WeightUnit)
+
+	// Saving a converted response back keeps the weights, the unit travels with them
+	require.NoError(t, pgStore.UpdateWorkout(retrieved))
+	updated, err := pgStore.GetWorkoutByID(int64(createdWorkout.ID))
+	require.NoError(t, err)
+	assert.InDelta(t, 200.0, *updated.Entries[1].Weight, 0.01)
+
+	_, err = pgStore.CreateWorkout(&Workout{
+		Title:   "Stones",
+		Entries: []WorkoutEntry{{ExerciseName: "Atlas Stone", Sets: 1, Reps: IntPtr(1), Weight: FloatPtr(14), WeightUnit: "st", OrderIndex: 1}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidWeightUnit)
+}
+
+func TestImportExportWorkouts(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWorkoutStore(db)
+
+	file := "date,title,exercise_name,sets,reps,weight,weight_unit\n" +
+		"2023-01-10,Lower,Back Squat,5,5,100,kg\n" +
+		"2023-01-12,Lower,Back Squat,5,5,110,kg\n" +
+		"2023-01-12,Lower,Leg Curl,3,12,40,kg\n"
+	result, err := ParseCSV(strings.NewReader(file), nil)
+	require.NoError(t, err)
+	require.Empty(t, result.Errors)
+
+	require.NoError(t, pgStore.ImportWorkouts(result.Workouts))
+	require.Len(t, result.Workouts, 2)
+	assert.Equal(t, time.Date(2023, 1, 12, 0, 0, 0, 0, time.UTC), result.Workouts[1].CreatedAt.UTC(),
+		"imports keep their dates")
+	assert.NotEmpty(t, result.Workouts[1].NewRecords, "records are detected in date order")
+
+	var rows [][]string
+	err = pgStore.ExportEntries(ExportFilter{}, func(workout *Workout, entry *WorkoutEntry) error {
+		rows = append(rows, EncodeCSVRow(workout, entry))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "2023-01-10T00:00:00Z", rows[0][1])
+	assert.Equal(t, "Leg Curl", rows[2][6])
+	assert.Equal(t, "110", rows[1][10])
+
+	from := time.Date(2023, 1, 11, 0, 0, 0, 0, time.UTC)
+	count := 0
+	err = pgStore.ExportEntries(ExportFilter{From: &from}, func(*Workout, *WorkoutEntry) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// A failing workout rolls the whole import back
+	bad := []*Workout{
+		{Title: "Fine", Entries: []WorkoutEntry{{ExerciseName: "Row", Sets: 1, Reps: IntPtr(10), OrderIndex: 1}}},
+		{UserID: 999999, Title: "Orphan", Entries: []WorkoutEntry{{ExerciseName: "Row", Sets: 1, Reps: IntPtr(10), OrderIndex: 1}}},
+	}
+	assert.ErrorIs(t, pgStore.ImportWorkouts(bad), ErrUnknownUser)
+
+	var total int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM workouts`).Scan(&total))
+	assert.Equal(t, 2, total)
+}
+
+func TestFindDuplicateWorkouts(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWorkoutStore(db)
+
+	file := "Date,Workout Name,Exercise Name,Set Order,Weight,Reps\n" +
+		"2024-01-08 18:02:11,Evening Push,Bench Press,1,100,5\n" +
+		"2024-01-09 18:00:00,Pull,Row,1,60,10\n"
+	first, err := ParseImport(strings.NewReader(file), FormatStrong, nil, ImportOptions{})
+	require.NoError(t, err)
+	require.NoError(t, pgStore.ImportWorkouts(first.Workouts))
+
+	// The same sessions, seconds apart and in another case, plus a new one
+	again := []*Workout{
+		{Title: "evening push", CreatedAt: time.Date(2024, 1, 8, 18, 2, 40, 0, time.UTC)},
+		{Title: "Pull", CreatedAt: time.Date(2024, 1, 10, 18, 0, 0, 0, time.UTC)},
+		{Title: "Pull", CreatedAt: time.Date(2024, 1, 9, 18, 0, 0, 0, time.UTC)},
+	}
+	duplicates, err := pgStore.FindDuplicateWorkouts(0, again)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{0: first.Workouts[0].ID, 2: first.Workouts[1].ID}, duplicates)
+
+	// Other users' workouts don't count
+	duplicates, err = pgStore.FindDuplicateWorkouts(42, again)
+	require.NoError(t, err)
+	assert.Empty(t, duplicates)
+}
+
+func TestActivityWorkout(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	pgStore := NewPostgresWorkoutStore(db)
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	points := []activity.TrackPoint{}
+	for i := range 5 {
+		latitude, longitude := 51.5+float64(i)*0.0045, -0.1
+		heartRate := 140 + i
+		points = append(points, activity.TrackPoint{Time: start.Add(time.Duration(i) * 150 * time.Second),
+			Latitude: &latitude, Longitude: &longitude, HeartRate: &heartRate})
+	}
+	recorded := &activity.Activity{Name: "Lunch Run", Sport: activity.Running, Points: points}
+	summary := activity.Summarize(points, activity.Running, 70)
+
+	workout := NewActivityWorkout(recorded, activity.Running, summary, "")
+	assert.Equal(t, "Lunch Run", workout.Title)
+	assert.Equal(t, 10, workout.DurationMinutes)
+	require.NoError(t, pgStore.CreateActivityWorkout(workout, points))
+	assert.Equal(t, start, workout.CreatedAt.UTC(), "the workout starts with the track")
+
+	retrieved, err := pgStore.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	require.Len(t, retrieved.Entries, 1)
+	entry := retrieved.Entries[0]
+	assert.Equal(t, "Running", entry.ExerciseName)
+	assert.InDelta(t, 2.0, *entry.Cardio.Distance, 0.01)
+	assert.Equal(t, 142, *entry.Cardio.AvgHeartRate)
+	assert.Equal(t, 144, *entry.Cardio.MaxHeartRate)
+	assert.NotNil(t, entry.Cardio.PaceSecondsPerKm)
+
+	track, err := pgStore.ListTrackPoints(int64(workout.ID))
+	require.NoError(t, err)
+	require.Len(t, track, 5)
+	assert.Equal(t, start.Add(150*time.Second), track[1].Time.UTC())
+	assert.InDelta(t, 500.3, *track[1].DistanceMeters, 1)
+	assert.Nil(t, track[1].ElevationMeters)
+
+	// Uploading the activity again is a duplicate whatever it's titled
+	existingID, err := pgStore.FindDuplicateActivity(0, summary)
+	require.NoError(t, err)
+	assert.Equal(t, workout.ID, existingID)
+	later := summary
+	later.StartedAt = later.StartedAt.Add(time.Hour)
+	existingID, err = pgStore.FindDuplicateActivity(0, later)
+	require.NoError(t, err)
+	assert.Zero(t, existingID)
+
+	renamed := NewActivityWorkout(recorded, activity.Running, summary, "Lunch Run")
+	renamed.Slug = new(string)
+	assert.ErrorIs(t, pgStore.CreateActivityWorkout(renamed, points), ErrInvalidSlug, "activities are validated")
+
+	// The track goes with the workout
+	require.NoError(t, pgStore.DeleteWorkout(int64(workout.ID)))
+	require.NoError(t, pgStore.PurgeWorkout(int64(workout.ID), 0))
+	var remaining int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM workout_track_points`).Scan(&remaining))
+	assert.Equal(t, 0, remaining)
+}
+
 func IntPtr(i int) *int {
 	return &i
 }
@@ -108,3 +702,81 @@ func IntPtr(i int) *int {
 func FloatPtr(i float64) *float64 {
 	return &i
 }
+
+func TestWorkoutEvents(t *testing.T) {
+	db := store.SetupTestDB(t, "../../../../migrations/")
+	defer db.Close()
+
+	_, err := db.Exec(`DELETE FROM outbox_events`)
+	require.NoError(t, err)
+
+	pgStore := NewPostgresWorkoutStore(db)
+	workout, err := pgStore.CreateWorkout(&Workout{
+		Title:           "Push Day",
+		DurationMinutes: 45,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(80), OrderIndex: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	eventTypes := func() []string {
+		rows, err := db.Query(`SELECT event_type FROM outbox_events WHERE workout_id = $1 ORDER BY id`, workout.ID)
+		require.NoError(t, err)
+		defer rows.Close()
+
+		var types []string
+		for rows.Next() {
+			var eventType string
+			require.NoError(t, rows.Scan(&eventType))
+			types = append(types, eventType)
+		}
+		return types
+	}
+
+	created := eventTypes()
+	require.NotEmpty(t, created)
+	assert.Equal(t, []string{outbox.WorkoutCreated, outbox.EntryAdded}, created[:2])
+	assert.Len(t, created, 2+len(workout.NewRecords), "one pr.achieved per record")
+
+	// Only the appended entry is new, and the bench records are the ones already held
+	workout.Entries = append(workout.Entries, WorkoutEntry{ExerciseName: "Dips", Sets: 3, Reps: IntPtr(12),
+		OrderIndex: 2})
+	require.NoError(t, pgStore.UpdateWorkout(workout))
+
+	updated := eventTypes()[len(created):]
+	require.NotEmpty(t, updated)
+	assert.Equal(t, []string{outbox.WorkoutUpdated, outbox.EntryAdded}, updated[:2])
+	for _, eventType := range updated[2:] {
+		assert.Equal(t, outbox.PRAchieved, eventType)
+	}
+	assert.Len(t, updated, 2+len(workout.NewRecords)-len(created[2:]), "records aren't announced twice")
+
+	// Trashing and restoring are announced too
+	require.NoError(t, pgStore.DeleteWorkout(int64(workout.ID)))
+	require.NoError(t, pgStore.RestoreWorkout(int64(workout.ID), int64(workout.UserID)))
+	all := eventTypes()
+	assert.Equal(t, []string{outbox.WorkoutDeleted, outbox.WorkoutRestored}, all[len(all)-2:])
+
+	// An entry put before the others is the only new one, however the rest moved
+	saved, err := pgStore.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	saved.Entries = append([]WorkoutEntry{{ExerciseName: "Push Up", Sets: 2, Reps: IntPtr(20)}}, saved.Entries...)
+	for i := range saved.Entries {
+		saved.Entries[i].OrderIndex = i + 1
+	}
+	require.NoError(t, pgStore.UpdateWorkout(saved))
+	added := 0
+	for _, eventType := range eventTypes()[len(all):] {
+		if eventType == outbox.EntryAdded {
+			added++
+		}
+	}
+	assert.Equal(t, 1, added)
+
+	// Purging from the trash is announced as well
+	require.NoError(t, pgStore.DeleteWorkout(int64(saved.ID)))
+	require.NoError(t, pgStore.PurgeWorkout(int64(saved.ID), int64(saved.UserID)))
+	all = eventTypes()
+	assert.Equal(t, []string{outbox.WorkoutDeleted, outbox.WorkoutPurged}, all[len(all)-2:])
+}
@@ -0,0 +1,75 @@
+package workouts
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Josesx506/gofems/internal/api/v1/records"
+	"github.com/Josesx506/gofems/internal/units"
+)
+
+var ErrInvalidWeightUnit = errors.New("invalid weight unit")
+
+// Entries without a weight_unit are taken to be in the owner's preferred unit
+func preferredWeightUnit(tx *sql.Tx, userID int) (units.WeightUnit, error) {
+	if userID == 0 {
+		return units.Kilograms, nil
+	}
+
+	var unit units.WeightUnit
+	err := tx.QueryRow(`SELECT preferred_weight_unit FROM users WHERE id = $1`, userID).Scan(&unit)
+	if err == sql.ErrNoRows {
+		return units.Kilograms, nil // The user foreign key reports the missing user
+	}
+
+	return unit, err
+}
+
+// normalizeWeights converts the weights of an entry and its sets to kilograms for storage
+// and returns the unit they were logged in
+func normalizeWeights(entry *WorkoutEntry, defaultUnit units.WeightUnit) (units.WeightUnit, error) {
+	unit := defaultUnit
+	if entry.WeightUnit != "" {
+		var err error
+		unit, err = units.ParseWeightUnit(string(entry.WeightUnit))
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidWeightUnit, err)
+		}
+	}
+
+	toKilograms := func(weight *float64) {
+		if weight != nil {
+			*weight = math.Round(units.ToKilograms(*weight, unit)*1000) / 1000
+		}
+	}
+	toKilograms(entry.Weight)
+	for i := range entry.SetsDetail {
+		toKilograms(entry.SetsDetail[i].Weight)
+	}
+	entry.WeightUnit = units.Kilograms
+
+	return unit, nil
+}
+
+// ConvertWeights converts a stored workout, in kilograms, into unit for output
+func (workout *Workout) ConvertWeights(unit units.WeightUnit) {
+	for i := range workout.Entries {
+		convertEntryWeights(&workout.Entries[i], unit)
+	}
+	for i := range workout.Groups {
+		for j := range workout.Groups[i].Entries {
+			convertEntryWeights(&workout.Groups[i].Entries[j], unit)
+		}
+	}
+	records.ConvertWeights(workout.NewRecords, unit)
+}
+
+func convertEntryWeights(entry *WorkoutEntry, unit units.WeightUnit) {
+	units.ConvertWeight(entry.Weight, unit)
+	for i := range entry.SetsDetail {
+		units.ConvertWeight(entry.SetsDetail[i].Weight, unit)
+	}
+	entry.WeightUnit = unit
+}
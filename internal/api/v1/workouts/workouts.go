@@ -1,23 +1,68 @@
 package workouts
 
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Josesx506/gofems/internal/api/v1/records"
+	"github.com/Josesx506/gofems/internal/units"
+)
+
 // Analogous to database table schema but tailored for API encoding/decoding responses
 type Workout struct {
 	ID              int            `json:"id"`
 	UserID          int            `json:"user_id"`
 	Title           string         `json:"title"`
+	Slug            *string        `json:"slug,omitempty"` // Optional, unique per user
 	Description     string         `json:"description"`
 	DurationMinutes int            `json:"duration_minutes"`
 	CaloriesBurned  int            `json:"calories_burned"`
 	Entries         []WorkoutEntry `json:"entries"`
+	Groups          []EntryGroup   `json:"groups,omitempty"`      // Supersets and circuits, in the order they're performed
+	TemplateID      *int           `json:"template_id,omitempty"` // Template the workout was started from
+	CreatedAt       time.Time      `json:"created_at"`
+	DeletedAt       *time.Time     `json:"deleted_at,omitempty"` // Set while the workout sits in the trash
+
+	NewRecords []records.Record `json:"-"` // Personal records set by the last create or update
 }
 
 type WorkoutEntry struct {
-	ID              int      `json:"id"`
-	ExerciseName    string   `json:"exercise_name"`
-	Sets            int      `json:"sets"`
-	Reps            *int     `json:"reps"`
-	DurationSeconds *int     `json:"duration_seconds"`
-	Weight          *float64 `json:"weight"`
-	Notes           string   `json:"notes"`
-	OrderIndex      int      `json:"order_index"`
+	ID              int              `json:"id"`
+	ExerciseID      *int             `json:"exercise_id"` // Catalog exercise, resolved from the name when omitted
+	ExerciseName    string           `json:"exercise_name"`
+	Sets            int              `json:"sets"`
+	Reps            *int             `json:"reps"`
+	DurationSeconds *int             `json:"duration_seconds"`
+	Weight          *float64         `json:"weight"`
+	WeightUnit      units.WeightUnit `json:"weight_unit"` // Unit of weight and the set weights, the owner's preference when omitted
+	Notes           string           `json:"notes"`
+	OrderIndex      int              `json:"order_index"`
+	SetsDetail      []EntrySet       `json:"sets_detail,omitempty"` // Per-set log, the fields above summarize it
+	GroupID         *int             `json:"group_id,omitempty"`    // Group of the workout the entry belongs to
+	Cardio          *CardioMetrics   `json:"cardio,omitempty"`      // Distance, heart rate and the like for endurance work
+}
+
+var ErrInvalidSlug = errors.New("invalid slug, use lowercase letters and digits separated by single hyphens")
+var ErrInvalidTitle = errors.New("invalid title, it is required and at most 255 characters")
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Validate checks a workout built outside the workout handlers, e.g. started from a
+// template, its title and then what the store checks on save
+func (workout *Workout) Validate() error {
+	if strings.TrimSpace(workout.Title) == "" || utf8.RuneCountInString(workout.Title) > 255 {
+		return ErrInvalidTitle
+	}
+	return validateWorkout(workout)
+}
+
+// Checks what a workout is saved with, its slug and then its groups
+func validateWorkout(workout *Workout) error {
+	if workout.Slug != nil && !slugPattern.MatchString(*workout.Slug) {
+		return ErrInvalidSlug
+	}
+	return validateGroups(workout)
 }
@@ -0,0 +1,29 @@
+package workouts
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateWorkoutSlug(t *testing.T) {
+	for _, slug := range []string{"leg-day", "week-2-push", "5x5"} {
+		assert.NoError(t, validateWorkout(&Workout{Title: "Legs", Slug: &slug}), slug)
+	}
+	for _, slug := range []string{"", "Leg-Day", "leg day", "-leg", "leg-", "leg--day", "leg_day", "léger"} {
+		assert.ErrorIs(t, validateWorkout(&Workout{Title: "Legs", Slug: &slug}), ErrInvalidSlug, slug)
+	}
+	assert.NoError(t, validateWorkout(&Workout{Title: "Legs"}), "the slug is optional")
+}
+
+func TestValidateWorkoutTitle(t *testing.T) {
+	assert.NoError(t, (&Workout{Title: "Legs"}).Validate())
+	assert.NoError(t, (&Workout{Title: strings.Repeat("é", 255)}).Validate(), "the limit counts characters")
+	for _, title := range []string{"", "   ", strings.Repeat("a", 256)} {
+		assert.ErrorIs(t, (&Workout{Title: title}).Validate(), ErrInvalidTitle, title)
+	}
+
+	slug := "Leg Day"
+	assert.ErrorIs(t, (&Workout{Title: "Legs", Slug: &slug}).Validate(), ErrInvalidSlug)
+}
@@ -1,18 +1,28 @@
 package app
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/Josesx506/gofems/internal/jobs"
+	"github.com/Josesx506/gofems/internal/outbox"
 	"github.com/Josesx506/gofems/internal/store"
 	"github.com/Josesx506/gofems/migrations"
 )
 
 type Application struct {
-	Logger *log.Logger
-	DB     *sql.DB
+	Logger     *log.Logger
+	DB         *sql.DB
+	SigningKey []byte      // HMAC key of signed download links
+	DataDir    string      // Generated files such as account exports are kept here
+	Events     *outbox.Bus // Committed domain events are relayed to its subscribers
+	Jobs       *jobs.Queue // Work outside the request path, features register their kinds of job on it
+	AdminToken string      // Bearer token of the /admin routes, which are closed without one
 }
 
 func NewApplication() (*Application, error) {
@@ -32,9 +42,27 @@ func NewApplication() (*Application, error) {
 
 	// Stores for db access
 
+	// Links signed with a random key stop working when the process restarts
+	signingKey := []byte(os.Getenv("SIGNING_KEY"))
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		rand.Read(signingKey)
+		logger.Printf("SIGNING_KEY is not set, signed links only last until the server restarts")
+	}
+
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = filepath.Join(os.TempDir(), "gofems")
+	}
+
 	app := &Application{
-		Logger: logger,
-		DB:     pgDB,
+		Logger:     logger,
+		DB:         pgDB,
+		SigningKey: signingKey,
+		DataDir:    dataDir,
+		Events:     outbox.NewBus(),
+		Jobs:       jobs.NewQueue(pgDB, logger, time.Second),
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
 	}
 
 	return app, nil
@@ -0,0 +1,121 @@
+// Package fuzzy scores typed names against catalog names for suggestions and auto-linking
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalize lowercases s and collapses punctuation and whitespace into single spaces
+func Normalize(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+			continue
+		}
+		space = true
+	}
+	return b.String()
+}
+
+// Similarity returns a score between 0 and 1 for two strings, the better of
+// their trigram similarity and normalized edit distance. Identical strings
+// after normalization score 1.
+func Similarity(a, b string) float64 {
+	a, b = Normalize(a), Normalize(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	return max(TrigramSimilarity(a, b), EditSimilarity(a, b))
+}
+
+// PrefixSimilarity is Similarity with a boost for candidates that start with
+// the query, so partially typed names rank well for autocomplete.
+func PrefixSimilarity(query, candidate string) float64 {
+	score := Similarity(query, candidate)
+
+	q, c := Normalize(query), Normalize(candidate)
+	if q == "" || c == "" {
+		return score
+	}
+
+	coverage := float64(len(q)) / float64(len(c))
+	switch {
+	case strings.HasPrefix(c, q):
+		score = max(score, 0.7+0.3*coverage)
+	case strings.Contains(" "+c, " "+q):
+		score = max(score, 0.6+0.3*coverage) // prefix of a later word
+	}
+
+	return min(score, 1)
+}
+
+// TrigramSimilarity mirrors pg_trgm: each word is padded with two leading and
+// one trailing space and the score is shared trigrams over all trigrams.
+func TrigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// EditSimilarity is 1 minus the Levenshtein distance over the longer length
+func EditSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func trigrams(s string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, word := range strings.Fields(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
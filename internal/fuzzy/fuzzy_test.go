@@ -0,0 +1,45 @@
+package fuzzy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "pull ups", Normalize("  Pull-Ups "))
+	assert.Equal(t, "db bench press", Normalize("DB_Bench   Press!"))
+	assert.Equal(t, "", Normalize("--"))
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		minScore float64
+		maxScore float64
+	}{
+		{name: "Case and punctuation only", a: "pull-ups", b: "Pull Ups", minScore: 1, maxScore: 1},
+		{name: "Missing space", a: "Benchpress", b: "Bench Press", minScore: 0.9, maxScore: 1},
+		{name: "Typo", a: "Dedlift", b: "Deadlift", minScore: 0.85, maxScore: 1},
+		{name: "Plural", a: "Bicep Curls", b: "Bicep Curl", minScore: 0.9, maxScore: 1},
+		{name: "Unrelated", a: "Plank", b: "Bench Press", minScore: 0, maxScore: 0.3},
+		{name: "Empty", a: "", b: "Squat", minScore: 0, maxScore: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := Similarity(tt.a, tt.b)
+			assert.GreaterOrEqual(t, score, tt.minScore)
+			assert.LessOrEqual(t, score, tt.maxScore)
+			assert.Equal(t, score, Similarity(tt.b, tt.a), "similarity is symmetric")
+		})
+	}
+}
+
+func TestPrefixSimilarity(t *testing.T) {
+	// Partially typed names rank the matching prefix above the rest
+	assert.Greater(t, PrefixSimilarity("ben", "Bench Press"), PrefixSimilarity("ben", "Leg Press"))
+	assert.Greater(t, PrefixSimilarity("pre", "Bench Press"), Similarity("pre", "Bench Press"))
+	assert.Equal(t, 1.0, PrefixSimilarity("plank", "Plank"))
+}
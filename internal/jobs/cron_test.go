@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	monday := time.Date(2026, 10, 19, 13, 41, 22, 0, time.UTC)
+	friday := time.Date(2026, 10, 23, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"*/15 * * * *", monday, time.Date(2026, 10, 19, 13, 45, 0, 0, time.UTC)},
+		{"@hourly", monday, time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", monday, time.Date(2026, 10, 20, 2, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", friday, time.Date(2026, 10, 26, 9, 0, 0, 0, time.UTC)},
+		{"@monthly", monday, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", monday, time.Date(2026, 10, 25, 12, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", monday, time.Date(2026, 10, 23, 0, 0, 0, 0, time.UTC)}, // either day field
+		{"0 0 29 2 *", monday, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 8,17 * * *", monday, time.Date(2026, 10, 19, 17, 0, 0, 0, time.UTC)},
+		{"5/20 * * * *", monday, time.Date(2026, 10, 19, 13, 45, 0, 0, time.UTC)},
+		{"@every 90s", monday, monday.Add(90 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			cron, err := ParseCron(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cron.Next(tt.after))
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"0 0 30 2 *", // never runs
+		"@every 0s",
+		"@every fast",
+		"@fortnightly",
+	} {
+		t.Run(spec, func(t *testing.T) {
+			_, err := ParseCron(spec)
+			assert.ErrorIs(t, err, ErrInvalidCron)
+		})
+	}
+}
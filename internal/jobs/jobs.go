@@ -0,0 +1,131 @@
+// Package jobs runs work outside the request path from a Postgres backed queue.
+// Features register typed handlers for their kinds of job, enqueue them (in their
+// own transaction when it matters) or schedule them with cron specs, and every
+// instance's workers share the queue through FOR UPDATE SKIP LOCKED.
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StatusPending   = "pending" // waiting for run_at, including retries
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead" // failed every attempt, or permanently
+)
+
+// A job as stored, for the admin endpoints
+type Job struct {
+	ID           int64           `json:"id"`
+	Queue        string          `json:"queue"`
+	Kind         string          `json:"kind"`
+	Args         json.RawMessage `json:"args"`
+	Status       string          `json:"status"`
+	Attempts     int             `json:"attempts"`
+	MaxAttempts  int             `json:"max_attempts"`
+	RunAt        time.Time       `json:"run_at"`
+	LastError    string          `json:"last_error,omitempty"`
+	ScheduleName *string         `json:"schedule_name"`
+	CreatedAt    time.Time       `json:"created_at"`
+	StartedAt    *time.Time      `json:"started_at"`
+	FinishedAt   *time.Time      `json:"finished_at"`
+}
+
+// A cron schedule as stored
+type Schedule struct {
+	Name      string     `json:"name"`
+	Spec      string     `json:"spec"`
+	Kind      string     `json:"kind"`
+	Queue     string     `json:"queue"`
+	NextRunAt time.Time  `json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at"`
+	LastJobID *int64     `json:"last_job_id"`
+}
+
+// Options of a kind of job, zero values take the defaults
+type Options struct {
+	Queue       string        // DefaultQueue
+	MaxAttempts int           // DefaultMaxAttempts
+	Timeout     time.Duration // DefaultTimeout, the handler's context is cancelled after it
+}
+
+const (
+	DefaultQueue       = "default"
+	MaintenanceQueue   = "maintenance" // periodic upkeep such as purges and rollups
+	DefaultMaxAttempts = 5
+	DefaultTimeout     = 5 * time.Minute
+	// Workers of a queue that wasn't given a number
+	DefaultWorkers = 1
+	// Wait before the first retry, doubled after every failure
+	baseBackoff = 10 * time.Second
+	maxBackoff  = time.Hour
+)
+
+func (o Options) withDefaults() Options {
+	if o.Queue == "" {
+		o.Queue = DefaultQueue
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultMaxAttempts
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	return o
+}
+
+var (
+	ErrUnknownKind    = errors.New("jobs: unknown kind")
+	ErrInvalidWorkers = errors.New("invalid workers, expected queue=count pairs such as default=4,exports=1")
+)
+
+// Backoff is the wait after a job failed for the attempts-th time
+func Backoff(attempts int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error that retrying won't fix, such as arguments that
+// no longer make sense, so the job is dead-lettered straight away.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// ParseWorkers reads per queue worker counts such as "default=4,exports=1"
+func ParseWorkers(value string) (map[string]int, error) {
+	workers := map[string]int{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		queue, count, ok := strings.Cut(pair, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if !ok || err != nil || n < 0 || strings.TrimSpace(queue) == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidWorkers, pair)
+		}
+		workers[strings.TrimSpace(queue)] = n
+	}
+	return workers, nil
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, baseBackoff, Backoff(1))
+	assert.Equal(t, 4*baseBackoff, Backoff(3))
+	assert.Equal(t, maxBackoff, Backoff(30), "capped")
+}
+
+func TestPermanent(t *testing.T) {
+	err := Permanent(errors.New("unknown export"))
+	assert.True(t, isPermanent(err))
+	assert.True(t, isPermanent(errors.Join(errors.New("context"), err)), "found when wrapped")
+	assert.False(t, isPermanent(errors.New("timeout")))
+	assert.EqualError(t, err, "unknown export")
+}
+
+func TestParseWorkers(t *testing.T) {
+	workers, err := ParseWorkers(" default=4, exports=1,maintenance=0 ")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"default": 4, "exports": 1, "maintenance": 0}, workers)
+
+	for _, value := range []string{"default", "default=-1", "=2", "default=many"} {
+		_, err := ParseWorkers(value)
+		assert.ErrorIs(t, err, ErrInvalidWorkers, value)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	type reminder struct {
+		UserID int64 `json:"user_id"`
+	}
+
+	q := NewQueue(nil, log.New(io.Discard, "", 0), time.Second)
+	var got reminder
+	Register(q, "email.reminder", Options{Queue: "email", MaxAttempts: 2}, func(ctx context.Context, args reminder) error {
+		got = args
+		return nil
+	})
+	q.SetWorkers("email", 3)
+
+	assert.Equal(t, []QueueInfo{
+		{Name: "email", Workers: 3, Kinds: []string{"email.reminder"}},
+		{Name: MaintenanceQueue, Workers: DefaultWorkers, Kinds: []string{"jobs.prune"}},
+	}, q.Queues())
+
+	registered := q.lookup("email.reminder")
+	require.NoError(t, callHandler(context.Background(), registered.handle, []byte(`{"user_id":7}`)))
+	assert.Equal(t, reminder{UserID: 7}, got)
+	assert.True(t, isPermanent(callHandler(context.Background(), registered.handle, []byte(`[]`))),
+		"arguments that don't decode aren't retried")
+
+	assert.Panics(t, func() {
+		Register(q, "email.reminder", Options{}, func(ctx context.Context, _ struct{}) error { return nil })
+	}, "names are unique")
+
+	_, err := KindOf[reminder](q, "email.digest").Enqueue(reminder{}, time.Time{})
+	assert.ErrorIs(t, err, ErrUnknownKind)
+	_, err = KindOf[struct{}](q, "email.reminder").Enqueue(struct{}{}, time.Time{})
+	assert.ErrorContains(t, err, "takes jobs.reminder arguments")
+	assert.Panics(t, func() { KindOf[reminder](q, "email.reminder").Schedule("weekly", "every monday", reminder{}) })
+}
+
+func TestCallHandlerRecovers(t *testing.T) {
+	err := callHandler(context.Background(), func(ctx context.Context, args json.RawMessage) error {
+		panic("nil map")
+	}, nil)
+	assert.EqualError(t, err, "panic: nil map")
+}
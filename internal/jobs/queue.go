@@ -0,0 +1,522 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Succeeded jobs are pruned once they're older than this, dead ones are kept for inspection
+const keepSucceededFor = 7 * 24 * time.Hour
+
+// Queue registers the kinds of job of the application and runs the ones due on the
+// queues this instance works. Kinds and schedules are registered before Run.
+type Queue struct {
+	db       *sql.DB
+	logger   *log.Logger
+	interval time.Duration // how often idle workers and the scheduler poll
+
+	mu        sync.RWMutex
+	kinds     map[string]*kind
+	schedules []*schedule
+	workers   map[string]int
+}
+
+type kind struct {
+	name     string
+	options  Options
+	argsType reflect.Type
+	handle   func(ctx context.Context, args json.RawMessage) error
+}
+
+type schedule struct {
+	name string
+	spec string
+	cron *Cron
+	kind *kind
+	args []byte
+}
+
+// What an instance runs of a queue
+type QueueInfo struct {
+	Name    string   `json:"name"`
+	Workers int      `json:"workers"`
+	Kinds   []string `json:"kinds"`
+}
+
+func NewQueue(db *sql.DB, logger *log.Logger, interval time.Duration) *Queue {
+	q := &Queue{
+		db:       db,
+		logger:   logger,
+		interval: interval,
+		kinds:    map[string]*kind{},
+		workers:  map[string]int{},
+	}
+
+	prune := Register(q, "jobs.prune", Options{Queue: MaintenanceQueue}, func(ctx context.Context, _ struct{}) error {
+		_, err := q.db.ExecContext(ctx, `
+		DELETE FROM jobs
+		WHERE status = 'succeeded' AND finished_at < $1
+		`, time.Now().Add(-keepSucceededFor))
+		return err
+	})
+	prune.Schedule("prune-jobs", "@hourly", struct{}{})
+
+	return q
+}
+
+// SetWorkers sets how many jobs of a queue the instance runs at once. Zero leaves the
+// queue to other instances.
+func (q *Queue) SetWorkers(queue string, workers int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.workers[queue] = workers
+}
+
+// Queues lists the queues with registered kinds, sorted by name
+func (q *Queue) Queues() []QueueInfo {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	byName := map[string]*QueueInfo{}
+	for _, k := range q.kinds {
+		info, ok := byName[k.options.Queue]
+		if !ok {
+			workers, configured := q.workers[k.options.Queue]
+			if !configured {
+				workers = DefaultWorkers
+			}
+			info = &QueueInfo{Name: k.options.Queue, Workers: workers}
+			byName[k.options.Queue] = info
+		}
+		info.Kinds = append(info.Kinds, k.name)
+	}
+
+	queues := make([]QueueInfo, 0, len(byName))
+	for _, info := range byName {
+		sort.Strings(info.Kinds)
+		queues = append(queues, *info)
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].Name < queues[j].Name })
+	return queues
+}
+
+// Kind enqueues and schedules jobs whose arguments are a T, encoded as JSON
+type Kind[T any] struct {
+	queue *Queue
+	name  string
+}
+
+// Register adds a kind of job handled by handle. Jobs run at least once: a handler can
+// see a job again after a failure or a crash, so it has to be safe to repeat. The name
+// is stored with every job and must be unique.
+func Register[T any](q *Queue, name string, options Options, handle func(ctx context.Context, args T) error) Kind[T] {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, exists := q.kinds[name]; exists {
+		panic(fmt.Sprintf("jobs: kind %q registered twice", name))
+	}
+	q.kinds[name] = &kind{
+		name:     name,
+		options:  options.withDefaults(),
+		argsType: reflect.TypeFor[T](),
+		handle: func(ctx context.Context, raw json.RawMessage) error {
+			var args T
+			err := json.Unmarshal(raw, &args)
+			if err != nil {
+				return Permanent(fmt.Errorf("decoding arguments: %w", err))
+			}
+			return handle(ctx, args)
+		},
+	}
+	return Kind[T]{queue: q, name: name}
+}
+
+// KindOf returns the kind registered as name, for code that enqueues jobs another
+// package registers. Enqueueing fails unless it was registered with a T.
+func KindOf[T any](q *Queue, name string) Kind[T] {
+	return Kind[T]{queue: q, name: name}
+}
+
+func (k Kind[T]) Name() string {
+	return k.name
+}
+
+// Enqueue adds a job to run at runAt, or right away when it's zero, and returns its id
+func (k Kind[T]) Enqueue(args T, runAt time.Time) (int64, error) {
+	return k.enqueue(k.queue.db, args, runAt)
+}
+
+// EnqueueTx adds the job in tx, so it only runs if the transaction commits
+func (k Kind[T]) EnqueueTx(tx *sql.Tx, args T, runAt time.Time) (int64, error) {
+	return k.enqueue(tx, args, runAt)
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (k Kind[T]) enqueue(q queryRower, args T, runAt time.Time) (int64, error) {
+	registered, err := k.registered()
+	if err != nil {
+		return 0, err
+	}
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return 0, err
+	}
+	return insertJob(q, registered, payload, runAt, nil)
+}
+
+func (k Kind[T]) registered() (*kind, error) {
+	registered := k.queue.lookup(k.name)
+	if registered == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKind, k.name)
+	}
+	if registered.argsType != reflect.TypeFor[T]() {
+		return nil, fmt.Errorf("jobs: %q takes %s arguments, not %s", k.name, registered.argsType,
+			reflect.TypeFor[T]())
+	}
+	return registered, nil
+}
+
+func insertJob(q queryRower, k *kind, args []byte, runAt time.Time, scheduleName *string) (int64, error) {
+	query := `
+	INSERT INTO jobs (queue, kind, args, max_attempts, timeout_seconds, run_at, schedule_name)
+	VALUES ($1, $2, $3, $4, $5, COALESCE($6, CURRENT_TIMESTAMP), $7)
+	RETURNING id
+	`
+	var at *time.Time
+	if !runAt.IsZero() {
+		at = &runAt
+	}
+	var id int64
+	err := q.QueryRow(query, k.options.Queue, k.name, string(args), k.options.MaxAttempts,
+		int(k.options.Timeout.Seconds()), at, scheduleName).Scan(&id)
+	return id, err
+}
+
+// Schedule queues a job with args whenever spec comes due, on whichever instance claims
+// it first. A run is skipped while the previous one is still pending or running, and
+// runs missed while every instance was down are made up for once. The name identifies
+// the schedule across deploys and must be unique.
+func (k Kind[T]) Schedule(name, spec string, args T) {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		panic(fmt.Sprintf("jobs: schedule %q: %v", name, err))
+	}
+	payload, err := json.Marshal(args)
+	if err != nil {
+		panic(fmt.Sprintf("jobs: schedule %q: %v", name, err))
+	}
+	registered, err := k.registered()
+	if err != nil {
+		panic(fmt.Sprintf("jobs: schedule %q: %v", name, err))
+	}
+
+	k.queue.mu.Lock()
+	defer k.queue.mu.Unlock()
+	for _, existing := range k.queue.schedules {
+		if existing.name == name {
+			panic(fmt.Sprintf("jobs: schedule %q registered twice", name))
+		}
+	}
+	k.queue.schedules = append(k.queue.schedules, &schedule{name: name, spec: spec, cron: cron, kind: registered,
+		args: payload})
+}
+
+// Run starts the workers of every queue and the scheduler, and returns once ctx is
+// cancelled and the running jobs have returned.
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, info := range q.Queues() {
+		for range info.Workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				q.work(ctx, info.Name)
+			}()
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.runScheduler(ctx)
+	}()
+
+	wg.Wait()
+}
+
+// A worker runs due jobs back to back and polls once the queue is empty
+func (q *Queue) work(ctx context.Context, queue string) {
+	for ctx.Err() == nil {
+		ran, err := q.WorkOnce(ctx, queue)
+		if err != nil {
+			q.logger.Printf("Error workOnce %s: %v", queue, err)
+		}
+		if ran && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(q.interval):
+		}
+	}
+}
+
+// The scheduler stores the schedules once and then queues the due ones on every interval
+func (q *Queue) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+
+	synced := false
+	for {
+		if !synced {
+			err := q.SyncSchedules()
+			if err != nil {
+				q.logger.Printf("Error syncSchedules: %v", err)
+			}
+			synced = err == nil
+		}
+		if synced {
+			_, err := q.ScheduleOnce(ctx)
+			if err != nil {
+				q.logger.Printf("Error scheduleOnce: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// The kinds of a queue this instance can handle
+func (q *Queue) kindNames(queue string) []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	var names []string
+	for _, k := range q.kinds {
+		if k.options.Queue == queue {
+			names = append(names, k.name)
+		}
+	}
+	return names
+}
+
+func (q *Queue) lookup(name string) *kind {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.kinds[name]
+}
+
+// WorkOnce claims the next due job of queue and runs it, reporting whether there was one.
+// Claimed jobs are leased for their timeout rather than locked, so a job whose worker
+// died is claimed again once the lease runs out.
+func (q *Queue) WorkOnce(ctx context.Context, queue string) (bool, error) {
+	kinds := q.kindNames(queue)
+	if len(kinds) == 0 {
+		return false, nil
+	}
+
+	// Only kinds registered here are claimed, an instance running older code leaves new kinds alone
+	query := `
+	UPDATE jobs
+	SET status = 'running', attempts = attempts + 1, started_at = CURRENT_TIMESTAMP,
+		locked_until = CURRENT_TIMESTAMP + make_interval(secs => timeout_seconds + 60)
+	WHERE id = (
+		SELECT id
+		FROM jobs
+		WHERE queue = $1 AND kind = ANY($2) AND (
+			(status = 'pending' AND run_at <= CURRENT_TIMESTAMP) OR
+			(status = 'running' AND locked_until < CURRENT_TIMESTAMP AND attempts < max_attempts)
+		)
+		ORDER BY run_at, id
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	)
+	RETURNING id, kind, args, attempts, max_attempts, timeout_seconds
+	`
+	var id int64
+	var kindName string
+	var args []byte
+	var attempts, maxAttempts, timeoutSeconds int
+	err := q.db.QueryRowContext(ctx, query, queue, kinds).Scan(&id, &kindName, &args, &attempts, &maxAttempts,
+		&timeoutSeconds)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	err = callHandler(jobCtx, q.lookup(kindName).handle, args)
+	cancel()
+
+	if err != nil {
+		q.logger.Printf("Job %d (%s) failed attempt %d of %d: %v", id, kindName, attempts, maxAttempts, err)
+	}
+	return true, q.finish(id, attempts, maxAttempts, err)
+}
+
+// A panicking handler fails its job like one returning an error instead of taking the worker down
+func callHandler(ctx context.Context, handle func(context.Context, json.RawMessage) error, args []byte) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handle(ctx, args)
+}
+
+// Records the outcome of an attempt, unless the lease was lost and the job claimed again meanwhile
+func (q *Queue) finish(id int64, attempts, maxAttempts int, handleErr error) error {
+	var err error
+	switch {
+	case handleErr == nil:
+		_, err = q.db.Exec(`
+		UPDATE jobs
+		SET status = 'succeeded', locked_until = NULL, last_error = NULL, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running' AND attempts = $2`, id, attempts)
+	case isPermanent(handleErr) || attempts >= maxAttempts:
+		_, err = q.db.Exec(`
+		UPDATE jobs
+		SET status = 'dead', locked_until = NULL, last_error = $3, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running' AND attempts = $2`, id, attempts, handleErr.Error())
+	default:
+		_, err = q.db.Exec(`
+		UPDATE jobs
+		SET status = 'pending', locked_until = NULL, last_error = $3, run_at = $4
+		WHERE id = $1 AND status = 'running' AND attempts = $2`, id, attempts, handleErr.Error(),
+			time.Now().Add(Backoff(attempts)))
+	}
+	return err
+}
+
+// SyncSchedules stores the schedules registered here. A schedule keeps its next run
+// unless its spec changed.
+func (q *Queue) SyncSchedules() error {
+	q.mu.RLock()
+	schedules := q.schedules
+	q.mu.RUnlock()
+
+	query := `
+	INSERT INTO job_schedules (name, spec, kind, queue, args, next_run_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (name) DO UPDATE
+	SET kind = EXCLUDED.kind, queue = EXCLUDED.queue, args = EXCLUDED.args, spec = EXCLUDED.spec,
+		next_run_at = CASE WHEN job_schedules.spec = EXCLUDED.spec
+			THEN job_schedules.next_run_at ELSE EXCLUDED.next_run_at END
+	`
+	now := time.Now()
+	for _, s := range schedules {
+		_, err := q.db.Exec(query, s.name, s.spec, s.kind.name, s.kind.options.Queue, string(s.args), s.cron.Next(now))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScheduleOnce queues the jobs of the due schedules registered here and returns how
+// many it queued. It also dead-letters jobs whose last attempt's worker died.
+func (q *Queue) ScheduleOnce(ctx context.Context) (int, error) {
+	_, err := q.db.ExecContext(ctx, `
+	UPDATE jobs
+	SET status = 'dead', locked_until = NULL, finished_at = CURRENT_TIMESTAMP,
+		last_error = 'the worker stopped during the last attempt'
+	WHERE status = 'running' AND locked_until < CURRENT_TIMESTAMP AND attempts >= max_attempts
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	q.mu.RLock()
+	byName := map[string]*schedule{}
+	names := make([]string, 0, len(q.schedules))
+	for _, s := range q.schedules {
+		byName[s.name] = s
+		names = append(names, s.name)
+	}
+	q.mu.RUnlock()
+	if len(names) == 0 {
+		return 0, nil
+	}
+
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // rollback transaction if not committed
+
+	query := `
+	SELECT s.name, EXISTS (
+		SELECT 1 FROM jobs j WHERE j.schedule_name = s.name AND j.status IN ('pending', 'running')
+	)
+	FROM job_schedules s
+	WHERE s.name = ANY($1) AND s.next_run_at <= CURRENT_TIMESTAMP
+	FOR UPDATE SKIP LOCKED
+	`
+	rows, err := tx.QueryContext(ctx, query, names)
+	if err != nil {
+		return 0, err
+	}
+	busy := map[string]bool{}
+	var due []string
+	for rows.Next() {
+		var name string
+		var unfinished bool
+		err := rows.Scan(&name, &unfinished)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, name)
+		busy[name] = unfinished
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	now := time.Now()
+	for _, name := range due {
+		s := byName[name]
+		if busy[name] {
+			_, err = tx.Exec(`UPDATE job_schedules SET next_run_at = $2 WHERE name = $1`, name, s.cron.Next(now))
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		id, err := insertJob(tx, s.kind, s.args, time.Time{}, &s.name)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(`
+		UPDATE job_schedules
+		SET next_run_at = $2, last_run_at = CURRENT_TIMESTAMP, last_job_id = $3
+		WHERE name = $1`, name, s.cron.Next(now), id)
+		if err != nil {
+			return 0, err
+		}
+		queued++
+	}
+
+	return queued, tx.Commit()
+}
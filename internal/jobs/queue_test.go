@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/store"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	db := store.SetupTestDB(t, "../../migrations/")
+	defer db.Close()
+
+	_, err := db.Exec(`DELETE FROM job_schedules; DELETE FROM jobs`)
+	require.NoError(t, err)
+
+	type rollup struct {
+		Day string `json:"day"`
+	}
+
+	q := NewQueue(db, log.New(io.Discard, "", 0), time.Second)
+	jobStore := NewPostgresJobStore(db)
+	ctx := context.Background()
+
+	var seen []string
+	fail := 0
+	kind := Register(q, "analytics.rollup", Options{Queue: "analytics", MaxAttempts: 2},
+		func(ctx context.Context, args rollup) error {
+			seen = append(seen, args.Day)
+			if fail > 0 {
+				fail--
+				return errors.New("rollup locked")
+			}
+			return nil
+		})
+
+	// Jobs run once due, and not before
+	id, err := kind.Enqueue(rollup{Day: "2026-10-19"}, time.Time{})
+	require.NoError(t, err)
+	_, err = kind.Enqueue(rollup{Day: "2026-10-20"}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	ran, err := q.WorkOnce(ctx, "analytics")
+	require.NoError(t, err)
+	assert.True(t, ran)
+	ran, err = q.WorkOnce(ctx, "analytics")
+	require.NoError(t, err)
+	assert.False(t, ran, "the second job isn't due")
+	assert.Equal(t, []string{"2026-10-19"}, seen)
+
+	job, err := jobStore.GetJob(id)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.JSONEq(t, `{"day":"2026-10-19"}`, string(job.Args))
+
+	// Failures are retried with backoff until the attempts run out
+	fail = 2
+	id, err = kind.Enqueue(rollup{Day: "2026-10-21"}, time.Time{})
+	require.NoError(t, err)
+	_, err = q.WorkOnce(ctx, "analytics")
+	require.NoError(t, err)
+	job, err = jobStore.GetJob(id)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, job.Status)
+	assert.Equal(t, "rollup locked", job.LastError)
+	assert.WithinDuration(t, time.Now().Add(Backoff(1)), job.RunAt, 5*time.Second)
+
+	_, err = db.Exec(`UPDATE jobs SET run_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	require.NoError(t, err)
+	_, err = q.WorkOnce(ctx, "analytics")
+	require.NoError(t, err)
+	job, err = jobStore.GetJob(id)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDead, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+
+	dead, err := jobStore.ListJobs(JobFilter{Status: StatusDead, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, id, dead[0].ID)
+
+	require.NoError(t, jobStore.RetryJob(id))
+	assert.ErrorIs(t, jobStore.RetryJob(id), sql.ErrNoRows, "only dead jobs are retried")
+	_, err = q.WorkOnce(ctx, "analytics")
+	require.NoError(t, err)
+	job, err = jobStore.GetJob(id)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, job.Status)
+
+	// A job whose worker died is claimed again once its lease runs out
+	id, err = kind.Enqueue(rollup{Day: "2026-10-22"}, time.Time{})
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE jobs SET status = 'running', attempts = 1,
+		locked_until = CURRENT_TIMESTAMP - interval '1 second' WHERE id = $1`, id)
+	require.NoError(t, err)
+	_, err = q.WorkOnce(ctx, "analytics")
+	require.NoError(t, err)
+	job, err = jobStore.GetJob(id)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+
+	stats, err := jobStore.QueueStats()
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, QueueStats{Queue: "analytics", Scheduled: 1, Succeeded: 3}, stats[0])
+}
+
+func TestSchedules(t *testing.T) {
+	db := store.SetupTestDB(t, "../../migrations/")
+	defer db.Close()
+
+	_, err := db.Exec(`DELETE FROM job_schedules; DELETE FROM jobs`)
+	require.NoError(t, err)
+
+	q := NewQueue(db, log.New(io.Discard, "", 0), time.Second)
+	jobStore := NewPostgresJobStore(db)
+	ctx := context.Background()
+
+	refresh := Register(q, "analytics.refresh", Options{Queue: MaintenanceQueue},
+		func(ctx context.Context, _ struct{}) error { return nil })
+	refresh.Schedule("refresh", "*/15 * * * *", struct{}{})
+
+	require.NoError(t, q.SyncSchedules())
+	schedules, err := jobStore.ListSchedules()
+	require.NoError(t, err)
+	require.Len(t, schedules, 2) // with the built-in pruning
+	assert.Equal(t, "prune-jobs", schedules[0].Name)
+	assert.Equal(t, "refresh", schedules[1].Name)
+	assert.Equal(t, "analytics.refresh", schedules[1].Kind)
+	assert.True(t, schedules[1].NextRunAt.After(time.Now()))
+
+	queued, err := q.ScheduleOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, queued, "nothing is due yet")
+
+	_, err = db.Exec(`UPDATE job_schedules SET next_run_at = CURRENT_TIMESTAMP - interval '1 hour'`)
+	require.NoError(t, err)
+	queued, err = q.ScheduleOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, queued)
+
+	schedules, err = jobStore.ListSchedules()
+	require.NoError(t, err)
+	require.NotNil(t, schedules[1].LastJobID)
+	assert.True(t, schedules[1].NextRunAt.After(time.Now()), "missed runs are made up for once")
+
+	job, err := jobStore.GetJob(*schedules[1].LastJobID)
+	require.NoError(t, err)
+	assert.Equal(t, "refresh", *job.ScheduleName)
+
+	// A run is skipped while the previous one hasn't finished
+	_, err = db.Exec(`UPDATE job_schedules SET next_run_at = CURRENT_TIMESTAMP - interval '1 minute'`)
+	require.NoError(t, err)
+	queued, err = q.ScheduleOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, queued)
+
+	ran, err := q.WorkOnce(ctx, MaintenanceQueue)
+	require.NoError(t, err)
+	assert.True(t, ran)
+	ran, err = q.WorkOnce(ctx, MaintenanceQueue)
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	_, err = db.Exec(`UPDATE job_schedules SET next_run_at = CURRENT_TIMESTAMP - interval '1 minute'`)
+	require.NoError(t, err)
+	queued, err = q.ScheduleOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, queued)
+
+	// Changing a spec moves the next run, keeping it doesn't
+	before, err := jobStore.ListSchedules()
+	require.NoError(t, err)
+	other := NewQueue(db, log.New(io.Discard, "", 0), time.Second)
+	Register(other, "analytics.refresh", Options{Queue: MaintenanceQueue},
+		func(ctx context.Context, _ struct{}) error { return nil }).Schedule("refresh", "@daily", struct{}{})
+	require.NoError(t, other.SyncSchedules())
+	after, err := jobStore.ListSchedules()
+	require.NoError(t, err)
+	assert.Equal(t, before[0].NextRunAt, after[0].NextRunAt)
+	assert.Equal(t, "@daily", after[1].Spec)
+	assert.NotEqual(t, before[1].NextRunAt, after[1].NextRunAt)
+}
@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DB connector struct
+type PostgresJobStore struct {
+	db *sql.DB
+}
+
+func NewPostgresJobStore(db *sql.DB) *PostgresJobStore {
+	return &PostgresJobStore{db: db}
+}
+
+type JobStore interface {
+	ListJobs(filter JobFilter) ([]Job, error)
+	GetJob(id int64) (*Job, error)
+	RetryJob(id int64) error
+	QueueStats() ([]QueueStats, error)
+	ListSchedules() ([]Schedule, error)
+}
+
+// Narrows ListJobs, empty fields match everything
+type JobFilter struct {
+	Queue    string
+	Kind     string
+	Status   string
+	BeforeID int64 // for paging, newest first
+	Limit    int
+}
+
+// How many jobs a queue holds in each state
+type QueueStats struct {
+	Queue     string     `json:"queue"`
+	Due       int        `json:"due"`       // pending and ready to run
+	Scheduled int        `json:"scheduled"` // pending with a run_at in the future, retries included
+	Running   int        `json:"running"`
+	Succeeded int        `json:"succeeded"`
+	Dead      int        `json:"dead"`
+	OldestDue *time.Time `json:"oldest_due"` // how far behind the workers are
+}
+
+const jobColumns = `id, queue, kind, args, status, attempts, max_attempts, run_at, COALESCE(last_error, ''),
+	schedule_name, created_at, started_at, finished_at`
+
+func scanJob(row interface{ Scan(...any) error }) (*Job, error) {
+	job := &Job{}
+	var args []byte
+	err := row.Scan(&job.ID, &job.Queue, &job.Kind, &args, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt,
+		&job.LastError, &job.ScheduleName, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.Args = args
+	return job, nil
+}
+
+// Lists jobs newest first
+func (pgStore *PostgresJobStore) ListJobs(filter JobFilter) ([]Job, error) {
+	query := `
+	SELECT ` + jobColumns + `
+	FROM jobs
+	WHERE ($1 = '' OR queue = $1) AND ($2 = '' OR kind = $2) AND ($3 = '' OR status = $3)
+		AND ($4 = 0 OR id < $4)
+	ORDER BY id DESC
+	LIMIT $5
+	`
+	rows, err := pgStore.db.Query(query, filter.Queue, filter.Kind, filter.Status, filter.BeforeID, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// Returns nil when the job doesn't exist
+func (pgStore *PostgresJobStore) GetJob(id int64) (*Job, error) {
+	job, err := scanJob(pgStore.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil // No job found
+	}
+	return job, err
+}
+
+// Requeues a dead job with a fresh set of attempts. Returns sql.ErrNoRows unless the
+// job exists and is dead.
+func (pgStore *PostgresJobStore) RetryJob(id int64) error {
+	query := `
+	UPDATE jobs
+	SET status = 'pending', attempts = 0, run_at = CURRENT_TIMESTAMP, finished_at = NULL
+	WHERE id = $1 AND status = 'dead'
+	`
+	result, err := pgStore.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Counts the jobs of every queue that holds any, sorted by queue
+func (pgStore *PostgresJobStore) QueueStats() ([]QueueStats, error) {
+	query := `
+	SELECT queue,
+		COUNT(*) FILTER (WHERE status = 'pending' AND run_at <= CURRENT_TIMESTAMP),
+		COUNT(*) FILTER (WHERE status = 'pending' AND run_at > CURRENT_TIMESTAMP),
+		COUNT(*) FILTER (WHERE status = 'running'),
+		COUNT(*) FILTER (WHERE status = 'succeeded'),
+		COUNT(*) FILTER (WHERE status = 'dead'),
+		MIN(run_at) FILTER (WHERE status = 'pending' AND run_at <= CURRENT_TIMESTAMP)
+	FROM jobs
+	GROUP BY queue
+	ORDER BY queue
+	`
+	rows, err := pgStore.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []QueueStats{}
+	for rows.Next() {
+		var s QueueStats
+		err := rows.Scan(&s.Queue, &s.Due, &s.Scheduled, &s.Running, &s.Succeeded, &s.Dead, &s.OldestDue)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
+
+func (pgStore *PostgresJobStore) ListSchedules() ([]Schedule, error) {
+	query := `
+	SELECT name, spec, kind, queue, next_run_at, last_run_at, last_job_id
+	FROM job_schedules
+	ORDER BY name
+	`
+	rows, err := pgStore.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []Schedule{}
+	for rows.Next() {
+		var s Schedule
+		err := rows.Scan(&s.Name, &s.Spec, &s.Kind, &s.Queue, &s.NextRunAt, &s.LastRunAt, &s.LastJobID)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+
+	return schedules, rows.Err()
+}
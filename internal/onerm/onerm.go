@@ -0,0 +1,130 @@
+// Package onerm estimates one rep maxes from submaximal sets and plans loads from them
+package onerm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+type Formula string
+
+const (
+	Epley    Formula = "epley"
+	Brzycki  Formula = "brzycki"
+	Lombardi Formula = "lombardi"
+	Mayhew   Formula = "mayhew"
+	Wathan   Formula = "wathan"
+)
+
+// Every supported formula, Epley is the default
+var Formulas = []Formula{Epley, Brzycki, Lombardi, Mayhew, Wathan}
+
+// The formulas are fitted on sets of roughly 1-15 reps, beyond MaxReps they stop meaning much
+const MaxReps = 30
+
+var (
+	ErrUnknownFormula = errors.New("unknown one rep max formula")
+	ErrInvalidSet     = fmt.Errorf("weight must be positive and reps between 1 and %d", MaxReps)
+)
+
+// ParseFormula accepts a formula name case-insensitively, an empty name selects Epley
+func ParseFormula(name string) (Formula, error) {
+	if name == "" {
+		return Epley, nil
+	}
+	formula := Formula(strings.ToLower(strings.TrimSpace(name)))
+	for _, f := range Formulas {
+		if f == formula {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormula, name)
+}
+
+// Estimate returns the one rep max predicted by formula for weight lifted for reps.
+// A single rep is taken as the one rep max itself, whatever the formula.
+func Estimate(formula Formula, weight float64, reps int) (float64, error) {
+	if weight <= 0 || reps < 1 || reps > MaxReps {
+		return 0, ErrInvalidSet
+	}
+	if reps == 1 {
+		return weight, nil
+	}
+
+	r := float64(reps)
+	switch formula {
+	case Epley:
+		return weight * (1 + r/30), nil
+	case Brzycki:
+		return weight * 36 / (37 - r), nil
+	case Lombardi:
+		return weight * math.Pow(r, 0.10), nil
+	case Mayhew:
+		return 100 * weight / (52.2 + 41.9*math.Exp(-0.055*r)), nil
+	case Wathan:
+		return 100 * weight / (48.8 + 53.8*math.Exp(-0.075*r)), nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownFormula, formula)
+}
+
+// ExpectedReps inverts formula: how many reps should be possible at percent of the one rep max.
+// ok is false when the formula can't predict a rep count within MaxReps for that load.
+func ExpectedReps(formula Formula, percent float64) (reps int, ok bool) {
+	if percent <= 0 || percent > 100 {
+		return 0, false
+	}
+
+	var r float64
+	switch formula {
+	case Epley:
+		r = 30 * (100/percent - 1)
+	case Brzycki:
+		r = 37 - 36*percent/100
+	case Lombardi:
+		r = math.Pow(100/percent, 10)
+	case Mayhew:
+		if percent <= 52.2 {
+			return 0, false
+		}
+		r = -math.Log((percent-52.2)/41.9) / 0.055
+	case Wathan:
+		if percent <= 48.8 {
+			return 0, false
+		}
+		r = -math.Log((percent-48.8)/53.8) / 0.075
+	default:
+		return 0, false
+	}
+
+	// Round down with some slack for float error, partial reps don't count
+	reps = max(int(math.Floor(r+1e-9)), 1)
+	if reps > MaxReps {
+		return 0, false
+	}
+	return reps, true
+}
+
+// One row of a loading table
+type PercentageRow struct {
+	Percent      int     `json:"percent"`
+	Weight       float64 `json:"weight"`
+	ExpectedReps *int    `json:"expected_reps"` // nil when the formula can't predict it
+}
+
+// PercentageTable lists 50-100% of oneRepMax in steps of 5% with the reps formula expects at each load
+func PercentageTable(formula Formula, oneRepMax float64) []PercentageRow {
+	rows := []PercentageRow{}
+	for percent := 100; percent >= 50; percent -= 5 {
+		row := PercentageRow{
+			Percent: percent,
+			Weight:  math.Round(oneRepMax*float64(percent)) / 100,
+		}
+		if reps, ok := ExpectedReps(formula, float64(percent)); ok {
+			row.ExpectedReps = &reps
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,86 @@
+package onerm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		formula Formula
+		want    float64
+	}{
+		{formula: Epley, want: 116.67},
+		{formula: Brzycki, want: 112.5},
+		{formula: Lombardi, want: 117.46},
+		{formula: Mayhew, want: 119.01},
+		{formula: Wathan, want: 116.58},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.formula), func(t *testing.T) {
+			estimate, err := Estimate(tt.formula, 100, 5)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, estimate, 0.01)
+
+			single, err := Estimate(tt.formula, 100, 1)
+			require.NoError(t, err)
+			assert.Equal(t, 100.0, single, "a single rep is the one rep max")
+		})
+	}
+
+	_, err := Estimate(Epley, 100, 0)
+	assert.ErrorIs(t, err, ErrInvalidSet)
+	_, err = Estimate(Epley, 100, MaxReps+1)
+	assert.ErrorIs(t, err, ErrInvalidSet)
+	_, err = Estimate("oconner", 100, 5)
+	assert.ErrorIs(t, err, ErrUnknownFormula)
+}
+
+func TestParseFormula(t *testing.T) {
+	formula, err := ParseFormula("")
+	require.NoError(t, err)
+	assert.Equal(t, Epley, formula)
+
+	formula, err = ParseFormula(" Brzycki ")
+	require.NoError(t, err)
+	assert.Equal(t, Brzycki, formula)
+
+	_, err = ParseFormula("guess")
+	assert.ErrorIs(t, err, ErrUnknownFormula)
+}
+
+func TestExpectedReps(t *testing.T) {
+	// Inverting the estimate lands back on the reps that produced it
+	for _, formula := range Formulas {
+		for _, reps := range []int{2, 5, 8, 12} {
+			estimate, err := Estimate(formula, 100, reps)
+			require.NoError(t, err)
+
+			got, ok := ExpectedReps(formula, 100*100/estimate)
+			require.True(t, ok, "%s at %d reps", formula, reps)
+			assert.Equal(t, reps, got, "%s at %d reps", formula, reps)
+		}
+	}
+
+	// Mayhew's curve flattens out above 52.2% of the one rep max
+	_, ok := ExpectedReps(Mayhew, 50)
+	assert.False(t, ok)
+}
+
+func TestPercentageTable(t *testing.T) {
+	table := PercentageTable(Epley, 142.5)
+	require.Len(t, table, 11)
+
+	assert.Equal(t, 100, table[0].Percent)
+	assert.Equal(t, 142.5, table[0].Weight)
+	require.NotNil(t, table[0].ExpectedReps)
+	assert.Equal(t, 1, *table[0].ExpectedReps)
+
+	assert.Equal(t, 50, table[10].Percent)
+	assert.Equal(t, 71.25, table[10].Weight)
+	require.NotNil(t, table[10].ExpectedReps)
+	assert.Equal(t, 30, *table[10].ExpectedReps)
+}
@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// A Handler reacts to an event. Events are delivered at least once, so handlers
+// must tolerate seeing one again after a failure or a crash.
+type Handler func(ctx context.Context, event Event) error
+
+// Bus hands relayed events to the subscribers registered in process. Features hook
+// into changes by subscribing instead of being called from the stores.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []subscriber
+}
+
+type subscriber struct {
+	name       string
+	eventTypes []string // nil for every type
+	handle     Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers handle for the given event types, or every type when none
+// are given. The name identifies the subscriber across retries and must be unique.
+func (b *Bus) Subscribe(name string, handle Handler, eventTypes ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, existing := range b.subscribers {
+		if existing.name == name {
+			panic(fmt.Sprintf("outbox: subscriber %q registered twice", name))
+		}
+	}
+	b.subscribers = append(b.subscribers, subscriber{name: name, eventTypes: eventTypes, handle: handle})
+}
+
+// Publish calls the subscribers interested in event, just those named in only unless
+// it's nil, and returns the errors of the ones that failed by subscriber name.
+func (b *Bus) Publish(ctx context.Context, event Event, only []string) map[string]error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var failed map[string]error
+	for _, sub := range b.subscribers {
+		if only != nil && !slices.Contains(only, sub.name) {
+			continue
+		}
+		if sub.eventTypes != nil && !slices.Contains(sub.eventTypes, event.Type) {
+			continue
+		}
+
+		err := callHandler(ctx, sub.handle, event)
+		if err != nil {
+			if failed == nil {
+				failed = map[string]error{}
+			}
+			failed[sub.name] = err
+		}
+	}
+
+	return failed
+}
+
+// A panicking subscriber fails like one returning an error instead of taking the relay down
+func callHandler(ctx context.Context, handle Handler, event Event) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handle(ctx, event)
+}
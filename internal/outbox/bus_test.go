@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusPublish(t *testing.T) {
+	bus := NewBus()
+	var seen []string
+	bus.Subscribe("all", func(ctx context.Context, event Event) error {
+		seen = append(seen, "all:"+event.Type)
+		return nil
+	})
+	bus.Subscribe("records", func(ctx context.Context, event Event) error {
+		seen = append(seen, "records:"+event.Type)
+		return errors.New("unavailable")
+	}, PRAchieved)
+	bus.Subscribe("broken", func(ctx context.Context, event Event) error {
+		panic("nil map")
+	}, WorkoutDeleted)
+
+	assert.Empty(t, bus.Publish(context.Background(), Event{Type: WorkoutCreated}, nil))
+	failed := bus.Publish(context.Background(), Event{Type: PRAchieved}, nil)
+	assert.EqualError(t, failed["records"], "unavailable")
+	assert.Equal(t, []string{"all:workout.created", "all:pr.achieved", "records:pr.achieved"}, seen)
+
+	seen = nil
+	bus.Publish(context.Background(), Event{Type: PRAchieved}, []string{"records"})
+	assert.Equal(t, []string{"records:pr.achieved"}, seen, "retries skip subscribers that succeeded")
+
+	failed = bus.Publish(context.Background(), Event{Type: WorkoutDeleted}, nil)
+	assert.EqualError(t, failed["broken"], "panic: nil map")
+
+	assert.Panics(t, func() { bus.Subscribe("all", nil) }, "names are unique")
+}
+
+func TestRelayBackoff(t *testing.T) {
+	assert.Equal(t, baseRelayBackoff, relayBackoff(1))
+	assert.Equal(t, 4*baseRelayBackoff, relayBackoff(3))
+	assert.Equal(t, maxRelayBackoff, relayBackoff(MaxRelayAttempts+20))
+}
@@ -0,0 +1,59 @@
+// Package outbox records domain events in the same transaction as the change they
+// describe, and relays them to in-process subscribers once committed, so anything
+// reacting to a change sees it exactly when, and only if, it happened.
+package outbox
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const (
+	WorkoutCreated  = "workout.created"
+	WorkoutUpdated  = "workout.updated"
+	WorkoutDeleted  = "workout.deleted"  // moved into the trash
+	WorkoutRestored = "workout.restored" // brought back from the trash
+	WorkoutPurged   = "workout.purged"   // removed from the trash for good
+	EntryAdded      = "entry.added"      // an entry that's new to its workout, one event per entry
+	PRAchieved      = "pr.achieved"      // a personal record set by a workout, one event per record
+)
+
+// Every event type, in the order they're documented
+var EventTypes = []string{WorkoutCreated, WorkoutUpdated, WorkoutDeleted, WorkoutRestored, WorkoutPurged, EntryAdded,
+	PRAchieved}
+
+// A committed change. Data is the JSON the change is described with, e.g. the
+// workout as saved for workout.created.
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	UserID    int             `json:"user_id"` // 0 for workouts logged without a user
+	WorkoutID int             `json:"workout_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func IsEventType(eventType string) bool {
+	for _, known := range EventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Write adds an event to the outbox within tx, it's only visible once tx commits
+func Write(tx *sql.Tx, eventType string, userID, workoutID int, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO outbox_events (event_type, user_id, workout_id, payload)
+	VALUES ($1, $2, $3, $4)
+	`
+	_, err = tx.Exec(query, eventType, sql.NullInt64{Int64: int64(userID), Valid: userID != 0}, workoutID, string(payload))
+	return err
+}
@@ -0,0 +1,217 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+)
+
+const (
+	// Events claimed and handed to the bus at once
+	relayBatchSize = 100
+	// How long a relay has to publish the events it claimed before another may claim them
+	relayLease = 5 * time.Minute
+	// An event is given up on once a subscriber failed it this many times
+	MaxRelayAttempts = 10
+	baseRelayBackoff = 5 * time.Second
+	maxRelayBackoff  = time.Hour
+)
+
+// Relay feeds committed outbox events to the bus. An event is published once every
+// interested subscriber handled it; subscribers that fail get it again with a growing
+// delay, without it being repeated to the ones that succeeded. Instances share the
+// work, each event being claimed by one relay at a time. Delivery is at least once:
+// a relay that dies after publishing leaves its events to be published again.
+type Relay struct {
+	db       *sql.DB
+	bus      *Bus
+	logger   *log.Logger
+	interval time.Duration
+}
+
+func NewRelay(db *sql.DB, bus *Bus, logger *log.Logger, interval time.Duration) *Relay {
+	return &Relay{
+		db:       db,
+		bus:      bus,
+		logger:   logger,
+		interval: interval,
+	}
+}
+
+// Run relays once immediately and then on every interval until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			relayed, err := r.RelayOnce(ctx)
+			if err != nil {
+				r.logger.Printf("Error relayOnce: %v", err)
+				break
+			}
+			if relayed < relayBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce hands a batch of due events to the bus and returns how many it handled.
+// The batch is claimed for relayLease and published after the claim committed, so
+// subscribers never run inside the relay's transaction. Events of a relay that died
+// meanwhile are claimed again once the lease ran out.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	batch, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, c := range batch {
+		failed := r.bus.Publish(ctx, c.event, c.pending)
+		err = r.record(c, failed)
+		if errors.Is(err, sql.ErrNoRows) {
+			r.logger.Printf("Event %d outlived its lease, leaving it to the relay that claimed it again", c.event.ID)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(batch), nil
+}
+
+type claimedEvent struct {
+	event       Event
+	attempts    int
+	pending     []string // nil for every subscriber
+	leasedUntil time.Time
+}
+
+// Pushes the next relay of a batch of due events past the lease and returns them in order
+func (r *Relay) claim(ctx context.Context) ([]claimedEvent, error) {
+	// FOR NO KEY UPDATE lets subscribers insert rows referencing the events meanwhile
+	query := `
+	UPDATE outbox_events
+	SET next_relay_at = $2
+	WHERE id IN (
+		SELECT id FROM outbox_events
+		WHERE published_at IS NULL AND next_relay_at <= CURRENT_TIMESTAMP
+		ORDER BY id
+		FOR NO KEY UPDATE SKIP LOCKED
+		LIMIT $1
+	)
+	RETURNING id, event_type, COALESCE(user_id, 0), workout_id, payload, created_at, relay_attempts,
+		pending_subscribers, next_relay_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, relayBatchSize, time.Now().Add(relayLease))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []claimedEvent
+	for rows.Next() {
+		var c claimedEvent
+		var payload []byte
+		var pending pgtype.TextArray
+		err := rows.Scan(&c.event.ID, &c.event.Type, &c.event.UserID, &c.event.WorkoutID, &payload,
+			&c.event.CreatedAt, &c.attempts, &pending, &c.leasedUntil)
+		if err != nil {
+			return nil, err
+		}
+		c.event.Data = payload
+		if pending.Status == pgtype.Present {
+			err = pending.AssignTo(&c.pending)
+			if err != nil {
+				return nil, err
+			}
+		}
+		batch = append(batch, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(batch, func(i, j int) bool { return batch[i].event.ID < batch[j].event.ID })
+	return batch, nil
+}
+
+// Records how publishing a claimed event went, sql.ErrNoRows when its lease was
+// taken over by another relay
+func (r *Relay) record(c claimedEvent, failed map[string]error) error {
+	var result sql.Result
+	var err error
+	if len(failed) == 0 {
+		result, err = r.db.Exec(`
+		UPDATE outbox_events
+		SET published_at = CURRENT_TIMESTAMP, pending_subscribers = NULL, last_error = NULL
+		WHERE id = $1 AND published_at IS NULL AND next_relay_at = $2`, c.event.ID, c.leasedUntil)
+	} else {
+		names, lastError := describeFailures(failed)
+		attempts := c.attempts + 1
+		if attempts >= MaxRelayAttempts {
+			r.logger.Printf("Event %d given up after %d attempts: %s", c.event.ID, attempts, lastError)
+			result, err = r.db.Exec(`
+			UPDATE outbox_events
+			SET published_at = CURRENT_TIMESTAMP, relay_attempts = $3, pending_subscribers = $4, last_error = $5
+			WHERE id = $1 AND published_at IS NULL AND next_relay_at = $2`,
+				c.event.ID, c.leasedUntil, attempts, names, lastError)
+		} else {
+			result, err = r.db.Exec(`
+			UPDATE outbox_events
+			SET relay_attempts = $3, pending_subscribers = $4, last_error = $5, next_relay_at = $6
+			WHERE id = $1 AND published_at IS NULL AND next_relay_at = $2`,
+				c.event.ID, c.leasedUntil, attempts, names, lastError, time.Now().Add(relayBackoff(attempts)))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// The names of the failed subscribers, sorted, and their errors in one message
+func describeFailures(failed map[string]error) ([]string, string) {
+	names := make([]string, 0, len(failed))
+	for name := range failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]string, len(names))
+	for i, name := range names {
+		messages[i] = name + ": " + failed[name].Error()
+	}
+	return names, strings.Join(messages, "; ")
+}
+
+// The wait after an event failed for the attempts-th time
+func relayBackoff(attempts int) time.Duration {
+	wait := baseRelayBackoff
+	for i := 1; i < attempts && wait < maxRelayBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxRelayBackoff)
+}
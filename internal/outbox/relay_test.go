@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/Josesx506/gofems/internal/store"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelay(t *testing.T) {
+	db := store.SetupTestDB(t, "../../migrations/")
+	defer db.Close()
+
+	_, err := db.Exec(`DELETE FROM outbox_events`)
+	require.NoError(t, err)
+
+	var userID int
+	require.NoError(t, db.QueryRow(`INSERT INTO users (username, email, password_hash)
+		VALUES ('lifter', 'lifter@example.com', 'hash') RETURNING id`).Scan(&userID))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, Write(tx, WorkoutCreated, userID, 1, map[string]int{"id": 1}))
+	require.NoError(t, Write(tx, PRAchieved, userID, 1, map[string]string{"record_type": "max_weight"}))
+
+	bus := NewBus()
+	var counted, recorded []int64
+	fail := true
+	bus.Subscribe("counter", func(ctx context.Context, event Event) error {
+		counted = append(counted, event.ID)
+		return nil
+	})
+	bus.Subscribe("records", func(ctx context.Context, event Event) error {
+		if fail {
+			return errors.New("unavailable")
+		}
+		recorded = append(recorded, event.ID)
+		return nil
+	}, PRAchieved)
+
+	relay := NewRelay(db, bus, log.New(io.Discard, "", 0), time.Minute)
+	relayed, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, relayed, "uncommitted events aren't relayed")
+
+	require.NoError(t, tx.Commit())
+	relayed, err = relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+	require.Len(t, counted, 2)
+	assert.Empty(t, recorded)
+
+	// The failed event waits for its backoff, then only goes to the subscriber that failed
+	_, err = db.Exec(`UPDATE outbox_events SET next_relay_at = CURRENT_TIMESTAMP WHERE published_at IS NULL`)
+	require.NoError(t, err)
+	fail = false
+	relayed, err = relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, relayed)
+	assert.Len(t, counted, 2)
+	assert.Equal(t, []int64{counted[1]}, recorded)
+
+	var unpublished int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM outbox_events WHERE published_at IS NULL`).Scan(&unpublished))
+	assert.Zero(t, unpublished)
+
+	// Subscribers run once the claim committed, the event isn't locked meanwhile
+	tx, err = db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, Write(tx, WorkoutDeleted, userID, 1, map[string]int{"id": 1}))
+	require.NoError(t, tx.Commit())
+
+	lockBus := NewBus()
+	var lockErr error
+	var deliveries int
+	lockBus.Subscribe("locker", func(ctx context.Context, event Event) error {
+		deliveries++
+		_, lockErr = db.ExecContext(ctx, `SELECT 1 FROM outbox_events WHERE id = $1 FOR UPDATE NOWAIT`, event.ID)
+		return nil
+	})
+	lockRelay := NewRelay(db, lockBus, log.New(io.Discard, "", 0), time.Minute)
+
+	// A relay that outlived its lease doesn't overwrite the one that claimed the event again
+	stale, err := lockRelay.claim(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stale, 1)
+	_, err = db.Exec(`UPDATE outbox_events SET next_relay_at = CURRENT_TIMESTAMP WHERE published_at IS NULL`)
+	require.NoError(t, err)
+
+	relayed, err = lockRelay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, relayed)
+	assert.Equal(t, 1, deliveries)
+	assert.NoError(t, lockErr)
+	assert.Equal(t, sql.ErrNoRows, lockRelay.record(stale[0], map[string]error{"locker": errors.New("late")}))
+
+	var lastError sql.NullString
+	require.NoError(t, db.QueryRow(`SELECT last_error FROM outbox_events WHERE id = $1`, stale[0].event.ID).Scan(&lastError))
+	assert.False(t, lastError.Valid)
+}
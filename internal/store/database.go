@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -72,9 +74,29 @@ func Migrate(db *sql.DB, dir string) error {
 	return nil
 }
 
+// The test database, the host is the service name in .devcontainer/docker-compose.yml
+const testDSN = "host=test_db user=postgres password=postgres dbname=postgres port=5432 sslmode=disable"
+
+// SetupTestDB migrates and resets a schema of the test database that belongs to the
+// calling package, so packages tested in parallel by go test ./... don't wipe each
+// other's rows or race each other's migrations.
 func SetupTestDB(t *testing.T, migrationDirectory string) *sql.DB {
-	//  Define the host using the  service name in .devcontainer/docker-compose.yml
-	db, err := sql.Open("pgx", "host=test_db user=postgres password=postgres dbname=postgres port=5432 sslmode=disable")
+	schema, err := testSchema()
+	if err != nil {
+		t.Fatalf("Failed to name test schema: %v", err)
+	}
+
+	admin, err := sql.Open("pgx", testDSN)
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	_, err = admin.Exec(`CREATE SCHEMA IF NOT EXISTS ` + schema)
+	admin.Close()
+	if err != nil {
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+
+	db, err := sql.Open("pgx", testDSN+" search_path="+schema)
 	if err != nil {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
@@ -86,10 +108,50 @@ func SetupTestDB(t *testing.T, migrationDirectory string) *sql.DB {
 	}
 
 	// Reset the database state before each test
-	_, err = db.Exec(`Truncate workouts, workout_entries CASCADE`)
+	_, err = db.Exec(`Truncate workouts, workout_entries, workout_templates CASCADE`)
 	if err != nil {
 		t.Fatalf("Failed to truncate test database: %v", err)
 	}
 
+	// Users are deleted rather than truncated so seeded rows that only
+	// reference them optionally (e.g. the built-in exercise library) survive
+	_, err = db.Exec(`DELETE FROM users`)
+	if err != nil {
+		t.Fatalf("Failed to reset users in test database: %v", err)
+	}
+
 	return db
 }
+
+// The schema of the package under test, named after its directory within the module,
+// e.g. test_internal_api_v1_workouts. Tests run from their package's directory.
+func testSchema() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	root := dir
+	for {
+		if _, err := os.Stat(filepath.Join(root, "go.mod")); err == nil {
+			break
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return "", fmt.Errorf("no go.mod above %s", dir)
+		}
+		root = parent
+	}
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return "", err
+	}
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(filepath.ToSlash(rel)))
+	return "test_" + name, nil
+}
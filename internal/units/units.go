@@ -0,0 +1,99 @@
+// Package units converts measurements between the units clients log in and
+// the canonical units they're stored in
+package units
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Distances are stored in meters
+type DistanceUnit string
+
+const (
+	Meters     DistanceUnit = "m"
+	Kilometers DistanceUnit = "km"
+	Miles      DistanceUnit = "mi"
+)
+
+const metersPerMile = 1609.344
+
+// ParseDistanceUnit accepts a unit case-insensitively, an empty unit selects kilometers
+func ParseDistanceUnit(unit string) (DistanceUnit, error) {
+	switch DistanceUnit(strings.ToLower(strings.TrimSpace(unit))) {
+	case "":
+		return Kilometers, nil
+	case Meters:
+		return Meters, nil
+	case Kilometers:
+		return Kilometers, nil
+	case Miles:
+		return Miles, nil
+	}
+	return "", fmt.Errorf("unknown distance unit %q, expected m, km or mi", unit)
+}
+
+func ToMeters(distance float64, unit DistanceUnit) float64 {
+	switch unit {
+	case Kilometers:
+		return distance * 1000
+	case Miles:
+		return distance * metersPerMile
+	}
+	return distance
+}
+
+func FromMeters(meters float64, unit DistanceUnit) float64 {
+	switch unit {
+	case Kilometers:
+		return meters / 1000
+	case Miles:
+		return meters / metersPerMile
+	}
+	return meters
+}
+
+// Weights are stored in kilograms
+type WeightUnit string
+
+const (
+	Kilograms WeightUnit = "kg"
+	Pounds    WeightUnit = "lb"
+)
+
+const kilogramsPerPound = 0.45359237
+
+// ParseWeightUnit accepts a unit case-insensitively, an empty unit selects kilograms
+func ParseWeightUnit(unit string) (WeightUnit, error) {
+	switch WeightUnit(strings.ToLower(strings.TrimSpace(unit))) {
+	case "", Kilograms, "kgs":
+		return Kilograms, nil
+	case Pounds, "lbs":
+		return Pounds, nil
+	}
+	return "", fmt.Errorf("unknown weight unit %q, expected kg or lb", unit)
+}
+
+func ToKilograms(weight float64, unit WeightUnit) float64 {
+	if unit == Pounds {
+		return weight * kilogramsPerPound
+	}
+	return weight
+}
+
+// FromKilograms converts a stored weight for output, rounded to two decimals
+// so that weights logged in pounds read back as they were entered
+func FromKilograms(kilograms float64, unit WeightUnit) float64 {
+	if unit == Pounds {
+		kilograms /= kilogramsPerPound
+	}
+	return math.Round(kilograms*100) / 100
+}
+
+// ConvertWeight converts an optional stored weight in place
+func ConvertWeight(kilograms *float64, unit WeightUnit) {
+	if kilograms != nil {
+		*kilograms = FromKilograms(*kilograms, unit)
+	}
+}
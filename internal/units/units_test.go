@@ -0,0 +1,51 @@
+package units
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistanceConversions(t *testing.T) {
+	unit, err := ParseDistanceUnit("")
+	require.NoError(t, err)
+	assert.Equal(t, Kilometers, unit)
+
+	unit, err = ParseDistanceUnit(" MI ")
+	require.NoError(t, err)
+	assert.Equal(t, Miles, unit)
+
+	_, err = ParseDistanceUnit("furlong")
+	assert.Error(t, err)
+
+	assert.Equal(t, 5000.0, ToMeters(5, Kilometers))
+	assert.InDelta(t, 1609.344, ToMeters(1, Miles), 1e-9)
+	assert.InDelta(t, 3.10686, FromMeters(5000, Miles), 1e-5)
+	assert.Equal(t, 400.0, FromMeters(ToMeters(400, Meters), Meters))
+}
+
+func TestWeightConversions(t *testing.T) {
+	unit, err := ParseWeightUnit("LBS")
+	require.NoError(t, err)
+	assert.Equal(t, Pounds, unit)
+
+	unit, err = ParseWeightUnit("")
+	require.NoError(t, err)
+	assert.Equal(t, Kilograms, unit)
+
+	_, err = ParseWeightUnit("stone")
+	assert.Error(t, err)
+
+	// Stored with three decimals, pounds survive the round trip
+	for _, pounds := range []float64{45, 135, 225, 315, 405, 1000} {
+		stored := math.Round(ToKilograms(pounds, Pounds)*1000) / 1000
+		assert.Equal(t, pounds, FromKilograms(stored, Pounds))
+	}
+
+	weight := 100.0
+	ConvertWeight(&weight, Pounds)
+	assert.Equal(t, 220.46, weight)
+	ConvertWeight(nil, Pounds)
+}
@@ -2,7 +2,7 @@ package utils
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -26,15 +26,20 @@ func WriteJSON(w http.ResponseWriter, status int, data Envelope) error {
 }
 
 func ReadIDParam(r *http.Request) (int64, error) {
-	idParam := chi.URLParam(r, "id")
-	if idParam == "" {
-		return 0, errors.New("missing id parameter")
+	return ReadInt64Param(r, "id")
+}
+
+// Reads a named integer url parameter e.g. {rev} in /workouts/{id}/revisions/{rev}
+func ReadInt64Param(r *http.Request, key string) (int64, error) {
+	param := chi.URLParam(r, key)
+	if param == "" {
+		return 0, fmt.Errorf("missing %s parameter", key)
 	}
 
-	id, err := strconv.ParseInt(idParam, 10, 64)
+	value, err := strconv.ParseInt(param, 10, 64)
 	if err != nil {
-		return 0, errors.New("invalid id parameter type")
+		return 0, fmt.Errorf("invalid %s parameter type", key)
 	}
 
-	return id, nil
+	return value, nil
 }
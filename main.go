@@ -1,18 +1,37 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/Josesx506/gofems/internal/api"
+	"github.com/Josesx506/gofems/internal/api/v1/account"
+	"github.com/Josesx506/gofems/internal/api/v1/analytics"
+	"github.com/Josesx506/gofems/internal/api/v1/webhooks"
+	"github.com/Josesx506/gofems/internal/api/v1/workouts"
 	"github.com/Josesx506/gofems/internal/app"
+	"github.com/Josesx506/gofems/internal/jobs"
+	"github.com/Josesx506/gofems/internal/outbox"
 )
 
 func main() {
 	var port int
+	var trashRetention time.Duration
+	var rollupRefresh time.Duration
+	var workers string
 	flag.IntVar(&port, "port", 8080, "go backend server port")
+	flag.DurationVar(&trashRetention, "trash-retention", 30*24*time.Hour, "how long deleted workouts stay in the trash")
+	flag.DurationVar(&rollupRefresh, "rollup-refresh", 15*time.Minute, "how often analytics rollups are refreshed, 0 disables")
+	flag.StringVar(&workers, "workers", "default=2,exports=1,maintenance=1", "jobs run at once per queue, 0 leaves a queue to other instances")
 	flag.Parse()
 
 	app, err := app.NewApplication()
@@ -23,6 +42,43 @@ func main() {
 
 	defer app.DB.Close() // Close the db connections at the end
 
+	// An interrupt or SIGTERM stops the server and the background work, which hands
+	// back what it claimed before the db connections close
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var background sync.WaitGroup
+	runInBackground := func(run func(context.Context)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run(ctx)
+		}()
+	}
+
+	// Features register their kinds of job and schedules before the queue starts
+	workouts.RegisterJobs(app, trashRetention)
+	account.RegisterJobs(app)
+	analytics.RegisterJobs(app, rollupRefresh)
+
+	queueWorkers, err := jobs.ParseWorkers(workers)
+	if err != nil {
+		app.Logger.Fatal(err)
+	}
+	for queue, count := range queueWorkers {
+		app.Jobs.SetWorkers(queue, count)
+	}
+	runInBackground(app.Jobs.Run)
+
+	// Features react to workout changes by subscribing to their events before the relay starts
+	webhookStore := webhooks.NewPostgresWebhookStore(app.DB)
+	app.Events.Subscribe("webhooks", webhooks.QueueEvent(webhookStore))
+
+	relay := outbox.NewRelay(app.DB, app.Events, app.Logger, time.Second)
+	runInBackground(relay.Run)
+
+	dispatcher := webhooks.NewDispatcher(webhookStore, webhooks.NewClient(10*time.Second), app.Logger, 5*time.Second)
+	runInBackground(dispatcher.Run)
+
 	// Create a health route manually with the stdlib
 	// http.HandleFunc("/health", HealthChecker)
 
@@ -35,13 +91,30 @@ func main() {
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Minute,
 		WriteTimeout: 30 * time.Minute,
+		// Event streams end with the server instead of holding up its shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
 
 	app.Logger.Printf("We are running our api on port %d\n", port)
 
-	// Update the err variable
-	err = server.ListenAndServe()
-	if err != nil {
-		app.Logger.Fatal(err)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serverErr:
+		app.Logger.Printf("Error listenAndServe: %v", err)
+		stop()
+	case <-ctx.Done():
+		app.Logger.Printf("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		err = server.Shutdown(shutdownCtx)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.Logger.Printf("Error shutdown: %v", err)
+		}
 	}
+
+	background.Wait()
 }
@@ -0,0 +1,40 @@
+-- +goose Up
+-- Titles are no longer unique: the same user can log "Leg Day" every week.
+-- An optional slug is unique per user among workouts that aren't in the trash.
+-- +goose StatementBegin
+ALTER TABLE workouts DROP CONSTRAINT IF EXISTS workouts_title_key;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE workouts
+    ADD COLUMN IF NOT EXISTS user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS slug VARCHAR(255);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_workouts_user_id ON workouts (user_id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS workouts_user_slug_key
+    ON workouts (COALESCE(user_id, 0), slug)
+    WHERE slug IS NOT NULL AND deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS workouts_user_slug_key;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_user_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN IF EXISTS slug, DROP COLUMN IF EXISTS user_id;
+-- +goose StatementEnd
+
+-- Fails if duplicate titles were logged while the constraint was lifted
+-- +goose StatementBegin
+ALTER TABLE workouts ADD CONSTRAINT workouts_title_key UNIQUE (title);
+-- +goose StatementEnd
@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_templates (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS template_entries (
+    id BIGSERIAL PRIMARY KEY,
+    template_id BIGINT NOT NULL REFERENCES workout_templates(id) ON DELETE CASCADE,
+    exercise_name VARCHAR(255) NOT NULL,
+    target_sets INTEGER NOT NULL,
+    target_reps_min INTEGER,
+    target_reps_max INTEGER,
+    target_duration_seconds INTEGER,
+    target_weight_min DECIMAL(6, 2),
+    target_weight_max DECIMAL(6, 2),
+    rest_seconds INTEGER,
+    notes TEXT,
+    order_index INTEGER NOT NULL,
+    CONSTRAINT valid_template_entry CHECK (
+        (target_reps_min IS NOT NULL OR target_duration_seconds IS NOT NULL) AND
+        (target_reps_min IS NULL OR target_duration_seconds IS NULL)
+    )
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS template_id BIGINT REFERENCES workout_templates(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_workouts_template_id ON workouts (template_id) WHERE template_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN IF EXISTS template_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE template_entries;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE workout_templates;
+-- +goose StatementEnd
@@ -0,0 +1,71 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS programs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL, -- coach who authored the program
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS program_days (
+    id BIGSERIAL PRIMARY KEY,
+    program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+    week_number INTEGER NOT NULL,
+    day_number INTEGER NOT NULL,
+    template_id BIGINT NOT NULL REFERENCES workout_templates(id) ON DELETE RESTRICT,
+    intensity_type VARCHAR(20),
+    intensity DECIMAL(5, 2),
+    notes TEXT,
+    UNIQUE (program_id, week_number, day_number),
+    CONSTRAINT valid_program_day CHECK (
+        week_number > 0 AND day_number > 0 AND
+        (intensity_type IS NULL OR intensity_type IN ('percent_1rm', 'rpe')) AND
+        ((intensity_type IS NULL) = (intensity IS NULL))
+    )
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS program_enrolments (
+    id BIGSERIAL PRIMARY KEY,
+    program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL DEFAULT CURRENT_DATE,
+    training_maxes JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_enrolment_status CHECK (status IN ('active', 'completed', 'cancelled'))
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS program_day_completions (
+    id BIGSERIAL PRIMARY KEY,
+    enrolment_id BIGINT NOT NULL REFERENCES program_enrolments(id) ON DELETE CASCADE,
+    program_day_id BIGINT NOT NULL REFERENCES program_days(id) ON DELETE CASCADE,
+    workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    completed_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (enrolment_id, program_day_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE program_day_completions;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE program_enrolments;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE program_days;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE programs;
+-- +goose StatementEnd
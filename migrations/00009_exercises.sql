@@ -0,0 +1,95 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS exercises (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE, -- NULL for the built-in library
+    name VARCHAR(255) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    category VARCHAR(50) NOT NULL,
+    primary_muscles TEXT[] NOT NULL DEFAULT '{}',
+    secondary_muscles TEXT[] NOT NULL DEFAULT '{}',
+    equipment VARCHAR(50) NOT NULL DEFAULT 'none',
+    unilateral BOOLEAN NOT NULL DEFAULT FALSE,
+    measurement_type VARCHAR(20) NOT NULL DEFAULT 'reps',
+    created_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_measurement_type CHECK (measurement_type IN ('reps', 'duration'))
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS exercises_user_name_key ON exercises (COALESCE(user_id, 0), LOWER(name));
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO exercises (name, aliases, category, primary_muscles, secondary_muscles, equipment, unilateral, measurement_type) VALUES
+    ('Back Squat', '{Squat,Squats,Barbell Squat}', 'strength', '{quadriceps,glutes}', '{hamstrings,lower back}', 'barbell', FALSE, 'reps'),
+    ('Front Squat', '{Barbell Front Squat}', 'strength', '{quadriceps}', '{glutes,core}', 'barbell', FALSE, 'reps'),
+    ('Goblet Squat', '{}', 'strength', '{quadriceps,glutes}', '{core}', 'dumbbell', FALSE, 'reps'),
+    ('Deadlift', '{Deadlifts,Conventional Deadlift,Barbell Deadlift}', 'strength', '{hamstrings,glutes,lower back}', '{forearms,traps}', 'barbell', FALSE, 'reps'),
+    ('Romanian Deadlift', '{RDL,RDLs}', 'strength', '{hamstrings}', '{glutes,lower back}', 'barbell', FALSE, 'reps'),
+    ('Lunge', '{Lunges,Walking Lunges}', 'strength', '{quadriceps,glutes}', '{hamstrings}', 'dumbbell', TRUE, 'reps'),
+    ('Bulgarian Split Squat', '{Split Squat}', 'strength', '{quadriceps,glutes}', '{hamstrings}', 'dumbbell', TRUE, 'reps'),
+    ('Leg Press', '{}', 'strength', '{quadriceps}', '{glutes}', 'machine', FALSE, 'reps'),
+    ('Leg Curl', '{Hamstring Curl}', 'strength', '{hamstrings}', '{}', 'machine', FALSE, 'reps'),
+    ('Calf Raise', '{Calf Raises}', 'strength', '{calves}', '{}', 'machine', FALSE, 'reps'),
+    ('Bench Press', '{Barbell Bench Press,Flat Bench}', 'strength', '{chest}', '{triceps,shoulders}', 'barbell', FALSE, 'reps'),
+    ('Incline Bench Press', '{Incline Press}', 'strength', '{chest,shoulders}', '{triceps}', 'barbell', FALSE, 'reps'),
+    ('Dumbbell Bench Press', '{DB Bench Press}', 'strength', '{chest}', '{triceps,shoulders}', 'dumbbell', FALSE, 'reps'),
+    ('Overhead Press', '{OHP,Military Press,Shoulder Press}', 'strength', '{shoulders}', '{triceps,core}', 'barbell', FALSE, 'reps'),
+    ('Push-Up', '{Push-Ups,Pushups,Push Up}', 'strength', '{chest}', '{triceps,shoulders,core}', 'bodyweight', FALSE, 'reps'),
+    ('Dip', '{Dips}', 'strength', '{triceps,chest}', '{shoulders}', 'bodyweight', FALSE, 'reps'),
+    ('Pull-Up', '{Pull-Ups,Pullups,Pull Up}', 'strength', '{lats}', '{biceps,upper back}', 'bodyweight', FALSE, 'reps'),
+    ('Chin-Up', '{Chin-Ups,Chinups}', 'strength', '{lats,biceps}', '{upper back}', 'bodyweight', FALSE, 'reps'),
+    ('Barbell Row', '{Bent Over Row,Bent-Over Row}', 'strength', '{upper back,lats}', '{biceps,lower back}', 'barbell', FALSE, 'reps'),
+    ('Dumbbell Row', '{One Arm Row,Single Arm Row}', 'strength', '{upper back,lats}', '{biceps}', 'dumbbell', TRUE, 'reps'),
+    ('Lat Pulldown', '{Pulldown}', 'strength', '{lats}', '{biceps}', 'cable', FALSE, 'reps'),
+    ('Bicep Curl', '{Biceps Curl,Curl,Dumbbell Curl}', 'strength', '{biceps}', '{forearms}', 'dumbbell', FALSE, 'reps'),
+    ('Tricep Extension', '{Triceps Extension,Skull Crusher}', 'strength', '{triceps}', '{}', 'dumbbell', FALSE, 'reps'),
+    ('Lateral Raise', '{Side Raise}', 'strength', '{shoulders}', '{}', 'dumbbell', FALSE, 'reps'),
+    ('Hip Thrust', '{Barbell Hip Thrust}', 'strength', '{glutes}', '{hamstrings}', 'barbell', FALSE, 'reps'),
+    ('Kettlebell Swing', '{KB Swing}', 'conditioning', '{glutes,hamstrings}', '{core,shoulders}', 'kettlebell', FALSE, 'reps'),
+    ('Burpee', '{Burpees}', 'conditioning', '{full body}', '{}', 'bodyweight', FALSE, 'reps'),
+    ('Box Jump', '{Box Jumps}', 'plyometrics', '{quadriceps,glutes}', '{calves}', 'box', FALSE, 'reps'),
+    ('Crunch', '{Crunches,Sit-Up,Sit-Ups}', 'core', '{abs}', '{}', 'bodyweight', FALSE, 'reps'),
+    ('Hanging Leg Raise', '{Leg Raise,Leg Raises}', 'core', '{abs}', '{hip flexors}', 'bodyweight', FALSE, 'reps'),
+    ('Plank', '{Planks,Front Plank}', 'core', '{abs}', '{shoulders}', 'bodyweight', FALSE, 'duration'),
+    ('Side Plank', '{}', 'core', '{obliques}', '{shoulders}', 'bodyweight', TRUE, 'duration'),
+    ('Wall Sit', '{}', 'strength', '{quadriceps}', '{glutes}', 'bodyweight', FALSE, 'duration'),
+    ('Dead Hang', '{Bar Hang}', 'strength', '{forearms}', '{lats}', 'bodyweight', FALSE, 'duration'),
+    ('Running', '{Run,Jogging,Jog}', 'cardio', '{quadriceps,calves}', '{hamstrings,glutes}', 'none', FALSE, 'duration'),
+    ('Walking', '{Walk}', 'cardio', '{quadriceps,calves}', '{glutes}', 'none', FALSE, 'duration'),
+    ('Cycling', '{Bike,Biking,Ride}', 'cardio', '{quadriceps}', '{calves,glutes}', 'bike', FALSE, 'duration'),
+    ('Rowing', '{Row Erg,Rowing Machine}', 'cardio', '{upper back,quadriceps}', '{biceps,core}', 'machine', FALSE, 'duration'),
+    ('Swimming', '{Swim}', 'cardio', '{full body}', '{}', 'none', FALSE, 'duration'),
+    ('Jump Rope', '{Skipping}', 'cardio', '{calves}', '{shoulders}', 'rope', FALSE, 'duration'),
+    ('Hamstring Stretch', '{}', 'mobility', '{hamstrings}', '{}', 'none', FALSE, 'duration');
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE workout_entries ADD COLUMN IF NOT EXISTS exercise_id BIGINT REFERENCES exercises(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_workout_entries_exercise_id ON workout_entries (exercise_id);
+-- +goose StatementEnd
+
+-- Backfill entries whose free-text name matches a built-in name or alias
+-- +goose StatementBegin
+UPDATE workout_entries we
+SET exercise_id = e.id
+FROM exercises e
+WHERE we.exercise_id IS NULL AND e.user_id IS NULL AND (
+    LOWER(e.name) = LOWER(TRIM(we.exercise_name)) OR
+    EXISTS (SELECT 1 FROM UNNEST(e.aliases) alias WHERE LOWER(alias) = LOWER(TRIM(we.exercise_name)))
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries DROP COLUMN IF EXISTS exercise_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE exercises;
+-- +goose StatementEnd
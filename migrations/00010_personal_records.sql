@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS personal_records (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    exercise_key VARCHAR(255) NOT NULL, -- exercise id, or the normalized name of unlinked entries
+    exercise_id BIGINT REFERENCES exercises(id) ON DELETE SET NULL,
+    exercise_name VARCHAR(255) NOT NULL,
+    record_type VARCHAR(30) NOT NULL,
+    value DECIMAL(12, 2) NOT NULL,
+    weight DECIMAL(8, 2), -- the weight a reps_at_weight record was set at
+    previous_value DECIMAL(12, 2),
+    workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    entry_id BIGINT REFERENCES workout_entries(id) ON DELETE SET NULL,
+    achieved_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_record_type CHECK (
+        record_type IN ('max_weight', 'reps_at_weight', 'estimated_1rm', 'longest_duration', 'session_volume')
+    )
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_personal_records_user_exercise ON personal_records (user_id, exercise_key, record_type);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_personal_records_workout_id ON personal_records (workout_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE personal_records;
+-- +goose StatementEnd
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_workouts_user_created_at ON workouts (user_id, created_at) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_workout_entries_workout_id ON workout_entries (workout_id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Daily totals per user for the analytics API, days are UTC. Refreshed periodically,
+-- so it can lag behind the live tables by the refresh interval.
+CREATE MATERIALIZED VIEW IF NOT EXISTS workout_daily_rollups AS
+SELECT
+    COALESCE(w.user_id, 0) AS user_id, -- 0 for workouts logged without a user
+    (w.created_at AT TIME ZONE 'UTC')::date AS day,
+    COUNT(*) AS sessions,
+    SUM(w.duration_minutes) AS duration_minutes,
+    COALESCE(SUM(w.calories_burned), 0) AS calories_burned,
+    COALESCE(SUM(e.volume), 0) AS volume,
+    COALESCE(SUM(e.sets), 0) AS sets
+FROM workouts w
+LEFT JOIN (
+    SELECT workout_id, SUM(sets * reps * weight) AS volume, SUM(sets) AS sets
+    FROM workout_entries
+    GROUP BY workout_id
+) e ON e.workout_id = w.id
+WHERE w.deleted_at IS NULL
+GROUP BY COALESCE(w.user_id, 0), (w.created_at AT TIME ZONE 'UTC')::date;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- REFRESH ... CONCURRENTLY needs a unique index over plain columns
+CREATE UNIQUE INDEX IF NOT EXISTS workout_daily_rollups_user_day_key ON workout_daily_rollups (user_id, day);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP MATERIALIZED VIEW workout_daily_rollups;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workout_entries_workout_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_user_created_at;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS entry_sets (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES workout_entries(id) ON DELETE CASCADE,
+    set_number INTEGER NOT NULL,
+    reps INTEGER,
+    duration_seconds INTEGER,
+    weight DECIMAL(5, 2),
+    rpe DECIMAL(3, 1),
+    rir INTEGER,
+    set_type VARCHAR(20) NOT NULL DEFAULT 'working',
+    rest_seconds INTEGER,
+    completed BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT entry_sets_entry_set_number_key UNIQUE (entry_id, set_number),
+    CONSTRAINT valid_entry_set CHECK (
+        (reps IS NOT NULL OR duration_seconds IS NOT NULL) AND
+        (reps IS NULL OR duration_seconds IS NULL)
+    ),
+    CONSTRAINT valid_set_type CHECK (set_type IN ('warmup', 'working', 'drop', 'failure')),
+    CONSTRAINT valid_rpe CHECK (rpe IS NULL OR rpe BETWEEN 1 AND 10),
+    CONSTRAINT valid_rir CHECK (rir IS NULL OR rir >= 0)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE entry_sets;
+-- +goose StatementEnd
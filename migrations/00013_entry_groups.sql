@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS entry_groups (
+    id BIGSERIAL PRIMARY KEY,
+    workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    group_number INTEGER NOT NULL, -- the group_id entries reference within their workout
+    group_type VARCHAR(20) NOT NULL,
+    rounds INTEGER NOT NULL DEFAULT 1,
+    rest_between_rounds_seconds INTEGER,
+    duration_seconds INTEGER, -- time cap of an AMRAP
+    CONSTRAINT entry_groups_workout_group_number_key UNIQUE (workout_id, group_number),
+    CONSTRAINT valid_group_type CHECK (group_type IN ('superset', 'circuit', 'emom', 'amrap')),
+    CONSTRAINT valid_rounds CHECK (rounds > 0)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE workout_entries ADD COLUMN IF NOT EXISTS group_id BIGINT REFERENCES entry_groups(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries DROP COLUMN IF EXISTS group_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE entry_groups;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS entry_cardio (
+    entry_id BIGINT PRIMARY KEY REFERENCES workout_entries(id) ON DELETE CASCADE,
+    distance_meters DECIMAL(10, 2), -- per set, converted from the unit it was logged in
+    distance_unit VARCHAR(5) NOT NULL DEFAULT 'km',
+    elevation_gain_meters DECIMAL(8, 2),
+    avg_heart_rate INTEGER,
+    max_heart_rate INTEGER,
+    avg_cadence INTEGER,
+    CONSTRAINT valid_distance_unit CHECK (distance_unit IN ('m', 'km', 'mi'))
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE entry_cardio;
+-- +goose StatementEnd
@@ -0,0 +1,116 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS preferred_weight_unit VARCHAR(2) NOT NULL DEFAULT 'kg'
+    CONSTRAINT valid_preferred_weight_unit CHECK (preferred_weight_unit IN ('kg', 'lb'));
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- The rollups read workout_entries.weight, which can't change type while they exist
+DROP MATERIALIZED VIEW IF EXISTS workout_daily_rollups;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Weights are kilograms from here on, weight_unit records what the entry was logged in.
+-- Three decimals keep weights logged in pounds exact when converted back.
+ALTER TABLE workout_entries
+    ALTER COLUMN weight TYPE DECIMAL(9, 3),
+    ADD COLUMN IF NOT EXISTS weight_unit VARCHAR(2) NOT NULL DEFAULT 'kg'
+        CONSTRAINT valid_weight_unit CHECK (weight_unit IN ('kg', 'lb'));
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE entry_sets ALTER COLUMN weight TYPE DECIMAL(9, 3);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE template_entries
+    ALTER COLUMN target_weight_min TYPE DECIMAL(9, 3),
+    ALTER COLUMN target_weight_max TYPE DECIMAL(9, 3);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE personal_records
+    ALTER COLUMN value TYPE DECIMAL(12, 3),
+    ALTER COLUMN weight TYPE DECIMAL(9, 3),
+    ALTER COLUMN previous_value TYPE DECIMAL(12, 3);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE MATERIALIZED VIEW IF NOT EXISTS workout_daily_rollups AS
+SELECT
+    COALESCE(w.user_id, 0) AS user_id, -- 0 for workouts logged without a user
+    (w.created_at AT TIME ZONE 'UTC')::date AS day,
+    COUNT(*) AS sessions,
+    SUM(w.duration_minutes) AS duration_minutes,
+    COALESCE(SUM(w.calories_burned), 0) AS calories_burned,
+    COALESCE(SUM(e.volume), 0) AS volume,
+    COALESCE(SUM(e.sets), 0) AS sets
+FROM workouts w
+LEFT JOIN (
+    SELECT workout_id, SUM(sets * reps * weight) AS volume, SUM(sets) AS sets
+    FROM workout_entries
+    GROUP BY workout_id
+) e ON e.workout_id = w.id
+WHERE w.deleted_at IS NULL
+GROUP BY COALESCE(w.user_id, 0), (w.created_at AT TIME ZONE 'UTC')::date;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS workout_daily_rollups_user_day_key ON workout_daily_rollups (user_id, day);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP MATERIALIZED VIEW IF EXISTS workout_daily_rollups;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE personal_records
+    ALTER COLUMN value TYPE DECIMAL(12, 2),
+    ALTER COLUMN weight TYPE DECIMAL(8, 2),
+    ALTER COLUMN previous_value TYPE DECIMAL(12, 2);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE template_entries
+    ALTER COLUMN target_weight_min TYPE DECIMAL(6, 2),
+    ALTER COLUMN target_weight_max TYPE DECIMAL(6, 2);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE entry_sets ALTER COLUMN weight TYPE DECIMAL(5, 2);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+    DROP COLUMN IF EXISTS weight_unit,
+    ALTER COLUMN weight TYPE DECIMAL(5, 2);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE MATERIALIZED VIEW IF NOT EXISTS workout_daily_rollups AS
+SELECT
+    COALESCE(w.user_id, 0) AS user_id, -- 0 for workouts logged without a user
+    (w.created_at AT TIME ZONE 'UTC')::date AS day,
+    COUNT(*) AS sessions,
+    SUM(w.duration_minutes) AS duration_minutes,
+    COALESCE(SUM(w.calories_burned), 0) AS calories_burned,
+    COALESCE(SUM(e.volume), 0) AS volume,
+    COALESCE(SUM(e.sets), 0) AS sets
+FROM workouts w
+LEFT JOIN (
+    SELECT workout_id, SUM(sets * reps * weight) AS volume, SUM(sets) AS sets
+    FROM workout_entries
+    GROUP BY workout_id
+) e ON e.workout_id = w.id
+WHERE w.deleted_at IS NULL
+GROUP BY COALESCE(w.user_id, 0), (w.created_at AT TIME ZONE 'UTC')::date;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS workout_daily_rollups_user_day_key ON workout_daily_rollups (user_id, day);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS preferred_weight_unit;
+-- +goose StatementEnd
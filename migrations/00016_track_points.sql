@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_track_points (
+    workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    point_index INTEGER NOT NULL, -- 0-based, in time order
+    recorded_at TIMESTAMP with TIME ZONE NOT NULL,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    elevation_meters DECIMAL(7, 2),
+    heart_rate SMALLINT,
+    cadence SMALLINT,
+    distance_meters DECIMAL(10, 2), -- cumulative from the start of the track
+    PRIMARY KEY (workout_id, point_index)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_track_points;
+-- +goose StatementEnd
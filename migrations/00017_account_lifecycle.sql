@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS deletion_mode VARCHAR(10) CHECK (deletion_mode IN ('cascade', 'anonymize')),
+    ADD COLUMN IF NOT EXISTS deletion_requested_at TIMESTAMP with TIME ZONE,
+    ADD COLUMN IF NOT EXISTS deletion_scheduled_for TIMESTAMP with TIME ZONE, -- end of the grace period
+    ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP with TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS users_deletion_scheduled_for_idx ON users (deletion_scheduled_for)
+    WHERE deletion_scheduled_for IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS account_exports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'ready', 'failed')),
+    file_path TEXT, -- archive on local disk, set once ready
+    size_bytes BIGINT,
+    error TEXT,
+    created_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP with TIME ZONE,
+    completed_at TIMESTAMP with TIME ZONE,
+    expires_at TIMESTAMP with TIME ZONE -- archive is removed after this
+);
+
+CREATE INDEX IF NOT EXISTS account_exports_user_id_idx ON account_exports (user_id);
+CREATE INDEX IF NOT EXISTS account_exports_open_idx ON account_exports (created_at)
+    WHERE status IN ('pending', 'running');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE account_exports;
+DROP INDEX IF EXISTS users_deletion_scheduled_for_idx;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deletion_mode,
+    DROP COLUMN IF EXISTS deletion_requested_at,
+    DROP COLUMN IF EXISTS deletion_scheduled_for,
+    DROP COLUMN IF EXISTS anonymized_at;
+-- +goose StatementEnd
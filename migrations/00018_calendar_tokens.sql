@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE, -- one feed per user, rotating replaces it
+    token_hash BYTEA UNIQUE NOT NULL, -- sha256 of the token, the token itself is only shown once
+    created_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE calendar_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS planned_sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    template_id BIGINT REFERENCES workout_templates(id) ON DELETE SET NULL,
+    starts_at TIMESTAMP with TIME ZONE NOT NULL, -- first occurrence
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC', -- occurrences keep the wall clock time of starts_at here
+    duration_minutes INTEGER NOT NULL DEFAULT 60 CHECK (duration_minutes > 0),
+    rrule TEXT, -- RFC 5545 recurrence rule, NULL for one-off sessions
+    created_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS planned_sessions_user_id_idx ON planned_sessions (user_id, starts_at);
+
+CREATE TABLE IF NOT EXISTS planned_session_completions (
+    id BIGSERIAL PRIMARY KEY,
+    session_id BIGINT NOT NULL REFERENCES planned_sessions(id) ON DELETE CASCADE,
+    occurrence_at TIMESTAMP with TIME ZONE NOT NULL,
+    workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    completed_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (session_id, occurrence_at),
+    UNIQUE (workout_id) -- a workout fulfils one occurrence at most
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE planned_session_completions;
+DROP TABLE planned_sessions;
+-- +goose StatementEnd
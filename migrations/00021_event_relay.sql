@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE outbox_events
+    ADD COLUMN published_at TIMESTAMP with TIME ZONE, -- set once every subscriber handled the event
+    ADD COLUMN relay_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN next_relay_at TIMESTAMP with TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN pending_subscribers TEXT[], -- subscribers that failed the event, NULL while none did
+    ADD COLUMN last_error TEXT;
+
+-- Webhook deliveries are now queued by a subscriber, events they were queued for are done
+UPDATE outbox_events SET published_at = webhooks_queued_at WHERE webhooks_queued_at IS NOT NULL;
+
+DROP INDEX IF EXISTS outbox_events_unqueued_idx;
+ALTER TABLE outbox_events DROP COLUMN webhooks_queued_at;
+
+CREATE INDEX IF NOT EXISTS outbox_events_unpublished_idx ON outbox_events (next_relay_at) WHERE published_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS outbox_events_unpublished_idx;
+
+ALTER TABLE outbox_events ADD COLUMN webhooks_queued_at TIMESTAMP with TIME ZONE;
+UPDATE outbox_events SET webhooks_queued_at = published_at;
+CREATE INDEX IF NOT EXISTS outbox_events_unqueued_idx ON outbox_events (id) WHERE webhooks_queued_at IS NULL;
+
+ALTER TABLE outbox_events
+    DROP COLUMN published_at,
+    DROP COLUMN relay_attempts,
+    DROP COLUMN next_relay_at,
+    DROP COLUMN pending_subscribers,
+    DROP COLUMN last_error;
+-- +goose StatementEnd
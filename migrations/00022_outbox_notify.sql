@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Tells listening instances whose events changed. Notifications are sent when the
+-- inserting transaction commits, the payload is the owner's id.
+CREATE OR REPLACE FUNCTION notify_outbox_event() RETURNS trigger AS $$
+BEGIN
+    IF NEW.user_id IS NOT NULL THEN
+        PERFORM pg_notify('outbox_events', NEW.user_id::text);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER outbox_events_notify
+AFTER INSERT ON outbox_events
+FOR EACH ROW EXECUTE FUNCTION notify_outbox_event();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS outbox_events_notify ON outbox_events;
+DROP FUNCTION IF EXISTS notify_outbox_event();
+-- +goose StatementEnd
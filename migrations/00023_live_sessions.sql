@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS live_sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'finished')),
+    started_at TIMESTAMP with TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP with TIME ZONE,
+    workout_id BIGINT REFERENCES workouts(id) ON DELETE SET NULL, -- the workout it was finished into
+    rest_started_at TIMESTAMP with TIME ZONE, -- set while a rest timer runs
+    rest_seconds INTEGER CHECK (rest_seconds > 0),
+    version INTEGER NOT NULL DEFAULT 1 -- bumped by every change
+);
+
+-- A user trains one session at a time, whichever device they log it from
+CREATE UNIQUE INDEX IF NOT EXISTS live_sessions_active_idx ON live_sessions (user_id) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS live_session_sets (
+    id BIGSERIAL PRIMARY KEY,
+    session_id BIGINT NOT NULL REFERENCES live_sessions(id) ON DELETE CASCADE,
+    exercise_name VARCHAR(255) NOT NULL,
+    set_type VARCHAR(10) NOT NULL DEFAULT 'working',
+    reps INTEGER,
+    duration_seconds INTEGER,
+    weight DECIMAL(8, 2), -- in the user's preferred unit
+    rpe DECIMAL(3, 1),
+    rest_seconds INTEGER, -- rest taken after the set, measured by the timer
+    completed_at TIMESTAMP with TIME ZONE,
+    created_at TIMESTAMP with TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS live_session_sets_session_id_idx ON live_session_sets (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE live_session_sets;
+DROP TABLE live_sessions;
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    queue VARCHAR(50) NOT NULL,
+    kind VARCHAR(100) NOT NULL,
+    args JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'succeeded', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL CHECK (max_attempts > 0),
+    timeout_seconds INTEGER NOT NULL CHECK (timeout_seconds > 0),
+    run_at TIMESTAMP with TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP, -- when a pending job is due
+    locked_until TIMESTAMP with TIME ZONE, -- lease of a running job, reclaimed once it passes
+    last_error TEXT,
+    schedule_name VARCHAR(100), -- the schedule that queued the job
+    created_at TIMESTAMP with TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP with TIME ZONE,
+    finished_at TIMESTAMP with TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS jobs_due_idx ON jobs (queue, run_at) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS jobs_schedule_name_idx ON jobs (schedule_name) WHERE schedule_name IS NOT NULL;
+
+-- Cron schedules registered by the running instances, due ones are claimed by one of them
+CREATE TABLE IF NOT EXISTS job_schedules (
+    name VARCHAR(100) PRIMARY KEY,
+    spec VARCHAR(100) NOT NULL,
+    kind VARCHAR(100) NOT NULL,
+    queue VARCHAR(50) NOT NULL,
+    args JSONB NOT NULL DEFAULT '{}',
+    next_run_at TIMESTAMP with TIME ZONE NOT NULL,
+    last_run_at TIMESTAMP with TIME ZONE,
+    last_job_id BIGINT REFERENCES jobs(id) ON DELETE SET NULL
+);
+
+-- Exports requested before archives were built by jobs get theirs
+INSERT INTO jobs (queue, kind, args, max_attempts, timeout_seconds)
+SELECT 'exports', 'account.build_export', jsonb_build_object('export_id', id), 3, 3600
+FROM account_exports
+WHERE status IN ('pending', 'running');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE job_schedules;
+DROP TABLE jobs;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Ids are taken at insert but become visible at commit, so a stream reading by id can
+-- pass an event whose transaction commits late. The inserting transaction is recorded
+-- so streams only read events of transactions older than every one still running.
+ALTER TABLE outbox_events ADD COLUMN xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS outbox_events_user_stream_idx ON outbox_events (user_id, xid, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS outbox_events_user_stream_idx;
+ALTER TABLE outbox_events DROP COLUMN xid;
+-- +goose StatementEnd
@@ -0,0 +1,76 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Volume of an entry: the reps times weight of its counted sets when they were logged
+-- one by one, otherwise the summary columns. Warmups and missed sets aren't counted,
+-- like in the summary.
+CREATE OR REPLACE FUNCTION entry_volume(entry_id BIGINT, sets INTEGER, reps INTEGER, weight DECIMAL)
+RETURNS DECIMAL AS $$
+    SELECT COALESCE(
+        (SELECT SUM(s.reps * s.weight)
+        FROM entry_sets s
+        WHERE s.entry_id = entry_volume.entry_id AND s.completed AND s.set_type <> 'warmup'),
+        sets * reps * weight
+    )
+$$ LANGUAGE SQL STABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP MATERIALIZED VIEW IF EXISTS workout_daily_rollups;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE MATERIALIZED VIEW IF NOT EXISTS workout_daily_rollups AS
+SELECT
+    COALESCE(w.user_id, 0) AS user_id, -- 0 for workouts logged without a user
+    (w.created_at AT TIME ZONE 'UTC')::date AS day,
+    COUNT(*) AS sessions,
+    SUM(w.duration_minutes) AS duration_minutes,
+    COALESCE(SUM(w.calories_burned), 0) AS calories_burned,
+    COALESCE(SUM(e.volume), 0) AS volume,
+    COALESCE(SUM(e.sets), 0) AS sets
+FROM workouts w
+LEFT JOIN (
+    SELECT workout_id, SUM(entry_volume(id, sets, reps, weight)) AS volume, SUM(sets) AS sets
+    FROM workout_entries
+    GROUP BY workout_id
+) e ON e.workout_id = w.id
+WHERE w.deleted_at IS NULL
+GROUP BY COALESCE(w.user_id, 0), (w.created_at AT TIME ZONE 'UTC')::date;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS workout_daily_rollups_user_day_key ON workout_daily_rollups (user_id, day);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP MATERIALIZED VIEW IF EXISTS workout_daily_rollups;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS entry_volume(BIGINT, INTEGER, INTEGER, DECIMAL);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE MATERIALIZED VIEW IF NOT EXISTS workout_daily_rollups AS
+SELECT
+    COALESCE(w.user_id, 0) AS user_id, -- 0 for workouts logged without a user
+    (w.created_at AT TIME ZONE 'UTC')::date AS day,
+    COUNT(*) AS sessions,
+    SUM(w.duration_minutes) AS duration_minutes,
+    COALESCE(SUM(w.calories_burned), 0) AS calories_burned,
+    COALESCE(SUM(e.volume), 0) AS volume,
+    COALESCE(SUM(e.sets), 0) AS sets
+FROM workouts w
+LEFT JOIN (
+    SELECT workout_id, SUM(sets * reps * weight) AS volume, SUM(sets) AS sets
+    FROM workout_entries
+    GROUP BY workout_id
+) e ON e.workout_id = w.id
+WHERE w.deleted_at IS NULL
+GROUP BY COALESCE(w.user_id, 0), (w.created_at AT TIME ZONE 'UTC')::date;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS workout_daily_rollups_user_day_key ON workout_daily_rollups (user_id, day);
+-- +goose StatementEnd
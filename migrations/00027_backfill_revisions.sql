@@ -0,0 +1,80 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Workouts saved before revisions were recorded get their current state as revision 1,
+-- in the shape the store snapshots them: weights in kilograms, distances in their unit.
+INSERT INTO workout_revisions (workout_id, revision, snapshot, created_at)
+SELECT w.id, 1, jsonb_build_object(
+    'id', w.id,
+    'user_id', COALESCE(w.user_id, 0),
+    'title', w.title,
+    'slug', w.slug,
+    'description', COALESCE(w.description, ''),
+    'duration_minutes', w.duration_minutes,
+    'calories_burned', COALESCE(w.calories_burned, 0),
+    'template_id', w.template_id,
+    'created_at', w.created_at,
+    'groups', (
+        SELECT jsonb_agg(jsonb_build_object(
+            'group_id', g.group_number,
+            'group_type', g.group_type,
+            'rounds', g.rounds,
+            'rest_between_rounds_seconds', g.rest_between_rounds_seconds,
+            'duration_seconds', g.duration_seconds
+        ) ORDER BY g.group_number)
+        FROM entry_groups g
+        WHERE g.workout_id = w.id
+    ),
+    'entries', COALESCE((
+        SELECT jsonb_agg(jsonb_build_object(
+            'id', e.id,
+            'exercise_id', e.exercise_id,
+            'exercise_name', e.exercise_name,
+            'sets', e.sets,
+            'reps', e.reps,
+            'duration_seconds', e.duration_seconds,
+            'weight', e.weight,
+            'weight_unit', 'kg',
+            'notes', COALESCE(e.notes, ''),
+            'order_index', e.order_index,
+            'group_id', g.group_number,
+            'sets_detail', (
+                SELECT jsonb_agg(jsonb_build_object(
+                    'id', s.id,
+                    'set_number', s.set_number,
+                    'reps', s.reps,
+                    'duration_seconds', s.duration_seconds,
+                    'weight', s.weight,
+                    'rpe', s.rpe,
+                    'rir', s.rir,
+                    'set_type', s.set_type,
+                    'rest_seconds', s.rest_seconds,
+                    'completed', s.completed
+                ) ORDER BY s.set_number)
+                FROM entry_sets s
+                WHERE s.entry_id = e.id
+            ),
+            'cardio', (
+                SELECT jsonb_build_object(
+                    'distance', c.distance_meters / CASE c.distance_unit
+                        WHEN 'km' THEN 1000 WHEN 'mi' THEN 1609.344 ELSE 1 END,
+                    'distance_unit', c.distance_unit,
+                    'elevation_gain_meters', c.elevation_gain_meters,
+                    'avg_heart_rate', c.avg_heart_rate,
+                    'max_heart_rate', c.max_heart_rate,
+                    'avg_cadence', c.avg_cadence
+                )
+                FROM entry_cardio c
+                WHERE c.entry_id = e.id
+            )
+        ) ORDER BY e.order_index, e.id)
+        FROM workout_entries e
+        LEFT JOIN entry_groups g ON g.id = e.group_id
+        WHERE e.workout_id = w.id
+    ), '[]'::jsonb)
+), w.created_at
+FROM workouts w
+WHERE NOT EXISTS (SELECT 1 FROM workout_revisions r WHERE r.workout_id = w.id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- The backfilled revisions can't be told apart from recorded ones, they're kept
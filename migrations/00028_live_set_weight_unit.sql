@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Sets keep the unit they were logged in, so changing the preferred unit mid-session
+-- doesn't reinterpret the weights already logged
+ALTER TABLE live_session_sets ADD COLUMN IF NOT EXISTS weight_unit VARCHAR(2) NOT NULL DEFAULT 'kg'
+    CONSTRAINT valid_live_set_weight_unit CHECK (weight_unit IN ('kg', 'lb'));
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE live_session_sets ls
+SET weight_unit = u.preferred_weight_unit
+FROM live_sessions s
+JOIN users u ON u.id = s.user_id
+WHERE s.id = ls.session_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE live_session_sets DROP COLUMN IF EXISTS weight_unit;
+-- +goose StatementEnd
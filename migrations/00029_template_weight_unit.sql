@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Target weights are kilograms from here on, weight_unit records what the targets were entered in
+ALTER TABLE template_entries ADD COLUMN IF NOT EXISTS weight_unit VARCHAR(2) NOT NULL DEFAULT 'kg'
+    CONSTRAINT valid_template_weight_unit CHECK (weight_unit IN ('kg', 'lb'));
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Targets saved so far were started in the owner's preferred unit, so that's what they were entered in
+UPDATE template_entries te
+SET weight_unit = u.preferred_weight_unit,
+    target_weight_min = ROUND(te.target_weight_min * 0.45359237, 3),
+    target_weight_max = ROUND(te.target_weight_max * 0.45359237, 3)
+FROM workout_templates t
+JOIN users u ON u.id = t.user_id
+WHERE t.id = te.template_id AND u.preferred_weight_unit = 'lb';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE template_entries
+SET target_weight_min = ROUND(target_weight_min / 0.45359237, 3),
+    target_weight_max = ROUND(target_weight_max / 0.45359237, 3)
+WHERE weight_unit = 'lb';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE template_entries DROP COLUMN IF EXISTS weight_unit;
+-- +goose StatementEnd